@@ -0,0 +1,25 @@
+// Команда highlightcss пишет стили подсветки кода для ui/static.
+// Запускается через go generate ./pkg/markdown.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+
+	"forum/pkg/markdown"
+)
+
+func main() {
+	out := flag.String("o", "ui/static/css/highlight.css", "CSS file to write")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := markdown.HighlightCSS(&buf); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
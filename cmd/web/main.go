@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"forum/internal/handler"
+	"forum/internal/mailer"
+	"forum/internal/oauth"
 	"forum/internal/repository"
 	"forum/internal/service"
 	"forum/pkg/config"
@@ -14,6 +16,7 @@ import (
 	// Go вызывает функцию init() внутри этого пакета.
 	_ "forum/internal/memory"
 	"forum/internal/session"
+	"forum/internal/sqlitestore"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -60,7 +63,10 @@ func main() {
 		os.Exit(1)
 	}
 
-	sessionManager, err := session.NewManager("memory", "gosessionid", 3600)
+	// Провайдеру sqlite нужна открытая база, поэтому регистрируем его здесь, а не в init()
+	sqlitestore.Register(db)
+
+	sessionManager, err := session.NewManager(conf.SessionProvider, "gosessionid", conf.SessionLifetime)
 	if err != nil {
 		logger.Error("Failed to create session manager", "error", err)
 		os.Exit(1)
@@ -68,12 +74,38 @@ func main() {
 
 	go sessionManager.GC()
 
+	mail, err := mailer.New(conf.MailTransport, mailer.SMTPConfig{
+		Host:     conf.SMTPHost,
+		Port:     conf.SMTPPort,
+		Username: conf.SMTPUsername,
+		Password: conf.SMTPPassword,
+		From:     conf.MailFrom,
+	}, conf.MailOutboxDir)
+	if err != nil {
+		logger.Error("Failed to create mailer", "error", err)
+		os.Exit(1)
+	}
+	// В режиме outbox письма никуда не уходят: пользователи не получат ссылки подтверждения и сброса пароля
+	if conf.MailTransport == "outbox" {
+		logger.Warn("MAIL_TRANSPORT is outbox: emails are written to disk and NOT delivered, do not use in production", "dir", conf.MailOutboxDir)
+	}
+
+	loginPolicy := service.LoginPolicy{
+		MaxAttempts:   conf.LoginMaxAttempts,
+		LockoutBase:   conf.LoginLockoutBase,
+		LockoutMax:    conf.LoginLockoutMax,
+		IPMaxAttempts: conf.LoginIPMaxAttempts,
+		IPWindow:      conf.LoginIPWindow,
+	}
+
 	app := &handler.Application{
 		Config:         conf,
 		Logger:         logger,
-		Service:        service.NewService(repository.NewRepository(db)),
+		Service:        service.NewService(repository.NewRepository(db), loginPolicy),
 		TemplateCache:  templateCache,
 		SessionManager: sessionManager,
+		Mailer:         mail,
+		OAuth:          oauth.NewRegistry(conf.OAuthProviders),
 	}
 
 	err = app.Serve(conf.Host + ":" + conf.Port)
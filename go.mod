@@ -6,9 +6,24 @@ require github.com/mattn/go-sqlite3 v1.14.22
 
 require golang.org/x/crypto v0.26.0
 
-require github.com/gofrs/uuid v4.4.0+incompatible
+require (
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/coreos/go-oidc/v3 v3.11.0
+	github.com/gofrs/uuid v4.4.0+incompatible
+	github.com/microcosm-cc/bluemonday v1.0.27
+	github.com/rivo/uniseg v0.4.7
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	github.com/yuin/goldmark v1.7.8
+	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
+	golang.org/x/oauth2 v0.24.0
+	golang.org/x/text v0.17.0
+)
 
 require (
 	cloud.google.com/go/compute/metadata v0.3.0 // indirect
-	golang.org/x/oauth2 v0.24.0 // indirect
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/dlclark/regexp2 v1.11.0 // indirect
+	github.com/go-jose/go-jose/v4 v4.0.2 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	golang.org/x/net v0.27.0 // indirect
 )
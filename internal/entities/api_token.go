@@ -0,0 +1,45 @@
+package entities
+
+// Права персональных токенов доступа
+const (
+	ScopeRead     = "read"     // GET-запросы
+	ScopeWrite    = "write"    // создание и изменение постов, комментариев, реакций
+	ScopeModerate = "moderate" // страницы и действия модераторов
+)
+
+var APITokenScopes = []string{ScopeRead, ScopeWrite, ScopeModerate}
+
+type APIToken struct {
+	ID       int
+	UserID   int
+	Name     string
+	Prefix   string // начало токена, чтобы пользователь мог его узнать в списке
+	Scopes   []string
+	Expiry   string // пусто, если срок не ограничен
+	LastUsed string
+	Created  string
+}
+
+// scopeImplies - права, которые включает право токена: без записи нельзя
+// ничего изменить, без чтения - открыть страницу, поэтому модерация включает
+// и то и другое
+var scopeImplies = map[string][]string{
+	ScopeWrite:    {ScopeRead},
+	ScopeModerate: {ScopeRead, ScopeWrite},
+}
+
+// HasScope сообщает, что у токена есть право scope, выданное явно или
+// включённое в другое право
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+		for _, implied := range scopeImplies[s] {
+			if implied == scope {
+				return true
+			}
+		}
+	}
+	return false
+}
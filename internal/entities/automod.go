@@ -0,0 +1,83 @@
+package entities
+
+// Условия правил автомодерации. Value правила задаёт параметр условия.
+const (
+	AutomodKeyword        = "keyword"            // в тексте есть одно из слов; слова через запятую
+	AutomodRegex          = "regex"              // текст совпадает с регулярным выражением
+	AutomodLinks          = "links"              // ссылок в тексте не меньше Value
+	AutomodAccountAge     = "account_age"        // аккаунту автора меньше Value часов
+	AutomodApprovedPosts  = "approved_posts"     // одобренных постов у автора меньше Value
+	AutomodTrustedAge     = "min_account_age"    // аккаунту автора не меньше Value часов
+	AutomodTrustedPosts   = "min_approved_posts" // одобренных постов у автора не меньше Value
+	AutomodReportsPerHour = "reports_per_hour"   // на автора за последний час пожаловались не меньше Value раз
+)
+
+var AutomodConditions = []string{
+	AutomodKeyword,
+	AutomodRegex,
+	AutomodLinks,
+	AutomodAccountAge,
+	AutomodApprovedPosts,
+	AutomodTrustedAge,
+	AutomodTrustedPosts,
+	AutomodReportsPerHour,
+}
+
+// Действия правил автомодерации
+const (
+	AutomodActionQueue   = "queue"   // пост ждёт одобрения модератора
+	AutomodActionHide    = "hide"    // скрыть до проверки модератором
+	AutomodActionApprove = "approve" // опубликовать пост без очереди
+	AutomodActionFlag    = "flag"    // жалоба от автомодерации в очередь
+)
+
+var AutomodActions = []string{
+	AutomodActionQueue,
+	AutomodActionHide,
+	AutomodActionApprove,
+	AutomodActionFlag,
+}
+
+// AutomodReporterID - автор жалоб, поданных автомодерацией
+const AutomodReporterID = 0
+
+// AutomodRule - правило автомодерации. Target - post, comment или пусто для
+// любого текста. Правило проверяется при создании и редактировании.
+type AutomodRule struct {
+	ID        int
+	Name      string
+	Target    string
+	Condition string
+	Value     string
+	Action    string
+	Enabled   bool
+	Created   string
+	Hits      int // сколько раз срабатывало
+}
+
+// Applies сообщает, проверяется ли правило для объекта типа targetType
+func (r *AutomodRule) Applies(targetType string) bool {
+	return r.Enabled && (r.Target == "" || r.Target == targetType)
+}
+
+// AutomodHit - запись о срабатывании правила. Название правила сохраняется:
+// правило могут изменить или удалить.
+type AutomodHit struct {
+	ID         int
+	RuleID     int
+	RuleName   string
+	TargetType string
+	TargetID   int
+	UserID     int
+	UserName   string
+	Action     string
+	Matched    string // что именно совпало
+	Created    string
+}
+
+// AuthorStats - сведения об авторе для условий автомодерации
+type AuthorStats struct {
+	AccountAgeHours int
+	ApprovedPosts   int
+	ReportsLastHour int
+}
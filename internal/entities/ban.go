@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"fmt"
+)
+
+// Ban - блокировка пользователя. Пустой Expires - бессрочная блокировка.
+type Ban struct {
+	ID           int
+	UserID       int
+	Username     string
+	BannedBy     int
+	BannedByName string
+	Reason       string
+	Created      string
+	Expires      string
+	Lifted       string
+	LiftedByName string
+	Shadow       bool // теневой бан: пользователь может войти, но его записи видны только ему
+	Active       bool
+}
+
+func (b *Ban) Permanent() bool {
+	return b.Expires == ""
+}
+
+// BanError возвращается, когда заблокированный пользователь пытается войти
+// или воспользоваться сессией
+type BanError struct {
+	Ban *Ban
+}
+
+func (e *BanError) Error() string {
+	if e.Ban.Permanent() {
+		return "user is banned permanently"
+	}
+	return fmt.Sprintf("user is suspended until %s", e.Ban.Expires)
+}
+
+func (e *BanError) Unwrap() error {
+	return ErrUserBanned
+}
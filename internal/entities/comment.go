@@ -1,5 +1,7 @@
 package entities
 
+import "html/template"
+
 type Comment struct {
 	ID           int
 	PostID       int
@@ -7,8 +9,11 @@ type Comment struct {
 	UserName     string
 	UserRole string
 	Content      string
+	ContentHTML  template.HTML // Content после markdown и фильтра разметки
 	UserReaction int
 	Like         int
 	Dislike      int
 	Created      string
+	Hidden       bool // скрыт автомодерацией, виден только автору
+	Edited       string // время последней правки, пусто, если не правили
 }
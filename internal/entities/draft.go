@@ -0,0 +1,34 @@
+package entities
+
+import "time"
+
+// Draft - черновик поста. Его видит только автор. Черновик с PublishAt
+// опубликует планировщик, после публикации черновик удаляется.
+type Draft struct {
+	ID          int
+	UserID      int
+	Title       string
+	Content     string
+	CategoryIDs []int
+	PublishAt   string // RFC3339; пусто, если публикация не запланирована
+	// PublishError - почему планировщик не смог опубликовать черновик
+	PublishError string
+	Created      string
+	Updated      string
+}
+
+func (d *Draft) IsScheduled() bool {
+	return d.PublishAt != ""
+}
+
+// Due сообщает, пора ли публиковать запланированный черновик
+func (d *Draft) Due(now time.Time) (bool, error) {
+	if !d.IsScheduled() {
+		return false, nil
+	}
+	at, err := time.Parse(time.RFC3339, d.PublishAt)
+	if err != nil {
+		return false, err
+	}
+	return !at.After(now), nil
+}
@@ -8,18 +8,44 @@ var (
 	ErrNoRecord = errors.New("no matching record found")
 
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account temporarily locked")
+	ErrTooManyAttempts    = errors.New("too many login attempts")
 	ErrInvalidData        = errors.New("invalid data")
 
 	ErrDuplicateEmail    = errors.New("duplicate email")
 	ErrDuplicateUsername = errors.New("duplicate username")
+	ErrSimilarUsername   = errors.New("username looks like an existing one")
+
+	ErrEmailAlreadyVerified = errors.New("email already verified")
 
 	ErrInvalidUser      = errors.New("invalid user")
 	ErrInvalidToken     = errors.New("invalid session token")
 	ErrInvalidCSRFToken = errors.New("invalid csrf token")
+	ErrExpiredToken     = errors.New("invalid or expired token")
+	ErrInvalidAPIToken  = errors.New("invalid or expired api token")
 
 	ErrUnsupportedFileType = errors.New("unsupported file type")
 	ErrFileSizeTooLarge    = errors.New("file size larger than max")
 
 	ErrFormAlreadySubmitted = errors.New("the form has already been submitted")
 
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrTwoFactorRequired    = errors.New("two-factor authentication is required for this role")
+
+	ErrIdentityInUse      = errors.New("external identity is linked to another user")
+	ErrIdentityNoSubject  = errors.New("external identity has no subject")
+	ErrIdentityEmailTaken = errors.New("an account with this email already exists")
+	ErrLastLoginMethod    = errors.New("cannot remove the last way to log in")
+
+	ErrForbidden     = errors.New("permission denied")
+	ErrDuplicateRole = errors.New("duplicate role")
+	ErrRoleInUse     = errors.New("role is assigned to users")
+	ErrBuiltinRole   = errors.New("builtin role cannot be changed this way")
+
+	ErrUserBanned = errors.New("user is banned")
+
+	ErrDuplicateReport = errors.New("report on this target is already open")
+	ErrReportClosed    = errors.New("reports on this target are already closed")
+	ErrClaimed         = errors.New("item is claimed by another staff member")
+
 )
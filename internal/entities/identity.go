@@ -0,0 +1,21 @@
+package entities
+
+// UserIdentity - внешний аккаунт, привязанный к пользователю форума
+type UserIdentity struct {
+	ID       int
+	UserID   int
+	Provider string
+	Subject  string
+	Email    string
+	Created  string
+}
+
+// ExternalIdentity - данные, пришедшие от провайдера при входе
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
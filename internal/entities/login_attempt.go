@@ -0,0 +1,39 @@
+package entities
+
+import (
+	"fmt"
+	"time"
+)
+
+type LoginAttempt struct {
+	ID      int
+	UserID  int
+	Email   string
+	IP      string
+	Success bool
+	Created string
+}
+
+// LoginIPStat - сколько неудачных входов было с одного IP и по скольким аккаунтам
+type LoginIPStat struct {
+	IP       string
+	Failures int
+	Emails   int
+	LastSeen string
+}
+
+// LockoutError возвращается, когда вход временно запрещён.
+// Notify выставляется только при самой блокировке, чтобы письмо ушло один раз.
+type LockoutError struct {
+	User   *User
+	Until  time.Time
+	Notify bool
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("account locked until %s", e.Until.Format(time.RFC3339))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrAccountLocked
+}
@@ -1,9 +1,39 @@
 package entities
 
 type ModeratorApplicant struct {
-	ID       int
-	Reason   string
-	Created  string
-	Username string
+	ID         int
+	Reason     string
+	Created    string
+	Username   string
+	Categories []*Category // категории, которые пользователь хочет модерировать
 }
 
+type Moderator struct {
+	ID         int
+	Username   string
+	Email      string
+	Role       string
+	Scoped     bool        // модерирует только Categories, иначе весь форум
+	Categories []*Category // у ограниченного модератора может быть пусто, если его категории удалили
+}
+
+func (m *Moderator) HasCategory(categoryID int) bool {
+	for _, c := range m.Categories {
+		if c.ID == categoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// PostScopedPermissions - права на чужие посты и комментарии. Если у
+// пользователя заданы категории модерации, они действуют только на посты
+// из этих категорий.
+var PostScopedPermissions = []string{
+	PermPostApprove,
+	PermPostEditAny,
+	PermPostDeleteAny,
+	PermCommentEditAny,
+	PermCommentDeleteAny,
+	PermRevisionRollback,
+}
@@ -0,0 +1,94 @@
+package entities
+
+// Типы объектов, над которыми действует администрация
+const (
+	TargetUser     = "user"
+	TargetPost     = "post"
+	TargetComment  = "comment"
+	TargetCategory = "category"
+)
+
+// Действия администрации, которые пишутся в журнал
+const (
+	ActionRoleChange          = "user.role"
+	ActionPasswordReset       = "user.password_reset"
+	ActionForceLogout         = "user.logout"
+	ActionBan                 = "user.ban"
+	ActionShadowBan           = "user.shadow_ban"
+	ActionLiftBan             = "user.unban"
+	ActionDelete              = "user.delete"
+	ActionPostApprove         = "post.approve"
+	ActionPostReject          = "post.reject"
+	ActionPostDelete          = "post.delete"
+	ActionPostRollback        = "post.rollback"
+	ActionCommentDelete       = "comment.delete"
+	ActionCommentRollback     = "comment.rollback"
+	ActionReportReview        = "report.review"
+	ActionReportAccept        = "report.accept"
+	ActionReportReject        = "report.reject"
+	ActionQueueAssign         = "queue.assign"
+	ActionCategoryCreate      = "category.create"
+	ActionCategoryDelete      = "category.delete"
+	ActionModeratorPromote    = "moderator.promote"
+	ActionModeratorReject     = "moderator.reject"
+	ActionModeratorRemove     = "moderator.remove"
+	ActionModeratorCategories = "moderator.categories"
+)
+
+// ModerationActions перечисляет действия для фильтра журнала
+var ModerationActions = []string{
+	ActionPostApprove,
+	ActionPostReject,
+	ActionPostDelete,
+	ActionPostRollback,
+	ActionCommentDelete,
+	ActionCommentRollback,
+	ActionReportReview,
+	ActionReportAccept,
+	ActionReportReject,
+	ActionQueueAssign,
+	ActionCategoryCreate,
+	ActionCategoryDelete,
+	ActionModeratorPromote,
+	ActionModeratorReject,
+	ActionModeratorRemove,
+	ActionModeratorCategories,
+	ActionRoleChange,
+	ActionPasswordReset,
+	ActionForceLogout,
+	ActionBan,
+	ActionShadowBan,
+	ActionLiftBan,
+	ActionDelete,
+}
+
+var TargetTypes = []string{TargetUser, TargetPost, TargetComment, TargetCategory}
+
+// ModerationAction - запись журнала действий администрации. Название объекта
+// сохраняется на момент действия: объект могут удалить. Before и After -
+// JSON-снимки объекта до и после действия, пусто, если снимка нет.
+type ModerationAction struct {
+	ID           int
+	ActorID      int
+	ActorName    string
+	Action       string
+	TargetType   string
+	TargetID     int
+	TargetUserID int // пользователь, которого касается действие: сам он или автор объекта
+	TargetName   string
+	Reason       string
+	Before       string
+	After        string
+	Created      string
+}
+
+// AuditFilter - условия выборки из журнала. Пустые поля не фильтруют.
+type AuditFilter struct {
+	Actor        string // имя сотрудника
+	Action       string
+	TargetType   string
+	TargetID     int
+	TargetUserID int
+	From         string // 2006-01-02
+	To           string // 2006-01-02, включительно
+}
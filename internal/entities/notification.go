@@ -1,5 +1,13 @@
 package entities
 
+// Уведомления автору о решении модератора по его посту
+const (
+	NotificationPostApproved = "approved"
+	NotificationPostRejected = "rejected"
+	// запланированный пост опубликован и ждёт модерации
+	NotificationPostPublished = "published"
+)
+
 type Notification struct {
 	ID              int
 	OwnerID         int
@@ -10,4 +18,5 @@ type Notification struct {
 	Created         string
 	TriggerUserID   int
 	TriggerUserName string
+	Reason          string // причина отклонения поста
 }
@@ -0,0 +1,87 @@
+package entities
+
+// Права, которые выдаются ролям. Проверяются только через авторизатор
+// (service.Authorizer), а не сравнением названий ролей.
+const (
+	PermPostApprove      = "post.approve"       // очередь модерации и одобрение постов
+	PermPostEditAny      = "post.edit.any"      // редактирование чужих постов
+	PermPostDeleteAny    = "post.delete.any"    // удаление чужих постов
+	PermCommentEditAny   = "comment.edit.any"   // редактирование чужих комментариев
+	PermCommentDeleteAny = "comment.delete.any" // удаление чужих комментариев
+	PermRevisionRollback = "revision.rollback"  // откат постов и комментариев к прежней версии
+	PermReportResolve    = "report.resolve"     // рассмотрение жалоб пользователей
+	PermCategoryManage   = "category.manage"
+	PermModeratorManage  = "moderator.manage" // заявки в модераторы и список модераторов
+	PermUserManage       = "user.manage"      // консоль пользователей, журнал входов, блокировки
+	PermSettingsManage   = "settings.manage"
+	PermRoleManage       = "role.manage"
+	PermAuditView        = "audit.view"     // журнал действий модерации и его выгрузка
+	PermQueueAssign      = "queue.assign"   // назначение элементов очереди модераторам
+	PermAutomodManage    = "automod.manage" // правила автомодерации и журнал их срабатываний
+)
+
+type Permission struct {
+	Name        string
+	Description string
+}
+
+// Permissions перечисляет все права в том порядке, в котором они показываются на странице ролей
+var Permissions = []Permission{
+	{PermPostApprove, "Approve posts from the moderation queue"},
+	{PermPostEditAny, "Edit any post"},
+	{PermPostDeleteAny, "Delete any post"},
+	{PermCommentEditAny, "Edit any comment"},
+	{PermCommentDeleteAny, "Delete any comment"},
+	{PermRevisionRollback, "Roll posts and comments back to an earlier revision"},
+	{PermReportResolve, "Review and resolve reports on posts, comments and users"},
+	{PermCategoryManage, "Create and delete categories"},
+	{PermModeratorManage, "Review moderator applications and remove moderators"},
+	{PermUserManage, "Manage users: reset passwords, sign out, suspend and delete accounts, view login activity"},
+	{PermSettingsManage, "Change site settings"},
+	{PermRoleManage, "Manage roles and assign them to users"},
+	{PermAuditView, "View and export the moderation audit log"},
+	{PermQueueAssign, "Assign moderation queue items to other staff members"},
+	{PermAutomodManage, "Manage auto-moderation rules and view their hits"},
+}
+
+// BuiltinRolePermissions - права встроенных ролей при первом запуске.
+// Администратор получает все права всегда, поэтому его здесь нет.
+var BuiltinRolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleModerator: {
+		PermPostApprove,
+		PermPostDeleteAny,
+		PermCommentDeleteAny,
+		PermRevisionRollback,
+	},
+}
+
+type Role struct {
+	Name        string
+	Description string
+	Builtin     bool // встроенные роли нельзя удалить
+	Permissions []string
+	Users       int // сколько пользователей с этой ролью
+	Created     string
+}
+
+func (r *Role) HasPermission(permission string) bool {
+	if r.Name == RoleAdmin {
+		return true
+	}
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func IsPermission(name string) bool {
+	for _, p := range Permissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
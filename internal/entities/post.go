@@ -1,11 +1,26 @@
 package entities
 
+import "html/template"
+
 type Post struct {
-	ID         int
-	Title      string
-	Content    string
+	ID      int
+	Title   string
+	Content string
+	// ContentHTML - Content после markdown и фильтра разметки
+	ContentHTML template.HTML
+	// Excerpt - начало Content без разметки для списков постов
+	Excerpt    string
 	UserID     int
 	UserName   string
 	Created    string
 	IsApproved bool
+	// Отклонённый пост видит только автор: он может исправить его и отправить снова
+	Rejected        string
+	RejectionReason string
+	// Edited - время последней правки, пусто, если пост не правили
+	Edited string
+}
+
+func (p *Post) IsRejected() bool {
+	return p.Rejected != ""
 }
@@ -0,0 +1,86 @@
+package entities
+
+import (
+	"strconv"
+	"time"
+)
+
+// Виды элементов очереди модерации
+const (
+	QueueApproval = "approval" // пост ждёт одобрения
+	QueueReport   = "report"   // на объект есть незакрытые жалобы
+)
+
+// Действия над выбранными элементами очереди
+const (
+	QueueActionClaim   = "claim"
+	QueueActionRelease = "release"
+	QueueActionAssign  = "assign"
+	QueueActionApprove = "approve" // одобрить пост или принять жалобу
+	QueueActionReject  = "reject"  // отклонить пост или жалобу
+	QueueActionDelete  = "delete"  // удалить пост или комментарий
+)
+
+var QueueActions = []string{
+	QueueActionClaim,
+	QueueActionRelease,
+	QueueActionAssign,
+	QueueActionApprove,
+	QueueActionReject,
+	QueueActionDelete,
+}
+
+// ClaimTimeout - через столько без действий взятый в работу элемент
+// возвращается в общую очередь. Назначенные элементы не освобождаются.
+const ClaimTimeout = 30 * time.Minute
+
+// Кто работает над элементом очереди, для фильтра
+const (
+	QueueAssigneeMe        = "me"
+	QueueAssigneeUnclaimed = "unclaimed"
+	QueueAssigneeClaimed   = "claimed"
+)
+
+// QueueItem - пост на одобрении или объект с жалобами. Объект определяется
+// типом и ID: на пост на одобрении жаловаться нельзя, поэтому пара уникальна.
+type QueueItem struct {
+	Kind           string
+	TargetType     string
+	TargetID       int
+	TargetName     string
+	TargetUserID   int
+	TargetUserName string
+	PostID         int
+	Reports        int
+	Reasons        string // причины жалоб через запятую
+	Queued         string // когда попал в очередь
+	Claim          *QueueClaim
+}
+
+// Key - значение для выбора элемента в форме, например post:12
+func (i *QueueItem) Key() string {
+	return QueueKey(i.TargetType, i.TargetID)
+}
+
+func QueueKey(targetType string, targetID int) string {
+	return targetType + ":" + strconv.Itoa(targetID)
+}
+
+// QueueClaim - элемент очереди взят в работу или назначен модератору
+type QueueClaim struct {
+	TargetType string
+	TargetID   int
+	UserID     int
+	UserName   string
+	AssignedBy int    // 0, если модератор взял элемент сам
+	Expires    string // пусто у назначенных
+}
+
+// QueueFilter - условия выборки очереди. Пустые поля не фильтруют.
+type QueueFilter struct {
+	Kind        string
+	CategoryID  int
+	MinAgeHours int
+	MinReports  int
+	Assignee    string
+}
@@ -1,10 +1,75 @@
 package entities
 
+// Статусы жалобы. Открытая жалоба ждёт модератора, взятая в работу -
+// рассматривается, решённая и отклонённая закрыты и остаются в истории.
+const (
+	ReportOpen      = "open"
+	ReportInReview  = "in_review"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+var ReportStatuses = []string{ReportOpen, ReportInReview, ReportResolved, ReportDismissed}
+
+// ReportActiveStatuses - жалобы, которые ещё не закрыты
+var ReportActiveStatuses = []string{ReportOpen, ReportInReview}
+
+// DefaultReportReasons - причины жалоб, пока администратор не задал свои
+var DefaultReportReasons = []string{
+	"Spam",
+	"Harassment or hate speech",
+	"Inappropriate content",
+	"Off-topic",
+	"Other",
+}
+
+// Report - жалоба пользователя на пост, комментарий или профиль
 type Report struct {
 	ID           int
 	UserID       int
 	ReporterName string
-	PostID       int
-	Reason       string
+	TargetType   string // TargetPost, TargetComment или TargetUser
+	TargetID     int
+	TargetUserID int // автор объекта или сам пользователь
+	PostID       int // пост, в котором находится объект; 0 для профиля
+	Category     string
+	Reason       string // пояснение жалующегося, может быть пустым
+	Status       string
 	Created      string
+	Updated      string
+}
+
+// ReportTarget - жалобы с одним статусом на один объект, собранные вместе
+type ReportTarget struct {
+	TargetType     string
+	TargetID       int
+	TargetName     string // заголовок поста, начало комментария или имя; пусто, если объект удалён
+	TargetUserID   int
+	TargetUserName string
+	PostID         int
+	Status         string
+	Reports        int
+	Categories     string // причины через запятую
+	FirstReported  string
+	LastReported   string
+}
+
+// ReportEvent - смена статуса жалобы
+type ReportEvent struct {
+	ID        int
+	ReportID  int
+	ActorID   int
+	ActorName string
+	Status    string
+	Note      string
+	Created   string
+}
+
+func IsReportStatus(status string) bool {
+	for _, s := range ReportStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,17 @@
+package entities
+
+// Revision - сохранённая версия поста или комментария. Версии нумеруются с 1:
+// первая - исходный текст, каждая правка и откат добавляют следующую.
+type Revision struct {
+	ID          int
+	TargetType  string // post, comment
+	TargetID    int
+	Number      int
+	EditorID    int
+	EditorName  string
+	Title       string // у комментариев пусто
+	Content     string
+	CategoryIDs []int // категории поста
+	Summary     string
+	Created     string
+}
@@ -0,0 +1,8 @@
+package entities
+
+// Ключи настроек сайта, которые администратор меняет на /administration/settings
+const (
+	SettingRequireStaffTwoFactor    = "require_staff_2fa"
+	SettingRequireEmailVerification = "require_email_verification"
+	SettingReportReasons            = "report_reasons" // причины жалоб, по одной в строке
+)
@@ -0,0 +1,8 @@
+package entities
+
+// Назначения одноразовых токенов, которые отправляются пользователю по почте
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change"
+)
@@ -0,0 +1,10 @@
+package entities
+
+type TwoFactor struct {
+	UserID            int
+	Secret            string
+	Enabled           bool
+	LastCounter       int64 // последний принятый шаг TOTP, защищает от повторного использования кода
+	RecoveryCodesLeft int
+	Created           string
+}
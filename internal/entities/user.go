@@ -7,6 +7,9 @@ type User struct {
 	HashedPassword []byte
 	Created        string
 	Role           string // "user", "moderator", "admin"
+	EmailVerified  bool
+	FailedLogins   int
+	LockedUntil    string // RFC3339, пусто если аккаунт не заблокирован
 }
 
 const (
@@ -14,3 +17,32 @@ const (
 	RoleModerator = "moderator"
 	RoleAdmin     = "admin"
 )
+
+// Фильтр активности в списке пользователей
+const (
+	ActivityActive   = "active"   // были активны за последние ActivityWindowDays дней
+	ActivityInactive = "inactive" // не были активны за это время
+)
+
+const ActivityWindowDays = 30
+
+// UserFilter - условия поиска на странице пользователей. Пустые поля не фильтруют.
+type UserFilter struct {
+	Query      string // часть имени или email
+	Role       string
+	JoinedFrom string // 2006-01-02
+	JoinedTo   string // 2006-01-02, включительно
+	Activity   string
+}
+
+// UserOverview - пользователь со счётчиками для страниц администрирования.
+// LastActive - последний вход, пост или комментарий; пусто, если активности не было.
+type UserOverview struct {
+	User
+	Posts           int
+	Comments        int
+	ReportsFiled    int // жалобы, поданные пользователем
+	ReportsReceived int // жалобы на пользователя, его посты и комментарии
+	LastActive      string
+	Banned          bool
+}
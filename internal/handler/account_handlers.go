@@ -63,6 +63,7 @@ func (app *Application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 	form.CurrentPassword = r.PostForm.Get("currentPassword")
 	form.NewPassword = r.PostForm.Get("newPassword")
 	form.NewPasswordConfirmation = r.PostForm.Get("newPasswordConfirmation")
+	form.SignOutOtherSessions = r.PostForm.Get("signOutOtherSessions") == "true"
 
 	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
 	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
@@ -88,7 +89,92 @@ func (app *Application) accountPasswordUpdate(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	sess.Set("flash", "Your password has been updated!")
+	if form.SignOutOtherSessions {
+		err = app.SessionManager.DestroyOtherUserSessions(r, userID)
+		if err != nil {
+			app.Logger.Error("destroy other sessions", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+		sess.Set(FlashSessionKey, "Your password has been updated! All other sessions were signed out.")
+	} else {
+		sess.Set(FlashSessionKey, "Your password has been updated!")
+	}
 
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
+
+func (app *Application) accountSessionsView(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountSessionsView")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	sessions, err := app.SessionManager.UserSessions(r, userID)
+	if err != nil {
+		app.Logger.Error("get user sessions", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Sessions = sessions
+
+	app.render(w, http.StatusOK, "sessions.html", data)
+}
+
+func (app *Application) accountSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountSessionRevoke")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	found, err := app.SessionManager.DestroyUserSession(userID, r.PostForm.Get("session_id"))
+	if err != nil {
+		app.Logger.Error("destroy user session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+	if !found {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	sess.Set(FlashSessionKey, "The session has been signed out.")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+func (app *Application) accountSessionRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountSessionRevokeOthers")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := app.SessionManager.DestroyOtherUserSessions(r, userID)
+	if err != nil {
+		app.Logger.Error("destroy other sessions", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	sess.Set(FlashSessionKey, "All other sessions have been signed out.")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
@@ -3,39 +3,42 @@ package handler
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"forum/internal/entities"
 	"forum/pkg/validator"
 )
 
 func (app *Application) administrationReportsView(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
-	if err != nil {
-		app.render(w, http.StatusBadRequest, Errorpage, nil)
-		return
-	}
-
 	page := 1
-	pageSize := 10
+	pageSize := 20
 
-	if p, err := validator.ValidateID(r.PostFormValue("page")); err == nil {
+	query := r.URL.Query()
+	if p, err := validator.ValidateID(query.Get("page")); err == nil {
 		page = p
 	}
+	// Незакрытые жалобы разбираются в очереди модерации, здесь остаётся история
+	status := query.Get("status")
+	if status == "" || status == entities.ReportOpen || status == entities.ReportInReview {
+		http.Redirect(w, r, "/moderation/queue?kind="+entities.QueueReport, http.StatusSeeOther)
+		return
+	}
 
-	paginationURL := "/administration/reports"
-	reportsDTO, err := app.Service.Reaction.GetAllPaginatedPostReportsDTO(page, pageSize, paginationURL)
+	reportsDTO, err := app.Service.Report.GetReportsDTO(status, page, pageSize, "/administration/reports")
 	if err != nil {
-		app.Logger.Error("get post reports", "error", err)
-		if errors.Is(err, entities.ErrNoRecord) {
+		if errors.Is(err, entities.ErrInvalidData) {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
 		} else {
+			app.Logger.Error("get reports", "error", err)
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
-			return
 		}
+		return
 	}
 
 	data := app.newTemplateData(r)
-	data.Reports = reportsDTO.Reports
-	data.Header = "All reports from moderators"
+	data.ReportTargets = reportsDTO.Targets
+	data.ReportStatus = reportsDTO.Status
+	data.ReportStatuses = []string{entities.ReportResolved, entities.ReportDismissed}
 	data.Pagination = pagination{
 		CurrentPage:      reportsDTO.CurrentPage,
 		HasNextPage:      reportsDTO.HasNextPage,
@@ -43,3 +46,103 @@ func (app *Application) administrationReportsView(w http.ResponseWriter, r *http
 	}
 	app.render(w, http.StatusOK, "reports.html", data)
 }
+
+func (app *Application) administrationReportView(w http.ResponseWriter, r *http.Request) {
+	targetID, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	target, err := app.Service.Report.GetReportTarget(r.PathValue("target"), targetID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("get report target", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ReportTarget = target
+	data.Form = app.Service.Report.NewReportStatusForm()
+	app.render(w, http.StatusOK, "report.html", data)
+}
+
+func (app *Application) administrationReportStatus(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	actorID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || actorID < 1 {
+		err := errors.New("get userID in administrationReportStatus")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	targetID, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+	targetType := r.PathValue("target")
+
+	err = r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Report.NewReportStatusForm()
+	form.Status = r.PostForm.Get("status")
+	form.Note = r.PostForm.Get("note")
+	form.Remove = r.PostForm.Get("remove") == "true"
+
+	err = app.Service.Report.SetReportStatus(actorID, targetType, targetID, &form)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidData):
+			target, err := app.Service.Report.GetReportTarget(targetType, targetID)
+			if err != nil {
+				app.Logger.Error("get report target", "error", err)
+				app.render(w, http.StatusInternalServerError, Errorpage, nil)
+				return
+			}
+			data := app.newTemplateData(r)
+			data.ReportTarget = target
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "report.html", data)
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		case errors.Is(err, entities.ErrReportClosed):
+			sess.Set(FlashSessionKey, "These reports have already been closed.")
+			http.Redirect(w, r, reportURL(targetType, targetID), http.StatusSeeOther)
+		case errors.Is(err, entities.ErrClaimed):
+			sess.Set(FlashSessionKey, "Another moderator is working on these reports.")
+			http.Redirect(w, r, reportURL(targetType, targetID), http.StatusSeeOther)
+		case errors.Is(err, entities.ErrForbidden):
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+		default:
+			app.Logger.Error("set report status", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	switch form.Status {
+	case entities.ReportInReview:
+		sess.Set(FlashSessionKey, "The reports are now in review.")
+		http.Redirect(w, r, reportURL(targetType, targetID), http.StatusSeeOther)
+	case entities.ReportResolved:
+		sess.Set(FlashSessionKey, "The reports have been resolved.")
+		http.Redirect(w, r, "/administration/reports", http.StatusSeeOther)
+	default:
+		sess.Set(FlashSessionKey, "The reports have been dismissed.")
+		http.Redirect(w, r, "/administration/reports", http.StatusSeeOther)
+	}
+}
+
+func reportURL(targetType string, targetID int) string {
+	return "/administration/reports/" + targetType + "/" + strconv.Itoa(targetID)
+}
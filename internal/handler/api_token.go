@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"forum/internal/entities"
+)
+
+// apiSession заменяет cookie-сессию для запросов с токеном доступа: значения
+// живут только до конца запроса, поэтому хэндлеры могут работать с сессией
+// как обычно, а в базе не копятся сессии скриптов.
+type apiSession struct {
+	values map[interface{}]interface{}
+}
+
+func newAPISession() *apiSession {
+	return &apiSession{values: make(map[interface{}]interface{})}
+}
+
+func (s *apiSession) Set(key, value interface{}) error {
+	s.values[key] = value
+	return nil
+}
+
+func (s *apiSession) Get(key interface{}) interface{} {
+	return s.values[key]
+}
+
+func (s *apiSession) GetAll() map[interface{}]interface{} {
+	return s.values
+}
+
+func (s *apiSession) Delete(key interface{}) error {
+	delete(s.values, key)
+	return nil
+}
+
+func (s *apiSession) SessionID() string {
+	return ""
+}
+
+// bearerToken возвращает токен из заголовка Authorization: Bearer
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+func (app *Application) apiTokenFromContext(r *http.Request) *entities.APIToken {
+	token, _ := r.Context().Value(apiTokenContextKey).(*entities.APIToken)
+	return token
+}
+
+// requiredScope - право, нужное токену для запроса: чтение для безопасных
+// методов, запись для остальных.
+func requiredScope(r *http.Request) string {
+	if csrfProtectedMethods[r.Method] {
+		return entities.ScopeWrite
+	}
+	return entities.ScopeRead
+}
+
+// moderationPermissions - права, которыми можно пользоваться по токену с
+// правом moderate. Остальное (администрирование) доступно только из браузера.
+var moderationPermissions = map[string]bool{
+	entities.PermPostApprove:      true,
+	entities.PermPostEditAny:      true,
+	entities.PermPostDeleteAny:    true,
+	entities.PermCommentEditAny:   true,
+	entities.PermCommentDeleteAny: true,
+	entities.PermRevisionRollback: true,
+	entities.PermReportResolve:    true,
+	entities.PermQueueAssign:      true,
+}
+
+func (app *Application) rejectAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.apiTokenFromContext(r) != nil {
+			app.Logger.Warn("api token used for account management", "url", r.URL.RequestURI())
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/internal/entities"
+)
+
+func (app *Application) accountAPITokensView(w http.ResponseWriter, r *http.Request) {
+	app.renderAPITokens(w, r, http.StatusOK, app.Service.APIToken.NewAPITokenForm(), "")
+}
+
+func (app *Application) accountAPITokenCreate(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountAPITokenCreate")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.APIToken.NewAPITokenForm()
+	form.Name = r.PostForm.Get("name")
+	form.Scopes = r.PostForm["scopes"]
+	form.ExpiresInDays = r.PostForm.Get("expiresInDays")
+
+	token, err := app.Service.APIToken.CreateAPIToken(userID, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			app.renderAPITokens(w, r, http.StatusUnprocessableEntity, form, "")
+		} else {
+			app.Logger.Error("create api token", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	// Токен показываем один раз, сразу после создания, без редиректа
+	app.renderAPITokens(w, r, http.StatusOK, app.Service.APIToken.NewAPITokenForm(), token)
+}
+
+func (app *Application) accountAPITokenRevoke(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountAPITokenRevoke")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	tokenID, err := strconv.Atoi(r.PostForm.Get("token_id"))
+	if err != nil || tokenID < 1 {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	err = app.Service.APIToken.RevokeAPIToken(userID, tokenID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("revoke api token", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, "The token has been revoked.")
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+func (app *Application) renderAPITokens(w http.ResponseWriter, r *http.Request, status int, form any, newToken string) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in renderAPITokens")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	tokens, err := app.Service.APIToken.ListAPITokens(userID)
+	if err != nil {
+		app.Logger.Error("list api tokens", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	data.NewAPIToken = newToken
+	data.Form = form
+	app.render(w, status, "api_tokens.html", data)
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"forum/internal/entities"
+	"forum/pkg/validator"
+)
+
+// auditRecord - запись журнала в выгрузке JSON
+type auditRecord struct {
+	ID           int             `json:"id"`
+	Created      string          `json:"created"`
+	ActorID      int             `json:"actor_id"`
+	Actor        string          `json:"actor"`
+	Action       string          `json:"action"`
+	TargetType   string          `json:"target_type"`
+	TargetID     int             `json:"target_id"`
+	TargetUserID int             `json:"target_user_id"`
+	TargetName   string          `json:"target_name"`
+	Reason       string          `json:"reason"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+}
+
+func (app *Application) administrationAuditView(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	pageSize := 50
+
+	query := r.URL.Query()
+	if p, err := validator.ValidateID(query.Get("page")); err == nil {
+		page = p
+	}
+
+	form := app.Service.Audit.NewAuditFilterForm()
+	if !parseAuditFilter(query, &form.AuditFilter) {
+		form.AddFieldError("target_id", "Enter a positive number")
+	}
+
+	data := app.newTemplateData(r)
+	data.ModerationActionNames = entities.ModerationActions
+	data.TargetTypes = entities.TargetTypes
+
+	auditDTO, err := app.Service.Audit.ListActions(&form, page, pageSize, "/administration/audit")
+	data.Form = form
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			app.render(w, http.StatusUnprocessableEntity, "audit.html", data)
+		} else {
+			app.Logger.Error("list moderation actions", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data.ModerationActions = auditDTO.Actions
+	data.Pagination = pagination{
+		CurrentPage:      auditDTO.CurrentPage,
+		HasNextPage:      auditDTO.HasNextPage,
+		PaginationAction: auditDTO.PaginationURL,
+	}
+	app.render(w, http.StatusOK, "audit.html", data)
+}
+
+// administrationAuditExport отдаёт записи журнала по тем же фильтрам, что и
+// страница журнала, файлом CSV или JSON
+func (app *Application) administrationAuditExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "csv" && format != "json" {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Audit.NewAuditFilterForm()
+	if !parseAuditFilter(query, &form.AuditFilter) {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	actions, err := app.Service.Audit.ExportActions(&form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+		} else {
+			app.Logger.Error("export moderation actions", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	actorID, _ := sess.Get(AuthUserIDSessionKey).(int)
+	app.Logger.Info("audit log exported", "by", actorID, "format", format, "records", len(actions))
+
+	filename := "moderation-audit-" + time.Now().UTC().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		records := make([]auditRecord, len(actions))
+		for i, a := range actions {
+			records[i] = auditRecord{
+				ID:           a.ID,
+				Created:      a.Created,
+				ActorID:      a.ActorID,
+				Actor:        a.ActorName,
+				Action:       a.Action,
+				TargetType:   a.TargetType,
+				TargetID:     a.TargetID,
+				TargetUserID: a.TargetUserID,
+				TargetName:   a.TargetName,
+				Reason:       a.Reason,
+				Before:       rawSnapshot(a.Before),
+				After:        rawSnapshot(a.After),
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			app.Logger.Error("write audit json", "error", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "created", "actor_id", "actor", "action", "target_type", "target_id",
+		"target_user_id", "target_name", "reason", "before", "after"})
+	for _, a := range actions {
+		cw.Write([]string{
+			strconv.Itoa(a.ID), a.Created, strconv.Itoa(a.ActorID), a.ActorName, a.Action, a.TargetType,
+			strconv.Itoa(a.TargetID), strconv.Itoa(a.TargetUserID), a.TargetName, a.Reason, a.Before, a.After,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		app.Logger.Error("write audit csv", "error", err)
+	}
+}
+
+// parseAuditFilter заполняет фильтр журнала из строки запроса.
+// Возвращает false, если target_id не число.
+func parseAuditFilter(query url.Values, filter *entities.AuditFilter) bool {
+	filter.Actor = validator.Normalize(query.Get("actor"))
+	filter.Action = query.Get("action")
+	filter.TargetType = query.Get("target_type")
+	filter.From = query.Get("from")
+	filter.To = query.Get("to")
+
+	if id := query.Get("target_id"); id != "" {
+		targetID, err := strconv.Atoi(id)
+		if err != nil {
+			return false
+		}
+		filter.TargetID = targetID
+	}
+	return true
+}
+
+// rawSnapshot вставляет снимок в JSON как есть, пустой снимок - как null
+func rawSnapshot(snapshot string) json.RawMessage {
+	if snapshot == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(snapshot)
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/internal/entities"
+	"forum/pkg/validator"
+)
+
+func (app *Application) administrationAutomodView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = app.Service.Automod.NewAutomodRuleForm()
+	app.renderAutomod(w, http.StatusOK, data)
+}
+
+func (app *Application) administrationAutomodCreate(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Automod.NewAutomodRuleForm()
+	form.Name = r.PostForm.Get("name")
+	form.Target = r.PostForm.Get("target")
+	form.Condition = r.PostForm.Get("condition")
+	form.Value = r.PostForm.Get("value")
+	form.Action = r.PostForm.Get("action")
+	form.Enabled = r.PostForm.Get("enabled") == "true"
+
+	err = app.Service.Automod.SaveRule(&form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.renderAutomod(w, http.StatusUnprocessableEntity, data)
+		} else {
+			app.Logger.Error("create automod rule", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "Rule \""+form.Name+"\" has been created.")
+	http.Redirect(w, r, "/administration/automod", http.StatusSeeOther)
+}
+
+func (app *Application) administrationAutomodRuleView(w http.ResponseWriter, r *http.Request) {
+	id, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	form, err := app.Service.Automod.GetRuleForm(id)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("get automod rule", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.AutomodConditions = entities.AutomodConditions
+	data.AutomodActions = entities.AutomodActions
+	app.render(w, http.StatusOK, "automod_rule.html", data)
+}
+
+func (app *Application) administrationAutomodRuleUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Automod.NewAutomodRuleForm()
+	form.ID = id
+	form.Name = r.PostForm.Get("name")
+	form.Target = r.PostForm.Get("target")
+	form.Condition = r.PostForm.Get("condition")
+	form.Value = r.PostForm.Get("value")
+	form.Action = r.PostForm.Get("action")
+	form.Enabled = r.PostForm.Get("enabled") == "true"
+
+	err = app.Service.Automod.SaveRule(&form)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidData):
+			data := app.newTemplateData(r)
+			data.Form = form
+			data.AutomodConditions = entities.AutomodConditions
+			data.AutomodActions = entities.AutomodActions
+			app.render(w, http.StatusUnprocessableEntity, "automod_rule.html", data)
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		default:
+			app.Logger.Error("update automod rule", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "Rule \""+form.Name+"\" has been saved.")
+	http.Redirect(w, r, "/administration/automod", http.StatusSeeOther)
+}
+
+func (app *Application) administrationAutomodRuleDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	err = app.Service.Automod.DeleteRule(id)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("delete automod rule", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "Rule #"+strconv.Itoa(id)+" has been deleted.")
+	http.Redirect(w, r, "/administration/automod", http.StatusSeeOther)
+}
+
+func (app *Application) administrationAutomodHits(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	pageSize := 50
+
+	query := r.URL.Query()
+	if p, err := validator.ValidateID(query.Get("page")); err == nil {
+		page = p
+	}
+	ruleID := 0
+	if rule := query.Get("rule"); rule != "" {
+		id, err := validator.ValidateID(rule)
+		if err != nil {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+			return
+		}
+		ruleID = id
+	}
+
+	hitsDTO, err := app.Service.Automod.GetHitsDTO(ruleID, page, pageSize, "/administration/automod/hits")
+	if err != nil {
+		app.Logger.Error("get automod hits", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.AutomodHits = hitsDTO.Hits
+	data.AutomodRuleID = hitsDTO.RuleID
+	data.Pagination = pagination{
+		CurrentPage:      hitsDTO.CurrentPage,
+		HasNextPage:      hitsDTO.HasNextPage,
+		PaginationAction: hitsDTO.PaginationURL,
+	}
+	app.render(w, http.StatusOK, "automod_hits.html", data)
+}
+
+func (app *Application) renderAutomod(w http.ResponseWriter, status int, data *templateData) {
+	rules, err := app.Service.Automod.GetRules()
+	if err != nil {
+		app.Logger.Error("list automod rules", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data.AutomodRules = rules
+	data.AutomodConditions = entities.AutomodConditions
+	data.AutomodActions = entities.AutomodActions
+	app.render(w, status, "automod.html", data)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/internal/entities"
+)
+
+func (app *Application) administrationBansView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = app.Service.Ban.NewBanForm()
+	app.renderBans(w, http.StatusOK, data)
+}
+
+func (app *Application) administrationBanUser(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	actorID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || actorID < 1 {
+		err := errors.New("get userID in administrationBanUser")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Ban.NewBanForm()
+	form.UserID, _ = strconv.Atoi(r.PostForm.Get("user_id"))
+	form.Reason = r.PostForm.Get("reason")
+	form.Days, _ = strconv.Atoi(r.PostForm.Get("days"))
+	form.Permanent = r.PostForm.Get("permanent") == "true"
+	form.Shadow = r.PostForm.Get("shadow") == "true"
+
+	err = app.Service.Ban.BanUser(actorID, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.renderBans(w, http.StatusUnprocessableEntity, data)
+		} else {
+			app.Logger.Error("ban user", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	app.Logger.Warn("user banned", "user_id", form.UserID, "by", actorID, "permanent", form.Permanent, "days", form.Days, "shadow", form.Shadow)
+
+	// Теневой бан пользователь заметить не должен, поэтому сессии оставляем
+	if form.Shadow {
+		sess.Set(FlashSessionKey, "User #"+strconv.Itoa(form.UserID)+" has been shadow-banned.")
+		http.Redirect(w, r, "/administration/bans", http.StatusSeeOther)
+		return
+	}
+
+	// Заблокированный пользователь выходит со всех устройств сразу
+	err = app.SessionManager.DestroyAllUserSessions(form.UserID)
+	if err != nil {
+		app.Logger.Error("destroy sessions of banned user", "user_id", form.UserID, "error", err)
+	}
+
+	sess.Set(FlashSessionKey, "User #"+strconv.Itoa(form.UserID)+" has been suspended.")
+	http.Redirect(w, r, "/administration/bans", http.StatusSeeOther)
+}
+
+func (app *Application) administrationLiftBan(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	actorID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || actorID < 1 {
+		err := errors.New("get userID in administrationLiftBan")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user_id"))
+	if err != nil || userID < 1 {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	err = app.Service.Ban.LiftBan(actorID, userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			sess.Set(FlashSessionKey, "This user is not suspended.")
+			http.Redirect(w, r, "/administration/bans", http.StatusSeeOther)
+		} else {
+			app.Logger.Error("lift ban", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, "The suspension has been lifted.")
+	http.Redirect(w, r, "/administration/bans", http.StatusSeeOther)
+}
+
+func (app *Application) renderBans(w http.ResponseWriter, status int, data *templateData) {
+	bans, err := app.Service.Ban.ListBans()
+	if err != nil {
+		app.Logger.Error("list bans", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data.Bans = bans
+	app.render(w, status, "bans.html", data)
+}
+
+// renderSuspended показывает заблокированному пользователю причину и срок блокировки
+func (app *Application) renderSuspended(w http.ResponseWriter, r *http.Request, banErr *entities.BanError) {
+	data := app.newTemplateData(r)
+	data.Ban = banErr.Ban
+	app.render(w, http.StatusForbidden, "suspended.html", data)
+}
+
+// renderBanError показывает страницу блокировки, если сервис отказал в
+// действии из-за неё. Блокировку проверяет и middleware, но пользователя могут
+// заблокировать, пока запрос уже выполняется.
+func (app *Application) renderBanError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var banErr *entities.BanError
+	if !errors.As(err, &banErr) {
+		return false
+	}
+	app.renderSuspended(w, r, banErr)
+	return true
+}
@@ -7,3 +7,5 @@ const isAuthenticatedContextKey = contextKey("isAuthenticated")
 const sessionContextKey = contextKey("session")
 
 const csrfTokenContextKey = contextKey("csrfToken")
+
+const apiTokenContextKey = contextKey("apiToken")
@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/internal/entities"
+	"forum/pkg/validator"
+)
+
+func (app *Application) draftsView(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		app.Logger.Error("get userid from session", "error", errors.New("get userID in draftsView"))
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	drafts, err := app.Service.Draft.GetUserDrafts(userID)
+	if err != nil {
+		app.Logger.Error("get user drafts", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Drafts = drafts
+	app.render(w, http.StatusOK, "drafts.html", data)
+}
+
+func (app *Application) draftEditView(w http.ResponseWriter, r *http.Request) {
+	draftID, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		app.Logger.Error("get userid from session", "error", errors.New("get userID in draftEditView"))
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	draft, err := app.Service.Draft.GetDraft(userID, draftID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("get draft", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	categories, err := app.Service.Category.GetAll()
+	if err != nil {
+		app.Logger.Error("get all categories", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Draft.NewDraftForm()
+	form.ID = draft.ID
+	form.Title, form.Content, form.Categories = draft.Title, draft.Content, draft.CategoryIDs
+
+	data := app.newTemplateData(r)
+	data.Categories = categories
+	data.Form = form
+	data.Draft = draft
+	app.render(w, http.StatusOK, "draft.html", data)
+}
+
+// draftSave сохраняет черновик со страницы нового поста или черновика.
+// Кнопка action выбирает, что сделать дальше: draft - просто сохранить,
+// schedule - запланировать публикацию, publish - опубликовать сразу.
+func (app *Application) draftSave(w http.ResponseWriter, r *http.Request) {
+	// Со страницы нового поста форма приходит как multipart, картинки в черновик не попадают
+	err := r.ParseMultipartForm(20*1024*1024 + (10 * 1024))
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		app.Logger.Error("Uploaded file is too big", "error", err)
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		app.Logger.Error("get userid from session", "error", errors.New("get userID in draftSave"))
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Draft.NewDraftForm()
+	if v := r.PostForm.Get("draft_id"); v != "" {
+		if form.ID, err = validator.ValidateID(v); err != nil {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+			return
+		}
+	}
+	for _, id := range r.PostForm["categories"] {
+		intID, err := validator.ValidateID(id)
+		if err != nil {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+			return
+		}
+		form.Categories = append(form.Categories, intID)
+	}
+	form.Title = r.PostForm.Get("title")
+	form.Content = r.PostForm.Get("content")
+
+	action := r.PostForm.Get("action")
+	if action == "schedule" {
+		form.PublishAt = r.PostForm.Get("publish_at")
+		if form.PublishAt == "" {
+			form.AddFieldError("publishAt", "Choose when to publish the post")
+		}
+		if v := r.PostForm.Get("timezone_offset"); v != "" {
+			if form.TimezoneOffset, err = strconv.Atoi(v); err != nil {
+				app.render(w, http.StatusBadRequest, Errorpage, nil)
+				return
+			}
+		}
+	}
+
+	draftID := form.ID
+	if form.Valid() {
+		draftID, err = app.Service.Draft.SaveDraft(userID, &form)
+	} else {
+		err = entities.ErrInvalidCredentials
+	}
+	if err == nil && action == "publish" {
+		form.ID = draftID
+		var postID int
+		postID, err = app.Service.Draft.PublishDraft(userID, draftID, &form)
+		if err == nil {
+			sess.Set(FlashSessionKey, "Your post will be published after passing moderation")
+			http.Redirect(w, r, fmt.Sprintf("/post/view/%d", postID), http.StatusSeeOther)
+			return
+		}
+	}
+	if err != nil {
+		if app.renderBanError(w, r, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, entities.ErrInvalidCredentials):
+			categories, err := app.Service.Category.GetAll()
+			if err != nil {
+				app.Logger.Error("get all categories", "error", err)
+				app.render(w, http.StatusInternalServerError, Errorpage, nil)
+				return
+			}
+			data := app.newTemplateData(r)
+			data.Categories = categories
+			data.Form = form
+			data.Draft = &entities.Draft{ID: form.ID}
+			app.render(w, http.StatusUnprocessableEntity, "draft.html", data)
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		default:
+			app.Logger.Error("save draft", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	if action == "schedule" {
+		sess.Set(FlashSessionKey, "Your post is scheduled for publishing.")
+	} else {
+		sess.Set(FlashSessionKey, "Draft saved.")
+	}
+	http.Redirect(w, r, fmt.Sprintf("/draft/edit/%d", draftID), http.StatusSeeOther)
+}
+
+// draftAutosave сохраняет черновик из браузера, пока автор пишет, и отвечает
+// JSON с id черновика: следующее автосохранение обновит тот же черновик
+func (app *Application) draftAutosave(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		app.Logger.Error("get userid from session", "error", errors.New("get userID in draftAutosave"))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	form := app.Service.Draft.NewDraftForm()
+	if v := r.PostForm.Get("draft_id"); v != "" {
+		if form.ID, err = validator.ValidateID(v); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, id := range r.PostForm["categories"] {
+		intID, err := validator.ValidateID(id)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		form.Categories = append(form.Categories, intID)
+	}
+	form.Title = r.PostForm.Get("title")
+	form.Content = r.PostForm.Get("content")
+
+	draftID, err := app.Service.Draft.AutosaveDraft(userID, &form)
+	if err != nil {
+		var banErr *entities.BanError
+		switch {
+		case errors.As(err, &banErr):
+			http.Error(w, "Your account is suspended", http.StatusForbidden)
+		case errors.Is(err, entities.ErrInvalidCredentials):
+			http.Error(w, "The draft is too long or contains unsupported characters", http.StatusUnprocessableEntity)
+		case errors.Is(err, entities.ErrNoRecord):
+			http.Error(w, "The draft has been published or deleted", http.StatusNotFound)
+		default:
+			app.Logger.Error("autosave draft", "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	err = json.NewEncoder(w).Encode(map[string]any{
+		"id":    draftID,
+		"saved": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		app.Logger.Error("encode autosave response", "error", err)
+	}
+}
+
+func (app *Application) draftDelete(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	draftID, err := validator.ValidateID(r.PostForm.Get("draft_id"))
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		app.Logger.Error("get userid from session", "error", errors.New("get userID in draftDelete"))
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err = app.Service.Draft.DeleteDraft(userID, draftID)
+	if err != nil {
+		if app.renderBanError(w, r, err) {
+			return
+		}
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("delete draft", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, "Draft deleted.")
+	http.Redirect(w, r, "/user/drafts", http.StatusSeeOther)
+}
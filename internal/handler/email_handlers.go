@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"forum/internal/entities"
+	"forum/internal/mailer"
+)
+
+// sendEmailVerification отправляет ссылку для подтверждения адреса пользователя
+func (app *Application) sendEmailVerification(userID int) error {
+	user, token, err := app.Service.User.CreateEmailVerification(userID)
+	if err != nil {
+		return err
+	}
+
+	link := app.absoluteURL("/user/verify-email", url.Values{"token": {token}})
+	app.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: "Hi " + user.Username + ",\r\n\r\n" +
+			"Please confirm your email address by opening the link below within 24 hours:\r\n\r\n" +
+			link + "\r\n\r\n" +
+			"Until you do, you won't be able to post, comment or react on the forum.",
+	})
+	return nil
+}
+
+func (app *Application) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+
+	_, err := app.Service.User.VerifyEmail(r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, entities.ErrExpiredToken) {
+			sess.Set(FlashSessionKey, "This confirmation link is invalid or has expired.")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			app.Logger.Error("verify email", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, "Thank you, your email address has been confirmed.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *Application) accountVerifyEmailResend(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountVerifyEmailResend")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := app.sendEmailVerification(userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrEmailAlreadyVerified) {
+			sess.Set(FlashSessionKey, "Your email address is already confirmed.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		} else {
+			app.Logger.Error("send email verification", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, "We've sent a new confirmation link to your email address.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *Application) accountEmailUpdateView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = app.Service.User.NewEmailUpdateForm()
+
+	app.render(w, http.StatusOK, "email.html", data)
+}
+
+func (app *Application) accountEmailUpdate(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountEmailUpdate")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.User.NewEmailUpdateForm()
+	form.NewEmail = r.PostForm.Get("newEmail")
+	form.CurrentPassword = r.PostForm.Get("currentPassword")
+
+	user, token, err := app.Service.User.RequestEmailChange(userID, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "email.html", data)
+		} else {
+			app.Logger.Error("request email change", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	link := app.absoluteURL("/account/email/confirm", url.Values{"token": {token}})
+	app.sendMail(mailer.Message{
+		To:      form.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: "Hi " + user.Username + ",\r\n\r\n" +
+			"Open the link below within 24 hours to use this address for your Forum account:\r\n\r\n" +
+			link,
+	})
+	app.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: "Hi " + user.Username + ",\r\n\r\n" +
+			"Someone asked to change the email address of your Forum account to " + form.NewEmail + ". " +
+			"The change will happen once the new address is confirmed.\r\n\r\n" +
+			"If it wasn't you, change your password right away.",
+	})
+
+	sess.Set(FlashSessionKey, "We've sent a confirmation link to "+form.NewEmail+". Your email will change once you open it.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *Application) accountEmailConfirm(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+
+	_, err := app.Service.User.ConfirmEmailChange(r.URL.Query().Get("token"))
+	if err != nil {
+		if errors.Is(err, entities.ErrExpiredToken) {
+			sess.Set(FlashSessionKey, "This confirmation link is invalid or has expired.")
+		} else if errors.Is(err, entities.ErrDuplicateEmail) {
+			sess.Set(FlashSessionKey, "This email address is already in use by another account.")
+		} else {
+			app.Logger.Error("confirm email change", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	sess.Set(FlashSessionKey, "Your email address has been changed.")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -13,6 +13,8 @@ import (
 	"syscall"
 	"time"
 
+	"forum/internal/mailer"
+	"forum/internal/oauth"
 	"forum/internal/service"
 	"forum/internal/session"
 	"forum/pkg/config"
@@ -25,6 +27,8 @@ type Application struct {
 	Logger         *slog.Logger
 	TemplateCache  map[string]*template.Template
 	SessionManager *session.Manager
+	Mailer         mailer.Mailer
+	OAuth          *oauth.Registry
 }
 
 func NewHandler(usecases *service.Service) *Application {
@@ -78,6 +82,10 @@ func (app *Application) Serve(addr string) error {
 	// by the graceful Shutdown() function.
 	shutdownError := make(chan error)
 
+	// Планировщик публикации черновиков останавливается вместе с сервером
+	stopScheduler := make(chan struct{})
+	go app.publishScheduled(app.Config.SchedulerInterval, stopScheduler)
+
 	go func() {
 		// Intercept the signals, as before.
 		quit := make(chan os.Signal, 1)
@@ -86,6 +94,7 @@ func (app *Application) Serve(addr string) error {
 
 		// Update the log entry to say "shutting down server" instead of "caught signal".
 		app.Logger.Info("shutting down server", "signal", s.String())
+		close(stopScheduler)
 
 		// Create a context with a 20-second timeout.
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
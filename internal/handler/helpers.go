@@ -2,9 +2,10 @@ package handler
 
 import (
 	"bytes"
-	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 
@@ -17,9 +18,28 @@ var RequestPaths = map[string]bool{
 	"/about":                   true,
 	"/account/view":            true,
 	"/account/password/update": true,
+	"/account/email/update":    true,
+	"/account/sessions":        true,
+	"/account/2fa":             true,
+	"/account/identities":      true,
+	"/account/tokens":          true,
+	"/administration/settings": true,
+	"/administration/logins":   true,
+	"/administration/roles":    true,
+	"/administration/bans":     true,
+	"/administration/users":    true,
+	"/administration/audit":    true,
+	"/administration/automod":  true,
+	"/administration/reports":  true,
+	"/moderation/queue":        true,
 	"/post/create":             true,
+	"/user/drafts":             true,
 	"/user/liked":              true,
 	"/user/login":              true,
+	"/user/login/2fa":          true,
+	"/user/oauth/username":     true,
+	"/user/password/forgot":    true,
+	"/user/password/reset":     true,
 	"/user/signup":             true,
 	"/user/logout":             true,
 }
@@ -27,8 +47,17 @@ var RequestPaths = map[string]bool{
 const (
 	Errorpage       = "errorpage.html"
 	DefaultCategory = 1
+	CSRFHeaderName  = "X-CSRF-Token"
+	csrfTokenLength = 32
 )
 
+var csrfProtectedMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
 func (app *Application) serverError(w http.ResponseWriter) {
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
@@ -92,12 +121,25 @@ func (app *Application) newTemplateData(r *http.Request) *templateData {
 			app.Logger.Error("Session error during delete flash", "error", err)
 		}
 	}
+
+	var permissions map[string]bool
+	if app.isAuthenticated(r) {
+		role, _ := sess.Get(UserRoleSessionKey).(string)
+		var err error
+		permissions, err = app.Service.Authorizer.RolePermissions(role)
+		if err != nil {
+			app.Logger.Error("get role permissions", "error", err)
+		}
+	}
+
 	return &templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           flash,
-		CSRFToken:       r.Context().Value(csrfTokenContextKey).(string),
+		CSRFToken:       app.maskCSRFToken(r.Context().Value(csrfTokenContextKey).(string)),
 		IsAuthenticated: app.isAuthenticated(r),
 		ReactionData:    &ReactionData{UserReaction: &entities.PostReaction{}},
+		OAuthProviders:  app.OAuth.List(),
+		UserPermissions: permissions,
 	}
 }
 
@@ -111,10 +153,61 @@ func (app *Application) isAuthenticated(r *http.Request) bool {
 }
 
 // Генерация CSRF-токена
-func (app *Application) generateCSRFToken() string {
-	h := md5.New()
-	salt := "YouShallNotPass%^7&8888" // Используйте уникальную соль для своего приложения
-	io.WriteString(h, salt+time.Now().String())
-	token := fmt.Sprintf("%x", h.Sum(nil))
-	return token
+func (app *Application) generateCSRFToken() (string, error) {
+	token := make([]byte, csrfTokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// maskCSRFToken XOR-ит токен со случайным одноразовым ключом, чтобы в каждом
+// ответе токен выглядел по-разному (защита от BREACH). Результат: ключ || токен^ключ.
+func (app *Application) maskCSRFToken(token string) string {
+	raw, ok := decodeCSRFToken(token)
+	if !ok {
+		return ""
+	}
+
+	masked := make([]byte, 2*csrfTokenLength)
+	pad := masked[:csrfTokenLength]
+	if _, err := rand.Read(pad); err != nil {
+		app.Logger.Error("mask csrf token", "error", err)
+		return ""
+	}
+	subtle.XORBytes(masked[csrfTokenLength:], raw, pad)
+
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func decodeCSRFToken(value interface{}) ([]byte, bool) {
+	token, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != csrfTokenLength {
+		return nil, false
+	}
+	return raw, true
+}
+
+// verifyCSRFToken принимает как маскированный токен из формы, так и исходный
+func verifyCSRFToken(sessionToken []byte, requestToken string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(requestToken)
+	if err != nil {
+		return false
+	}
+
+	switch len(raw) {
+	case 2 * csrfTokenLength:
+		unmasked := make([]byte, csrfTokenLength)
+		subtle.XORBytes(unmasked, raw[csrfTokenLength:], raw[:csrfTokenLength])
+		raw = unmasked
+	case csrfTokenLength:
+	default:
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(raw, sessionToken) == 1
 }
@@ -18,8 +18,11 @@ func (app *Application) home(w http.ResponseWriter, r *http.Request) {
 	page := 1      // Определяем текущую страницу. По умолчанию - страница 1.
 	pageSize := 10 // Количество постов на одной странице
 
+	sess := app.SessionFromContext(r)
+	userID, _ := sess.Get(AuthUserIDSessionKey).(int)
+
 	// Получаем посты для нужной страницы через юзкейс.
-	userPostsDTO, err := app.Service.Post.GetAllPaginatedPostsDTO(page, pageSize, "/")
+	userPostsDTO, err := app.Service.Post.GetAllPaginatedPostsDTO(userID, page, pageSize, "/")
 	if err != nil {
 		app.Logger.Error("get all paginated posts", "error", err)
 		app.render(w, http.StatusInternalServerError, Errorpage, nil)
@@ -76,7 +79,10 @@ func (app *Application) filterPosts(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = form
 
-	filteredPostsDTO, err := app.Service.Post.GetFilteredPaginatedPostsDTO(&form, page, pageSize, "/")
+	sess := app.SessionFromContext(r)
+	userID, _ := sess.Get(AuthUserIDSessionKey).(int)
+
+	filteredPostsDTO, err := app.Service.Post.GetFilteredPaginatedPostsDTO(userID, &form, page, pageSize, "/")
 	if filteredPostsDTO != nil {
 		data.Posts = filteredPostsDTO.Posts
 		data.Header = filteredPostsDTO.Header
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"forum/internal/entities"
+)
+
+func (app *Application) providerDisplayName(name string) string {
+	provider, err := app.OAuth.Get(name)
+	if err != nil {
+		return name
+	}
+	return provider.DisplayName
+}
+
+func (app *Application) identityEmailTaken(w http.ResponseWriter, r *http.Request, ext *entities.ExternalIdentity) {
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "An account with this email already exists. Log in with your password, then link your "+
+		app.providerDisplayName(ext.Provider)+" account on the account page.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// oauthSignUp регистрирует нового пользователя внешнего провайдера. Если имя
+// от провайдера занято или не подходит, просит выбрать другое.
+func (app *Application) oauthSignUp(w http.ResponseWriter, r *http.Request, ext *entities.ExternalIdentity, username string) {
+	sess := app.SessionFromContext(r)
+
+	form := app.Service.Identity.NewUsernameForm()
+	form.Username = username
+
+	user, err := app.Service.Identity.SignUp(ext, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			sess.Set(PendingIdentitySessionKey, *ext)
+			data := app.newTemplateData(r)
+			data.Header = app.providerDisplayName(ext.Provider)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "oauth_username.html", data)
+		} else if errors.Is(err, entities.ErrIdentityEmailTaken) {
+			app.identityEmailTaken(w, r, ext)
+		} else {
+			app.Logger.Error("sign up external identity", "provider", ext.Provider, "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	err = sess.Delete(PendingIdentitySessionKey)
+	if err != nil {
+		app.Logger.Error("Session error during delete pending identity", "error", err)
+	}
+
+	if !ext.EmailVerified {
+		err = app.sendEmailVerification(user.ID)
+		if err != nil {
+			app.Logger.Error("email verification", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+	}
+
+	app.startLogin(w, r, user)
+}
+
+func (app *Application) oauthUsernameView(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	ext, ok := sess.Get(PendingIdentitySessionKey).(entities.ExternalIdentity)
+	if !ok {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	form := app.Service.Identity.NewUsernameForm()
+	form.Username = ext.Username
+
+	data := app.newTemplateData(r)
+	data.Header = app.providerDisplayName(ext.Provider)
+	data.Form = form
+	app.render(w, http.StatusOK, "oauth_username.html", data)
+}
+
+func (app *Application) oauthUsername(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	ext, ok := sess.Get(PendingIdentitySessionKey).(entities.ExternalIdentity)
+	if !ok {
+		sess.Set(FlashSessionKey, "Your sign up has expired. Please try again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	app.oauthSignUp(w, r, &ext, r.PostForm.Get("username"))
+}
+
+func (app *Application) accountIdentitiesView(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountIdentitiesView")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	identities, err := app.Service.Identity.ListIdentities(userID)
+	if err != nil {
+		app.Logger.Error("list identities", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Identities = identities
+	app.render(w, http.StatusOK, "identities.html", data)
+}
+
+// accountIdentityLink запоминает, что после возврата от провайдера его аккаунт
+// нужно привязать к текущему пользователю, а не входить под ним.
+func (app *Application) accountIdentityLink(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountIdentityLink")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	provider, err := app.OAuth.Get(r.PostForm.Get("provider"))
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	sess.Set(OAuthLinkUserIDSessionKey, userID)
+	http.Redirect(w, r, "/auth/"+provider.Name+"/login", http.StatusSeeOther)
+}
+
+func (app *Application) linkIdentity(w http.ResponseWriter, r *http.Request, userID int, ext *entities.ExternalIdentity) {
+	sess := app.SessionFromContext(r)
+	displayName := app.providerDisplayName(ext.Provider)
+
+	err := app.Service.Identity.Link(userID, ext)
+	if err != nil {
+		if errors.Is(err, entities.ErrIdentityInUse) {
+			sess.Set(FlashSessionKey, "This "+displayName+" account can't be linked: it is already linked to another user, or you have linked a different "+displayName+" account.")
+		} else {
+			app.Logger.Error("link identity", "provider", ext.Provider, "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+	} else {
+		sess.Set(FlashSessionKey, "Your "+displayName+" account has been linked.")
+	}
+
+	http.Redirect(w, r, "/account/identities", http.StatusSeeOther)
+}
+
+func (app *Application) accountIdentityUnlink(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountIdentityUnlink")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	provider := r.PostForm.Get("provider")
+	err = app.Service.Identity.Unlink(userID, provider)
+	if err != nil {
+		if errors.Is(err, entities.ErrLastLoginMethod) {
+			sess.Set(FlashSessionKey, "This is your only way to log in. Set a password first, using \"Forgot your password?\" on the login page.")
+		} else if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+			return
+		} else {
+			app.Logger.Error("unlink identity", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+	} else {
+		sess.Set(FlashSessionKey, "Your "+app.providerDisplayName(provider)+" account has been unlinked.")
+	}
+
+	http.Redirect(w, r, "/account/identities", http.StatusSeeOther)
+}
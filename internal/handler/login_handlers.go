@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/internal/entities"
+	"forum/internal/mailer"
+)
+
+func (app *Application) sendAccountLockedEmail(lockErr *entities.LockoutError) {
+	app.sendMail(mailer.Message{
+		To:      lockErr.User.Email,
+		Subject: "Your Forum account has been locked",
+		Body: "Hi " + lockErr.User.Username + ",\r\n\r\n" +
+			"There were too many failed attempts to log in to your Forum account, so we've locked it until " +
+			lockErr.Until.UTC().Format("2006-01-02 15:04 MST") + ".\r\n\r\n" +
+			"If it wasn't you, someone may be trying to guess your password. " +
+			"You can set a new one here:\r\n\r\n" +
+			app.absoluteURL("/user/password/forgot", nil),
+	})
+}
+
+func (app *Application) administrationLoginsView(w http.ResponseWriter, r *http.Request) {
+	activity, err := app.Service.User.GetLoginActivity()
+	if err != nil {
+		app.Logger.Error("get login activity", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.LoginActivity = activity
+
+	app.render(w, http.StatusOK, "logins.html", data)
+}
+
+func (app *Application) administrationUnlockUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user_id"))
+	if err != nil || userID < 1 {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	err = app.Service.User.UnlockUser(userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("unlock user", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "The account has been unlocked.")
+	http.Redirect(w, r, "/administration/logins", http.StatusSeeOther)
+}
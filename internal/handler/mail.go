@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/url"
+
+	"forum/internal/mailer"
+)
+
+// sendMail отправляет письмо в фоне, чтобы медленный SMTP не задерживал ответ
+// и по времени ответа нельзя было понять, существует ли адрес.
+func (app *Application) sendMail(msg mailer.Message) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.Logger.Error("recover panic in sendMail", "error", err)
+			}
+		}()
+
+		err := app.Mailer.Send(msg)
+		if err != nil {
+			app.Logger.Error("send mail", "to", msg.To, "subject", msg.Subject, "error", err)
+		}
+	}()
+}
+
+// absoluteURL собирает ссылку для письма из BASE_URL
+func (app *Application) absoluteURL(path string, query url.Values) string {
+	u := app.Config.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"forum/internal/entities"
 	"forum/internal/session"
@@ -26,7 +27,11 @@ func New(middlewares ...Middleware) *Chain {
 
 // Append adds more middlewares to the chain
 func (c *Chain) Append(middlewares ...Middleware) *Chain {
-	return &Chain{middlewares: append(c.middlewares, middlewares...)}
+	// Копируем, чтобы цепочки, созданные от одной родительской, не делили
+	// общий массив и не затирали middleware друг друга
+	chain := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	chain = append(chain, c.middlewares...)
+	return &Chain{middlewares: append(chain, middlewares...)}
 }
 
 // Then applies all middleware to the given handler
@@ -90,35 +95,46 @@ func (app *Application) recoverPanic(next http.Handler) http.Handler {
 
 func (app *Application) verifyCSRF(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Проверяем (все) запросы которые могут изменить данные
-		if r.Method == http.MethodPost {
-			sess, err := app.SessionManager.SessionStart(w, r)
+		// Проверяем все запросы, которые могут изменить данные. Токен доступа
+		// браузер сам не подставит, поэтому такие запросы подделать нельзя.
+		if !csrfProtectedMethods[r.Method] || app.apiTokenFromContext(r) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sess := app.SessionFromContext(r)
+		sessionToken, ok := decodeCSRFToken(sess.Get(CsrfTokenSessionKey))
+		if !ok {
+			app.Logger.Warn("Invalid CSRF token", "error", entities.ErrInvalidCSRFToken, "reason", "no token in session")
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+			return
+		}
+
+		// Скрипты передают токен в заголовке, html-формы - в скрытом поле
+		requestToken := r.Header.Get(CSRFHeaderName)
+		if requestToken == "" {
+			requestToken = r.FormValue(CsrfTokenSessionKey)
+		}
+
+		if !verifyCSRFToken(sessionToken, requestToken) {
+			app.Logger.Warn("Invalid CSRF token", "error", entities.ErrInvalidCSRFToken, "method", r.Method, "url", r.URL.RequestURI())
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+			return
+		}
+
+		if app.Config.CSRFRotatePerForm {
+			token, err := app.generateCSRFToken()
 			if err != nil {
-				app.Logger.Error("verifyCSRF", "error", err)
+				app.Logger.Error("generate csrf token", "error", err)
 				app.render(w, http.StatusInternalServerError, Errorpage, nil)
 				return
 			}
-			sessionToken, ok := sess.Get(CsrfTokenSessionKey).(string)
-			if !ok || sessionToken == "" {
-				sessionToken = app.generateCSRFToken()
-				if err := sess.Set(CsrfTokenSessionKey, sessionToken); err != nil {
-					app.Logger.Error("get csrftoken", "error", err)
-					app.render(w, http.StatusInternalServerError, Errorpage, nil)
-					return
-				}
-			}
-			requestToken := r.FormValue(CsrfTokenSessionKey)
-
-			// Вставляем сессию в контекст запроса, чтобы другие хэндлеры могли её использовать
-			ctx := context.WithValue(r.Context(), sessionContextKey, sess)
-			r = r.WithContext(ctx)
-
-			app.Logger.Debug("tokens in verifyCSRF", "request", requestToken, "session", sessionToken)
-			if requestToken != sessionToken {
-				app.Logger.Error("Invalid CSRF token", "error", err)
-				app.render(w, http.StatusForbidden, Errorpage, nil)
+			if err := sess.Set(CsrfTokenSessionKey, token); err != nil {
+				app.Logger.Error("set csrf token", "error", err)
+				app.render(w, http.StatusInternalServerError, Errorpage, nil)
 				return
 			}
+			r = r.WithContext(context.WithValue(r.Context(), csrfTokenContextKey, token))
 		}
 
 		// Продолжить выполнение запроса
@@ -128,23 +144,28 @@ func (app *Application) verifyCSRF(next http.Handler) http.Handler {
 
 func (app *Application) sessionMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Извлекаем сессию из контекста
-		sess, ok := r.Context().Value(sessionContextKey).(session.Session)
-		if !ok {
-			var err error
+		var sess session.Session
+		var err error
+		if bearerToken(r) != "" {
+			sess = newAPISession()
+		} else {
 			sess, err = app.SessionManager.SessionStart(w, r)
 			if err != nil {
 				app.Logger.Error("SessionStart", "error", err)
 				app.render(w, http.StatusInternalServerError, Errorpage, nil)
 				return
 			}
-			app.Logger.Debug("session in sessionMiddleware", "session", sess)
 		}
 
 		// Если токен уже существует в сессии, не перезаписываем его
 		token, ok := sess.Get(CsrfTokenSessionKey).(string)
-		if !ok || token == "" {
-			token = app.generateCSRFToken()
+		if _, valid := decodeCSRFToken(token); !ok || !valid {
+			token, err = app.generateCSRFToken()
+			if err != nil {
+				app.Logger.Error("generate csrf token", "error", err)
+				app.render(w, http.StatusInternalServerError, Errorpage, nil)
+				return
+			}
 			if err := sess.Set(CsrfTokenSessionKey, token); err != nil {
 				app.Logger.Error("get csrftoken from session", "error", err)
 				app.render(w, http.StatusInternalServerError, Errorpage, nil)
@@ -165,25 +186,80 @@ func (app *Application) sessionMiddleware(next http.Handler) http.Handler {
 func (app *Application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sess := app.SessionFromContext(r)
+
+		if plaintext := bearerToken(r); plaintext != "" {
+			user, token, err := app.Service.APIToken.AuthenticateAPIToken(plaintext)
+			if err != nil {
+				var banErr *entities.BanError
+				if errors.As(err, &banErr) {
+					app.renderSuspended(w, r, banErr)
+				} else if errors.Is(err, entities.ErrInvalidAPIToken) {
+					app.Logger.Warn("invalid api token", "remote_addr", r.RemoteAddr)
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					app.render(w, http.StatusUnauthorized, Errorpage, nil)
+				} else {
+					app.Logger.Error("authenticate api token", "error", err)
+					app.render(w, http.StatusInternalServerError, Errorpage, nil)
+				}
+				return
+			}
+
+			scope := requiredScope(r)
+			if !token.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+				app.render(w, http.StatusForbidden, Errorpage, nil)
+				return
+			}
+
+			sess.Set(AuthUserIDSessionKey, user.ID)
+			sess.Set(UserRoleSessionKey, user.Role)
+
+			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, apiTokenContextKey, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		id, ok := sess.Get(AuthUserIDSessionKey).(int)
 		if !ok || id == 0 {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		exists, err := app.Service.User.UserExists(id)
+		user, err := app.Service.User.GetUserByID(id)
 		if err != nil {
-			app.Logger.Error("user exists", "error", err)
+			if errors.Is(err, entities.ErrNoRecord) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			app.Logger.Error("get authenticated user", "error", err)
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
 			return
 		}
 
-		if exists {
-			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
-			r = r.WithContext(ctx)
+		err = app.Service.Ban.CheckBan(user.ID)
+		if err != nil {
+			var banErr *entities.BanError
+			if errors.As(err, &banErr) {
+				// Сессии завершаются при блокировке, это на случай уцелевшей
+				app.SessionManager.SessionDestroy(w, r)
+				app.renderSuspended(w, r, banErr)
+				return
+			}
+			app.Logger.Error("check user ban", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
 		}
 
-		next.ServeHTTP(w, r)
+		// Роль могли поменять после входа - права должны действовать сразу
+		if role, _ := sess.Get(UserRoleSessionKey).(string); role != user.Role {
+			if err := sess.Set(UserRoleSessionKey, user.Role); err != nil {
+				app.Logger.Error("set UserRoleSessionKey", "error", err)
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -207,18 +283,22 @@ func (app *Application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
-func (app *Application) requireModeration(next http.Handler) http.Handler {
+// requireVerifiedEmail не даёт писать и реагировать, пока пользователь не
+// подтвердил адрес, если это требуется настройками сайта.
+func (app *Application) requireVerifiedEmail(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		sess := app.SessionFromContext(r)
-		userRole, ok := sess.Get(UserRoleSessionKey).(string)
-		if !ok {
-			app.Logger.Error("cannot extract user role from session in requireModeration")
+		userID, _ := sess.Get(AuthUserIDSessionKey).(int)
+
+		required, err := app.Service.User.EmailVerificationRequired(userID)
+		if err != nil {
+			app.Logger.Error("check email verification", "error", err)
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
 			return
 		}
-		if !(userRole == entities.RoleModerator || userRole == entities.RoleAdmin) {
-			app.Logger.Warn("user role not moderator or admin in requireModeration")
-			app.render(w, http.StatusForbidden, Errorpage, nil)
+		if required {
+			sess.Set(FlashSessionKey, "Please confirm your email address before posting or reacting. Check your inbox or request a new link below.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 			return
 		}
 
@@ -226,23 +306,75 @@ func (app *Application) requireModeration(next http.Handler) http.Handler {
 	})
 }
 
-func (app *Application) requireAdministration(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		sess := app.SessionFromContext(r)
-		userRole, ok := sess.Get(UserRoleSessionKey).(string)
-		if !ok {
-			app.Logger.Error("cannot extract user role from session in requireAdministration")
-			app.render(w, http.StatusInternalServerError, Errorpage, nil)
-			return
-		}
-		if !(userRole == entities.RoleAdmin) {
-			app.Logger.Warn("user role not moderator or admin in requireAdministration")
-			app.render(w, http.StatusForbidden, Errorpage, nil)
-			return
-		}
+// requirePermission пускает дальше, только если у роли пользователя есть хотя
+// бы одно из прав
+func (app *Application) requirePermission(permissions ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sess := app.SessionFromContext(r)
+			userRole, ok := sess.Get(UserRoleSessionKey).(string)
+			if !ok {
+				app.Logger.Error("cannot extract user role from session in requirePermission")
+				app.render(w, http.StatusInternalServerError, Errorpage, nil)
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			// granted - первое из прав, которое есть у роли
+			granted := ""
+			for _, permission := range permissions {
+				allowed, err := app.Service.Authorizer.Can(userRole, permission)
+				if err != nil {
+					app.Logger.Error("check permission", "permission", permission, "error", err)
+					app.render(w, http.StatusInternalServerError, Errorpage, nil)
+					return
+				}
+				if allowed {
+					granted = permission
+					break
+				}
+			}
+			if granted == "" {
+				app.Logger.Warn("permission denied", "role", userRole, "permissions", permissions, "url", r.URL.RequestURI())
+				app.render(w, http.StatusForbidden, Errorpage, nil)
+				return
+			}
+
+			if token := app.apiTokenFromContext(r); token != nil {
+				if !moderationPermissions[granted] {
+					app.render(w, http.StatusForbidden, Errorpage, nil)
+					return
+				}
+				if !token.HasScope(entities.ScopeModerate) {
+					w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+entities.ScopeModerate+`"`)
+					app.render(w, http.StatusForbidden, Errorpage, nil)
+					return
+				}
+			}
+			if app.redirectToTwoFactorSetup(w, r, userRole) {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// redirectToTwoFactorSetup не пускает модераторов и администраторов к их
+// страницам, пока они не включили 2FA, если этого требуют настройки сайта.
+func (app *Application) redirectToTwoFactorSetup(w http.ResponseWriter, r *http.Request, userRole string) bool {
+	userID, _ := app.SessionFromContext(r).Get(AuthUserIDSessionKey).(int)
+	required, err := app.Service.TwoFactor.SetupRequired(userID, userRole)
+	if err != nil {
+		app.Logger.Error("check two factor requirement", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return true
+	}
+	if required {
+		app.SessionFromContext(r).Set(FlashSessionKey, "Your role requires two-factor authentication. Please set it up to continue.")
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		return true
+	}
+	return false
 }
 
 type rateLimiter struct {
@@ -272,7 +404,7 @@ func (app *Application) rateLimiting(next http.Handler) http.Handler {
 	rl := NewRateLimiter(120, 1*time.Minute) // 60 запросов в минуту
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := r.RemoteAddr
+		ip := session.ClientIP(r)
 		v, _ := rl.visitors.LoadOrStore(ip, &visitor{lastSeen: time.Now(), count: 0})
 
 		vis := v.(*visitor)
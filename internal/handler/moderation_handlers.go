@@ -9,37 +9,9 @@ import (
 	"forum/pkg/validator"
 )
 
+// Посты на одобрении теперь разбираются в общей очереди модерации
 func (app *Application) moderationUnapprovedPostsView(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
-	if err != nil {
-		app.render(w, http.StatusBadRequest, Errorpage, nil)
-		return
-	}
-
-	page := 1
-	pageSize := 10
-
-	if p, err := validator.ValidateID(r.PostFormValue("page")); err == nil {
-		page = p
-	}
-
-	paginationURL := "/moderation/posts/unapproved"
-	unapprovedPostsDTO, err := app.Service.Post.GetAllPaginatedUnapprovedPostsDTO(page, pageSize, paginationURL)
-	if err != nil {
-		app.Logger.Error("get unapproved posts", "error", err)
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-	}
-
-	data := app.newTemplateData(r)
-	data.Posts = unapprovedPostsDTO.Posts
-	data.Header = "Unapproved posts"
-	data.Pagination = pagination{
-		CurrentPage:      unapprovedPostsDTO.CurrentPage,
-		HasNextPage:      unapprovedPostsDTO.HasNextPage,
-		PaginationAction: unapprovedPostsDTO.PaginationURL,
-	}
-	app.render(w, http.StatusOK, "user_posts.html", data)
+	http.Redirect(w, r, "/moderation/queue?kind="+entities.QueueApproval, http.StatusSeeOther)
 }
 
 func (app *Application) moderationApprovePost(w http.ResponseWriter, r *http.Request) {
@@ -64,11 +36,19 @@ func (app *Application) moderationApprovePost(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	err = app.Service.Post.ApprovePost(postID)
+	err = app.Service.Post.ApprovePost(postID, userID)
 	if err != nil {
 		app.Logger.Error("post approval", "error", err)
+		if app.renderBanError(w, r, err) {
+			return
+		}
 		if errors.Is(err, entities.ErrNoRecord) {
 			app.render(w, http.StatusBadRequest, Errorpage, nil)
+		} else if errors.Is(err, entities.ErrForbidden) {
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+		} else if errors.Is(err, entities.ErrClaimed) {
+			app.render(w, http.StatusConflict, Errorpage,
+				&templateData{AppError: AppError{Message: "Another moderator is working on this post", StatusCode: http.StatusConflict}})
 		} else {
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		}
@@ -81,14 +61,14 @@ func (app *Application) moderationApprovePost(w http.ResponseWriter, r *http.Req
 		app.Logger.Error("Session error during set flash", "error", err)
 	}
 
-	http.Redirect(w, r, "/moderation/posts/unapproved", http.StatusSeeOther)
+	http.Redirect(w, r, "/moderation/queue?kind="+entities.QueueApproval, http.StatusSeeOther)
 }
 
-func (app *Application) moderationReportPost(w http.ResponseWriter, r *http.Request) {
+func (app *Application) moderationRejectPost(w http.ResponseWriter, r *http.Request) {
 	sess := app.SessionFromContext(r)
 	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
 	if !ok || userID < 1 {
-		err := errors.New("get userID in accountPasswordUpdate")
+		err := errors.New("get userID in moderationRejectPost")
 		app.Logger.Error("get userid from session", "error", err)
 		app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		return
@@ -106,25 +86,102 @@ func (app *Application) moderationReportPost(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	reason := r.PostForm.Get("report_reason")
-	if !validator.Matches(reason, validator.TextRX) {
-		app.render(w, http.StatusBadRequest, Errorpage,
-			&templateData{AppError: AppError{Message: "Report reason must contain only english or russian letters", StatusCode: http.StatusBadRequest}})
+	form := app.Service.Post.NewRejectPostForm()
+	form.Reason = r.PostForm.Get("reason")
+
+	err = app.Service.Post.RejectPost(postID, userID, &form)
+	if err != nil {
+		if app.renderBanError(w, r, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, entities.ErrInvalidData):
+			message := "Only posts waiting for approval can be rejected"
+			if msg, ok := form.FieldErrors["reason"]; ok {
+				message = msg
+			}
+			app.render(w, http.StatusUnprocessableEntity, Errorpage,
+				&templateData{AppError: AppError{Message: message, StatusCode: http.StatusUnprocessableEntity}})
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+		case errors.Is(err, entities.ErrForbidden):
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+		case errors.Is(err, entities.ErrClaimed):
+			app.render(w, http.StatusConflict, Errorpage,
+				&templateData{AppError: AppError{Message: "Another moderator is working on this post", StatusCode: http.StatusConflict}})
+		default:
+			app.Logger.Error("post rejection", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
 		return
 	}
 
-	err = app.Service.Reaction.CreateReport(userID, postID, reason)
+	sess.Set(FlashSessionKey, "The post has been rejected. The author will see your reason.")
+	http.Redirect(w, r, "/moderation/queue?kind="+entities.QueueApproval, http.StatusSeeOther)
+}
+
+func (app *Application) createReport(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in createReport")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
 	if err != nil {
-		app.Logger.Error("create report", "error", err)
-		if errors.Is(err, entities.ErrNoRecord) {
-			app.render(w, http.StatusBadRequest, Errorpage, nil)
-		} else {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	targetID, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Report.NewReportForm()
+	form.TargetType = r.PathValue("target")
+	form.TargetID = targetID
+	form.Category = r.PostForm.Get("category")
+	form.Reason = r.PostForm.Get("reason")
+
+	err = app.Service.Report.CreateReport(userID, &form)
+	if err != nil {
+		if app.renderBanError(w, r, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, entities.ErrInvalidData):
+			app.render(w, http.StatusBadRequest, Errorpage,
+				&templateData{AppError: AppError{Message: "Choose a reason from the list and keep the details under 500 characters", StatusCode: http.StatusBadRequest}})
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		case errors.Is(err, entities.ErrForbidden):
+			app.render(w, http.StatusForbidden, Errorpage,
+				&templateData{AppError: AppError{Message: "You cannot report your own posts, comments or profile", StatusCode: http.StatusForbidden}})
+		case errors.Is(err, entities.ErrDuplicateReport):
+			sess.Set(FlashSessionKey, "You have already reported this. Moderators will look into it.")
+			http.Redirect(w, r, reportBackURL(r), http.StatusSeeOther)
+		default:
+			app.Logger.Error("create report", "error", err)
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		}
 		return
 	}
 
-	http.Redirect(w, r, r.Referer(), http.StatusSeeOther)
+	sess.Set(FlashSessionKey, "Thank you. Your report has been sent to the moderators.")
+	http.Redirect(w, r, reportBackURL(r), http.StatusSeeOther)
+}
+
+// reportBackURL - страница, с которой отправили жалобу
+func reportBackURL(r *http.Request) string {
+	if referer := r.Referer(); referer != "" {
+		return referer
+	}
+	return "/"
 }
 
 func (app *Application) moderationApplicationView(w http.ResponseWriter, r *http.Request) {
@@ -141,9 +198,17 @@ func (app *Application) moderationApplicationView(w http.ResponseWriter, r *http
 	if !ok {
 		app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		return
-
 	}
+	categories, err := app.Service.Category.GetAll()
+	if err != nil {
+		app.Logger.Error("get all categories", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
 	data := app.newTemplateData(r)
+	data.Categories = categories
+	data.Form = app.Service.User.NewModerationForm()
 
 	if userRole == entities.RoleUser {
 		app.render(w, http.StatusOK, "moderation_application.html", data)
@@ -169,17 +234,35 @@ func (app *Application) createModerationApplication(w http.ResponseWriter, r *ht
 	if !ok {
 		app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		return
+	}
 
+	var categoryIDs []int
+	for _, id := range r.PostForm["categories"] {
+		intID, err := validator.ValidateID(id)
+		if err != nil {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+			return
+		}
+		categoryIDs = append(categoryIDs, intID)
 	}
 
 	form := app.Service.User.NewModerationForm()
 	form.Reason = r.PostForm.Get("reason")
+	form.Categories = categoryIDs
 
 	if userRole == entities.RoleUser {
 		err := app.Service.User.CreateModerationRequest(userId, &form)
 		if err != nil {
 			if errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrFormAlreadySubmitted) {
+				categories, err := app.Service.Category.GetAll()
+				if err != nil {
+					app.Logger.Error("get all categories", "error", err)
+					app.render(w, http.StatusInternalServerError, Errorpage, nil)
+					return
+				}
+
 				data := app.newTemplateData(r)
+				data.Categories = categories
 				data.Form = form
 				app.render(w, http.StatusUnprocessableEntity, "moderation_application.html", data)
 			} else {
@@ -204,12 +287,6 @@ func (app *Application) moderatorsView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
 	moderators, err := app.Service.User.GetModerators()
 	if err != nil {
 		if errors.Is(err, entities.ErrNoRecord) {
@@ -220,15 +297,21 @@ func (app *Application) moderatorsView(w http.ResponseWriter, r *http.Request) {
 
 		}
 	}
+	categories, err := app.Service.Category.GetAll()
+	if err != nil {
+		app.Logger.Error("get all categories", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
 	data := app.newTemplateData(r)
-	data.Users = moderators
+	data.Moderators = moderators
+	data.Categories = categories
 
-	if userRole == entities.RoleAdmin {
-		app.render(w, http.StatusOK, "moderatorslist.html", data)
-	}
+	app.render(w, http.StatusOK, "moderatorslist.html", data)
 }
 
-func (app *Application) deleteModerator(w http.ResponseWriter, r *http.Request) {
+func (app *Application) setModeratorCategories(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
@@ -237,73 +320,48 @@ func (app *Application) deleteModerator(w http.ResponseWriter, r *http.Request)
 	sess := app.SessionFromContext(r)
 	userId, ok := sess.Get(AuthUserIDSessionKey).(int)
 	if !ok || userId < 1 {
-		err := errors.New("get userID in editPostView")
+		err := errors.New("get userID in setModeratorCategories")
 		app.Logger.Error("get userid from session", "error", err)
 		app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-
 	moderatorId, err := validator.ValidateID(r.PostForm.Get("id"))
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
 		return
 	}
 
-	if userRole == entities.RoleAdmin {
-		err := app.Service.User.DeleteModerator(moderatorId)
+	var categoryIDs []int
+	for _, id := range r.PostForm["categories"] {
+		intID, err := validator.ValidateID(id)
 		if err != nil {
-			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
 			return
-
 		}
-
+		categoryIDs = append(categoryIDs, intID)
 	}
 
-	http.Redirect(w, r, "/moderators/list", http.StatusSeeOther)
-}
-
-func (app *Application) moderationApplicantsView(w http.ResponseWriter, r *http.Request) {
-	sess := app.SessionFromContext(r)
-	userId, ok := sess.Get(AuthUserIDSessionKey).(int)
-	if !ok || userId < 1 {
-		err := errors.New("get userID in editPostView")
-		app.Logger.Error("get userid from session", "error", err)
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-	}
-
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-	applicants, err := app.Service.User.GetModerationApplicants()
+	err = app.Service.User.SetModeratorCategories(userId, moderatorId, categoryIDs)
 	if err != nil {
-		if errors.Is(err, entities.ErrNoRecord) {
+		app.Logger.Error("set moderator categories", "error", err)
+		if errors.Is(err, entities.ErrNoRecord) || errors.Is(err, entities.ErrInvalidData) {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
 		} else {
-
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
-			return
-
 		}
+		return
 	}
-	data := app.newTemplateData(r)
-	data.Applicants = applicants
 
-	if userRole == entities.RoleAdmin {
-		app.render(w, http.StatusOK, "moderation-applicants.html", data)
+	err = sess.Set(FlashSessionKey, "Moderator categories updated.")
+	if err != nil {
+		app.Logger.Error("Session error during set flash", "error", err)
 	}
+
+	http.Redirect(w, r, "/moderators/list", http.StatusSeeOther)
 }
 
-func (app *Application) requestModeratorRole(w http.ResponseWriter, r *http.Request) {
+func (app *Application) deleteModerator(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
@@ -318,42 +376,22 @@ func (app *Application) requestModeratorRole(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-
-	applicantId, err := validator.ValidateID(r.PostForm.Get("id"))
+	moderatorId, err := validator.ValidateID(r.PostForm.Get("id"))
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
 		return
 	}
 
-	if userRole == entities.RoleAdmin {
-		err := app.Service.User.ApproveModerationRequest(applicantId)
-		if err != nil {
-			app.render(w, http.StatusInternalServerError, Errorpage, nil)
-			return
-		}
-		err = app.Service.User.DeleteModerationRequest(applicantId)
-		if err != nil {
-			app.render(w, http.StatusInternalServerError, Errorpage, nil)
-			return
-		}
-
+	err = app.Service.User.DeleteModerator(userId, moderatorId)
+	if err != nil {
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
 	}
 
-	http.Redirect(w, r, "/moderation-applicants", http.StatusSeeOther)
+	http.Redirect(w, r, "/moderators/list", http.StatusSeeOther)
 }
 
-func (app *Application) rejectModeratorRequest(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
-	if err != nil {
-		app.render(w, http.StatusBadRequest, Errorpage, nil)
-		return
-	}
+func (app *Application) moderationApplicantsView(w http.ResponseWriter, r *http.Request) {
 	sess := app.SessionFromContext(r)
 	userId, ok := sess.Get(AuthUserIDSessionKey).(int)
 	if !ok || userId < 1 {
@@ -363,32 +401,23 @@ func (app *Application) rejectModeratorRequest(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-
-	applicantId, err := validator.ValidateID(r.PostForm.Get("id"))
+	applicants, err := app.Service.User.GetModerationApplicants()
 	if err != nil {
-		app.render(w, http.StatusBadRequest, Errorpage, nil)
-		return
-	}
+		if errors.Is(err, entities.ErrNoRecord) {
+		} else {
 
-	if userRole == entities.RoleAdmin {
-		err := app.Service.User.DeleteModerationRequest(applicantId)
-		if err != nil {
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
 			return
-		}
 
+		}
 	}
+	data := app.newTemplateData(r)
+	data.Applicants = applicants
 
-	http.Redirect(w, r, "/moderation-applicants", http.StatusSeeOther)
+	app.render(w, http.StatusOK, "moderation-applicants.html", data)
 }
 
-func (app *Application) acceptReport(w http.ResponseWriter, r *http.Request) {
+func (app *Application) requestModeratorRole(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
@@ -403,32 +432,27 @@ func (app *Application) acceptReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+	applicantId, err := validator.ValidateID(r.PostForm.Get("id"))
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
 		return
-
 	}
 
-	postId, err := validator.ValidateID(r.PostForm.Get("postId"))
+	err = app.Service.User.ApproveModerationRequest(userId, applicantId)
 	if err != nil {
-		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		return
 	}
-
-	if userRole == entities.RoleAdmin {
-		err := app.Service.Post.DeletePost(postId, userId)
-		if err != nil {
-			app.render(w, http.StatusInternalServerError, Errorpage, nil)
-			return
-		}
-
+	err = app.Service.User.DeleteModerationRequest(applicantId)
+	if err != nil {
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
 	}
 
-	http.Redirect(w, r, "/administration/reports", http.StatusSeeOther)
+	http.Redirect(w, r, "/moderation-applicants", http.StatusSeeOther)
 }
 
-func (app *Application) rejectReport(w http.ResponseWriter, r *http.Request) {
+func (app *Application) rejectModeratorRequest(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
@@ -443,34 +467,19 @@ func (app *Application) rejectReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-
-	postId, err := validator.ValidateID(r.PostForm.Get("postId"))
+	applicantId, err := validator.ValidateID(r.PostForm.Get("id"))
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
 		return
 	}
-	reporterId, err := validator.ValidateID(r.PostForm.Get("reporter_id"))
+
+	err = app.Service.User.RejectModerationRequest(userId, applicantId)
 	if err != nil {
-		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		return
 	}
 
-	if userRole == entities.RoleAdmin {
-		err := app.Service.Post.DeleteReport(reporterId, postId)
-		if err != nil {
-			app.render(w, http.StatusInternalServerError, Errorpage, nil)
-			return
-		}
-
-	}
-
-	http.Redirect(w, r, "/administration/reports", http.StatusSeeOther)
+	http.Redirect(w, r, "/moderation-applicants", http.StatusSeeOther)
 }
 
 func (app *Application) categoryEditView(w http.ResponseWriter, r *http.Request) {
@@ -483,13 +492,6 @@ func (app *Application) categoryEditView(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-
 	categories, err := app.Service.Category.GetAll() //дальше больше надо будет передавать
 	if err != nil {
 		app.Logger.Error("get all categories", "error", err)
@@ -500,9 +502,7 @@ func (app *Application) categoryEditView(w http.ResponseWriter, r *http.Request)
 	data := app.newTemplateData(r)
 	data.Categories = categories
 
-	if userRole == entities.RoleAdmin {
-		app.render(w, http.StatusOK, "categoryedit.html", data)
-	}
+	app.render(w, http.StatusOK, "categoryedit.html", data)
 }
 
 func (app *Application) createCategory(w http.ResponseWriter, r *http.Request) {
@@ -520,38 +520,27 @@ func (app *Application) createCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-
 	form := app.Service.Category.NewCategoryCreateForm()
 	form.Name = r.PostForm.Get("category_name")
 
-	if userRole == entities.RoleAdmin {
-		_, err := app.Service.Category.Insert(&form)
-		if err != nil {
-			if errors.Is(err, entities.ErrInvalidData) {
-				data := app.newTemplateData(r)
-				categories, err := app.Service.Category.GetAll() //дальше больше надо будет передавать
-				if err != nil {
-					app.Logger.Error("get all categories", "error", err)
-					app.render(w, http.StatusInternalServerError, Errorpage, nil)
-					return
-				}
-				data.Categories = categories
-
-				data.Form = form
-				app.render(w, http.StatusUnprocessableEntity, "categoryedit.html", data)
-			} else {
+	_, err = app.Service.Category.Insert(userId, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			categories, err := app.Service.Category.GetAll() //дальше больше надо будет передавать
+			if err != nil {
+				app.Logger.Error("get all categories", "error", err)
 				app.render(w, http.StatusInternalServerError, Errorpage, nil)
+				return
 			}
-			return
+			data.Categories = categories
 
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "categoryedit.html", data)
+		} else {
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		}
-
+		return
 	}
 
 	http.Redirect(w, r, "/edit/category", http.StatusSeeOther)
@@ -572,40 +561,28 @@ func (app *Application) deleteCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRole, ok := sess.Get(UserRoleSessionKey).(string)
-	if !ok {
-		app.render(w, http.StatusInternalServerError, Errorpage, nil)
-		return
-
-	}
-
 	categoryId, err := validator.ValidateID(r.PostForm.Get("category_id"))
 	if err != nil {
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
 		return
-
 	}
-	if userRole == entities.RoleAdmin {
-		err := app.Service.Category.Delete(categoryId)
-		fmt.Println(err)
-		if err != nil {
-			if errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrNoRecord) {
-				data := app.newTemplateData(r)
-				categories, err := app.Service.Category.GetAll() //дальше больше надо будет передавать
-				if err != nil {
-					app.Logger.Error("get all categories", "error", err)
-					app.render(w, http.StatusInternalServerError, Errorpage, nil)
-					return
-				}
-				data.Categories = categories
-				app.render(w, http.StatusUnprocessableEntity, "categoryedit.html", data)
-			} else {
+	err = app.Service.Category.Delete(userId, categoryId)
+	fmt.Println(err)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrNoRecord) {
+			data := app.newTemplateData(r)
+			categories, err := app.Service.Category.GetAll() //дальше больше надо будет передавать
+			if err != nil {
+				app.Logger.Error("get all categories", "error", err)
 				app.render(w, http.StatusInternalServerError, Errorpage, nil)
+				return
 			}
-			return
-
+			data.Categories = categories
+			app.render(w, http.StatusUnprocessableEntity, "categoryedit.html", data)
+		} else {
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
 		}
-
+		return
 	}
 
 	http.Redirect(w, r, "/edit/category", http.StatusSeeOther)
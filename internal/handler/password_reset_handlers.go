@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"forum/internal/entities"
+	"forum/internal/mailer"
+)
+
+func (app *Application) passwordForgotView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = app.Service.User.NewForgotPasswordForm()
+
+	app.render(w, http.StatusOK, "password_forgot.html", data)
+}
+
+func (app *Application) passwordForgot(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.User.NewForgotPasswordForm()
+	form.Email = r.PostForm.Get("email")
+
+	user, token, err := app.Service.User.RequestPasswordReset(&form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "password_forgot.html", data)
+			return
+		} else if !errors.Is(err, entities.ErrNoRecord) {
+			app.Logger.Error("request password reset", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+	}
+
+	// Отвечаем одинаково, есть такой адрес или нет
+	if user != nil {
+		link := app.absoluteURL("/user/password/reset", url.Values{"token": {token}})
+		app.sendMail(mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your Forum password",
+			Body: "Hi " + user.Username + ",\r\n\r\n" +
+				"Someone asked to reset the password for your Forum account. " +
+				"If it was you, open the link below within an hour to choose a new password:\r\n\r\n" +
+				link + "\r\n\r\n" +
+				"If you didn't ask for this, you can ignore this email. Your password won't change.",
+		})
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "If an account exists for that email, we've sent a link to reset the password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (app *Application) passwordResetView(w http.ResponseWriter, r *http.Request) {
+	form := app.Service.User.NewPasswordResetForm()
+	form.Token = r.URL.Query().Get("token")
+
+	err := app.Service.User.CheckPasswordResetToken(form.Token)
+	if err != nil {
+		if errors.Is(err, entities.ErrExpiredToken) {
+			sess := app.SessionFromContext(r)
+			sess.Set(FlashSessionKey, "This password reset link is invalid or has expired.")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		} else {
+			app.Logger.Error("check password reset token", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	app.render(w, http.StatusOK, "password_reset.html", data)
+}
+
+func (app *Application) passwordReset(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.User.NewPasswordResetForm()
+	form.Token = r.PostForm.Get("resetToken")
+	form.NewPassword = r.PostForm.Get("newPassword")
+	form.NewPasswordConfirmation = r.PostForm.Get("newPasswordConfirmation")
+
+	sess := app.SessionFromContext(r)
+	userID, err := app.Service.User.ResetPassword(&form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "password_reset.html", data)
+		} else if errors.Is(err, entities.ErrExpiredToken) {
+			sess.Set(FlashSessionKey, "This password reset link is invalid or has expired.")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		} else {
+			app.Logger.Error("reset password", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	// Старый пароль мог быть украден, поэтому завершаем все сессии пользователя
+	err = app.SessionManager.DestroyAllUserSessions(userID)
+	if err != nil {
+		app.Logger.Error("destroy user sessions", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	sess.Set(FlashSessionKey, "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
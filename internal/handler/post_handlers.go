@@ -44,22 +44,18 @@ func (app *Application) postView(w http.ResponseWriter, r *http.Request) {
 		userRole = ""
 	}
 
-	var report *entities.Report
 	if !postData.Post.IsApproved {
-		if !(userRole == entities.RoleModerator || userRole == entities.RoleAdmin || userID == postData.Post.UserID) {
+		canApprove, err := app.Service.Authorizer.CanOnPost(userID, userRole, postID, entities.PermPostApprove)
+		if err != nil {
+			app.Logger.Error("check permission", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+		if !(canApprove || userID == postData.Post.UserID) {
 			app.render(w, http.StatusForbidden, Errorpage,
 				&templateData{AppError: AppError{Message: "This post is under moderation", StatusCode: http.StatusForbidden}})
 			return
 		}
-		report, err = app.Service.Reaction.GetPostReport(postID)
-		if err != nil {
-			app.Logger.Error("get post report", "error", err)
-			if errors.Is(err, entities.ErrNoRecord) {
-			} else {
-				app.render(w, http.StatusInternalServerError, Errorpage, nil)
-				return
-			}
-		}
 	}
 
 	data := app.newTemplateData(r)
@@ -73,8 +69,22 @@ func (app *Application) postView(w http.ResponseWriter, r *http.Request) {
 	data.ReactionData.Dislikes = postData.Dislikes
 	data.ReactionData.UserReaction = postData.UserReaction
 	data.Form = sess.Get(ReactionFormSessionKey)
-	data.Role = userRole
-	data.Report = report
+	if userID > 0 {
+		data.ReportReasons, err = app.Service.Report.ReportReasons()
+		if err != nil {
+			app.Logger.Error("get report reasons", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+
+		// Модератор категорий не получает кнопки модерации на чужих постах
+		data.UserPermissions, err = app.Service.Authorizer.PostPermissions(userID, userRole, postID)
+		if err != nil {
+			app.Logger.Error("get post permissions", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+	}
 	err = sess.Delete(ReactionFormSessionKey)
 	if err != nil {
 		app.Logger.Error("Session error during delete reaction form", "error", err)
@@ -124,6 +134,15 @@ func (app *Application) commentedPostView(w http.ResponseWriter, r *http.Request
 	data.ReactionData.Dislikes = postData.Dislikes
 	data.ReactionData.UserReaction = postData.UserReaction
 	data.Form = sess.Get(ReactionFormSessionKey)
+	if userID > 0 {
+		userRole, _ := sess.Get(UserRoleSessionKey).(string)
+		data.UserPermissions, err = app.Service.Authorizer.PostPermissions(userID, userRole, postID)
+		if err != nil {
+			app.Logger.Error("get post permissions", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+	}
 	err = sess.Delete(ReactionFormSessionKey)
 	if err != nil {
 		app.Logger.Error("Session error during delete reaction form", "error", err)
@@ -247,9 +266,20 @@ func (app *Application) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.Service.DeletePost(post_id, userId)
+	err = app.Service.DeletePost(post_id, userId, app.apiTokenFromContext(r))
 	if err != nil {
-
+		if app.renderBanError(w, r, err) {
+			return
+		}
+		if errors.Is(err, entities.ErrForbidden) {
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+			return
+		}
+		if errors.Is(err, entities.ErrClaimed) {
+			app.render(w, http.StatusConflict, Errorpage,
+				&templateData{AppError: AppError{Message: "Another moderator is working on this post", StatusCode: http.StatusConflict}})
+			return
+		}
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
 		return
 	}
@@ -283,9 +313,20 @@ func (app *Application) DeleteComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.Service.DeleteComment(comment_id, userId)
+	err = app.Service.DeleteComment(comment_id, userId, app.apiTokenFromContext(r))
 	if err != nil {
-
+		if app.renderBanError(w, r, err) {
+			return
+		}
+		if errors.Is(err, entities.ErrForbidden) {
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+			return
+		}
+		if errors.Is(err, entities.ErrClaimed) {
+			app.render(w, http.StatusConflict, Errorpage,
+				&templateData{AppError: AppError{Message: "Another moderator is working on this comment", StatusCode: http.StatusConflict}})
+			return
+		}
 		app.render(w, http.StatusBadRequest, Errorpage, nil)
 		return
 	}
@@ -330,6 +371,9 @@ func (app *Application) postCreate(w http.ResponseWriter, r *http.Request) {
 	postID, allCategories, err := app.Service.Post.CreatePostWithCategories(&form, files, userId)
 	if err != nil {
 		app.Logger.Error("insert post and categories", "error", err)
+		if app.renderBanError(w, r, err) {
+			return
+		}
 		if errors.Is(err, entities.ErrInvalidCredentials) {
 			data := app.newTemplateData(r)
 			data.Categories = allCategories
@@ -338,6 +382,10 @@ func (app *Application) postCreate(w http.ResponseWriter, r *http.Request) {
 				form.Categories = []int{DefaultCategory}
 			}
 			data.Form = form
+			// автосохранение продолжит тот же черновик
+			if draftID, err := validator.ValidateID(r.PostForm.Get("draft_id")); err == nil {
+				data.Draft = &entities.Draft{ID: draftID}
+			}
 			app.render(w, http.StatusUnprocessableEntity, "create_post.html", data)
 		} else if errors.Is(err, entities.ErrNoRecord) {
 			app.render(w, http.StatusBadRequest, Errorpage, nil)
@@ -347,6 +395,14 @@ func (app *Application) postCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Черновик, который автосохранялся, пока пост писали, больше не нужен
+	if draftID, err := validator.ValidateID(r.PostForm.Get("draft_id")); err == nil {
+		err = app.Service.Draft.DeleteDraft(userId, draftID)
+		if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+			app.Logger.Error("delete published draft", "error", err)
+		}
+	}
+
 	err = sess.Set(FlashSessionKey, "Your post will be published after passing moderation")
 	if err != nil {
 		// кажется тут не нужна ошибка, достаточно логирования
@@ -399,11 +455,15 @@ func (app *Application) editPost(w http.ResponseWriter, r *http.Request) {
 	form.Title = r.PostForm.Get("title")
 	form.Content = r.PostForm.Get("content")
 	form.Categories = categoryIDs
+	form.Summary = r.PostForm.Get("summary")
 	files := r.MultipartForm.File["image"]
 
-	err = app.Service.Post.UpdatePostWithImage(&form, postID, files, userId)
+	err = app.Service.Post.UpdatePostWithImage(&form, postID, files, userId, app.apiTokenFromContext(r))
 	if err != nil {
 		app.Logger.Error("update post and image", "error", err)
+		if app.renderBanError(w, r, err) {
+			return
+		}
 		if errors.Is(err, entities.ErrInvalidCredentials) {
 			data := app.newTemplateData(r)
 			data.Categories = categories
@@ -414,6 +474,8 @@ func (app *Application) editPost(w http.ResponseWriter, r *http.Request) {
 			data.Post = &entities.Post{}
 			data.Post.ID = postID
 			app.render(w, http.StatusUnprocessableEntity, "editpost.html", data)
+		} else if errors.Is(err, entities.ErrForbidden) {
+			app.render(w, http.StatusForbidden, Errorpage, nil)
 		} else if errors.Is(err, entities.ErrNoRecord) {
 			app.render(w, http.StatusBadRequest, Errorpage, nil)
 		} else {
@@ -454,6 +516,7 @@ func (app *Application) editComment(w http.ResponseWriter, r *http.Request) {
 
 	form := app.Service.Post.NewCommentForm()
 	form.Content = content
+	form.Summary = r.PostForm.Get("summary")
 
 	sess := app.SessionFromContext(r)
 	userId, ok := sess.Get(AuthUserIDSessionKey).(int)
@@ -464,16 +527,22 @@ func (app *Application) editComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.Service.Post.UpdateComment(&form, commentID, userId)
+	err = app.Service.Post.UpdateComment(&form, commentID, userId, app.apiTokenFromContext(r))
 	if err != nil {
 		app.Logger.Error("update comment", "error", err)
+		if app.renderBanError(w, r, err) {
+			return
+		}
 		if errors.Is(err, entities.ErrInvalidCredentials) {
 			data := app.newTemplateData(r)
 			data.Form = form
 			data.Comment = &entities.Comment{}
 			data.Comment.ID = commentID
 			data.Comment.PostID = postID
+			data.Comment.Content = form.Content
 			app.render(w, http.StatusUnprocessableEntity, "editcomment.html", data)
+		} else if errors.Is(err, entities.ErrForbidden) {
+			app.render(w, http.StatusForbidden, Errorpage, nil)
 		} else if errors.Is(err, entities.ErrNoRecord) {
 			app.render(w, http.StatusBadRequest, Errorpage, nil)
 		} else {
@@ -511,9 +580,12 @@ func (app *Application) userPostsView(w http.ResponseWriter, r *http.Request) {
 		page = p
 	}
 
+	sess := app.SessionFromContext(r)
+	viewerID, _ := sess.Get(AuthUserIDSessionKey).(int)
+
 	paginationURL := fmt.Sprintf("/user/%d/posts", userId)
 	app.Logger.Debug("get user posts", "userID", userId, "page", page, "pageSize", pageSize, "paginationURL", paginationURL)
-	userPostsDTO, err := app.Service.Post.GetUserPostsDTO(userId, page, pageSize, paginationURL)
+	userPostsDTO, err := app.Service.Post.GetUserPostsDTO(viewerID, userId, page, pageSize, paginationURL)
 	app.Logger.Debug("get user posts", "userPostsDTO", userPostsDTO)
 	if err != nil {
 		app.Logger.Error("get user posts", "error", err)
@@ -526,8 +598,18 @@ func (app *Application) userPostsView(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := app.newTemplateData(r)
+	data.User = userPostsDTO.User
 	data.Posts = userPostsDTO.Posts
 	data.Header = fmt.Sprintf("Posts by %s", userPostsDTO.User.Username)
+	// Пожаловаться можно на чужой профиль
+	if viewerID > 0 && viewerID != userId {
+		data.ReportReasons, err = app.Service.Report.ReportReasons()
+		if err != nil {
+			app.Logger.Error("get report reasons", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+	}
 	data.Pagination = pagination{
 		CurrentPage:      userPostsDTO.CurrentPage,
 		HasNextPage:      userPostsDTO.HasNextPage,
@@ -627,3 +709,27 @@ func (app *Application) userCommentedPostsView(w http.ResponseWriter, r *http.Re
 	}
 	app.render(w, http.StatusOK, "commented_posts.html", data)
 }
+
+// postPreview возвращает HTML-фрагмент с текстом поста или комментария в том
+// виде, в каком его покажет страница поста. Вызывается редактором из main.js.
+func (app *Application) postPreview(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	html, err := app.Service.Post.PreviewMarkdown(r.PostForm.Get("content"))
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			http.Error(w, "The text is too long or contains unsupported characters", http.StatusUnprocessableEntity)
+		} else {
+			app.Logger.Error("preview markdown", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"forum/internal/entities"
+	"forum/pkg/validator"
+)
+
+func (app *Application) moderationQueueView(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in moderationQueueView")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	app.renderQueue(w, r, userID, http.StatusOK, r.URL.Query(), app.Service.Queue.NewQueueActionForm())
+}
+
+// moderationQueueAction применяет одно действие ко всем отмеченным элементам очереди
+func (app *Application) moderationQueueAction(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in moderationQueueAction")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	// Фильтры страницы, с которой пришла форма, чтобы вернуться к ним же
+	filter, err := url.ParseQuery(r.PostForm.Get("filter"))
+	if err != nil {
+		filter = url.Values{}
+	}
+
+	form := app.Service.Queue.NewQueueActionForm()
+	form.Items = r.PostForm["items"]
+	form.Action = r.PostForm.Get("action")
+	form.Reason = r.PostForm.Get("reason")
+	if id := r.PostForm.Get("assignee"); id != "" {
+		form.AssigneeID, _ = strconv.Atoi(id)
+	}
+
+	result, err := app.Service.Queue.ApplyAction(userID, &form)
+	if err != nil {
+		if app.renderBanError(w, r, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, entities.ErrInvalidData):
+			app.renderQueue(w, r, userID, http.StatusUnprocessableEntity, filter, form)
+		case errors.Is(err, entities.ErrForbidden):
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+		default:
+			app.Logger.Error("apply queue action", "action", form.Action, "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	message := fmt.Sprintf("Done: %d.", result.Done)
+	if result.Skipped > 0 {
+		message += fmt.Sprintf(" Skipped: %d (claimed by someone else or already handled).", result.Skipped)
+	}
+	sess.Set(FlashSessionKey, message)
+
+	http.Redirect(w, r, "/moderation/queue?"+filter.Encode(), http.StatusSeeOther)
+}
+
+// renderQueue показывает очередь по фильтрам из query вместе с формой действия
+func (app *Application) renderQueue(w http.ResponseWriter, r *http.Request, userID, status int, query url.Values, actionForm any) {
+	page := 1
+	pageSize := 20
+	if p, err := validator.ValidateID(query.Get("page")); err == nil {
+		page = p
+	}
+
+	form := app.Service.Queue.NewQueueFilterForm()
+	form.Kind = query.Get("kind")
+	form.Assignee = query.Get("assignee")
+	var ok bool
+	form.CategoryID, ok = queryInt(query, "category")
+	form.CheckField(ok, "category", "Choose a category from the list")
+	form.MinAgeHours, ok = queryInt(query, "age")
+	form.CheckField(ok, "age", "Enter a positive number")
+	form.MinReports, ok = queryInt(query, "reports")
+	form.CheckField(ok, "reports", "Enter a positive number")
+
+	data := app.newTemplateData(r)
+	data.QueueAction = actionForm
+	// Фильтры без номера страницы: после действия возвращаемся на первую
+	filter := url.Values{}
+	for _, key := range []string{"kind", "assignee", "category", "age", "reports"} {
+		if v := query.Get(key); v != "" {
+			filter.Set(key, v)
+		}
+	}
+	data.QueueFilter = filter.Encode()
+
+	queueDTO, err := app.Service.Queue.GetQueueDTO(userID, &form, page, pageSize, "/moderation/queue")
+	data.Form = form
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			app.render(w, http.StatusUnprocessableEntity, "queue.html", data)
+		} else {
+			app.Logger.Error("get moderation queue", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data.QueueItems = queueDTO.Items
+	data.Categories = queueDTO.Categories
+	data.Users = queueDTO.Staff
+	data.Pagination = pagination{
+		CurrentPage:      queueDTO.CurrentPage,
+		HasNextPage:      queueDTO.HasNextPage,
+		PaginationAction: queueDTO.PaginationURL,
+	}
+	app.render(w, status, "queue.html", data)
+}
+
+// queryInt читает необязательное число из query; пустое значение - ноль
+func queryInt(query url.Values, key string) (int, bool) {
+	value := query.Get(key)
+	if value == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
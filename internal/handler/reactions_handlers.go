@@ -49,6 +49,9 @@ func (app *Application) postReaction(w http.ResponseWriter, r *http.Request) {
 	err = app.Service.Reaction.UpdatePostReaction(userID, postID, &form)
 	if err != nil {
 		app.Logger.Error("update reaction on post in database", "error", err)
+		if app.renderBanError(w, r, err) {
+			return
+		}
 		if errors.Is(err, entities.ErrInvalidData) {
 			sess.Set(ReactionFormSessionKey, form)
 			http.Redirect(w, r, r.Referer(), http.StatusSeeOther)
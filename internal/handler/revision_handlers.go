@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"forum/internal/entities"
+	"forum/pkg/validator"
+)
+
+// postHistory обслуживает /post/{id}/history. Такой шаблон ServeMux считает
+// конфликтующим с /post/view/{id} и /post/edit/{post_id}, поэтому маршрут
+// зарегистрирован как /post/{id}/{page}, а остальные страницы - 404.
+func (app *Application) postHistory(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("page") != "history" {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+	app.history(w, r, entities.TargetPost)
+}
+
+func (app *Application) commentHistory(w http.ResponseWriter, r *http.Request) {
+	app.history(w, r, entities.TargetComment)
+}
+
+func (app *Application) postRollback(w http.ResponseWriter, r *http.Request) {
+	app.rollback(w, r, entities.TargetPost)
+}
+
+func (app *Application) commentRollback(w http.ResponseWriter, r *http.Request) {
+	app.rollback(w, r, entities.TargetComment)
+}
+
+// history показывает версии поста или комментария. ?from= и ?to= выбирают
+// сравниваемые версии, ?view=split - сравнение в две колонки.
+func (app *Application) history(w http.ResponseWriter, r *http.Request, targetType string) {
+	id, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := 0, 0
+	if v := query.Get("from"); v != "" {
+		if from, err = validator.ValidateID(v); err != nil {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, err = validator.ValidateID(v); err != nil {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+			return
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = app.Service.Revision.NewRollbackForm()
+	app.renderHistory(w, r, http.StatusOK, targetType, id, from, to, query.Get("view") == "split", data)
+}
+
+func (app *Application) rollback(w http.ResponseWriter, r *http.Request, targetType string) {
+	id, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Revision.NewRollbackForm()
+	form.Revision, err = validator.ValidateID(r.PostForm.Get("revision"))
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+	form.Reason = r.PostForm.Get("reason")
+
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		app.Logger.Error("get userid from session", "error", errors.New("get userID in rollback"))
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err = app.Service.Revision.Rollback(userID, targetType, id, &form)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidData):
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.renderHistory(w, r, http.StatusUnprocessableEntity, targetType, id, 0, 0, false, data)
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		case errors.Is(err, entities.ErrForbidden):
+			app.render(w, http.StatusForbidden, Errorpage, nil)
+		case errors.Is(err, entities.ErrClaimed):
+			app.render(w, http.StatusConflict, Errorpage,
+				&templateData{AppError: AppError{Message: "Another moderator is working on this " + targetType, StatusCode: http.StatusConflict}})
+		default:
+			app.Logger.Error("rollback revision", "error", err, "target", targetType, "id", id)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, fmt.Sprintf("Revision %d has been restored.", form.Revision))
+	http.Redirect(w, r, fmt.Sprintf("/%s/%d/history", targetType, id), http.StatusSeeOther)
+}
+
+func (app *Application) renderHistory(w http.ResponseWriter, r *http.Request, status int, targetType string, id, from, to int, split bool, data *templateData) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok {
+		userID = 0
+	}
+
+	history, err := app.Service.Revision.GetHistoryDTO(targetType, id, userID, from, to, split)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		case errors.Is(err, entities.ErrForbidden):
+			app.render(w, http.StatusForbidden, Errorpage,
+				&templateData{AppError: AppError{Message: "This post is under moderation", StatusCode: http.StatusForbidden}})
+		default:
+			app.Logger.Error("get revision history", "error", err, "target", targetType, "id", id)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data.History = history
+	app.render(w, status, "history.html", data)
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"forum/internal/entities"
+)
+
+func (app *Application) administrationRolesView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = app.Service.Role.NewRoleForm()
+	app.renderRoles(w, r, http.StatusOK, data)
+}
+
+func (app *Application) administrationRoleCreate(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.Role.NewRoleForm()
+	form.Name = r.PostForm.Get("name")
+	form.Description = r.PostForm.Get("description")
+	form.Permissions = r.PostForm["permissions"]
+
+	err = app.Service.Role.CreateRole(&form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.renderRoles(w, r, http.StatusUnprocessableEntity, data)
+		} else {
+			app.Logger.Error("create role", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "Role \""+form.Name+"\" has been created.")
+	http.Redirect(w, r, "/administration/roles", http.StatusSeeOther)
+}
+
+func (app *Application) administrationRoleView(w http.ResponseWriter, r *http.Request) {
+	role, err := app.Service.Role.GetRole(r.PathValue("name"))
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("get role", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	form := app.Service.Role.NewRoleForm()
+	form.Name = role.Name
+	form.Description = role.Description
+	form.Permissions = role.Permissions
+
+	data := app.newTemplateData(r)
+	data.EditedRole = role
+	data.Form = form
+	data.AllPermissions = entities.Permissions
+
+	app.render(w, http.StatusOK, "role_edit.html", data)
+}
+
+func (app *Application) administrationRoleUpdate(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	role, err := app.Service.Role.GetRole(r.PathValue("name"))
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("get role", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	form := app.Service.Role.NewRoleForm()
+	form.Name = role.Name
+	form.Description = r.PostForm.Get("description")
+	form.Permissions = r.PostForm["permissions"]
+
+	err = app.Service.Role.UpdateRole(&form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.EditedRole = role
+			data.Form = form
+			data.AllPermissions = entities.Permissions
+			app.render(w, http.StatusUnprocessableEntity, "role_edit.html", data)
+		} else if errors.Is(err, entities.ErrBuiltinRole) {
+			app.render(w, http.StatusBadRequest, Errorpage,
+				&templateData{AppError: AppError{Message: "Administrators always have every permission", StatusCode: http.StatusBadRequest}})
+		} else {
+			app.Logger.Error("update role", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "Role \""+role.Name+"\" has been saved.")
+	http.Redirect(w, r, "/administration/roles/"+url.PathEscape(role.Name), http.StatusSeeOther)
+}
+
+func (app *Application) administrationRoleDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	sess := app.SessionFromContext(r)
+
+	err := app.Service.Role.DeleteRole(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrNoRecord):
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		case errors.Is(err, entities.ErrBuiltinRole):
+			sess.Set(FlashSessionKey, "Built-in roles cannot be deleted.")
+			http.Redirect(w, r, "/administration/roles/"+url.PathEscape(name), http.StatusSeeOther)
+		case errors.Is(err, entities.ErrRoleInUse):
+			sess.Set(FlashSessionKey, "Assign another role to the users of this role before deleting it.")
+			http.Redirect(w, r, "/administration/roles/"+url.PathEscape(name), http.StatusSeeOther)
+		default:
+			app.Logger.Error("delete role", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, "Role \""+name+"\" has been deleted.")
+	http.Redirect(w, r, "/administration/roles", http.StatusSeeOther)
+}
+
+func (app *Application) administrationAssignRole(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	actorID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || actorID < 1 {
+		err := errors.New("get userID in administrationAssignRole")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user_id"))
+	if err != nil || userID < 1 {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+	role := r.PostForm.Get("role")
+
+	err = app.Service.Role.AssignRole(actorID, userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrNoRecord):
+			sess.Set(FlashSessionKey, "User or role not found.")
+		case errors.Is(err, entities.ErrForbidden):
+			app.Logger.Warn("role assignment denied", "actor", actorID, "user", userID, "role", role)
+			sess.Set(FlashSessionKey, "You cannot change your own role, and only administrators can grant or revoke the admin role.")
+		default:
+			app.Logger.Error("assign role", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+			return
+		}
+		http.Redirect(w, r, "/administration/roles", http.StatusSeeOther)
+		return
+	}
+
+	sess.Set(FlashSessionKey, "User #"+strconv.Itoa(userID)+" now has the role \""+role+"\".")
+	http.Redirect(w, r, "/administration/roles", http.StatusSeeOther)
+}
+
+func (app *Application) renderRoles(w http.ResponseWriter, r *http.Request, status int, data *templateData) {
+	roles, err := app.Service.Role.ListRoles()
+	if err != nil {
+		app.Logger.Error("list roles", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data.Roles = roles
+	data.AllPermissions = entities.Permissions
+	app.render(w, status, "roles.html", data)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"os"
 
+	"forum/internal/entities"
 	"forum/ui"
 )
 
@@ -39,73 +40,141 @@ func (app *Application) Routes() http.Handler {
 	uploadServer := http.FileServer(http.Dir("./uploads"))
 	mux.Handle("GET /uploads/", http.StripPrefix("/uploads/", cacheControlMiddleware(uploadServer)))
 
-	dynamic := New(app.verifyCSRF, app.sessionMiddleware, app.authenticate)
+	dynamic := New(app.sessionMiddleware, app.authenticate, app.verifyCSRF)
 	mux.Handle("/", dynamic.ThenFunc(app.errorHandler))
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
 	mux.Handle("POST /", dynamic.ThenFunc(app.filterPosts))
 	mux.Handle("GET /post/view/{id}", dynamic.ThenFunc(app.postView))
 	mux.Handle("GET /commented-post/view/{id}", dynamic.ThenFunc(app.commentedPostView))
+	// Это /post/{id}/history, см. postHistory
+	mux.Handle("GET /post/{id}/{page}", dynamic.ThenFunc(app.postHistory))
+	mux.Handle("GET /comment/{id}/history", dynamic.ThenFunc(app.commentHistory))
 	mux.Handle("GET /user/{userId}/posts", dynamic.ThenFunc(app.userPostsView))
 	mux.Handle("POST /user/{userId}/posts", dynamic.ThenFunc(app.userPostsView))
 	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignupView))
 	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignup))
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLoginView))
 	mux.Handle("POST /user/login", dynamic.ThenFunc(app.userLogin))
+	mux.Handle("GET /user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactorView))
+	mux.Handle("POST /user/login/2fa", dynamic.ThenFunc(app.userLoginTwoFactor))
+	mux.Handle("GET /user/password/forgot", dynamic.ThenFunc(app.passwordForgotView))
+	mux.Handle("POST /user/password/forgot", dynamic.ThenFunc(app.passwordForgot))
+	mux.Handle("GET /user/password/reset", dynamic.ThenFunc(app.passwordResetView))
+	mux.Handle("POST /user/password/reset", dynamic.ThenFunc(app.passwordReset))
+	mux.Handle("GET /user/verify-email", dynamic.ThenFunc(app.verifyEmail))
+	mux.Handle("GET /account/email/confirm", dynamic.ThenFunc(app.accountEmailConfirm))
 	mux.Handle("GET /about", dynamic.ThenFunc(app.aboutView))
 
-	mux.Handle("GET /auth/google/login", dynamic.ThenFunc(app.oauthGoogleLogin))
-	mux.Handle("GET /auth/google/callback", dynamic.ThenFunc(app.oauthGoogleCallback))
-	mux.Handle("GET /auth/github/login", dynamic.ThenFunc(app.oauthGithubLogin))
-	mux.Handle("GET /auth/github/callback", dynamic.ThenFunc(app.oauthGithubCallback))
+	mux.Handle("GET /auth/{provider}/login", dynamic.ThenFunc(app.oauthLogin))
+	mux.Handle("GET /auth/{provider}/callback", dynamic.ThenFunc(app.oauthCallback))
+	mux.Handle("GET /user/oauth/username", dynamic.ThenFunc(app.oauthUsernameView))
+	mux.Handle("POST /user/oauth/username", dynamic.ThenFunc(app.oauthUsername))
 
 	protected := dynamic.Append(app.requireAuthentication)
-	mux.Handle("GET /post/edit/{post_id}", protected.ThenFunc(app.editPostView))
-	mux.Handle("POST /post/edit/{post_id}", protected.ThenFunc(app.editPost))
-	mux.Handle("POST /post/view/{id}", protected.ThenFunc(app.postReaction))
+	verified := protected.Append(app.requireVerifiedEmail)
+	// Управление аккаунтом только из браузера, не по токену доступа
+	account := protected.Append(app.rejectAPIToken)
+	mux.Handle("GET /post/edit/{post_id}", verified.ThenFunc(app.editPostView))
+	mux.Handle("POST /post/edit/{post_id}", verified.ThenFunc(app.editPost))
+	mux.Handle("POST /post/view/{id}", verified.ThenFunc(app.postReaction))
 	mux.Handle("POST /post/delete", protected.ThenFunc(app.DeletePost))
-	mux.Handle("GET /post/create", protected.ThenFunc(app.postCreateView))
-	mux.Handle("POST /post/create", protected.ThenFunc(app.postCreate))
-
-	mux.Handle("GET /comment/edit", protected.ThenFunc(app.editCommentView))
-	mux.Handle("POST /comment/edit", protected.ThenFunc(app.editComment))
+	mux.Handle("GET /post/create", verified.ThenFunc(app.postCreateView))
+	mux.Handle("POST /post/create", verified.ThenFunc(app.postCreate))
+	mux.Handle("GET /draft/edit/{id}", verified.ThenFunc(app.draftEditView))
+	mux.Handle("POST /draft/save", verified.ThenFunc(app.draftSave))
+	mux.Handle("POST /draft/autosave", verified.ThenFunc(app.draftAutosave))
+	mux.Handle("POST /draft/delete", protected.ThenFunc(app.draftDelete))
+	mux.Handle("POST /post/preview", verified.ThenFunc(app.postPreview))
+
+	mux.Handle("GET /comment/edit", verified.ThenFunc(app.editCommentView))
+	mux.Handle("POST /comment/edit", verified.ThenFunc(app.editComment))
 	mux.Handle("POST /comment/delete", protected.ThenFunc(app.DeleteComment))
 
+	mux.Handle("POST /report/{target}/{id}", verified.ThenFunc(app.createReport))
+
 	mux.Handle("GET /account/notification", protected.ThenFunc(app.notificationView))
-	mux.Handle("GET /account/view", protected.ThenFunc(app.accountView))
-	mux.Handle("GET /account/password/update", protected.ThenFunc(app.accountPasswordUpdateView))
-	mux.Handle("POST /account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	mux.Handle("GET /account/view", account.ThenFunc(app.accountView))
+	mux.Handle("GET /account/password/update", account.ThenFunc(app.accountPasswordUpdateView))
+	mux.Handle("POST /account/password/update", account.ThenFunc(app.accountPasswordUpdate))
+	mux.Handle("GET /account/email/update", account.ThenFunc(app.accountEmailUpdateView))
+	mux.Handle("POST /account/email/update", account.ThenFunc(app.accountEmailUpdate))
+	mux.Handle("POST /account/verify-email/resend", account.ThenFunc(app.accountVerifyEmailResend))
+	mux.Handle("GET /account/sessions", account.ThenFunc(app.accountSessionsView))
+	mux.Handle("POST /account/sessions/revoke", account.ThenFunc(app.accountSessionRevoke))
+	mux.Handle("POST /account/sessions/revoke-others", account.ThenFunc(app.accountSessionRevokeOthers))
+	mux.Handle("GET /account/identities", account.ThenFunc(app.accountIdentitiesView))
+	mux.Handle("POST /account/identities/link", account.ThenFunc(app.accountIdentityLink))
+	mux.Handle("POST /account/identities/unlink", account.ThenFunc(app.accountIdentityUnlink))
+	mux.Handle("GET /account/tokens", account.ThenFunc(app.accountAPITokensView))
+	mux.Handle("POST /account/tokens/create", account.ThenFunc(app.accountAPITokenCreate))
+	mux.Handle("POST /account/tokens/revoke", account.ThenFunc(app.accountAPITokenRevoke))
+	mux.Handle("GET /account/2fa", account.ThenFunc(app.accountTwoFactorView))
+	mux.Handle("GET /account/2fa/qr.png", account.ThenFunc(app.accountTwoFactorQR))
+	mux.Handle("POST /account/2fa/enable", account.ThenFunc(app.accountTwoFactorEnable))
+	mux.Handle("POST /account/2fa/disable", account.ThenFunc(app.accountTwoFactorDisable))
+	mux.Handle("POST /account/2fa/recovery-codes", account.ThenFunc(app.accountTwoFactorRecoveryCodes))
 
 	mux.Handle("GET /user/liked", protected.ThenFunc(app.userLikedPostsView))
 	mux.Handle("GET /user/commented", protected.ThenFunc(app.userCommentedPostsView))
+	mux.Handle("GET /user/drafts", protected.ThenFunc(app.draftsView))
 	mux.Handle("POST /user/liked", protected.ThenFunc(app.userLikedPostsView))
 	mux.Handle("POST /user/commented", protected.ThenFunc(app.userCommentedPostsView))
-	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogout))
+	mux.Handle("POST /user/logout", account.ThenFunc(app.userLogout))
 
 	mux.Handle("GET /moderation-application", protected.ThenFunc(app.moderationApplicationView))
 	mux.Handle("POST /moderation-application", protected.ThenFunc(app.createModerationApplication))
 
-	moderated := protected.Append(app.requireModeration)
-	mux.Handle("GET /moderation/posts/unapproved", moderated.ThenFunc(app.moderationUnapprovedPostsView))
-	mux.Handle("POST /moderation/approve/{post_id}", protected.ThenFunc(app.moderationApprovePost))
-	mux.Handle("POST /moderation/report/{post_id}", protected.ThenFunc(app.moderationReportPost))
-
-
-	administrated := protected.Append(app.requireAdministration)
-	mux.Handle("GET /administration/reports", administrated.ThenFunc(app.administrationReportsView))
-	mux.Handle("GET /moderation-applicants", administrated.ThenFunc(app.moderationApplicantsView))
-	mux.Handle("GET /moderators/list", administrated.ThenFunc(app.moderatorsView))
-	mux.Handle("POST /moderators/delete", administrated.ThenFunc(app.deleteModerator))
-	mux.Handle("POST /moderation/accept", administrated.ThenFunc(app.requestModeratorRole))
-	mux.Handle("POST /moderation/reject", administrated.ThenFunc(app.rejectModeratorRequest))
-	mux.Handle("POST /report/accept", administrated.ThenFunc(app.acceptReport))
-	mux.Handle("POST /report/reject", administrated.ThenFunc(app.rejectReport))
-	mux.Handle("GET /edit/category", administrated.ThenFunc(app.categoryEditView))
-	mux.Handle("POST /admin/category/create", administrated.ThenFunc(app.createCategory))
-	mux.Handle("POST /admin/category/delete", administrated.ThenFunc(app.deleteCategory))
-
-
-	
-
+	// Страницы модерации и администрирования открываются по правам роли
+	permitted := func(permissions ...string) *Chain {
+		return protected.Append(app.requirePermission(permissions...))
+	}
+	mux.Handle("GET /moderation/posts/unapproved", permitted(entities.PermPostApprove).ThenFunc(app.moderationUnapprovedPostsView))
+	mux.Handle("GET /moderation/queue", permitted(entities.PermPostApprove, entities.PermReportResolve).ThenFunc(app.moderationQueueView))
+	mux.Handle("POST /moderation/queue", permitted(entities.PermPostApprove, entities.PermReportResolve).ThenFunc(app.moderationQueueAction))
+	mux.Handle("POST /moderation/approve/{post_id}", permitted(entities.PermPostApprove).ThenFunc(app.moderationApprovePost))
+	mux.Handle("POST /moderation/reject/{post_id}", permitted(entities.PermPostApprove).ThenFunc(app.moderationRejectPost))
+	mux.Handle("POST /post/rollback/{id}", permitted(entities.PermRevisionRollback).ThenFunc(app.postRollback))
+	mux.Handle("POST /comment/rollback/{id}", permitted(entities.PermRevisionRollback).ThenFunc(app.commentRollback))
+
+	mux.Handle("GET /administration/reports", permitted(entities.PermReportResolve).ThenFunc(app.administrationReportsView))
+	mux.Handle("GET /administration/reports/{target}/{id}", permitted(entities.PermReportResolve).ThenFunc(app.administrationReportView))
+	mux.Handle("POST /administration/reports/{target}/{id}/status", permitted(entities.PermReportResolve).ThenFunc(app.administrationReportStatus))
+	mux.Handle("GET /moderation-applicants", permitted(entities.PermModeratorManage).ThenFunc(app.moderationApplicantsView))
+	mux.Handle("GET /moderators/list", permitted(entities.PermModeratorManage).ThenFunc(app.moderatorsView))
+	mux.Handle("POST /moderators/delete", permitted(entities.PermModeratorManage).ThenFunc(app.deleteModerator))
+	mux.Handle("POST /moderators/categories", permitted(entities.PermModeratorManage).ThenFunc(app.setModeratorCategories))
+	mux.Handle("POST /moderation/accept", permitted(entities.PermModeratorManage).ThenFunc(app.requestModeratorRole))
+	mux.Handle("POST /moderation/reject", permitted(entities.PermModeratorManage).ThenFunc(app.rejectModeratorRequest))
+	mux.Handle("GET /edit/category", permitted(entities.PermCategoryManage).ThenFunc(app.categoryEditView))
+	mux.Handle("POST /admin/category/create", permitted(entities.PermCategoryManage).ThenFunc(app.createCategory))
+	mux.Handle("POST /admin/category/delete", permitted(entities.PermCategoryManage).ThenFunc(app.deleteCategory))
+	mux.Handle("GET /administration/settings", permitted(entities.PermSettingsManage).ThenFunc(app.administrationSettingsView))
+	mux.Handle("POST /administration/settings", permitted(entities.PermSettingsManage).ThenFunc(app.administrationSettingsUpdate))
+	mux.Handle("GET /administration/logins", permitted(entities.PermUserManage).ThenFunc(app.administrationLoginsView))
+	mux.Handle("GET /administration/users", permitted(entities.PermUserManage).ThenFunc(app.administrationUsersView))
+	mux.Handle("GET /administration/users/{id}", permitted(entities.PermUserManage).ThenFunc(app.administrationUserView))
+	mux.Handle("POST /administration/users/{id}/password-reset", permitted(entities.PermUserManage).ThenFunc(app.administrationUserPasswordReset))
+	mux.Handle("POST /administration/users/{id}/logout", permitted(entities.PermUserManage).ThenFunc(app.administrationUserLogout))
+	mux.Handle("POST /administration/users/{id}/delete", permitted(entities.PermUserManage).ThenFunc(app.administrationUserDelete))
+	mux.Handle("POST /administration/users/unlock", permitted(entities.PermUserManage).ThenFunc(app.administrationUnlockUser))
+	mux.Handle("GET /administration/bans", permitted(entities.PermUserManage).ThenFunc(app.administrationBansView))
+	mux.Handle("POST /administration/bans", permitted(entities.PermUserManage).ThenFunc(app.administrationBanUser))
+	mux.Handle("POST /administration/bans/lift", permitted(entities.PermUserManage).ThenFunc(app.administrationLiftBan))
+	mux.Handle("GET /administration/audit", permitted(entities.PermAuditView).ThenFunc(app.administrationAuditView))
+	mux.Handle("GET /administration/audit/export", permitted(entities.PermAuditView).ThenFunc(app.administrationAuditExport))
+	mux.Handle("GET /administration/automod", permitted(entities.PermAutomodManage).ThenFunc(app.administrationAutomodView))
+	mux.Handle("POST /administration/automod", permitted(entities.PermAutomodManage).ThenFunc(app.administrationAutomodCreate))
+	mux.Handle("GET /administration/automod/hits", permitted(entities.PermAutomodManage).ThenFunc(app.administrationAutomodHits))
+	mux.Handle("GET /administration/automod/{id}", permitted(entities.PermAutomodManage).ThenFunc(app.administrationAutomodRuleView))
+	mux.Handle("POST /administration/automod/{id}", permitted(entities.PermAutomodManage).ThenFunc(app.administrationAutomodRuleUpdate))
+	mux.Handle("POST /administration/automod/{id}/delete", permitted(entities.PermAutomodManage).ThenFunc(app.administrationAutomodRuleDelete))
+	mux.Handle("GET /administration/roles", permitted(entities.PermRoleManage).ThenFunc(app.administrationRolesView))
+	mux.Handle("POST /administration/roles", permitted(entities.PermRoleManage).ThenFunc(app.administrationRoleCreate))
+	mux.Handle("GET /administration/roles/{name}", permitted(entities.PermRoleManage).ThenFunc(app.administrationRoleView))
+	mux.Handle("POST /administration/roles/{name}", permitted(entities.PermRoleManage).ThenFunc(app.administrationRoleUpdate))
+	mux.Handle("POST /administration/roles/{name}/delete", permitted(entities.PermRoleManage).ThenFunc(app.administrationRoleDelete))
+	mux.Handle("POST /administration/users/role", permitted(entities.PermRoleManage).ThenFunc(app.administrationAssignRole))
+	mux.Handle("POST /administration/users/{id}/role", permitted(entities.PermRoleManage).ThenFunc(app.administrationUserRole))
 
 	standard := New(app.recoverPanic, app.logRequest, secureHeaders, app.rateLimiting)
 	return standard.Then(mux)
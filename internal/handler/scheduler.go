@@ -0,0 +1,41 @@
+package handler
+
+import "time"
+
+// publishScheduled публикует запланированные посты раз в interval, пока не
+// закроется done. Посты, время которых пришло, пока сервер был выключен,
+// публикуются сразу при запуске.
+func (app *Application) publishScheduled(interval time.Duration, done <-chan struct{}) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.publishDueDrafts()
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (app *Application) publishDueDrafts() {
+	defer func() {
+		if err := recover(); err != nil {
+			app.Logger.Error("recover panic in publishDueDrafts", "error", err)
+		}
+	}()
+
+	published, err := app.Service.Draft.PublishDueDrafts()
+	if err != nil {
+		app.Logger.Error("publish scheduled posts", "error", err)
+		return
+	}
+	if published > 0 {
+		app.Logger.Info("published scheduled posts", "count", published)
+	}
+}
@@ -1,5 +1,12 @@
 package handler
 
+import (
+	"encoding/gob"
+
+	"forum/internal/entities"
+	"forum/internal/service"
+)
+
 // Ключи для значений, хранимых в сессиях
 const (
 	FlashSessionKey                  = "flash"
@@ -8,6 +15,19 @@ const (
 	CsrfTokenSessionKey              = "token"
 	RedirectPathAfterLoginSessionKey = "redirect_path_after_login"
 	ReactionFormSessionKey           = "reaction_form"
-	GoogleOAuthStateSessionKey       = "google_oauth_state"
-	GitHubOAuthStateSessionKey       = "github_oauth_state"
+	OAuthProviderSessionKey          = "oauth_provider"
+	OAuthStateSessionKey             = "oauth_state"
+	OAuthNonceSessionKey             = "oauth_nonce"
+	OAuthVerifierSessionKey          = "oauth_verifier"
+	OAuthLinkUserIDSessionKey        = "oauth_link_userID"
+	PendingIdentitySessionKey        = "pending_identity"
+	PendingTwoFactorUserIDSessionKey = "pending_2fa_userID"
+	PendingTwoFactorExpirySessionKey = "pending_2fa_expiry"
 )
+
+// Сессии в базе сохраняются через gob, поэтому каждый невстроенный тип,
+// который кладётся в сессию, нужно зарегистрировать здесь.
+func init() {
+	gob.Register(service.ReactionForm{})
+	gob.Register(entities.ExternalIdentity{})
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"forum/internal/entities"
+)
+
+func (app *Application) administrationSettingsView(w http.ResponseWriter, r *http.Request) {
+	form, err := app.Service.Setting.GetSettingsForm()
+	if err != nil {
+		app.Logger.Error("get settings", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	app.render(w, http.StatusOK, "settings.html", data)
+}
+
+func (app *Application) administrationSettingsUpdate(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form, err := app.Service.Setting.GetSettingsForm()
+	if err != nil {
+		app.Logger.Error("get settings", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+	form.RequireStaffTwoFactor = r.PostForm.Get("requireStaffTwoFactor") == "true"
+	form.RequireEmailVerification = r.PostForm.Get("requireEmailVerification") == "true"
+	form.ReportReasons = r.PostForm.Get("reportReasons")
+
+	err = app.Service.Setting.UpdateSettings(form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "settings.html", data)
+			return
+		}
+		app.Logger.Error("update settings", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "Settings have been saved.")
+	http.Redirect(w, r, "/administration/settings", http.StatusSeeOther)
+}
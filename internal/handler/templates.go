@@ -8,6 +8,9 @@ import (
 	"strings"
 
 	"forum/internal/entities"
+	"forum/internal/oauth"
+	"forum/internal/service"
+	"forum/internal/session"
 	"forum/ui"
 )
 
@@ -34,28 +37,76 @@ func (rd *ReactionData) GetUserReaction() int {
 }
 
 type templateData struct {
-	AppError        AppError
-	CurrentYear     int
-	Categories      []*entities.Category
-	CSRFToken       string
-	Post            *entities.Post
-	Posts           []*entities.Post
-	Images          []*entities.Image
-	Comment         *entities.Comment
-	Comments        []*entities.Comment
-	Notifications   []*entities.Notification
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	User            *entities.User
-	Users           []*entities.User
-	Applicants      []*entities.ModeratorApplicant
-	ReactionData    *ReactionData
-	Header          string
-	Pagination      any
-	Role            string
-	Report          *entities.Report
-	Reports         []*entities.Report
+	AppError              AppError
+	CurrentYear           int
+	Categories            []*entities.Category
+	CSRFToken             string
+	Post                  *entities.Post
+	Posts                 []*entities.Post
+	Images                []*entities.Image
+	Comment               *entities.Comment
+	Comments              []*entities.Comment
+	Notifications         []*entities.Notification
+	Form                  any
+	Flash                 string
+	IsAuthenticated       bool
+	User                  *entities.User
+	Users                 []*entities.User
+	Applicants            []*entities.ModeratorApplicant
+	Moderators            []*entities.Moderator
+	ReactionData          *ReactionData
+	Header                string
+	Pagination            any
+	ReportTarget          *service.ReportTargetDTO
+	ReportTargets         []*entities.ReportTarget
+	ReportStatus          string
+	ReportStatuses        []string
+	ReportReasons         []string
+	QueueItems            []*entities.QueueItem
+	QueueAction           any    // форма массового действия над очередью
+	QueueFilter           string // фильтры очереди для возврата после действия
+	Sessions              []session.Info
+	TwoFactor             *entities.TwoFactor
+	RecoveryCodes         []string
+	LoginActivity         *service.LoginActivityDTO
+	OAuthProviders        []*oauth.Provider
+	Identities            []*entities.UserIdentity
+	APITokens             []*entities.APIToken
+	NewAPIToken           string
+	UserPermissions       map[string]bool // права роли текущего пользователя
+	Roles                 []*entities.Role
+	EditedRole            *entities.Role
+	AllPermissions        []entities.Permission
+	Ban                   *entities.Ban
+	Bans                  []*entities.Ban
+	UserOverview          *entities.UserOverview
+	UserOverviews         []*entities.UserOverview
+	ModerationActions     []*entities.ModerationAction
+	ModerationActionNames []string
+	TargetTypes           []string
+	AutomodRules          []*entities.AutomodRule
+	AutomodHits           []*entities.AutomodHit
+	AutomodRuleID         int // правило, по которому отфильтрован журнал
+	AutomodConditions     []string
+	AutomodActions        []string
+	History               *service.HistoryDTO
+	Draft                 *entities.Draft
+	Drafts                []*entities.Draft
+}
+
+// Can проверяет право текущего пользователя в шаблоне: {{if .Can "post.approve"}}
+func (td *templateData) Can(permission string) bool {
+	return td.UserPermissions[permission]
+}
+
+// linked сообщает, привязан ли к пользователю аккаунт провайдера
+func linked(identities []*entities.UserIdentity, provider string) bool {
+	for _, i := range identities {
+		if i.Provider == provider {
+			return true
+		}
+	}
+	return false
 }
 
 func contains(s []int, e int) bool {
@@ -67,6 +118,15 @@ func contains(s []int, e int) bool {
 	return false
 }
 
+func containsString(s []string, e string) bool {
+	for _, a := range s {
+		if a == e {
+			return true
+		}
+	}
+	return false
+}
+
 func join(items []int, sep rune) string {
 	strItems := make([]string, len(items))
 	for i, item := range items {
@@ -76,10 +136,12 @@ func join(items []int, sep rune) string {
 }
 
 var functions = template.FuncMap{
-	"contains": contains,
-	"add":      func(a, b int) int { return a + b },
-	"sub":      func(a, b int) int { return a - b },
-	"join":     join,
+	"contains":       contains,
+	"add":            func(a, b int) int { return a + b },
+	"sub":            func(a, b int) int { return a - b },
+	"join":           join,
+	"linked":         linked,
+	"containsString": containsString,
 }
 
 func NewTemplateCache() (map[string]*template.Template, error) {
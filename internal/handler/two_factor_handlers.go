@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"forum/internal/entities"
+	"forum/internal/service"
+	"forum/internal/session"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const twoFactorLoginTimeout = 5 * time.Minute
+
+// pendingTwoFactorUserID возвращает пользователя, который ввёл пароль и ещё
+// не подтвердил вход кодом.
+func (app *Application) pendingTwoFactorUserID(r *http.Request) (int, bool) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(PendingTwoFactorUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		return 0, false
+	}
+	expiry, ok := sess.Get(PendingTwoFactorExpirySessionKey).(int64)
+	if !ok || time.Now().Unix() > expiry {
+		return 0, false
+	}
+	return userID, true
+}
+
+func (app *Application) clearPendingTwoFactor(r *http.Request) {
+	sess := app.SessionFromContext(r)
+	for _, key := range []string{PendingTwoFactorUserIDSessionKey, PendingTwoFactorExpirySessionKey} {
+		err := sess.Delete(key)
+		if err != nil {
+			app.Logger.Error("Session error during delete pending two factor", "key", key, "error", err)
+		}
+	}
+}
+
+func (app *Application) userLoginTwoFactorView(w http.ResponseWriter, r *http.Request) {
+	if _, ok := app.pendingTwoFactorUserID(r); !ok {
+		app.clearPendingTwoFactor(r)
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = app.Service.TwoFactor.NewTwoFactorForm()
+
+	app.render(w, http.StatusOK, "login_2fa.html", data)
+}
+
+func (app *Application) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := app.pendingTwoFactorUserID(r)
+	if !ok {
+		app.clearPendingTwoFactor(r)
+		sess.Set(FlashSessionKey, "Your login has expired. Please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.TwoFactor.NewTwoFactorForm()
+	form.Code = r.PostForm.Get("code")
+
+	// Неверные коды копятся в счётчике неудачных входов аккаунта, а не в
+	// сессии: повторный вход по паролю их не обнуляет
+	user, err := app.Service.User.LoginTwoFactor(userID, session.ClientIP(r), &form)
+	if err != nil {
+		var lockErr *entities.LockoutError
+		var banErr *entities.BanError
+		switch {
+		case errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrInvalidTwoFactorCode):
+			app.Logger.Warn("invalid two factor code", "user_id", userID, "ip", session.ClientIP(r))
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "login_2fa.html", data)
+		case errors.As(err, &lockErr):
+			if lockErr.Notify {
+				app.Logger.Warn("account locked", "user_id", lockErr.User.ID, "ip", session.ClientIP(r), "until", lockErr.Until)
+				app.sendAccountLockedEmail(lockErr)
+			}
+			app.clearPendingTwoFactor(r)
+			sess.Set(FlashSessionKey, "Too many failed login attempts. Please try again later or reset your password.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		case errors.As(err, &banErr):
+			app.clearPendingTwoFactor(r)
+			app.renderSuspended(w, r, banErr)
+		case errors.Is(err, entities.ErrNoRecord):
+			app.clearPendingTwoFactor(r)
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		default:
+			app.Logger.Error("verify two factor code", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	app.clearPendingTwoFactor(r)
+	app.completeLogin(w, r, user)
+}
+
+func (app *Application) accountTwoFactorView(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountTwoFactorView")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	tf, err := app.Service.TwoFactor.BeginEnrollment(userID)
+	if err != nil {
+		app.Logger.Error("begin two factor enrollment", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.TwoFactor = tf
+	data.Form = app.Service.TwoFactor.NewTwoFactorForm()
+
+	app.render(w, http.StatusOK, "twofactor.html", data)
+}
+
+// accountTwoFactorQR отдаёт QR-код с otpauth:// ссылкой для приложения-аутентификатора
+func (app *Application) accountTwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountTwoFactorQR")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	uri, err := app.Service.TwoFactor.ProvisioningURI(userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("get provisioning uri", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		app.Logger.Error("encode qr code", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+func (app *Application) accountTwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountTwoFactorEnable")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.TwoFactor.NewTwoFactorForm()
+	form.Code = r.PostForm.Get("code")
+
+	codes, err := app.Service.TwoFactor.ConfirmEnrollment(userID, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			app.renderTwoFactorForm(w, r, userID, form)
+		} else if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+		} else {
+			app.Logger.Error("confirm two factor enrollment", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Flash = "Two-factor authentication is now on."
+	data.RecoveryCodes = codes
+
+	app.render(w, http.StatusOK, "recovery_codes.html", data)
+}
+
+func (app *Application) accountTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountTwoFactorDisable")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+	role, _ := sess.Get(UserRoleSessionKey).(string)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.TwoFactor.NewTwoFactorForm()
+	form.Code = r.PostForm.Get("code")
+
+	err = app.Service.TwoFactor.Disable(userID, role, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrInvalidTwoFactorCode) || errors.Is(err, entities.ErrTwoFactorRequired) {
+			app.renderTwoFactorForm(w, r, userID, form)
+		} else if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+		} else {
+			app.Logger.Error("disable two factor", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	sess.Set(FlashSessionKey, "Two-factor authentication has been turned off.")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (app *Application) accountTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	sess := app.SessionFromContext(r)
+	userID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || userID < 1 {
+		err := errors.New("get userID in accountTwoFactorRecoveryCodes")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return
+	}
+
+	form := app.Service.TwoFactor.NewTwoFactorForm()
+	form.Code = r.PostForm.Get("code")
+
+	codes, err := app.Service.TwoFactor.RegenerateRecoveryCodes(userID, &form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			app.renderTwoFactorForm(w, r, userID, form)
+		} else if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusBadRequest, Errorpage, nil)
+		} else {
+			app.Logger.Error("regenerate recovery codes", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Flash = "New recovery codes have been generated. The old ones no longer work."
+	data.RecoveryCodes = codes
+
+	app.render(w, http.StatusOK, "recovery_codes.html", data)
+}
+
+func (app *Application) renderTwoFactorForm(w http.ResponseWriter, r *http.Request, userID int, form service.TwoFactorForm) {
+	tf, err := app.Service.TwoFactor.GetTwoFactor(userID)
+	if err != nil {
+		app.Logger.Error("get two factor", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.TwoFactor = tf
+	data.Form = form
+	app.render(w, http.StatusUnprocessableEntity, "twofactor.html", data)
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"forum/internal/entities"
+	"forum/internal/mailer"
+	"forum/pkg/validator"
+)
+
+func (app *Application) administrationUsersView(w http.ResponseWriter, r *http.Request) {
+	page := 1
+	pageSize := 20
+
+	query := r.URL.Query()
+	if p, err := validator.ValidateID(query.Get("page")); err == nil {
+		page = p
+	}
+
+	form := app.Service.UserAdmin.NewUserFilterForm()
+	form.Query = query.Get("q")
+	form.Role = query.Get("role")
+	form.JoinedFrom = query.Get("joined_from")
+	form.JoinedTo = query.Get("joined_to")
+	form.Activity = query.Get("activity")
+
+	roles, err := app.Service.Role.ListRoles()
+	if err != nil {
+		app.Logger.Error("list roles", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Roles = roles
+
+	usersDTO, err := app.Service.UserAdmin.SearchUsers(&form, page, pageSize, "/administration/users")
+	data.Form = form
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) {
+			app.render(w, http.StatusUnprocessableEntity, "users.html", data)
+		} else {
+			app.Logger.Error("search users", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data.UserOverviews = usersDTO.Users
+	data.Pagination = pagination{
+		CurrentPage:      usersDTO.CurrentPage,
+		HasNextPage:      usersDTO.HasNextPage,
+		PaginationAction: usersDTO.PaginationURL,
+	}
+	app.render(w, http.StatusOK, "users.html", data)
+}
+
+func (app *Application) administrationUserView(w http.ResponseWriter, r *http.Request) {
+	userID, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return
+	}
+
+	detail, err := app.Service.UserAdmin.GetUserDetail(userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			app.render(w, http.StatusNotFound, Errorpage, nil)
+		} else {
+			app.Logger.Error("get user detail", "error", err)
+			app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.UserOverview = detail.User
+	data.ModerationActions = detail.Actions
+	data.Roles = detail.Roles
+	data.Form = app.Service.Ban.NewBanForm()
+	app.render(w, http.StatusOK, "user_detail.html", data)
+}
+
+func (app *Application) administrationUserRole(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := app.userActionIDs(w, r)
+	if !ok {
+		return
+	}
+
+	role := r.PostForm.Get("role")
+	err := app.Service.Role.AssignRole(actorID, userID, role)
+	if err != nil {
+		app.userActionError(w, r, userID, err)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "User #"+strconv.Itoa(userID)+" now has the role \""+role+"\".")
+	http.Redirect(w, r, userAdminURL(userID), http.StatusSeeOther)
+}
+
+func (app *Application) administrationUserPasswordReset(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := app.userActionIDs(w, r)
+	if !ok {
+		return
+	}
+
+	user, token, err := app.Service.UserAdmin.ResetPassword(actorID, userID)
+	if err != nil {
+		app.userActionError(w, r, userID, err)
+		return
+	}
+
+	// Старый пароль больше не действует, поэтому и сессии с ним тоже. Токены
+	// доступа отозвал сервис.
+	err = app.SessionManager.DestroyAllUserSessions(userID)
+	if err != nil {
+		app.Logger.Error("destroy sessions after password reset", "user_id", userID, "error", err)
+	}
+
+	link := app.absoluteURL("/user/password/reset", url.Values{"token": {token}})
+	app.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Forum password has been reset",
+		Body: "Hi " + user.Username + ",\r\n\r\n" +
+			"An administrator has reset the password for your Forum account, signed you out everywhere and revoked your access tokens. " +
+			"Open the link below within 24 hours to choose a new password:\r\n\r\n" +
+			link,
+	})
+
+	app.Logger.Warn("password reset by staff", "user_id", userID, "by", actorID)
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "The password has been reset and a link to choose a new one was sent to "+user.Email+".")
+	http.Redirect(w, r, userAdminURL(userID), http.StatusSeeOther)
+}
+
+func (app *Application) administrationUserLogout(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := app.userActionIDs(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Service.UserAdmin.ForceLogout(actorID, userID)
+	if err != nil {
+		app.userActionError(w, r, userID, err)
+		return
+	}
+
+	err = app.SessionManager.DestroyAllUserSessions(userID)
+	if err != nil {
+		app.Logger.Error("force logout", "user_id", userID, "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	sess.Set(FlashSessionKey, "User #"+strconv.Itoa(userID)+" has been signed out everywhere and their access tokens were revoked.")
+	http.Redirect(w, r, userAdminURL(userID), http.StatusSeeOther)
+}
+
+func (app *Application) administrationUserDelete(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := app.userActionIDs(w, r)
+	if !ok {
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	if r.PostForm.Get("confirm") != "true" {
+		sess.Set(FlashSessionKey, "Tick the box to confirm that the account and all its posts should be deleted.")
+		http.Redirect(w, r, userAdminURL(userID), http.StatusSeeOther)
+		return
+	}
+
+	err := app.Service.UserAdmin.DeleteUser(actorID, userID)
+	if err != nil {
+		app.userActionError(w, r, userID, err)
+		return
+	}
+
+	err = app.SessionManager.DestroyAllUserSessions(userID)
+	if err != nil {
+		app.Logger.Error("destroy sessions of deleted user", "user_id", userID, "error", err)
+	}
+
+	app.Logger.Warn("user deleted", "user_id", userID, "by", actorID)
+
+	sess.Set(FlashSessionKey, "User #"+strconv.Itoa(userID)+" has been deleted.")
+	http.Redirect(w, r, "/administration/users", http.StatusSeeOther)
+}
+
+// userActionIDs разбирает форму действия над пользователем и возвращает ID
+// сотрудника и пользователя. При ошибке ответ уже отправлен.
+func (app *Application) userActionIDs(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	sess := app.SessionFromContext(r)
+	actorID, ok := sess.Get(AuthUserIDSessionKey).(int)
+	if !ok || actorID < 1 {
+		err := errors.New("get userID in user action")
+		app.Logger.Error("get userid from session", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return 0, 0, false
+	}
+
+	userID, err := validator.ValidateID(r.PathValue("id"))
+	if err != nil {
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+		return 0, 0, false
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.render(w, http.StatusBadRequest, Errorpage, nil)
+		return 0, 0, false
+	}
+
+	return actorID, userID, true
+}
+
+func (app *Application) userActionError(w http.ResponseWriter, r *http.Request, userID int, err error) {
+	switch {
+	case errors.Is(err, entities.ErrNoRecord):
+		app.render(w, http.StatusNotFound, Errorpage, nil)
+	case errors.Is(err, entities.ErrForbidden):
+		sess := app.SessionFromContext(r)
+		sess.Set(FlashSessionKey, "You cannot do this to your own account, and only administrators can manage staff members.")
+		http.Redirect(w, r, userAdminURL(userID), http.StatusSeeOther)
+	default:
+		app.Logger.Error("user action", "user_id", userID, "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+	}
+}
+
+func userAdminURL(userID int) string {
+	return "/administration/users/" + strconv.Itoa(userID)
+}
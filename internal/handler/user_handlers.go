@@ -4,8 +4,10 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"forum/internal/entities"
+	"forum/internal/session"
 	"forum/pkg/validator"
 )
 
@@ -59,11 +61,12 @@ func (app *Application) userSignup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := app.Service.User.NewUserAuthForm()
-	form.Username = r.PostForm.Get("username")
+	form.Username = validator.Normalize(r.PostForm.Get("username"))
 	form.Email = r.PostForm.Get("email")
 	form.Password = r.PostForm.Get("password")
 
 	form.CheckField(validator.Matches(form.Username, validator.UsernameRX), "username", "This field must be a valid username")
+	form.CheckField(validator.SingleScript(form.Username), "username", "Letters from different alphabets cannot be mixed")
 	form.CheckField(validator.NotBlank(form.Username), "username", "This field cannot be blank")
 	form.CheckField(validator.MaxChars(form.Username, 100), "username", "This field cannot be more than 100 characters long")
 
@@ -85,12 +88,14 @@ func (app *Application) userSignup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = app.Service.User.Insert(form.Username, form.Email, form.Password, entities.RoleUser)
+	userID, err := app.Service.User.Insert(form.Username, form.Email, form.Password, entities.RoleUser)
 	if err != nil {
 		if errors.Is(err, entities.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 		} else if errors.Is(err, entities.ErrDuplicateUsername) {
 			form.AddFieldError("username", "Username is already in use")
+		} else if errors.Is(err, entities.ErrSimilarUsername) {
+			form.AddFieldError("username", "Username looks too much like an existing one")
 		} else {
 			app.Logger.Error("insert user credentials", "error", err)
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
@@ -109,7 +114,12 @@ func (app *Application) userSignup(w http.ResponseWriter, r *http.Request) {
 		app.Logger.Error("Session error during delete csrfToken", "error", err)
 	}
 
-	err = sess.Set(FlashSessionKey, "Your signup was successful. Please log in.")
+	err = app.sendEmailVerification(userID)
+	if err != nil {
+		app.Logger.Error("send email verification", "error", err)
+	}
+
+	err = sess.Set(FlashSessionKey, "Your signup was successful. We've sent a confirmation link to your email. Please log in.")
 	if err != nil {
 		app.Logger.Error("set flashsessionkey", "error", err)
 		// app.render(w, http.StatusInternalServerError, Errorpage, nil)
@@ -160,14 +170,32 @@ func (app *Application) userLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Check whether the credentials are valid. If they're not, add a generic
 	// non-field error message and re-display the login page.
-	user, err := app.Service.User.Authenticate(form.Email, form.Password)
+	user, err := app.Service.User.Login(form.Email, form.Password, session.ClientIP(r))
 	if err != nil {
-		if errors.Is(err, entities.ErrInvalidCredentials) {
+		var banErr *entities.BanError
+		if errors.As(err, &banErr) {
+			app.renderSuspended(w, r, banErr)
+			return
+		} else if errors.Is(err, entities.ErrInvalidCredentials) {
 			form.AddNonFieldError("Email or password is incorrect")
 			data := app.newTemplateData(r)
 			data.Form = form
 			app.render(w, http.StatusUnprocessableEntity, "login.html", data)
 			return
+		} else if errors.Is(err, entities.ErrAccountLocked) || errors.Is(err, entities.ErrTooManyAttempts) {
+			var lockErr *entities.LockoutError
+			if errors.As(err, &lockErr) && lockErr.Notify {
+				app.Logger.Warn("account locked", "user_id", lockErr.User.ID, "ip", session.ClientIP(r), "until", lockErr.Until)
+				app.sendAccountLockedEmail(lockErr)
+			} else if errors.Is(err, entities.ErrTooManyAttempts) {
+				app.Logger.Warn("too many failed logins from ip", "ip", session.ClientIP(r))
+			}
+
+			form.AddNonFieldError("Too many failed login attempts. Please try again later or reset your password.")
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, http.StatusTooManyRequests, "login.html", data)
+			return
 		} else {
 			app.Logger.Error("get id Authenticate user", "error", err)
 			app.render(w, http.StatusInternalServerError, Errorpage, nil)
@@ -175,9 +203,46 @@ func (app *Application) userLogin(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	app.startLogin(w, r, user)
+}
+
+// startLogin отправляет пользователя с включённой 2FA на ввод кода,
+// остальных сразу пускает на сайт.
+func (app *Application) startLogin(w http.ResponseWriter, r *http.Request, user *entities.User) {
+	enabled, err := app.Service.TwoFactor.IsEnabled(user.ID)
+	if err != nil {
+		app.Logger.Error("get two factor status", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	if !enabled {
+		app.completeLogin(w, r, user)
+		return
+	}
+
+	sess := app.SessionFromContext(r)
+	err = sess.Set(PendingTwoFactorUserIDSessionKey, user.ID)
+	if err != nil {
+		app.Logger.Error("set PendingTwoFactorUserIDSessionKey", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	err = sess.Set(PendingTwoFactorExpirySessionKey, time.Now().Add(twoFactorLoginTimeout).Unix())
+	if err != nil {
+		app.Logger.Error("set PendingTwoFactorExpirySessionKey", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+}
+
+func (app *Application) completeLogin(w http.ResponseWriter, r *http.Request, user *entities.User) {
 	sess := app.SessionFromContext(r)
 	// Если валидация прошла успешно, удаляем токен из сессии
-	err = sess.Delete(CsrfTokenSessionKey)
+	err := sess.Delete(CsrfTokenSessionKey)
 	if err != nil {
 		app.Logger.Error("Session error during delete csrfToken", "error", err)
 	}
@@ -205,10 +270,25 @@ func (app *Application) userLogin(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			app.Logger.Error("Session error during delete redirectPath", "error", err)
 		}
-		redirectUrl = path
+		// Пустой Referer дал бы относительный редирект на текущий путь
+		if path != "" {
+			redirectUrl = path
+		}
 	}
 
-	err = sess.Set(FlashSessionKey, "Your log in was successful.")
+	setupRequired, err := app.Service.TwoFactor.SetupRequired(user.ID, user.Role)
+	if err != nil {
+		app.Logger.Error("check two factor requirement", "error", err)
+		app.render(w, http.StatusInternalServerError, Errorpage, nil)
+		return
+	}
+
+	if setupRequired {
+		redirectUrl = "/account/2fa"
+		err = sess.Set(FlashSessionKey, "Your role requires two-factor authentication. Please set it up to continue.")
+	} else {
+		err = sess.Set(FlashSessionKey, "Your log in was successful.")
+	}
 	if err != nil {
 		app.Logger.Error("Set FlashSessionKey", "error", err)
 		// app.render(w, http.StatusInternalServerError, Errorpage, nil)
@@ -233,6 +313,12 @@ func (app *Application) userLogout(w http.ResponseWriter, r *http.Request) {
 	}
 	sess.Set(FlashSessionKey, "You've been logged out successfully!")
 
+	// Отвязываем сессию от пользователя, чтобы она пропала из списка его устройств
+	err = app.SessionManager.RenewToken(w, r, 0)
+	if err != nil {
+		app.Logger.Error("renewtoken", "error", err)
+	}
+
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -0,0 +1,42 @@
+// Package mailer sends the forum's transactional email through a pluggable
+// transport: SMTP in production, an outbox directory in development.
+package mailer
+
+import (
+	"fmt"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string // обычный текст
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New возвращает транспорт по имени: "smtp" или "outbox"
+func New(transport string, smtpConf SMTPConfig, outboxDir string) (Mailer, error) {
+	switch transport {
+	case "smtp":
+		return NewSMTP(smtpConf), nil
+	case "outbox":
+		return NewOutbox(outboxDir, smtpConf.From)
+	default:
+		return nil, fmt.Errorf("mailer: unknown transport %q", transport)
+	}
+}
+
+// format собирает письмо в формате RFC 5322
+func format(from string, msg Message, date time.Time) []byte {
+	return []byte("From: " + from + "\r\n" +
+		"To: " + msg.To + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n" +
+		"Date: " + date.Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		msg.Body + "\r\n")
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outbox складывает письма в каталог в виде .eml файлов вместо отправки.
+// Используется при разработке и в тестах.
+type Outbox struct {
+	dir  string
+	from string
+	lock sync.Mutex
+	seq  int
+}
+
+func NewOutbox(dir, from string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Outbox{dir: dir, from: from}, nil
+}
+
+func (m *Outbox) Send(msg Message) error {
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errors.New("mailer: invalid header value")
+	}
+
+	m.lock.Lock()
+	m.seq++
+	now := time.Now()
+	name := fmt.Sprintf("%s-%04d.eml", now.Format("20060102-150405"), m.seq)
+	m.lock.Unlock()
+
+	return os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg, now), 0o600)
+}
@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type SMTP struct {
+	conf SMTPConfig
+}
+
+func NewSMTP(conf SMTPConfig) *SMTP {
+	return &SMTP{conf: conf}
+}
+
+func (m *SMTP) Send(msg Message) error {
+	// Не даём подставить дополнительные заголовки через адрес или тему
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return errors.New("mailer: invalid header value")
+	}
+
+	var auth smtp.Auth
+	if m.conf.Username != "" {
+		auth = smtp.PlainAuth("", m.conf.Username, m.conf.Password, m.conf.Host)
+	}
+
+	addr := net.JoinHostPort(m.conf.Host, m.conf.Port)
+	return smtp.SendMail(addr, auth, m.conf.From, []string{msg.To}, format(m.conf.From, msg, time.Now()))
+}
@@ -9,7 +9,7 @@ import (
 	"forum/internal/session"
 )
 
-var pder = &Provider{list: list.New(), userSessions: map[int]string{}}
+var pder = &Provider{list: list.New(), userSessions: map[int]map[string]bool{}}
 
 func init() {
 	pder.sessions = make(map[string]*list.Element, 0)
@@ -18,14 +18,17 @@ func init() {
 
 type SessionStore struct {
 	sid          string                      // unique session id
+	created      time.Time                   // creation time
 	timeAccessed time.Time                   // last access time
 	value        map[interface{}]interface{} // session value stored inside
+	userID       int                         // owner of the session, 0 if anonymous
+	client       session.Client              // ip and user agent of the owner
 }
 
 type Provider struct {
 	lock         sync.Mutex               // lock
 	sessions     map[string]*list.Element // save in memory
-	userSessions map[int]string           // userID -> sessionID
+	userSessions map[int]map[string]bool  // userID -> set of sessionIDs
 	list         *list.List               // gc
 }
 
@@ -75,31 +78,47 @@ func (st *SessionStore) SessionID() string {
 func (pder *Provider) SessionInit(sid string) (session.Session, error) {
 	pder.lock.Lock()
 	defer pder.lock.Unlock()
+	return pder.init(sid), nil
+}
+
+func (pder *Provider) init(sid string) *SessionStore {
 	v := make(map[interface{}]interface{}, 0)
-	newsess := &SessionStore{sid: sid, timeAccessed: time.Now(), value: v}
-	element := pder.list.PushBack(newsess)
+	now := time.Now()
+	newsess := &SessionStore{sid: sid, created: now, timeAccessed: now, value: v}
+	element := pder.list.PushFront(newsess)
 	pder.sessions[sid] = element
-	return newsess, nil
+	return newsess
 }
 
 func (pder *Provider) SessionRead(sid string) (session.Session, error) {
+	pder.lock.Lock()
+	defer pder.lock.Unlock()
+
 	if element, ok := pder.sessions[sid]; ok {
 		return element.Value.(*SessionStore), nil
-	} else {
-		sess, err := pder.SessionInit(sid)
-		return sess, err
 	}
+	return pder.init(sid), nil
 }
 
 func (pder *Provider) SessionDestroy(sid string) error {
-	if element, ok := pder.sessions[sid]; ok {
-		delete(pder.sessions, sid)
-		pder.list.Remove(element)
-		return nil
-	}
+	pder.lock.Lock()
+	defer pder.lock.Unlock()
+
+	pder.destroy(sid)
 	return nil
 }
 
+func (pder *Provider) destroy(sid string) {
+	element, ok := pder.sessions[sid]
+	if !ok {
+		return
+	}
+	st := element.Value.(*SessionStore)
+	pder.unbind(st)
+	delete(pder.sessions, sid)
+	pder.list.Remove(element)
+}
+
 func (pder *Provider) SessionGC(maxlifetime int64) {
 	pder.lock.Lock()
 	defer pder.lock.Unlock()
@@ -110,8 +129,7 @@ func (pder *Provider) SessionGC(maxlifetime int64) {
 			break
 		}
 		if (element.Value.(*SessionStore).timeAccessed.Unix() + maxlifetime) < time.Now().Unix() {
-			pder.list.Remove(element)
-			delete(pder.sessions, element.Value.(*SessionStore).sid)
+			pder.destroy(element.Value.(*SessionStore).sid)
 		} else {
 			break
 		}
@@ -131,17 +149,68 @@ func (pder *Provider) SessionUpdate(sid string) error {
 	return fmt.Errorf("session not found")
 }
 
-func (pder *Provider) SessionBindUser(userID int, sid string) error {
+func (pder *Provider) SessionBindUser(userID int, sid string, client session.Client) error {
+	pder.lock.Lock()
+	defer pder.lock.Unlock()
+
+	element, ok := pder.sessions[sid]
+	if !ok {
+		return fmt.Errorf("session not found")
+	}
+	st := element.Value.(*SessionStore)
+	pder.unbind(st)
+
+	if userID < 1 {
+		return nil
+	}
+
+	// Связываем сессию с пользователем, остальные его сессии не трогаем
+	st.userID = userID
+	st.client = client
+	if pder.userSessions[userID] == nil {
+		pder.userSessions[userID] = map[string]bool{}
+	}
+	pder.userSessions[userID][sid] = true
+	return nil
+}
+
+func (pder *Provider) unbind(st *SessionStore) {
+	if st.userID < 1 {
+		return
+	}
+	delete(pder.userSessions[st.userID], st.sid)
+	if len(pder.userSessions[st.userID]) == 0 {
+		delete(pder.userSessions, st.userID)
+	}
+	st.userID = 0
+}
+
+func (pder *Provider) SessionsByUser(userID int) ([]session.Info, error) {
+	pder.lock.Lock()
+	defer pder.lock.Unlock()
+
+	sessions := []session.Info{}
+	for sid := range pder.userSessions[userID] {
+		st := pder.sessions[sid].Value.(*SessionStore)
+		sessions = append(sessions, session.Info{
+			SID:        st.sid,
+			UserID:     st.userID,
+			Created:    st.created,
+			LastAccess: st.timeAccessed,
+			Client:     st.client,
+		})
+	}
+	return sessions, nil
+}
+
+func (pder *Provider) SessionDestroyUser(userID int, exceptSid string) error {
 	pder.lock.Lock()
 	defer pder.lock.Unlock()
 
-	// Уничтожаем старую сессию, если она есть
-	if oldSid, ok := pder.userSessions[userID]; ok {
-		if err := pder.SessionDestroy(oldSid); err != nil {
-			return err
+	for sid := range pder.userSessions[userID] {
+		if sid != exceptSid {
+			pder.destroy(sid)
 		}
 	}
-	// Связываем новую сессию
-	pder.userSessions[userID] = sid
 	return nil
 }
@@ -0,0 +1,325 @@
+// Package oauth собирает провайдеров внешнего входа из конфигурации и
+// проводит для них authorization code flow с PKCE и nonce.
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"forum/pkg/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const requestTimeout = 10 * time.Second
+
+var (
+	ErrUnknownProvider = errors.New("oauth: unknown provider")
+	ErrInvalidNonce    = errors.New("oauth: id token nonce mismatch")
+	ErrNoEmail         = errors.New("oauth: provider returned no email")
+	ErrNoSubject       = errors.New("oauth: provider returned no subject")
+)
+
+// UserInfo - данные пользователя, полученные от провайдера
+type UserInfo struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+type Provider struct {
+	Name        string
+	DisplayName string
+	IconURL     string
+
+	conf config.OAuthProvider
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	oidc     *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+type Registry struct {
+	providers map[string]*Provider
+	ordered   []*Provider
+}
+
+func NewRegistry(providers []config.OAuthProvider) *Registry {
+	r := &Registry{providers: make(map[string]*Provider)}
+	for _, conf := range providers {
+		p := &Provider{
+			Name:        conf.Name,
+			DisplayName: conf.DisplayName,
+			IconURL:     conf.IconURL,
+			conf:        conf,
+		}
+		r.providers[p.Name] = p
+		r.ordered = append(r.ordered, p)
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (*Provider, error) {
+	if r == nil {
+		return nil, ErrUnknownProvider
+	}
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// List возвращает провайдеров в порядке из OAUTH_PROVIDERS
+func (r *Registry) List() []*Provider {
+	if r == nil {
+		return nil
+	}
+	return r.ordered
+}
+
+func (p *Provider) isOIDC() bool {
+	return p.conf.Issuer != ""
+}
+
+// config лениво настраивает провайдера. Discovery для OIDC выполняется при
+// первом входе, а не на старте, чтобы недоступный issuer не мешал запуску
+// сайта; при ошибке попытка повторится на следующем запросе.
+func (p *Provider) config(ctx context.Context) (*oauth2.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 != nil {
+		return p.oauth2, nil
+	}
+
+	endpoint := oauth2.Endpoint{
+		AuthURL:  p.conf.AuthURL,
+		TokenURL: p.conf.TokenURL,
+	}
+
+	if p.isOIDC() {
+		provider, err := oidc.NewProvider(ctx, p.conf.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("oauth: discovery for %s: %w", p.Name, err)
+		}
+		p.oidc = provider
+		p.verifier = provider.Verifier(&oidc.Config{ClientID: p.conf.ClientID})
+		endpoint = provider.Endpoint()
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.conf.ClientID,
+		ClientSecret: p.conf.ClientSecret,
+		RedirectURL:  p.conf.CallbackURL,
+		Scopes:       p.conf.Scopes,
+		Endpoint:     endpoint,
+	}
+	return p.oauth2, nil
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера. state, nonce и
+// verifier нужно сохранить в сессии и передать потом в Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	conf, err := p.config(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier)}
+	if p.isOIDC() {
+		opts = append(opts, oidc.Nonce(nonce))
+	}
+	return conf.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange меняет код на токен и достаёт из ответа провайдера данные пользователя
+func (p *Provider) Exchange(code, nonce, verifier string) (*UserInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	conf, err := p.config(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oauth: exchange code: %w", err)
+	}
+
+	var claims map[string]any
+	if p.isOIDC() {
+		claims, err = p.idTokenClaims(ctx, token, nonce)
+	} else {
+		claims, err = fetchJSON(ctx, conf.Client(ctx, token), p.conf.UserInfoURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &UserInfo{
+		Provider:      p.Name,
+		Subject:       claimString(claims, p.conf.SubjectField),
+		Email:         claimString(claims, p.conf.EmailField),
+		EmailVerified: claimBool(claims, p.conf.EmailVerifiedField),
+		Name:          claimString(claims, p.conf.NameField),
+		Username:      claimString(claims, p.conf.UsernameField),
+	}
+
+	// Без subject все входы через провайдера свелись бы к одной учётной записи
+	if info.Subject == "" {
+		return nil, ErrNoSubject
+	}
+
+	if (info.Email == "" || !info.EmailVerified) && p.conf.EmailsURL != "" {
+		info.Email, info.EmailVerified, err = fetchPrimaryEmail(ctx, conf.Client(ctx, token), p.conf.EmailsURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if info.Email == "" {
+		return nil, ErrNoEmail
+	}
+	return info, nil
+}
+
+// idTokenClaims проверяет подпись, издателя, аудиторию, срок и nonce ID-токена.
+// Если в токене нет email, он запрашивается через userinfo endpoint.
+func (p *Provider) idTokenClaims(ctx context.Context, token *oauth2.Token, nonce string) (map[string]any, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oauth: no id_token in token response")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: verify id token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidNonce
+	}
+
+	claims := map[string]any{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if claimString(claims, p.conf.EmailField) == "" {
+		userInfo, err := p.oidc.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("oauth: fetch userinfo: %w", err)
+		}
+		if userInfo.Subject != idToken.Subject {
+			return nil, errors.New("oauth: userinfo subject mismatch")
+		}
+		err = userInfo.Claims(&claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+func fetchJSON(ctx context.Context, client *http.Client, url string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth: GET %s: %s", url, resp.Status)
+	}
+
+	claims := map[string]any{}
+	err = json.NewDecoder(resp.Body).Decode(&claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// fetchPrimaryEmail читает список адресов в формате GitHub и возвращает основной подтверждённый
+func fetchPrimaryEmail(ctx context.Context, client *http.Client, url string) (string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("oauth: GET %s: %s", url, resp.Status)
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Verified bool   `json:"verified"`
+		Primary  bool   `json:"primary"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&emails)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, email := range emails {
+		if email.Primary && email.Verified {
+			return email.Email, true, nil
+		}
+	}
+
+	return "", false, ErrNoEmail
+}
+
+func claimString(claims map[string]any, key string) string {
+	switch v := claims[key].(type) {
+	case string:
+		return v
+	case float64:
+		// числовые идентификаторы, например id у GitHub
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+func claimBool(claims map[string]any, key string) bool {
+	switch v := claims[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	default:
+		return false
+	}
+}
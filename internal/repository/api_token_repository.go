@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type APITokenSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewAPITokenSqlite3(db *sql.DB) *APITokenSqlite3 {
+	return &APITokenSqlite3{
+		DB: db,
+	}
+}
+
+// InsertAPIToken сохраняет токен. Нулевой expiry - бессрочный токен.
+func (r *APITokenSqlite3) InsertAPIToken(userID int, name, hash, prefix string, scopes []string, expiry time.Time) (int, error) {
+	var exp sql.NullString
+	if !expiry.IsZero() {
+		exp = sql.NullString{String: expiry.UTC().Format("2006-01-02 15:04:05"), Valid: true}
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, hash, prefix, scopes, expiry, created)
+	VALUES (?, ?, ?, ?, ?, ?, datetime('now'))`
+	result, err := r.DB.Exec(stmt, userID, name, hash, prefix, strings.Join(scopes, " "), exp)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetActiveAPIToken ищет неистёкший токен по хэшу
+func (r *APITokenSqlite3) GetActiveAPIToken(hash string) (*entities.APIToken, error) {
+	stmt := `SELECT id, user_id, name, prefix, scopes, expiry, last_used, created FROM api_tokens
+	WHERE hash = ? AND (expiry IS NULL OR expiry > datetime('now'))`
+
+	t, err := scanAPIToken(r.DB.QueryRow(stmt, hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// TouchAPIToken обновляет время последнего использования не чаще раза в минуту
+func (r *APITokenSqlite3) TouchAPIToken(id int) error {
+	stmt := `UPDATE api_tokens SET last_used = datetime('now')
+	WHERE id = ? AND (last_used IS NULL OR last_used < datetime('now', '-60 seconds'))`
+	_, err := r.DB.Exec(stmt, id)
+	return err
+}
+
+func (r *APITokenSqlite3) ListAPITokens(userID int) ([]*entities.APIToken, error) {
+	stmt := `SELECT id, user_id, name, prefix, scopes, expiry, last_used, created FROM api_tokens
+	WHERE user_id = ? ORDER BY id DESC`
+	rows, err := r.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*entities.APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *APITokenSqlite3) DeleteAPIToken(userID, id int) error {
+	result, err := r.DB.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteUserAPITokens отзывает все токены пользователя и возвращает их число
+func (r *APITokenSqlite3) DeleteUserAPITokens(userID int) (int, error) {
+	result, err := r.DB.Exec("DELETE FROM api_tokens WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	return int(rows), err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIToken(row rowScanner) (*entities.APIToken, error) {
+	t := &entities.APIToken{}
+	var scopes, created string
+	var expiry, lastUsed sql.NullString
+
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, &scopes, &expiry, &lastUsed, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Scopes = strings.Fields(scopes)
+	if t.Expiry, err = formatNullTime(expiry); err != nil {
+		return nil, err
+	}
+	if t.LastUsed, err = formatNullTime(lastUsed); err != nil {
+		return nil, err
+	}
+	if t.Created, err = formatNullTime(sql.NullString{String: created, Valid: true}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type AuditSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewAuditSqlite3(db *sql.DB) *AuditSqlite3 {
+	return &AuditSqlite3{
+		DB: db,
+	}
+}
+
+func (r *AuditSqlite3) InsertModerationAction(a *entities.ModerationAction) error {
+	stmt := `INSERT INTO moderation_actions (actor_id, action, target_type, target_id, target_user_id,
+		target_name, reason, before_state, after_state, created)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))`
+	_, err := r.DB.Exec(stmt, a.ActorID, a.Action, a.TargetType, a.TargetID, a.TargetUserID,
+		a.TargetName, a.Reason, a.Before, a.After)
+	return err
+}
+
+// ListModerationActions возвращает записи журнала по фильтру, новые первыми
+func (r *AuditSqlite3) ListModerationActions(filter *entities.AuditFilter, limit, offset int) ([]*entities.ModerationAction, error) {
+	var conditions []string
+	var args []any
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "u.username = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "m.action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "m.target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		conditions = append(conditions, "m.target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.TargetUserID != 0 {
+		conditions = append(conditions, "m.target_user_id = ?")
+		args = append(args, filter.TargetUserID)
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "m.created >= date(?)")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "m.created < date(?, '+1 day')")
+		args = append(args, filter.To)
+	}
+
+	stmt := `SELECT m.id, m.actor_id, COALESCE(u.username, ''), m.action, m.target_type, m.target_id,
+		m.target_user_id, m.target_name, m.reason, m.before_state, m.after_state, m.created
+	FROM moderation_actions m
+	LEFT JOIN users u ON u.id = m.actor_id`
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	stmt += " ORDER BY m.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := r.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []*entities.ModerationAction
+	for rows.Next() {
+		a := &entities.ModerationAction{}
+		var created string
+
+		err := rows.Scan(&a.ID, &a.ActorID, &a.ActorName, &a.Action, &a.TargetType, &a.TargetID,
+			&a.TargetUserID, &a.TargetName, &a.Reason, &a.Before, &a.After, &created)
+		if err != nil {
+			return nil, err
+		}
+
+		createdTime, err := time.Parse("2006-01-02 15:04:05", created)
+		if err != nil {
+			return nil, err
+		}
+		a.Created = createdTime.Format(time.RFC3339)
+
+		actions = append(actions, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
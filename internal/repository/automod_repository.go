@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type AutomodSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewAutomodSqlite3(db *sql.DB) *AutomodSqlite3 {
+	return &AutomodSqlite3{
+		DB: db,
+	}
+}
+
+// GetRules возвращает все правила в порядке создания вместе с числом срабатываний
+func (r *AutomodSqlite3) GetRules() ([]*entities.AutomodRule, error) {
+	stmt := `SELECT r.id, r.name, r.target, r.condition, r.value, r.action, r.enabled, r.created,
+		(SELECT COUNT(*) FROM automod_hits h WHERE h.rule_id = r.id)
+	FROM automod_rules r
+	ORDER BY r.id`
+
+	rows, err := r.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*entities.AutomodRule{}
+	for rows.Next() {
+		rule := &entities.AutomodRule{}
+		var created string
+
+		err := rows.Scan(&rule.ID, &rule.Name, &rule.Target, &rule.Condition, &rule.Value, &rule.Action,
+			&rule.Enabled, &created, &rule.Hits)
+		if err != nil {
+			return nil, err
+		}
+		if rule.Created, err = formatReportTime(created); err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *AutomodSqlite3) GetRule(id int) (*entities.AutomodRule, error) {
+	stmt := `SELECT id, name, target, condition, value, action, enabled, created
+	FROM automod_rules WHERE id = ?`
+
+	rule := &entities.AutomodRule{}
+	var created string
+
+	err := r.DB.QueryRow(stmt, id).Scan(&rule.ID, &rule.Name, &rule.Target, &rule.Condition, &rule.Value,
+		&rule.Action, &rule.Enabled, &created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	if rule.Created, err = formatReportTime(created); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *AutomodSqlite3) InsertRule(rule *entities.AutomodRule) (int, error) {
+	stmt := `INSERT INTO automod_rules (name, target, condition, value, action, enabled, created)
+	VALUES (?, ?, ?, ?, ?, ?, datetime('now'))`
+	result, err := r.DB.Exec(stmt, rule.Name, rule.Target, rule.Condition, rule.Value, rule.Action, rule.Enabled)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func (r *AutomodSqlite3) UpdateRule(rule *entities.AutomodRule) error {
+	stmt := `UPDATE automod_rules SET name = ?, target = ?, condition = ?, value = ?, action = ?, enabled = ?
+	WHERE id = ?`
+	result, err := r.DB.Exec(stmt, rule.Name, rule.Target, rule.Condition, rule.Value, rule.Action, rule.Enabled, rule.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteRule удаляет правило; журнал его срабатываний остаётся
+func (r *AutomodSqlite3) DeleteRule(id int) error {
+	result, err := r.DB.Exec("DELETE FROM automod_rules WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+func (r *AutomodSqlite3) InsertHit(hit *entities.AutomodHit) error {
+	stmt := `INSERT INTO automod_hits (rule_id, rule_name, target_type, target_id, user_id, action, matched, created)
+	VALUES (?, ?, ?, ?, ?, ?, ?, datetime('now'))`
+	_, err := r.DB.Exec(stmt, hit.RuleID, hit.RuleName, hit.TargetType, hit.TargetID, hit.UserID, hit.Action, hit.Matched)
+	return err
+}
+
+// GetHits возвращает срабатывания правил, новые первыми. ruleID 0 - все правила.
+func (r *AutomodSqlite3) GetHits(ruleID, limit, offset int) ([]*entities.AutomodHit, error) {
+	stmt := `SELECT h.id, h.rule_id, h.rule_name, h.target_type, h.target_id, h.user_id, COALESCE(u.username, ''),
+		h.action, h.matched, h.created
+	FROM automod_hits h
+	LEFT JOIN users u ON u.id = h.user_id
+	WHERE ? = 0 OR h.rule_id = ?
+	ORDER BY h.id DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := r.DB.Query(stmt, ruleID, ruleID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []*entities.AutomodHit{}
+	for rows.Next() {
+		hit := &entities.AutomodHit{}
+		var created string
+
+		err := rows.Scan(&hit.ID, &hit.RuleID, &hit.RuleName, &hit.TargetType, &hit.TargetID, &hit.UserID,
+			&hit.UserName, &hit.Action, &hit.Matched, &created)
+		if err != nil {
+			return nil, err
+		}
+		if hit.Created, err = formatReportTime(created); err != nil {
+			return nil, err
+		}
+
+		hits = append(hits, hit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// GetAuthorStats собирает сведения об авторе для условий правил
+func (r *AutomodSqlite3) GetAuthorStats(userID int) (*entities.AuthorStats, error) {
+	stmt := `SELECT u.created,
+		(SELECT COUNT(*) FROM posts WHERE user_id = u.id AND is_approved = true),
+		(SELECT COUNT(*) FROM reports WHERE target_user_id = u.id AND created >= datetime('now', '-1 hour'))
+	FROM users u WHERE u.id = ?`
+
+	stats := &entities.AuthorStats{}
+	var created string
+
+	err := r.DB.QueryRow(stmt, userID).Scan(&created, &stats.ApprovedPosts, &stats.ReportsLastHour)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	t, err := time.Parse("2006-01-02 15:04:05", created)
+	if err != nil {
+		return nil, err
+	}
+	stats.AccountAgeHours = int(time.Since(t).Hours())
+	return stats, nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"forum/internal/entities"
+)
+
+// Условие действующей блокировки: не снята и не истекла
+const activeBanCondition = "lifted IS NULL AND (expires IS NULL OR expires > datetime('now'))"
+
+// Подзапрос: пользователи под действующим теневым баном
+const shadowBannedUsers = "SELECT user_id FROM user_bans WHERE shadow = true AND " + activeBanCondition
+
+// visibleAuthor - условие для списков: автор записи в column не под теневым
+// баном или сам смотрит на свои записи. Ждёт один параметр - ID смотрящего.
+func visibleAuthor(column string) string {
+	return "(" + column + " = ? OR " + column + " NOT IN (" + shadowBannedUsers + "))"
+}
+
+type BanSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewBanSqlite3(db *sql.DB) *BanSqlite3 {
+	return &BanSqlite3{
+		DB: db,
+	}
+}
+
+// InsertBan блокирует пользователя. Нулевой expires - бессрочно.
+// Действующая блокировка того же вида, если она есть, снимается и заменяется
+// новой. Блокировки другого вида остаются: теневой бан не должен снимать
+// обычный, иначе заблокированный снова сможет войти.
+func (r *BanSqlite3) InsertBan(userID, bannedBy int, reason string, expires time.Time, shadow bool) (int, error) {
+	var exp sql.NullString
+	if !expires.IsZero() {
+		exp = sql.NullString{String: expires.UTC().Format("2006-01-02 15:04:05"), Valid: true}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE user_bans SET lifted = datetime('now'), lifted_by = ?
+	WHERE user_id = ? AND shadow = ? AND ` + activeBanCondition
+	if _, err := tx.Exec(stmt, bannedBy, userID, shadow); err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO user_bans (user_id, banned_by, reason, created, expires, shadow)
+	VALUES (?, ?, ?, datetime('now'), ?, ?)`
+	result, err := tx.Exec(stmt, userID, bannedBy, reason, exp, shadow)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// GetActiveBan возвращает действующую блокировку, запрещающую вход. Теневой бан
+// вход не запрещает и сюда не попадает.
+func (r *BanSqlite3) GetActiveBan(userID int) (*entities.Ban, error) {
+	stmt := banSelect + " WHERE b.user_id = ? AND b.shadow = false AND " + activeBanCondition + " ORDER BY b.id DESC LIMIT 1"
+	b, err := scanBan(r.DB.QueryRow(stmt, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (r *BanSqlite3) IsShadowBanned(userID int) (bool, error) {
+	var banned bool
+	stmt := "SELECT EXISTS(" + shadowBannedUsers + " AND user_id = ?)"
+	err := r.DB.QueryRow(stmt, userID).Scan(&banned)
+	return banned, err
+}
+
+// LiftBan досрочно снимает действующую блокировку
+func (r *BanSqlite3) LiftBan(userID, liftedBy int) error {
+	stmt := `UPDATE user_bans SET lifted = datetime('now'), lifted_by = ?
+	WHERE user_id = ? AND ` + activeBanCondition
+	result, err := r.DB.Exec(stmt, liftedBy, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+// ListBans возвращает историю блокировок, новые первыми
+func (r *BanSqlite3) ListBans(limit int) ([]*entities.Ban, error) {
+	rows, err := r.DB.Query(banSelect+" ORDER BY b.id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bans []*entities.Ban
+	for rows.Next() {
+		b, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		bans = append(bans, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+const banSelect = `SELECT b.id, b.user_id, COALESCE(u.username, ''), b.banned_by, COALESCE(a.username, ''),
+	b.reason, b.created, b.expires, b.lifted, COALESCE(l.username, ''), b.shadow,
+	` + activeBanCondition + `
+	FROM user_bans b
+	LEFT JOIN users u ON u.id = b.user_id
+	LEFT JOIN users a ON a.id = b.banned_by
+	LEFT JOIN users l ON l.id = b.lifted_by`
+
+func scanBan(row rowScanner) (*entities.Ban, error) {
+	b := &entities.Ban{}
+	var created string
+	var expires, lifted sql.NullString
+
+	err := row.Scan(&b.ID, &b.UserID, &b.Username, &b.BannedBy, &b.BannedByName,
+		&b.Reason, &created, &expires, &lifted, &b.LiftedByName, &b.Shadow, &b.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	createdTime, err := time.Parse("2006-01-02 15:04:05", created)
+	if err != nil {
+		return nil, err
+	}
+	b.Created = createdTime.Format(time.RFC3339)
+
+	if b.Expires, err = formatNullTime(expires); err != nil {
+		return nil, err
+	}
+	if b.Lifted, err = formatNullTime(lifted); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forum/internal/entities"
+)
+
+func newTestBanRepo(t *testing.T) *BanSqlite3 {
+	t.Helper()
+	db, err := NewSqliteDB(filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err = InitSqliteDB(db); err != nil {
+		t.Fatal(err)
+	}
+	return NewBanSqlite3(db)
+}
+
+func TestInsertBanKeepsOtherKind(t *testing.T) {
+	// Пользователи из testdata.sql: 1 - администратор, 3 - обычный участник
+	const admin, user = 1, 3
+
+	tests := []struct {
+		name       string
+		bans       []bool // shadow каждой блокировки по порядку
+		wantBanned bool   // вход запрещён
+		wantShadow bool
+		wantActive int // действующих блокировок в итоге
+	}{
+		{"shadow after suspension", []bool{false, true}, true, true, 2},
+		{"suspension after shadow", []bool{true, false}, true, true, 2},
+		{"suspension replaces suspension", []bool{false, false}, true, false, 1},
+		{"shadow replaces shadow", []bool{true, true}, false, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestBanRepo(t)
+			for _, shadow := range tt.bans {
+				if _, err := repo.InsertBan(user, admin, "test", time.Time{}, shadow); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := repo.GetActiveBan(user)
+			switch {
+			case err == nil && !tt.wantBanned:
+				t.Error("GetActiveBan found a ban, want none")
+			case errors.Is(err, entities.ErrNoRecord) && tt.wantBanned:
+				t.Error("GetActiveBan found no ban, the suspension was lifted")
+			case err != nil && !errors.Is(err, entities.ErrNoRecord):
+				t.Fatal(err)
+			}
+
+			shadow, err := repo.IsShadowBanned(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if shadow != tt.wantShadow {
+				t.Errorf("IsShadowBanned = %v, want %v", shadow, tt.wantShadow)
+			}
+
+			var active int
+			err = repo.DB.QueryRow("SELECT COUNT(*) FROM user_bans WHERE user_id = ? AND "+activeBanCondition, user).Scan(&active)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if active != tt.wantActive {
+				t.Errorf("%d active bans, want %d", active, tt.wantActive)
+			}
+		})
+	}
+}
@@ -50,10 +50,10 @@ func (r *CommentReactionSqlite3) GetUserReaction(userID, commentID int) (*entiti
 	return commentReaction, nil
 }
 
-func (r *CommentReactionSqlite3) GetLikesCount(commentID int) (int, error) {
+func (r *CommentReactionSqlite3) GetLikesCount(commentID, viewerID int) (int, error) {
 	stmt := `SELECT COUNT(is_like) FROM comment_reactions
-	WHERE comment_id = ? AND is_like = 1`
-	row := r.DB.QueryRow(stmt, commentID)
+	WHERE comment_id = ? AND is_like = 1 AND ` + visibleAuthor("user_id")
+	row := r.DB.QueryRow(stmt, commentID, viewerID)
 
 	var likes int
 	err := row.Scan(&likes)
@@ -66,11 +66,11 @@ func (r *CommentReactionSqlite3) GetLikesCount(commentID int) (int, error) {
 	return likes, nil
 }
 
-func (r *CommentReactionSqlite3) GetDislikesCount(commentID int) (int, error) {
+func (r *CommentReactionSqlite3) GetDislikesCount(commentID, viewerID int) (int, error) {
 	stmt := `SELECT COUNT(is_like) FROM comment_reactions
-	WHERE comment_id = ? AND is_like = 0`
+	WHERE comment_id = ? AND is_like = 0 AND ` + visibleAuthor("user_id")
 	var dislikes int
-	row := r.DB.QueryRow(stmt, commentID)
+	row := r.DB.QueryRow(stmt, commentID, viewerID)
 	err := row.Scan(&dislikes)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -25,10 +25,10 @@ func (r *CommentSqlite3) Exists(id int) (bool, error) {
 	return exists, err
 }
 
-func (c *CommentSqlite3) InsertComment(postID, userID int, content string) (int, error) {
-	stmt := `INSERT INTO comments (post_id, user_id,content, created)
-	VALUES (?,?,?, datetime('now'))`
-	res, err := c.DB.Exec(stmt, postID, userID, content)
+func (c *CommentSqlite3) InsertComment(postID, userID int, content, contentHTML string) (int, error) {
+	stmt := `INSERT INTO comments (post_id, user_id,content, content_html, created)
+	VALUES (?,?,?,?, datetime('now'))`
+	res, err := c.DB.Exec(stmt, postID, userID, content, contentHTML)
 	if err != nil {
 		return 0, err
 	}
@@ -41,7 +41,7 @@ func (c *CommentSqlite3) InsertComment(postID, userID int, content string) (int,
 }
 
 func (r *CommentSqlite3) GetUserCommentsByPosts(postId, userId int) ([]*entities.Comment, error) {
-	stmt := `SELECT c.id, post_id, c.user_id, username, content, c.created, role
+	stmt := `SELECT c.id, post_id, c.user_id, username, content, content_html, c.created, c.hidden, c.edited, role
 	FROM comments as c INNER JOIN users as u ON c.user_id = u.id
 	WHERE u.id = ? AND c.post_id = ?`
 	rows, err := r.DB.Query(stmt, userId, postId)
@@ -54,8 +54,12 @@ func (r *CommentSqlite3) GetUserCommentsByPosts(postId, userId int) ([]*entities
 	for rows.Next() {
 		comment := &entities.Comment{}
 		var created string
+		var edited sql.NullString
 
-		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.UserName, &comment.Content, &created, &comment.UserRole); err != nil {
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.UserName, &comment.Content, &comment.ContentHTML, &created, &comment.Hidden, &edited, &comment.UserRole); err != nil {
+			return nil, err
+		}
+		if comment.Edited, err = formatNullTime(edited); err != nil {
 			return nil, err
 		}
 
@@ -74,13 +78,15 @@ func (r *CommentSqlite3) GetUserCommentsByPosts(postId, userId int) ([]*entities
 	return comments, nil
 }
 
-func (c *CommentSqlite3) GetComments(postID int) ([]*entities.Comment, error) {
-	stmt := `SELECT comments.id, post_id, username, comments.user_id, content, comments.created  
+// GetComments возвращает комментарии к посту, которые видит viewerID.
+// Скрытые автомодерацией комментарии видит только их автор.
+func (c *CommentSqlite3) GetComments(postID, viewerID int) ([]*entities.Comment, error) {
+	stmt := `SELECT comments.id, post_id, username, comments.user_id, content, content_html, comments.created, comments.hidden, comments.edited
 	FROM comments LEFT JOIN users ON users.id = comments.user_id
-	WHERE post_id = ?
+	WHERE post_id = ? AND ` + visibleAuthor("comments.user_id") + ` AND (comments.hidden = false OR comments.user_id = ?)
 	ORDER BY comments.created DESC`
 
-	rows, err := c.DB.Query(stmt, postID)
+	rows, err := c.DB.Query(stmt, postID, viewerID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -90,9 +96,12 @@ func (c *CommentSqlite3) GetComments(postID int) ([]*entities.Comment, error) {
 	for rows.Next() {
 		comment := &entities.Comment{}
 		var created string
-		var username sql.NullString
+		var username, edited sql.NullString
 
-		if err := rows.Scan(&comment.ID, &comment.PostID, &username, &comment.UserID, &comment.Content, &created); err != nil {
+		if err := rows.Scan(&comment.ID, &comment.PostID, &username, &comment.UserID, &comment.Content, &comment.ContentHTML, &created, &comment.Hidden, &edited); err != nil {
+			return nil, err
+		}
+		if comment.Edited, err = formatNullTime(edited); err != nil {
 			return nil, err
 		}
 		if username.Valid {
@@ -126,16 +135,17 @@ func (c *CommentSqlite3) DeleteComment(commentID int) error {
 		return err
 	}
 
-	return nil
+	_, err = c.DB.Exec("DELETE FROM revisions WHERE target_type = 'comment' AND target_id = ?", commentID)
+	return err
 }
 
-func (c *CommentSqlite3) UpdateComment(commentID int, content string) error {
+func (c *CommentSqlite3) UpdateComment(commentID int, content, contentHTML string) error {
 	stmt := `
 	UPDATE comments
-	SET content = ?
+	SET content = ?, content_html = ?, edited = datetime('now')
 	WHERE id = ?
 	`
-	_, err := c.DB.Exec(stmt, content, commentID)
+	_, err := c.DB.Exec(stmt, content, contentHTML, commentID)
 	if err != nil {
 		return err
 	}
@@ -144,14 +154,16 @@ func (c *CommentSqlite3) UpdateComment(commentID int, content string) error {
 }
 
 func (r *CommentSqlite3) GetComment(commentId int) (*entities.Comment, error) {
-	stmt := `SELECT id, post_id, user_id, content FROM comments
+	stmt := `SELECT id, post_id, user_id, content, created, hidden, edited FROM comments
 	WHERE id = ?
 	`
 
 	row := r.DB.QueryRow(stmt, commentId)
 	c := &entities.Comment{}
+	var created string
+	var edited sql.NullString
 
-	err := row.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content)
+	err := row.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &created, &c.Hidden, &edited)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, entities.ErrNoRecord
@@ -159,5 +171,17 @@ func (r *CommentSqlite3) GetComment(commentId int) (*entities.Comment, error) {
 			return nil, err
 		}
 	}
+	if c.Created, err = formatReportTime(created); err != nil {
+		return nil, err
+	}
+	if c.Edited, err = formatNullTime(edited); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
+
+// SetCommentHidden скрывает комментарий до проверки модератором или показывает его снова
+func (c *CommentSqlite3) SetCommentHidden(commentID int, hidden bool) error {
+	_, err := c.DB.Exec("UPDATE comments SET hidden = ? WHERE id = ?", hidden, commentID)
+	return err
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type DraftSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewDraftSqlite3(db *sql.DB) *DraftSqlite3 {
+	return &DraftSqlite3{
+		DB: db,
+	}
+}
+
+func (r *DraftSqlite3) InsertDraft(draft *entities.Draft) (int, error) {
+	publishAt, err := parseNullTime(draft.PublishAt)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO drafts (user_id, title, content, categories, publish_at, created, updated)
+	VALUES (?, ?, ?, ?, ?, datetime('now'), datetime('now'))`
+
+	result, err := r.DB.Exec(stmt, draft.UserID, draft.Title, draft.Content, joinIDs(draft.CategoryIDs), publishAt)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// UpdateDraft сохраняет черновик и сбрасывает прошлую ошибку публикации.
+// ErrNoRecord - черновика уже нет, например, его только что опубликовал планировщик.
+func (r *DraftSqlite3) UpdateDraft(draft *entities.Draft) error {
+	publishAt, err := parseNullTime(draft.PublishAt)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE drafts SET title = ?, content = ?, categories = ?, publish_at = ?, publish_error = '', updated = datetime('now')
+	WHERE id = ?`
+
+	result, err := r.DB.Exec(stmt, draft.Title, draft.Content, joinIDs(draft.CategoryIDs), publishAt, draft.ID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+func (r *DraftSqlite3) GetDraft(id int) (*entities.Draft, error) {
+	draft, err := scanDraft(r.DB.QueryRow(draftSelect+` WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+	return draft, nil
+}
+
+// GetUserDrafts возвращает черновики автора, последние изменённые - первыми
+func (r *DraftSqlite3) GetUserDrafts(userID int) ([]*entities.Draft, error) {
+	return r.queryDrafts(draftSelect+` WHERE user_id = ? ORDER BY updated DESC, id DESC`, userID)
+}
+
+// GetDueDrafts возвращает черновики, время публикации которых наступило
+func (r *DraftSqlite3) GetDueDrafts() ([]*entities.Draft, error) {
+	return r.queryDrafts(draftSelect + ` WHERE publish_at IS NOT NULL AND publish_at <= datetime('now') ORDER BY publish_at, id`)
+}
+
+// FailDraft снимает черновик с расписания и запоминает, почему его не удалось опубликовать
+func (r *DraftSqlite3) FailDraft(id int, message string) error {
+	result, err := r.DB.Exec(`UPDATE drafts SET publish_at = NULL, publish_error = ? WHERE id = ?`, message, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+// DeleteDraft удаляет черновик. ErrNoRecord - черновик уже удалён или
+// опубликован: так публикация одного черновика не случится дважды.
+func (r *DraftSqlite3) DeleteDraft(id int) error {
+	result, err := r.DB.Exec(`DELETE FROM drafts WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+func (r *DraftSqlite3) queryDrafts(stmt string, args ...any) ([]*entities.Draft, error) {
+	rows, err := r.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []*entities.Draft{}
+	for rows.Next() {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+const draftSelect = `SELECT id, user_id, title, content, categories, publish_at, publish_error, created, updated FROM drafts`
+
+func scanDraft(row rowScanner) (*entities.Draft, error) {
+	draft := &entities.Draft{}
+	var categories, created, updated string
+	var publishAt sql.NullString
+
+	err := row.Scan(&draft.ID, &draft.UserID, &draft.Title, &draft.Content, &categories,
+		&publishAt, &draft.PublishError, &created, &updated)
+	if err != nil {
+		return nil, err
+	}
+
+	if draft.CategoryIDs, err = splitIDs(categories); err != nil {
+		return nil, err
+	}
+	if draft.PublishAt, err = formatNullTime(publishAt); err != nil {
+		return nil, err
+	}
+	if draft.Created, err = formatReportTime(created); err != nil {
+		return nil, err
+	}
+	if draft.Updated, err = formatReportTime(updated); err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// parseNullTime переводит время RFC3339 в формат базы; пустая строка - NULL
+func parseNullTime(value string) (sql.NullString, error) {
+	if value == "" {
+		return sql.NullString{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: t.UTC().Format("2006-01-02 15:04:05"), Valid: true}, nil
+}
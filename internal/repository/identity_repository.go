@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/internal/entities"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type IdentitySqlite3 struct {
+	DB *sql.DB
+}
+
+func NewIdentitySqlite3(db *sql.DB) *IdentitySqlite3 {
+	return &IdentitySqlite3{
+		DB: db,
+	}
+}
+
+func (r *IdentitySqlite3) GetIdentityUserID(provider, subject string) (int, error) {
+	var userID int
+	stmt := "SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?"
+	err := r.DB.QueryRow(stmt, provider, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, entities.ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+func (r *IdentitySqlite3) InsertIdentity(userID int, provider, subject, email string) error {
+	stmt := `INSERT INTO user_identities (user_id, provider, subject, email, created)
+	VALUES (?, ?, ?, ?, datetime('now'))`
+	_, err := r.DB.Exec(stmt, userID, provider, subject, email)
+	if err != nil {
+		var sqliteError sqlite3.Error
+		if errors.As(err, &sqliteError) && sqliteError.Code == sqlite3.ErrConstraint &&
+			strings.Contains(sqliteError.Error(), "UNIQUE") {
+			return entities.ErrIdentityInUse
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *IdentitySqlite3) DeleteIdentity(userID int, provider string) error {
+	result, err := r.DB.Exec("DELETE FROM user_identities WHERE user_id = ? AND provider = ?", userID, provider)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+func (r *IdentitySqlite3) ListIdentities(userID int) ([]*entities.UserIdentity, error) {
+	stmt := `SELECT id, user_id, provider, subject, email, created FROM user_identities
+	WHERE user_id = ? ORDER BY provider`
+	rows, err := r.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []*entities.UserIdentity
+	for rows.Next() {
+		i := &entities.UserIdentity{}
+		var created string
+
+		if err := rows.Scan(&i.ID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &created); err != nil {
+			return nil, err
+		}
+
+		createdTime, err := time.Parse("2006-01-02 15:04:05", created)
+		if err != nil {
+			return nil, err
+		}
+		i.Created = createdTime.Format(time.RFC3339)
+
+		identities = append(identities, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return identities, nil
+}
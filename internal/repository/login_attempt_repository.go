@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"forum/internal/entities"
+)
+
+// Сколько хранить журнал попыток входа
+const loginAttemptsRetention = 30 * 24 * time.Hour
+
+type LoginAttemptSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewLoginAttemptSqlite3(db *sql.DB) *LoginAttemptSqlite3 {
+	return &LoginAttemptSqlite3{
+		DB: db,
+	}
+}
+
+func (r *LoginAttemptSqlite3) InsertLoginAttempt(userID int, email, ip string, success bool) error {
+	var uid sql.NullInt64
+	if userID > 0 {
+		uid = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+
+	stmt := `INSERT INTO login_attempts (user_id, email, ip, success, created)
+	VALUES (?, ?, ?, ?, datetime('now'))`
+	_, err := r.DB.Exec(stmt, uid, email, ip, success)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Exec("DELETE FROM login_attempts WHERE created < datetime('now', ?)", sinceModifier(loginAttemptsRetention))
+	return err
+}
+
+// FailedByIP возвращает число неудачных входов с адреса за window и время последнего
+func (r *LoginAttemptSqlite3) FailedByIP(ip string, window time.Duration) (int, time.Time, error) {
+	var count int
+	var last sql.NullString
+	stmt := `SELECT COUNT(*), MAX(created) FROM login_attempts
+	WHERE ip = ? AND success = false AND created > datetime('now', ?)`
+	err := r.DB.QueryRow(stmt, ip, sinceModifier(window)).Scan(&count, &last)
+	if err != nil || !last.Valid {
+		return count, time.Time{}, err
+	}
+
+	lastTime, err := time.Parse("2006-01-02 15:04:05", last.String)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, lastTime, nil
+}
+
+func (r *LoginAttemptSqlite3) ListRecentLoginAttempts(limit int) ([]*entities.LoginAttempt, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), email, ip, success, created FROM login_attempts
+	ORDER BY id DESC LIMIT ?`
+	rows, err := r.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []*entities.LoginAttempt
+	for rows.Next() {
+		a := &entities.LoginAttempt{}
+		var created string
+
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Email, &a.IP, &a.Success, &created); err != nil {
+			return nil, err
+		}
+
+		createdTime, err := time.Parse("2006-01-02 15:04:05", created)
+		if err != nil {
+			return nil, err
+		}
+		a.Created = createdTime.Format(time.RFC3339)
+
+		attempts = append(attempts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// ListFailingIPs возвращает IP с наибольшим числом неудачных входов за окно
+func (r *LoginAttemptSqlite3) ListFailingIPs(window time.Duration, limit int) ([]*entities.LoginIPStat, error) {
+	stmt := `SELECT ip, COUNT(*), COUNT(DISTINCT email), MAX(created) FROM login_attempts
+	WHERE success = false AND created > datetime('now', ?)
+	GROUP BY ip
+	ORDER BY COUNT(*) DESC
+	LIMIT ?`
+	rows, err := r.DB.Query(stmt, sinceModifier(window), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*entities.LoginIPStat
+	for rows.Next() {
+		st := &entities.LoginIPStat{}
+		var lastSeen string
+
+		if err := rows.Scan(&st.IP, &st.Failures, &st.Emails, &lastSeen); err != nil {
+			return nil, err
+		}
+
+		lastSeenTime, err := time.Parse("2006-01-02 15:04:05", lastSeen)
+		if err != nil {
+			return nil, err
+		}
+		st.LastSeen = lastSeenTime.Format(time.RFC3339)
+
+		stats = append(stats, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// sinceModifier переводит длительность в модификатор datetime() вида "-900 seconds"
+func sinceModifier(d time.Duration) string {
+	return fmt.Sprintf("-%d seconds", int(d.Seconds()))
+}
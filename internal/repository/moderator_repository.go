@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"database/sql"
+
+	"forum/internal/entities"
+)
+
+type ModeratorSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewModeratorSqlite3(db *sql.DB) *ModeratorSqlite3 {
+	return &ModeratorSqlite3{
+		DB: db,
+	}
+}
+
+// GetModeratorCategoryIDs возвращает категории модерации пользователя, в том
+// числе уже удалённые. Пустой список - ограничений нет.
+func (r *ModeratorSqlite3) GetModeratorCategoryIDs(userID int) ([]int, error) {
+	rows, err := r.DB.Query("SELECT category_id FROM moderator_categories WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *ModeratorSqlite3) SetModeratorCategories(userID int, categoryIDs []int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM moderator_categories WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, id := range categoryIDs {
+		_, err := tx.Exec("INSERT OR IGNORE INTO moderator_categories (user_id, category_id) VALUES (?, ?)", userID, id)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *ModeratorSqlite3) ListModerators() ([]*entities.Moderator, error) {
+	stmt := `SELECT id, username, email, role,
+	EXISTS(SELECT 1 FROM moderator_categories mc WHERE mc.user_id = users.id)
+	FROM users WHERE role = ? ORDER BY username`
+	rows, err := r.DB.Query(stmt, entities.RoleModerator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var moderators []*entities.Moderator
+	for rows.Next() {
+		m := &entities.Moderator{}
+		if err := rows.Scan(&m.ID, &m.Username, &m.Email, &m.Role, &m.Scoped); err != nil {
+			return nil, err
+		}
+		moderators = append(moderators, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, m := range moderators {
+		m.Categories, err = userCategories(r.DB, "moderator_categories", m.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return moderators, nil
+}
+
+// userCategories читает существующие категории пользователя из таблицы
+// moderator_categories или moderation_request_categories
+func userCategories(db *sql.DB, table string, userID int) ([]*entities.Category, error) {
+	stmt := `SELECT c.id, c.name FROM categories c
+	INNER JOIN ` + table + ` t ON c.id = t.category_id
+	WHERE t.user_id = ? ORDER BY c.id`
+	rows, err := db.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*entities.Category
+	for rows.Next() {
+		c := &entities.Category{}
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
@@ -18,9 +18,9 @@ func NewPostReactionSqlite3(db *sql.DB) *PostReactionSqlite3 {
 
 func (r *PostReactionSqlite3) GetNotifications(userID int) ([]*entities.Notification, error) {
 	stmt := `
-	SELECT n.id,n.post_id,n.action_type, n.trigger_user_id,n.created, u.username, p.title, p.content FROM notifications as n 
+	SELECT n.id,n.post_id,n.action_type, n.trigger_user_id,n.created, u.username, p.title, p.content, n.note FROM notifications as n 
 	JOIN users as u ON n.trigger_user_id = u.id JOIN posts as p ON n.post_id = p.id
-	WHERE n.user_id = ?
+	WHERE n.user_id = ? AND n.trigger_user_id NOT IN (` + shadowBannedUsers + `)
 	ORDER BY n.created DESC
 	`
 
@@ -44,7 +44,8 @@ func (r *PostReactionSqlite3) GetNotifications(userID int) ([]*entities.Notifica
 			&created,
 			&notification.TriggerUserName,
 			&notification.PostTitle,
-			&notification.PostContent)
+			&notification.PostContent,
+			&notification.Reason)
 		if err != nil {
 			return nil, err
 		}
@@ -76,6 +77,14 @@ func (r *PostReactionSqlite3) AddNotification(userID, postID, triggerUserID int,
 	return nil
 }
 
+// AddModerationNotification сообщает автору решение модератора по посту; note - причина
+func (r *PostReactionSqlite3) AddModerationNotification(userID, postID, triggerUserID int, actionType, note string) error {
+	stmt := `INSERT INTO notifications (user_id, post_id, action_type, trigger_user_id, note, created)
+	VALUES (?,?,?,?,?, datetime('now'))`
+	_, err := r.DB.Exec(stmt, userID, postID, actionType, triggerUserID, note)
+	return err
+}
+
 func (r *PostReactionSqlite3) RemoveNotification(userID, postID, triggerUserID int, actionType string) error {
 	stmt := `DELETE FROM notifications
 	WHERE user_id = ? AND post_id = ? AND trigger_user_id = ? AND action_type = ?`
@@ -138,11 +147,13 @@ func (r *PostReactionSqlite3) GetUserReaction(userID, postID int) (*entities.Pos
 	return &reaction, nil
 }
 
-func (r *PostReactionSqlite3) GetReactionsCount(postID int) (likes int, dislikes int, err error) {
+// GetReactionsCount не учитывает реакции пользователей под теневым баном,
+// кроме реакции самого viewerID
+func (r *PostReactionSqlite3) GetReactionsCount(postID, viewerID int) (likes int, dislikes int, err error) {
 	stmt := `SELECT COALESCE(SUM(CASE WHEN is_like = 1 THEN 1 ELSE 0 END), 0) AS likes,
                      COALESCE(SUM(CASE WHEN is_like = 0 THEN 1 ELSE 0 END), 0) AS dislikes
-              FROM post_reactions WHERE post_id = ?`
-	row := r.DB.QueryRow(stmt, postID)
+              FROM post_reactions WHERE post_id = ? AND ` + visibleAuthor("user_id")
+	row := r.DB.QueryRow(stmt, postID, viewerID)
 	err = row.Scan(&likes, &dislikes)
 	return
 }
@@ -44,7 +44,7 @@ func (r *PostSqlite3) Exists(id int) (bool, error) {
 	return exists, err
 }
 
-func (r *PostSqlite3) InsertPostWithCategories(title, content string, userID int, categoryIDs []int, filePaths []string) (int, error) {
+func (r *PostSqlite3) InsertPostWithCategories(title, content, contentHTML string, userID int, categoryIDs []int, filePaths []string) (int, error) {
 	// Начинаем транзакцию
 	tx, err := r.DB.Begin()
 	if err != nil {
@@ -59,8 +59,8 @@ func (r *PostSqlite3) InsertPostWithCategories(title, content string, userID int
 	}()
 
 	// Вставляем пост в базу данных
-	stmt := `INSERT INTO posts (title, content, user_id, created) VALUES (?, ?, ?, datetime('now'))`
-	result, err := tx.Exec(stmt, title, content, userID)
+	stmt := `INSERT INTO posts (title, content, content_html, user_id, created) VALUES (?, ?, ?, ?, datetime('now'))`
+	result, err := tx.Exec(stmt, title, content, contentHTML, userID)
 	if err != nil {
 		return 0, err
 	}
@@ -99,7 +99,7 @@ func (r *PostSqlite3) InsertPostWithCategories(title, content string, userID int
 	return int(postID), nil
 }
 
-func (r *PostSqlite3) UpdatePostWithImage(title, content string, postID int, filePaths []string, categoryIDs []int) error {
+func (r *PostSqlite3) UpdatePostWithImage(title, content, contentHTML string, postID int, filePaths []string, categoryIDs []int) error {
 	// Начинаем транзакцию
 	tx, err := r.DB.Begin()
 	if err != nil {
@@ -114,10 +114,10 @@ func (r *PostSqlite3) UpdatePostWithImage(title, content string, postID int, fil
 	}()
 
 	stmt := `UPDATE posts
-	SET title = ?, content = ?
+	SET title = ?, content = ?, content_html = ?, edited = datetime('now')
 	WHERE id = ?`
 
-	_, err = tx.Exec(stmt, title, content, postID)
+	_, err = tx.Exec(stmt, title, content, contentHTML, postID)
 	if err != nil {
 		return err
 	}
@@ -163,7 +163,7 @@ func (r *PostSqlite3) UpdatePostWithImage(title, content string, postID int, fil
 }
 
 func (r *PostSqlite3) GetPost(postID int) (*entities.Post, error) {
-	stmt := `SELECT posts.id,title,content,posts.created,is_approved,users.id,username 
+	stmt := `SELECT posts.id,title,content,content_html,posts.created,is_approved,rejected,rejection_reason,posts.edited,users.id,username 
 	FROM posts LEFT JOIN users ON posts.user_id = users.id
     WHERE posts.id = ?`
 
@@ -171,9 +171,9 @@ func (r *PostSqlite3) GetPost(postID int) (*entities.Post, error) {
 
 	p := &entities.Post{}
 	var created string
-	var username sql.NullString
+	var username, rejected, edited sql.NullString
 
-	err := row.Scan(&p.ID, &p.Title, &p.Content, &created, &p.IsApproved, &p.UserID, &username)
+	err := row.Scan(&p.ID, &p.Title, &p.Content, &p.ContentHTML, &created, &p.IsApproved, &rejected, &p.RejectionReason, &edited, &p.UserID, &username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, entities.ErrNoRecord
@@ -194,6 +194,13 @@ func (r *PostSqlite3) GetPost(postID int) (*entities.Post, error) {
 	}
 	p.Created = postTime.Format(time.RFC3339)
 
+	if p.Rejected, err = formatNullTime(rejected); err != nil {
+		return nil, err
+	}
+	if p.Edited, err = formatNullTime(edited); err != nil {
+		return nil, err
+	}
+
 	return p, nil
 }
 
@@ -233,7 +240,7 @@ func (r *PostSqlite3) GetPost(postID int) (*entities.Post, error) {
 // }
 
 // Получение постов по категориям с пагинацией
-func (r *PostSqlite3) GetPaginatedPostsByCategory(categoryIDs []int, page, pageSize int) ([]*entities.Post, error) {
+func (r *PostSqlite3) GetPaginatedPostsByCategory(viewerID int, categoryIDs []int, page, pageSize int) ([]*entities.Post, error) {
 	placeholders := make([]string, len(categoryIDs))
 	args := make([]interface{}, len(categoryIDs))
 	offset := (page - 1) * pageSize
@@ -247,14 +254,14 @@ func (r *PostSqlite3) GetPaginatedPostsByCategory(categoryIDs []int, page, pageS
         SELECT p.id, p.title, p.content, p.user_id, p.created 
         FROM posts p
         INNER JOIN post_categories pc ON p.id = pc.post_id
-        WHERE pc.category_id IN (%s) AND is_approved = true
+        WHERE pc.category_id IN (%s) AND is_approved = true AND %s
         GROUP BY p.id
         HAVING COUNT(DISTINCT pc.category_id) = ?
         ORDER BY p.created DESC
-		LIMIT ? OFFSET ?`, strings.Join(placeholders, ", "))
+		LIMIT ? OFFSET ?`, strings.Join(placeholders, ", "), visibleAuthor("p.user_id"))
 
-	// Добавляем в аргументы запроса: количество категорий; запрашиваем на одну запись больше, чем pageSize, чтобы проверить наличие следующей страницы; смещение
-	args = append(args, len(categoryIDs), pageSize+1, offset)
+	// Добавляем в аргументы запроса: смотрящего; количество категорий; запрашиваем на одну запись больше, чем pageSize, чтобы проверить наличие следующей страницы; смещение
+	args = append(args, viewerID, len(categoryIDs), pageSize+1, offset)
 
 	rows, err := r.DB.Query(stmt, args...)
 	if err != nil {
@@ -288,16 +295,16 @@ func (r *PostSqlite3) GetPaginatedPostsByCategory(categoryIDs []int, page, pageS
 	return posts, nil
 }
 
-func (r *PostSqlite3) GetUserPaginatedPosts(userId, page, pageSize int) ([]*entities.Post, error) {
+func (r *PostSqlite3) GetUserPaginatedPosts(viewerID, userId, page, pageSize int) ([]*entities.Post, error) {
 	offset := (page - 1) * pageSize
 
 	stmt := `SELECT id, title, content, user_id, created FROM posts
-	WHERE user_id = ? AND is_approved = true
+	WHERE user_id = ? AND is_approved = true AND ` + visibleAuthor("user_id") + `
     ORDER BY id DESC
 	LIMIT ? OFFSET ?`
 
 	// запрашиваем на одну запись больше, чем pageSize
-	rows, err := r.DB.Query(stmt, userId, pageSize+1, offset)
+	rows, err := r.DB.Query(stmt, userId, viewerID, pageSize+1, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -332,11 +339,11 @@ func (r *PostSqlite3) GetUserCommentedPosts(userId, page, pageSize int) ([]*enti
 	offset := (page - 1) * pageSize
 
 	stmt := `SELECT p.id, p.title, p.content, p.user_id, p.created FROM posts as p INNER JOIN comments as c ON p.id = c.post_id
-	WHERE c.user_id = ?
+	WHERE c.user_id = ? AND ` + visibleAuthor("p.user_id") + `
 	LIMIT ? OFFSET ?`
 
 	// запрашиваем на одну запись больше, чем pageSize
-	rows, err := r.DB.Query(stmt, userId, pageSize+1, offset)
+	rows, err := r.DB.Query(stmt, userId, userId, pageSize+1, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -373,51 +380,11 @@ func (r *PostSqlite3) GetUserLikedPaginatedPosts(userId, page, pageSize int) ([]
 	stmt := `SELECT id, title, content, p.user_id, created 
 	FROM posts p
 	INNER JOIN post_reactions pr ON p.id = pr.post_id
-	WHERE pr.user_id = ? AND pr.is_like = true
+	WHERE pr.user_id = ? AND pr.is_like = true AND ` + visibleAuthor("p.user_id") + `
     ORDER BY id DESC
 	LIMIT ? OFFSET ?`
 
-	rows, err := r.DB.Query(stmt, userId, pageSize+1, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	posts := []*entities.Post{}
-	var created string
-
-	for rows.Next() {
-		p := &entities.Post{}
-		err = rows.Scan(&p.ID, &p.Title, &p.Content, &p.UserID, &created)
-		if err != nil {
-			return nil, err
-		}
-
-		postTime, err := time.Parse("2006-01-02 15:04:05", created)
-		if err != nil {
-			return nil, err
-		}
-		p.Created = postTime.Format(time.RFC3339)
-
-		posts = append(posts, p)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return posts, nil
-}
-
-func (r *PostSqlite3) GetAllPaginatedPosts(page, pageSize int) ([]*entities.Post, error) {
-	offset := (page - 1) * pageSize // Вычисляем смещение для текущей страницы
-
-	stmt := `SELECT id, title, content, user_id, created FROM posts
-			 WHERE is_approved = true
-             ORDER BY created DESC
-             LIMIT ? OFFSET ?`
-
-	rows, err := r.DB.Query(stmt, pageSize+1, offset) // Лимит на одну запись больше
+	rows, err := r.DB.Query(stmt, userId, userId, pageSize+1, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -449,15 +416,15 @@ func (r *PostSqlite3) GetAllPaginatedPosts(page, pageSize int) ([]*entities.Post
 	return posts, nil
 }
 
-func (r *PostSqlite3) GetAllPaginatedUnapprovedPosts(page, pageSize int) ([]*entities.Post, error) {
+func (r *PostSqlite3) GetAllPaginatedPosts(viewerID, page, pageSize int) ([]*entities.Post, error) {
 	offset := (page - 1) * pageSize // Вычисляем смещение для текущей страницы
 
 	stmt := `SELECT id, title, content, user_id, created FROM posts
-			 WHERE is_approved = false
+			 WHERE is_approved = true AND ` + visibleAuthor("user_id") + `
              ORDER BY created DESC
              LIMIT ? OFFSET ?`
 
-	rows, err := r.DB.Query(stmt, pageSize+1, offset) // Лимит на одну запись больше
+	rows, err := r.DB.Query(stmt, viewerID, pageSize+1, offset) // Лимит на одну запись больше
 	if err != nil {
 		return nil, err
 	}
@@ -517,14 +484,63 @@ func (r *PostSqlite3) GetImagesByPost(postID int) ([]*entities.Image, error) {
 }
 
 func (r *PostSqlite3) ApprovePost(postID int) error {
-	stmt := "UPDATE posts SET is_approved = true WHERE id = ?"
+	stmt := "UPDATE posts SET is_approved = true, rejected = NULL, rejection_reason = '' WHERE id = ?"
 	_, err := r.DB.Exec(stmt, postID)
 	return err
 }
 
-// Удаление поста
-func (r *PostSqlite3) DeletePost(postID int) error {
-	stmt := `DELETE FROM posts WHERE id = ?`
+// UnapprovePost снимает пост с публикации до решения модератора
+func (r *PostSqlite3) UnapprovePost(postID int) error {
+	stmt := "UPDATE posts SET is_approved = false WHERE id = ?"
 	_, err := r.DB.Exec(stmt, postID)
 	return err
 }
+
+// RejectPost отклоняет пост с причиной для автора и убирает его из очереди модерации
+func (r *PostSqlite3) RejectPost(postID int, reason string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE posts SET is_approved = false, rejected = datetime('now'), rejection_reason = ?
+	WHERE id = ?`, reason, postID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM queue_claims WHERE target_type = 'post' AND target_id = ?", postID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResubmitPost возвращает отклонённый пост в очередь модерации
+func (r *PostSqlite3) ResubmitPost(postID int) error {
+	stmt := "UPDATE posts SET rejected = NULL, rejection_reason = '' WHERE id = ?"
+	_, err := r.DB.Exec(stmt, postID)
+	return err
+}
+
+// Удаление поста
+func (r *PostSqlite3) DeletePost(postID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Версии не связаны внешними ключами, удаляем их вместе с постом и его комментариями
+	stmts := []string{
+		`DELETE FROM revisions WHERE target_type = 'comment' AND target_id IN (SELECT id FROM comments WHERE post_id = ?)`,
+		`DELETE FROM revisions WHERE target_type = 'post' AND target_id = ?`,
+		`DELETE FROM posts WHERE id = ?`,
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(stmt, postID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
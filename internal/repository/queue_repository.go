@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type QueueSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewQueueSqlite3(db *sql.DB) *QueueSqlite3 {
+	return &QueueSqlite3{
+		DB: db,
+	}
+}
+
+// activeClaimCondition - взятый элемент ещё не освободился по таймауту
+const activeClaimCondition = "(c.expires IS NULL OR c.expires > datetime('now'))"
+
+// queueItems - посты на одобрении и объекты с незакрытыми жалобами
+const queueItems = `SELECT 'approval' AS kind, 'post' AS target_type, p.id AS target_id, p.title AS target_name,
+		p.user_id AS target_user_id, p.id AS post_id, 0 AS reports, '' AS reasons, p.created AS queued
+	FROM posts p
+	WHERE p.is_approved = false AND p.rejected IS NULL AND p.user_id NOT IN (` + shadowBannedUsers + `)
+	UNION ALL
+	SELECT 'report', r.target_type, r.target_id, ` + reportTargetName + `, r.target_user_id, MAX(r.post_id),
+		COUNT(*), GROUP_CONCAT(DISTINCT r.category), MIN(r.created)
+	FROM reports r
+	WHERE r.status IN ('open', 'in_review')
+	GROUP BY r.target_type, r.target_id`
+
+// GetQueue возвращает элементы очереди по фильтру, самые старые первыми.
+// kinds - виды элементов, которые может видеть модератор; categoryIDs, если
+// не пусто, ограничивает посты на одобрении категориями модератора.
+func (r *QueueSqlite3) GetQueue(viewerID int, filter *entities.QueueFilter, kinds []string, categoryIDs []int, limit, offset int) ([]*entities.QueueItem, error) {
+	if len(kinds) == 0 {
+		return []*entities.QueueItem{}, nil
+	}
+
+	conditions := []string{"q.kind IN (" + placeholders(len(kinds)) + ")"}
+	args := []any{}
+	for _, k := range kinds {
+		args = append(args, k)
+	}
+
+	if len(categoryIDs) > 0 {
+		conditions = append(conditions, fmt.Sprintf(
+			"(q.kind != 'approval' OR q.post_id IN (SELECT post_id FROM post_categories WHERE category_id IN (%s)))",
+			placeholders(len(categoryIDs))))
+		for _, id := range categoryIDs {
+			args = append(args, id)
+		}
+	}
+	if filter.Kind != "" {
+		conditions = append(conditions, "q.kind = ?")
+		args = append(args, filter.Kind)
+	}
+	if filter.CategoryID != 0 {
+		conditions = append(conditions, "q.post_id IN (SELECT post_id FROM post_categories WHERE category_id = ?)")
+		args = append(args, filter.CategoryID)
+	}
+	if filter.MinAgeHours > 0 {
+		conditions = append(conditions, "q.queued <= datetime('now', ?)")
+		args = append(args, fmt.Sprintf("-%d hours", filter.MinAgeHours))
+	}
+	if filter.MinReports > 0 {
+		conditions = append(conditions, "q.reports >= ?")
+		args = append(args, filter.MinReports)
+	}
+	switch filter.Assignee {
+	case entities.QueueAssigneeMe:
+		conditions = append(conditions, "c.user_id = ?")
+		args = append(args, viewerID)
+	case entities.QueueAssigneeUnclaimed:
+		conditions = append(conditions, "c.user_id IS NULL")
+	case entities.QueueAssigneeClaimed:
+		conditions = append(conditions, "c.user_id IS NOT NULL")
+	}
+
+	stmt := `SELECT q.kind, q.target_type, q.target_id, COALESCE(q.target_name, ''), q.target_user_id,
+		COALESCE(au.username, ''), q.post_id, q.reports, COALESCE(q.reasons, ''), q.queued,
+		c.user_id, COALESCE(cu.username, ''), c.assigned_by, c.expires
+	FROM (` + queueItems + `) q
+	LEFT JOIN users au ON au.id = q.target_user_id
+	LEFT JOIN queue_claims c ON c.target_type = q.target_type AND c.target_id = q.target_id AND ` + activeClaimCondition + `
+	LEFT JOIN users cu ON cu.id = c.user_id
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	ORDER BY q.queued ASC
+	LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := r.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*entities.QueueItem{}
+	for rows.Next() {
+		item := &entities.QueueItem{}
+		var queued, claimName string
+		var claimUserID, assignedBy sql.NullInt64
+		var expires sql.NullString
+
+		err := rows.Scan(&item.Kind, &item.TargetType, &item.TargetID, &item.TargetName, &item.TargetUserID,
+			&item.TargetUserName, &item.PostID, &item.Reports, &item.Reasons, &queued,
+			&claimUserID, &claimName, &assignedBy, &expires)
+		if err != nil {
+			return nil, err
+		}
+		item.Reasons = strings.ReplaceAll(item.Reasons, ",", ", ")
+
+		if item.Queued, err = formatReportTime(queued); err != nil {
+			return nil, err
+		}
+
+		if claimUserID.Valid {
+			item.Claim = &entities.QueueClaim{
+				TargetType: item.TargetType,
+				TargetID:   item.TargetID,
+				UserID:     int(claimUserID.Int64),
+				UserName:   claimName,
+				AssignedBy: int(assignedBy.Int64),
+			}
+			if item.Claim.Expires, err = formatNullTime(expires); err != nil {
+				return nil, err
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetClaim возвращает действующую отметку о том, кто работает над объектом
+func (r *QueueSqlite3) GetClaim(targetType string, targetID int) (*entities.QueueClaim, error) {
+	stmt := `SELECT c.target_type, c.target_id, c.user_id, COALESCE(u.username, ''), c.assigned_by, c.expires
+	FROM queue_claims c
+	LEFT JOIN users u ON u.id = c.user_id
+	WHERE c.target_type = ? AND c.target_id = ? AND ` + activeClaimCondition
+
+	claim := &entities.QueueClaim{}
+	var expires sql.NullString
+
+	err := r.DB.QueryRow(stmt, targetType, targetID).Scan(&claim.TargetType, &claim.TargetID, &claim.UserID,
+		&claim.UserName, &claim.AssignedBy, &expires)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	if claim.Expires, err = formatNullTime(expires); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// SaveClaim отмечает, что userID работает над объектом. Если timeout больше
+// нуля, отметка снимается сама, когда он пройдёт.
+func (r *QueueSqlite3) SaveClaim(targetType string, targetID, userID, assignedBy int, timeout time.Duration) error {
+	var expires any
+	if timeout > 0 {
+		expires = time.Now().UTC().Add(timeout).Format("2006-01-02 15:04:05")
+	}
+
+	stmt := `INSERT INTO queue_claims (target_type, target_id, user_id, assigned_by, claimed, expires)
+	VALUES (?, ?, ?, ?, datetime('now'), ?)
+	ON CONFLICT (target_type, target_id) DO UPDATE SET
+		user_id = excluded.user_id, assigned_by = excluded.assigned_by,
+		claimed = excluded.claimed, expires = excluded.expires`
+	_, err := r.DB.Exec(stmt, targetType, targetID, userID, assignedBy, expires)
+	return err
+}
+
+func (r *QueueSqlite3) DeleteClaim(targetType string, targetID int) error {
+	_, err := r.DB.Exec("DELETE FROM queue_claims WHERE target_type = ? AND target_id = ?", targetType, targetID)
+	return err
+}
+
+// GetQueueStaff возвращает пользователей, которые могут работать с очередью
+func (r *QueueSqlite3) GetQueueStaff() ([]*entities.User, error) {
+	stmt := `SELECT id, username, role FROM users
+	WHERE role = ? OR role IN (SELECT role FROM role_permissions WHERE permission IN (?, ?))
+	ORDER BY username`
+
+	rows, err := r.DB.Query(stmt, entities.RoleAdmin, entities.PermPostApprove, entities.PermReportResolve)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*entities.User{}
+	for rows.Next() {
+		u := &entities.User{}
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
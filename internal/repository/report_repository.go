@@ -2,7 +2,7 @@ package repository
 
 import (
 	"database/sql"
-	"errors"
+	"strings"
 	"time"
 
 	"forum/internal/entities"
@@ -18,69 +18,120 @@ func NewReportSqlite3(db *sql.DB) *ReportSqlite3 {
 	}
 }
 
-func (r *ReportSqlite3) CreateReport(userID, postID int, reason string) error {
-	stmt := `INSERT INTO reports (user_id, post_id, reason, created)
-			 VALUES (?, ?, ?, datetime('now'))`
-	_, err := r.DB.Exec(stmt, userID, postID, reason)
-	return err
-}
+// reportTargetName - название объекта жалобы; пусто, если объект уже удалён
+const reportTargetName = `COALESCE(CASE r.target_type
+		WHEN 'post' THEN (SELECT title FROM posts WHERE id = r.target_id)
+		WHEN 'comment' THEN (SELECT substr(content, 1, 80) FROM comments WHERE id = r.target_id)
+		WHEN 'user' THEN (SELECT username FROM users WHERE id = r.target_id)
+	END, '')`
+
+func (r *ReportSqlite3) CreateReport(report *entities.Report) (int, error) {
+	stmt := `INSERT INTO reports (user_id, target_type, target_id, target_user_id, post_id, category, reason,
+		status, created, updated)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))`
+	result, err := r.DB.Exec(stmt, report.UserID, report.TargetType, report.TargetID, report.TargetUserID,
+		report.PostID, report.Category, report.Reason, entities.ReportOpen)
+	if err != nil {
+		return 0, err
+	}
 
-func (r *ReportSqlite3) GetPostReport(postID int) (*entities.Report, error) {
-	stmt := `SELECT id, user_id, post_id, reason, created  
-	FROM reports
-	WHERE post_id = ?`
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
 
-	row := r.DB.QueryRow(stmt, postID)
+// HasActiveReport сообщает, есть ли у пользователя незакрытая жалоба на объект
+func (r *ReportSqlite3) HasActiveReport(userID int, targetType string, targetID int) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT 1 FROM reports
+	WHERE user_id = ? AND target_type = ? AND target_id = ? AND status IN (?, ?))`
 
-	report := &entities.Report{}
-	var created string
+	var exists bool
+	err := r.DB.QueryRow(stmt, userID, targetType, targetID, entities.ReportOpen, entities.ReportInReview).Scan(&exists)
+	return exists, err
+}
 
-	err := row.Scan(&report.ID, &report.UserID, &report.PostID, &report.Reason, &created)
+// GetReportTargets возвращает объекты, на которые есть жалобы со статусом status:
+// сначала те, на которые жаловались чаще
+func (r *ReportSqlite3) GetReportTargets(status string, limit, offset int) ([]*entities.ReportTarget, error) {
+	stmt := `SELECT r.target_type, r.target_id, ` + reportTargetName + `, r.target_user_id,
+		COALESCE(u.username, ''), MAX(r.post_id), COUNT(*), GROUP_CONCAT(DISTINCT r.category),
+		MIN(r.created), MAX(r.created)
+	FROM reports r
+	LEFT JOIN users u ON u.id = r.target_user_id
+	WHERE r.status = ?
+	GROUP BY r.target_type, r.target_id
+	ORDER BY COUNT(*) DESC, MAX(r.created) DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := r.DB.Query(stmt, status, limit, offset)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, entities.ErrNoRecord
-		} else {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []*entities.ReportTarget{}
+	for rows.Next() {
+		t := &entities.ReportTarget{Status: status}
+		var categories sql.NullString
+		var first, last string
+
+		err := rows.Scan(&t.TargetType, &t.TargetID, &t.TargetName, &t.TargetUserID, &t.TargetUserName,
+			&t.PostID, &t.Reports, &categories, &first, &last)
+		if err != nil {
+			return nil, err
+		}
+		t.Categories = strings.ReplaceAll(categories.String, ",", ", ")
+
+		if t.FirstReported, err = formatReportTime(first); err != nil {
 			return nil, err
 		}
+		if t.LastReported, err = formatReportTime(last); err != nil {
+			return nil, err
+		}
+
+		targets = append(targets, t)
 	}
 
-	userTime, err := time.Parse("2006-01-02 15:04:05", created)
-	if err != nil {
+	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-	report.Created = userTime.Format(time.RFC3339)
-
-	return report, nil
+	return targets, nil
 }
 
-func (r *ReportSqlite3) GetAllPaginatedPostReports(page, pageSize int) ([]*entities.Report, error) {
-	offset := (page - 1) * pageSize // Вычисляем смещение для текущей страницы
+// GetTargetReports возвращает все жалобы на объект, новые первыми
+func (r *ReportSqlite3) GetTargetReports(targetType string, targetID int) ([]*entities.Report, error) {
+	stmt := `SELECT r.id, r.user_id, COALESCE(u.username, ''), r.target_type, r.target_id, r.target_user_id,
+		r.post_id, r.category, r.reason, r.status, r.created, r.updated
+	FROM reports r
+	LEFT JOIN users u ON u.id = r.user_id
+	WHERE r.target_type = ? AND r.target_id = ?
+	ORDER BY r.id DESC`
 
-	stmt := `SELECT reports.id, post_id, user_id, username, reason, reports.created FROM reports
-	JOIN users ON reports.user_id = users.id
-             LIMIT ? OFFSET ?`
-
-	rows, err := r.DB.Query(stmt, pageSize+1, offset) // Лимит на одну запись больше
+	rows, err := r.DB.Query(stmt, targetType, targetID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	reports := []*entities.Report{}
-	var created string
-
 	for rows.Next() {
 		report := &entities.Report{}
-		err = rows.Scan(&report.ID, &report.PostID, &report.UserID, &report.ReporterName, &report.Reason, &created)
+		var created, updated string
+
+		err = rows.Scan(&report.ID, &report.UserID, &report.ReporterName, &report.TargetType, &report.TargetID,
+			&report.TargetUserID, &report.PostID, &report.Category, &report.Reason, &report.Status, &created, &updated)
 		if err != nil {
 			return nil, err
 		}
 
-		reportTime, err := time.Parse("2006-01-02 15:04:05", created)
-		if err != nil {
+		if report.Created, err = formatReportTime(created); err != nil {
+			return nil, err
+		}
+		if report.Updated, err = formatReportTime(updated); err != nil {
 			return nil, err
 		}
-		report.Created = reportTime.Format(time.RFC3339)
 
 		reports = append(reports, report)
 	}
@@ -88,13 +139,108 @@ func (r *ReportSqlite3) GetAllPaginatedPostReports(page, pageSize int) ([]*entit
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
-
 	return reports, nil
 }
 
-func (r *ReportSqlite3) DeleteReport(userID, postID int) error {
-	stmt := `DELETE FROM reports
-			WHERE user_id = ? AND post_id = ?`
-	_, err := r.DB.Exec(stmt, userID, postID)
-	return err
+// GetTargetEvents возвращает историю статусов жалоб на объект, новые первыми
+func (r *ReportSqlite3) GetTargetEvents(targetType string, targetID int) ([]*entities.ReportEvent, error) {
+	stmt := `SELECT e.id, e.report_id, e.actor_id, COALESCE(u.username, ''), e.status, e.note, e.created
+	FROM report_events e
+	JOIN reports r ON r.id = e.report_id
+	LEFT JOIN users u ON u.id = e.actor_id
+	WHERE r.target_type = ? AND r.target_id = ?
+	ORDER BY e.id DESC`
+
+	rows, err := r.DB.Query(stmt, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*entities.ReportEvent{}
+	for rows.Next() {
+		e := &entities.ReportEvent{}
+		var created string
+
+		err = rows.Scan(&e.ID, &e.ReportID, &e.ActorID, &e.ActorName, &e.Status, &e.Note, &created)
+		if err != nil {
+			return nil, err
+		}
+		if e.Created, err = formatReportTime(created); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// SetTargetStatus переводит незакрытые жалобы на объект в статус status и
+// записывает смену в историю. Возвращает число изменённых жалоб.
+func (r *ReportSqlite3) SetTargetStatus(targetType string, targetID int, status string, actorID int, note string) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id FROM reports
+	WHERE target_type = ? AND target_id = ? AND status IN (?, ?) AND status != ?`,
+		targetType, targetID, entities.ReportOpen, entities.ReportInReview, status)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		_, err = tx.Exec("UPDATE reports SET status = ?, updated = datetime('now') WHERE id = ?", status, id)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`INSERT INTO report_events (report_id, actor_id, status, note, created)
+		VALUES (?, ?, ?, ?, datetime('now'))`, id, actorID, status, note)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Закрытые жалобы уходят из очереди модерации
+	if len(ids) > 0 && status != entities.ReportInReview {
+		_, err = tx.Exec("DELETE FROM queue_claims WHERE target_type = ? AND target_id = ?", targetType, targetID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func formatReportTime(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
 }
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"time"
 
 	"forum/internal/entities"
 )
@@ -9,48 +10,61 @@ import (
 type UserRepository interface {
 	Exists(id int) (bool, error)
 	Insert(username, email, password, role string) (int, error)
-	OauthAuthenticate(email string) (*entities.User, error)
 	Authenticate(email, password string) (*entities.User, error)
 	Get(id int) (*entities.User, error)
 	UpdatePassword(id int, currentPassword, newPassword string) error
-	InsertModerationRequest(userId int, reason string) error
+	InsertModerationRequest(userId int, reason string, categoryIDs []int) error
 	ExistsModerationRequest(userId int) (bool, error)
 	ListModeratorApplicants() ([]*entities.ModeratorApplicant, error)
-	GetModerators() ([]*entities.User, error)
 	DeleteModerator(userId int) error
 	ApproveModeratorRequest(userId int) error
 	DeleteModerationRequest(userId int) error
-
+	GetByEmail(email string) (*entities.User, error)
+	SetPassword(id int, newPassword string) error
+	CheckPassword(id int, password string) error
+	HasPassword(id int) (bool, error)
+	SetEmailVerified(id int) error
+	UpdateEmail(id int, email string) error
+	RegisterFailedLogin(id int) (int, error)
+	LockUser(id int, until time.Time) error
+	ResetFailedLogins(id int) error
+	GetLockedUsers() ([]*entities.User, error)
+	SearchUsers(filter *entities.UserFilter, page, pageSize int) ([]*entities.UserOverview, error)
+	GetOverview(id int) (*entities.UserOverview, error)
+	DeleteUser(id int) error
 }
 
 type PostRepository interface {
 	GetPostOwner(postID int) (int, error)
 	Exists(id int) (bool, error)
-	InsertPostWithCategories(title, content string, userID int, categoryIDs []int, filePaths []string) (int, error)
+	InsertPostWithCategories(title, content, contentHTML string, userID int, categoryIDs []int, filePaths []string) (int, error)
 
 	GetPost(postID int) (*entities.Post, error)
 	// GetUnapprovedPost(postID int) (*entities.Post, error)
 
 	GetImagesByPost(postID int) ([]*entities.Image, error)
-	GetPaginatedPostsByCategory(categoryIDs []int, page, pageSize int) ([]*entities.Post, error)
+	GetPaginatedPostsByCategory(viewerID int, categoryIDs []int, page, pageSize int) ([]*entities.Post, error)
 	GetUserCommentedPosts(userId, page, pageSize int) ([]*entities.Post, error)
-	GetUserPaginatedPosts(userID, page, pageSize int) ([]*entities.Post, error)
+	GetUserPaginatedPosts(viewerID, userID, page, pageSize int) ([]*entities.Post, error)
 	GetUserLikedPaginatedPosts(userID, page, pageSize int) ([]*entities.Post, error)
 
-	GetAllPaginatedPosts(page, pageSize int) ([]*entities.Post, error)
-	GetAllPaginatedUnapprovedPosts(page, pageSize int) ([]*entities.Post, error)
+	GetAllPaginatedPosts(viewerID, page, pageSize int) ([]*entities.Post, error)
 
 	ApprovePost(postID int) error
+	UnapprovePost(postID int) error
+	RejectPost(postID int, reason string) error
+	ResubmitPost(postID int) error
 	DeletePost(postID int) error
-	UpdatePostWithImage(title, content string, postID int, filePaths []string, categoryIDs []int) error
+	UpdatePostWithImage(title, content, contentHTML string, postID int, filePaths []string, categoryIDs []int) error
 }
 
 type PostReactionRepository interface {
 	AddReaction(userID, postID int, isLike bool) error
 	RemoveReaction(userID, postID int) error
 	GetUserReaction(userID, postID int) (*entities.PostReaction, error)
-	GetReactionsCount(postID int) (likes int, dislikes int, err error)
+	GetReactionsCount(postID, viewerID int) (likes int, dislikes int, err error)
 	AddNotification(userID, postID, triggerUserID int, actionType string, commentID *int) error
+	AddModerationNotification(userID, postID, triggerUserID int, actionType, note string) error
 	RemoveNotification(userID, postID, triggerUserID int, actionType string) error
 	UpdateNotification(userID, postID, triggerUserID int, oldAction, newAction string) error
 	GetNotifications(userID int) ([]*entities.Notification, error)
@@ -58,28 +72,31 @@ type PostReactionRepository interface {
 }
 
 type ReportRepository interface {
-	CreateReport(userID, postID int, reason string) error
-	GetPostReport(postID int) (*entities.Report, error)
-	GetAllPaginatedPostReports(page, pageSize int) ([]*entities.Report, error)
-	DeleteReport(userID, postID int) error
+	CreateReport(report *entities.Report) (int, error)
+	HasActiveReport(userID int, targetType string, targetID int) (bool, error)
+	GetReportTargets(status string, limit, offset int) ([]*entities.ReportTarget, error)
+	GetTargetReports(targetType string, targetID int) ([]*entities.Report, error)
+	GetTargetEvents(targetType string, targetID int) ([]*entities.ReportEvent, error)
+	SetTargetStatus(targetType string, targetID int, status string, actorID int, note string) (int, error)
 }
 
 type CommentRepository interface {
 	Exists(id int) (bool, error)
-	InsertComment(postID, userID int, content string) (int, error)
-	GetComments(postID int) ([]*entities.Comment, error)
+	InsertComment(postID, userID int, content, contentHTML string) (int, error)
+	GetComments(postID, viewerID int) ([]*entities.Comment, error)
 	GetUserCommentsByPosts(postId, userId int) ([]*entities.Comment, error)
-	UpdateComment(commentID int, content string) error
+	UpdateComment(commentID int, content, contentHTML string) error
 	DeleteComment(commentID int) error
 	GetComment(commentId int) (*entities.Comment, error)
+	SetCommentHidden(commentID int, hidden bool) error
 }
 
 type CommentReactionRepository interface {
 	AddReaction(userID, commentID int, isLike bool) error
 	RemoveReaction(userID, commentID int) error
 	GetUserReaction(userID, commentID int) (*entities.CommentReaction, error)
-	GetLikesCount(commentID int) (int, error)
-	GetDislikesCount(commentID int) (int, error)
+	GetLikesCount(commentID, viewerID int) (int, error)
+	GetDislikesCount(commentID, viewerID int) (int, error)
 }
 
 type CategoryRepository interface {
@@ -93,6 +110,115 @@ type CategoryRepository interface {
 	ExistName(name string) (bool, error)
 }
 
+type TwoFactorRepository interface {
+	GetTwoFactor(userID int) (*entities.TwoFactor, error)
+	SetPendingSecret(userID int, secret string) error
+	EnableTwoFactor(userID int, counter int64, recoveryCodeHashes []string) error
+	DeleteTwoFactor(userID int) error
+	UseCounter(userID int, counter int64) (bool, error)
+	ReplaceRecoveryCodes(userID int, recoveryCodeHashes []string) error
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+}
+
+type SettingRepository interface {
+	GetSetting(key string) (string, error)
+	SetSetting(key, value string) error
+}
+
+type TokenRepository interface {
+	InsertToken(userID int, purpose, hash, data string, ttl time.Duration) error
+	GetTokenUserID(purpose, hash string) (int, error)
+	ConsumeToken(purpose, hash string) (int, string, error)
+	DeleteUserTokens(userID int, purpose string) error
+}
+
+type LoginAttemptRepository interface {
+	InsertLoginAttempt(userID int, email, ip string, success bool) error
+	FailedByIP(ip string, window time.Duration) (int, time.Time, error)
+	ListRecentLoginAttempts(limit int) ([]*entities.LoginAttempt, error)
+	ListFailingIPs(window time.Duration, limit int) ([]*entities.LoginIPStat, error)
+}
+
+type IdentityRepository interface {
+	GetIdentityUserID(provider, subject string) (int, error)
+	InsertIdentity(userID int, provider, subject, email string) error
+	DeleteIdentity(userID int, provider string) error
+	ListIdentities(userID int) ([]*entities.UserIdentity, error)
+}
+
+type APITokenRepository interface {
+	InsertAPIToken(userID int, name, hash, prefix string, scopes []string, expiry time.Time) (int, error)
+	GetActiveAPIToken(hash string) (*entities.APIToken, error)
+	TouchAPIToken(id int) error
+	ListAPITokens(userID int) ([]*entities.APIToken, error)
+	DeleteAPIToken(userID, id int) error
+	DeleteUserAPITokens(userID int) (int, error)
+}
+
+type RoleRepository interface {
+	GetRolePermissions(role string) ([]string, error)
+	GetRole(name string) (*entities.Role, error)
+	ListRoles() ([]*entities.Role, error)
+	InsertRole(name, description string, permissions []string) error
+	UpdateRole(name, description string, permissions []string) error
+	DeleteRole(name string) error
+	SetUserRole(userID int, role string) error
+}
+
+type ModeratorRepository interface {
+	GetModeratorCategoryIDs(userID int) ([]int, error)
+	SetModeratorCategories(userID int, categoryIDs []int) error
+	ListModerators() ([]*entities.Moderator, error)
+}
+
+type BanRepository interface {
+	InsertBan(userID, bannedBy int, reason string, expires time.Time, shadow bool) (int, error)
+	GetActiveBan(userID int) (*entities.Ban, error)
+	IsShadowBanned(userID int) (bool, error)
+	LiftBan(userID, liftedBy int) error
+	ListBans(limit int) ([]*entities.Ban, error)
+}
+
+type AuditRepository interface {
+	InsertModerationAction(a *entities.ModerationAction) error
+	ListModerationActions(filter *entities.AuditFilter, limit, offset int) ([]*entities.ModerationAction, error)
+}
+
+type AutomodRepository interface {
+	GetRules() ([]*entities.AutomodRule, error)
+	GetRule(id int) (*entities.AutomodRule, error)
+	InsertRule(rule *entities.AutomodRule) (int, error)
+	UpdateRule(rule *entities.AutomodRule) error
+	DeleteRule(id int) error
+	InsertHit(hit *entities.AutomodHit) error
+	GetHits(ruleID, limit, offset int) ([]*entities.AutomodHit, error)
+	GetAuthorStats(userID int) (*entities.AuthorStats, error)
+}
+
+type QueueRepository interface {
+	GetQueue(viewerID int, filter *entities.QueueFilter, kinds []string, categoryIDs []int, limit, offset int) ([]*entities.QueueItem, error)
+	GetClaim(targetType string, targetID int) (*entities.QueueClaim, error)
+	SaveClaim(targetType string, targetID, userID, assignedBy int, timeout time.Duration) error
+	DeleteClaim(targetType string, targetID int) error
+	GetQueueStaff() ([]*entities.User, error)
+}
+
+type RevisionRepository interface {
+	InsertRevision(rev *entities.Revision) (int, error)
+	GetRevisions(targetType string, targetID int) ([]*entities.Revision, error)
+	GetRevision(targetType string, targetID, number int) (*entities.Revision, error)
+}
+
+type DraftRepository interface {
+	InsertDraft(draft *entities.Draft) (int, error)
+	UpdateDraft(draft *entities.Draft) error
+	GetDraft(id int) (*entities.Draft, error)
+	GetUserDrafts(userID int) ([]*entities.Draft, error)
+	GetDueDrafts() ([]*entities.Draft, error)
+	FailDraft(id int, message string) error
+	DeleteDraft(id int) error
+}
+
 type Repository struct {
 	UserRepository
 	PostRepository
@@ -101,6 +227,20 @@ type Repository struct {
 	CommentReactionRepository
 	CategoryRepository
 	ReportRepository
+	TwoFactorRepository
+	SettingRepository
+	TokenRepository
+	LoginAttemptRepository
+	IdentityRepository
+	APITokenRepository
+	RoleRepository
+	ModeratorRepository
+	BanRepository
+	AuditRepository
+	QueueRepository
+	AutomodRepository
+	RevisionRepository
+	DraftRepository
 }
 
 func NewRepository(db *sql.DB) *Repository {
@@ -112,5 +252,19 @@ func NewRepository(db *sql.DB) *Repository {
 		CommentReactionRepository: NewCommentReactionSqlite3(db),
 		CategoryRepository:        NewCategorySqlite3(db),
 		ReportRepository:          NewReportSqlite3(db),
+		TwoFactorRepository:       NewTwoFactorSqlite3(db),
+		SettingRepository:         NewSettingSqlite3(db),
+		TokenRepository:           NewTokenSqlite3(db),
+		LoginAttemptRepository:    NewLoginAttemptSqlite3(db),
+		IdentityRepository:        NewIdentitySqlite3(db),
+		APITokenRepository:        NewAPITokenSqlite3(db),
+		RoleRepository:            NewRoleSqlite3(db),
+		ModeratorRepository:       NewModeratorSqlite3(db),
+		BanRepository:             NewBanSqlite3(db),
+		AuditRepository:           NewAuditSqlite3(db),
+		QueueRepository:           NewQueueSqlite3(db),
+		AutomodRepository:         NewAutomodSqlite3(db),
+		RevisionRepository:        NewRevisionSqlite3(db),
+		DraftRepository:           NewDraftSqlite3(db),
 	}
 }
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type RevisionSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewRevisionSqlite3(db *sql.DB) *RevisionSqlite3 {
+	return &RevisionSqlite3{
+		DB: db,
+	}
+}
+
+// InsertRevision сохраняет следующую версию объекта. Если rev.Created пусто,
+// версия получает текущее время.
+func (r *RevisionSqlite3) InsertRevision(rev *entities.Revision) (int, error) {
+	var created sql.NullString
+	if rev.Created != "" {
+		t, err := time.Parse(time.RFC3339, rev.Created)
+		if err != nil {
+			return 0, err
+		}
+		created = sql.NullString{String: t.Format("2006-01-02 15:04:05"), Valid: true}
+	}
+
+	stmt := `INSERT INTO revisions (target_type, target_id, number, editor_id, title, content, categories, summary, created)
+	VALUES (?, ?, (SELECT COALESCE(MAX(number), 0) + 1 FROM revisions WHERE target_type = ? AND target_id = ?),
+		?, ?, ?, ?, ?, COALESCE(?, datetime('now')))`
+
+	result, err := r.DB.Exec(stmt, rev.TargetType, rev.TargetID, rev.TargetType, rev.TargetID,
+		rev.EditorID, rev.Title, rev.Content, joinIDs(rev.CategoryIDs), rev.Summary, created)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetRevisions возвращает версии объекта от первой к последней
+func (r *RevisionSqlite3) GetRevisions(targetType string, targetID int) ([]*entities.Revision, error) {
+	stmt := revisionSelect + ` WHERE r.target_type = ? AND r.target_id = ? ORDER BY r.number`
+
+	rows, err := r.DB.Query(stmt, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*entities.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *RevisionSqlite3) GetRevision(targetType string, targetID, number int) (*entities.Revision, error) {
+	stmt := revisionSelect + ` WHERE r.target_type = ? AND r.target_id = ? AND r.number = ?`
+
+	rev, err := scanRevision(r.DB.QueryRow(stmt, targetType, targetID, number))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+const revisionSelect = `SELECT r.id, r.target_type, r.target_id, r.number, r.editor_id, COALESCE(u.username, 'Deleted User'),
+	r.title, r.content, r.categories, r.summary, r.created
+	FROM revisions r LEFT JOIN users u ON u.id = r.editor_id`
+
+func scanRevision(row rowScanner) (*entities.Revision, error) {
+	rev := &entities.Revision{}
+	var categories, created string
+
+	err := row.Scan(&rev.ID, &rev.TargetType, &rev.TargetID, &rev.Number, &rev.EditorID, &rev.EditorName,
+		&rev.Title, &rev.Content, &categories, &rev.Summary, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	if rev.CategoryIDs, err = splitIDs(categories); err != nil {
+		return nil, err
+	}
+	if rev.Created, err = formatReportTime(created); err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// joinIDs и splitIDs хранят список id одной строкой: "1,3,7"
+func joinIDs(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitIDs(value string) ([]int, error) {
+	ids := []int{}
+	if value == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"forum/internal/entities"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type RoleSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewRoleSqlite3(db *sql.DB) *RoleSqlite3 {
+	return &RoleSqlite3{
+		DB: db,
+	}
+}
+
+func (r *RoleSqlite3) GetRolePermissions(role string) ([]string, error) {
+	var exists bool
+	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM roles WHERE name = ?)", role).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, entities.ErrNoRecord
+	}
+
+	rows, err := r.DB.Query("SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission", role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (r *RoleSqlite3) GetRole(name string) (*entities.Role, error) {
+	stmt := `SELECT name, description, builtin, created,
+	(SELECT COUNT(*) FROM users WHERE users.role = roles.name)
+	FROM roles WHERE name = ?`
+
+	role, err := scanRole(r.DB.QueryRow(stmt, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	role.Permissions, err = r.GetRolePermissions(name)
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (r *RoleSqlite3) ListRoles() ([]*entities.Role, error) {
+	stmt := `SELECT name, description, builtin, created,
+	(SELECT COUNT(*) FROM users WHERE users.role = roles.name)
+	FROM roles ORDER BY builtin DESC, name`
+	rows, err := r.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*entities.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, role := range roles {
+		role.Permissions, err = r.GetRolePermissions(role.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+func (r *RoleSqlite3) InsertRole(name, description string, permissions []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO roles (name, description, builtin, created)
+	VALUES (?, ?, false, datetime('now'))`
+	_, err = tx.Exec(stmt, name, description)
+	if err != nil {
+		var sqliteError sqlite3.Error
+		if errors.As(err, &sqliteError) && sqliteError.Code == sqlite3.ErrConstraint &&
+			strings.Contains(sqliteError.Error(), "roles.name") {
+			return entities.ErrDuplicateRole
+		}
+		return err
+	}
+
+	if err := insertRolePermissions(tx, name, permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *RoleSqlite3) UpdateRole(name, description string, permissions []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE roles SET description = ? WHERE name = ?", description, name)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = ?", name); err != nil {
+		return err
+	}
+	if err := insertRolePermissions(tx, name, permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteRole удаляет только пользовательские роли
+func (r *RoleSqlite3) DeleteRole(name string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM roles WHERE name = ? AND builtin = false", name)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = ?", name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *RoleSqlite3) SetUserRole(userID int, role string) error {
+	result, err := r.DB.Exec("UPDATE users SET role = ? WHERE id = ?", role, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+func insertRolePermissions(tx *sql.Tx, role string, permissions []string) error {
+	for _, p := range permissions {
+		_, err := tx.Exec("INSERT OR IGNORE INTO role_permissions (role, permission) VALUES (?, ?)", role, p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanRole(row rowScanner) (*entities.Role, error) {
+	role := &entities.Role{}
+	var created string
+
+	err := row.Scan(&role.Name, &role.Description, &role.Builtin, &created, &role.Users)
+	if err != nil {
+		return nil, err
+	}
+
+	createdTime, err := time.Parse("2006-01-02 15:04:05", created)
+	if err != nil {
+		return nil, err
+	}
+	role.Created = createdTime.Format(time.RFC3339)
+
+	return role, nil
+}
+
+// permissionMigrations - права, которые появились во встроенных ролях после
+// того, как роли уже были созданы. Новые базы получают их вместе с ролью.
+var permissionMigrations = []struct{ role, permission string }{
+	{entities.RoleModerator, entities.PermRevisionRollback},
+}
+
+// seedRoles создаёт встроенные роли. Права добавляются вместе с ролью, а
+// права из permissionMigrations - один раз, чтобы не вернуть те, которые
+// администратор потом убрал.
+func seedRoles(db *sql.DB) error {
+	builtin := []struct{ name, description string }{
+		{entities.RoleUser, "Regular member"},
+		{entities.RoleModerator, "Reviews new posts and reports them to administrators"},
+		{entities.RoleAdmin, "Has every permission"},
+	}
+
+	for _, b := range builtin {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT OR IGNORE INTO roles (name, description, builtin, created)
+		VALUES (?, ?, true, datetime('now'))`
+		result, err := tx.Exec(stmt, b.name, b.description)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if rows > 0 {
+			if err := insertRolePermissions(tx, b.name, entities.BuiltinRolePermissions[b.name]); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return migratePermissions(db)
+}
+
+func migratePermissions(db *sql.DB) error {
+	for _, m := range permissionMigrations {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT OR IGNORE INTO role_permission_migrations (role, permission) VALUES (?, ?)`
+		result, err := tx.Exec(stmt, m.role, m.permission)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if rows > 0 {
+			stmt = `INSERT OR IGNORE INTO role_permissions (role, permission) VALUES (?, ?)`
+			if _, err := tx.Exec(stmt, m.role, m.permission); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migrate %s permission %s: %w", m.role, m.permission, err)
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
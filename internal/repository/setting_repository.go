@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"forum/internal/entities"
+)
+
+type SettingSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewSettingSqlite3(db *sql.DB) *SettingSqlite3 {
+	return &SettingSqlite3{
+		DB: db,
+	}
+}
+
+func (r *SettingSqlite3) GetSetting(key string) (string, error) {
+	var value string
+	err := r.DB.QueryRow("SELECT value FROM settings WHERE key = ?", key).Scan(&value)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", entities.ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+func (r *SettingSqlite3) SetSetting(key, value string) error {
+	stmt := `INSERT INTO settings (key, value) VALUES (?, ?)
+	ON CONFLICT(key) DO UPDATE SET value = excluded.value`
+	_, err := r.DB.Exec(stmt, key, value)
+	return err
+}
@@ -2,9 +2,57 @@ package repository
 
 import (
 	"database/sql"
+	"fmt"
+
+	"forum/pkg/validator"
 	"forum/schema"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
+// columnMigrations добавляет колонки, появившиеся после создания таблиц:
+// CREATE TABLE IF NOT EXISTS в forum.sql не меняет уже существующие базы.
+// backfill выполняется один раз сразу после добавления колонки.
+var columnMigrations = []struct {
+	table, column, definition, backfill string
+}{
+	{"sessions", "created", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"sessions", "ip", "TEXT NOT NULL DEFAULT ''", ""},
+	{"sessions", "user_agent", "TEXT NOT NULL DEFAULT ''", ""},
+	// Адреса пользователей, зарегистрированных до появления проверки, считаем подтверждёнными
+	{"users", "email_verified", "BOOLEAN NOT NULL DEFAULT false", "UPDATE users SET email_verified = true"},
+	{"tokens", "data", "TEXT NOT NULL DEFAULT ''", ""},
+	{"users", "failed_logins", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"users", "locked_until", "TEXT", ""},
+	{"user_bans", "shadow", "BOOLEAN NOT NULL DEFAULT false", ""},
+	{"moderation_actions", "target_type", "TEXT NOT NULL DEFAULT 'user'", ""},
+	{"moderation_actions", "target_id", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"moderation_actions", "reason", "TEXT NOT NULL DEFAULT ''", ""},
+	{"moderation_actions", "before_state", "TEXT NOT NULL DEFAULT ''", ""},
+	{"moderation_actions", "after_state", "TEXT NOT NULL DEFAULT ''", ""},
+	// До жалоб на комментарии и профили жаловаться можно было только на посты
+	{"reports", "target_type", "TEXT NOT NULL DEFAULT 'post'", ""},
+	{"reports", "target_id", "INTEGER NOT NULL DEFAULT 0", "UPDATE reports SET target_id = post_id"},
+	{"reports", "target_user_id", "INTEGER NOT NULL DEFAULT 0",
+		"UPDATE reports SET target_user_id = COALESCE((SELECT user_id FROM posts WHERE posts.id = reports.post_id), 0)"},
+	{"reports", "category", "TEXT NOT NULL DEFAULT ''", "UPDATE reports SET category = 'Other'"},
+	{"reports", "status", "TEXT NOT NULL DEFAULT 'open'", ""},
+	{"reports", "updated", "TEXT NOT NULL DEFAULT ''", "UPDATE reports SET updated = created"},
+	{"posts", "rejected", "TEXT", ""},
+	{"posts", "rejection_reason", "TEXT NOT NULL DEFAULT ''", ""},
+	{"notifications", "note", "TEXT NOT NULL DEFAULT ''", ""},
+	{"comments", "hidden", "BOOLEAN NOT NULL DEFAULT false", ""},
+	// HTML старых постов и комментариев собирается при показе
+	{"posts", "content_html", "TEXT NOT NULL DEFAULT ''", ""},
+	{"comments", "content_html", "TEXT NOT NULL DEFAULT ''", ""},
+	// Скелеты имён считает Go, см. migrateUsernameSkeletons
+	{"users", "username_skeleton", "TEXT NOT NULL DEFAULT ''", ""},
+	{"posts", "edited", "TEXT", ""},
+	// Заполняет migratePasswordFlags
+	{"users", "has_password", "BOOLEAN", ""},
+	{"comments", "edited", "TEXT", ""},
+}
+
 func NewSqliteDB(dsn string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
@@ -28,6 +76,18 @@ func InitSqliteDB(db *sql.DB) error {
 		return err
 	}
 
+	if err = migrateColumns(db); err != nil {
+		return err
+	}
+
+	if err = migrateReports(db); err != nil {
+		return err
+	}
+
+	if err = seedRoles(db); err != nil {
+		return err
+	}
+
 	// Проверка наличия данных
 	var count int
 	row := db.QueryRow("SELECT COUNT(*) FROM posts")
@@ -36,20 +96,161 @@ func InitSqliteDB(db *sql.DB) error {
 		return err
 	}
 
-	if count > 0 {
-		return nil // Данные уже есть, ничего не делаем
+	if count == 0 {
+		// Добавление тестовых данных
+		insertTestdataSQL, err := schema.Files.ReadFile("testdata.sql")
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec(string(insertTestdataSQL))
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = migrateUsernameSkeletons(db); err != nil {
+		return err
+	}
+
+	return migratePasswordFlags(db)
+}
+
+func migrateColumns(db *sql.DB) error {
+	for _, m := range columnMigrations {
+		var exists bool
+		stmt := "SELECT EXISTS(SELECT 1 FROM pragma_table_info(?) WHERE name = ?)"
+		if err := db.QueryRow(stmt, m.table, m.column).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		stmt = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("migrate %s.%s: %w", m.table, m.column, err)
+		}
+
+		if m.backfill != "" {
+			if _, err := db.Exec(m.backfill); err != nil {
+				return fmt.Errorf("backfill %s.%s: %w", m.table, m.column, err)
+			}
+		}
 	}
+	return nil
+}
 
-	// Добавление тестовых данных
-	insertTestdataSQL, err := schema.Files.ReadFile("testdata.sql")
+// migrateUsernameSkeletons заполняет скелеты имён, которых ещё нет: у
+// пользователей, созданных до проверки похожих имён, и у тестовых. Индекс не
+// уникальный: среди старых имён уже могут быть похожие.
+func migrateUsernameSkeletons(db *sql.DB) error {
+	rows, err := db.Query("SELECT id, username FROM users WHERE username_skeleton = ''")
 	if err != nil {
 		return err
 	}
+	skeletons := map[int]string{}
+	for rows.Next() {
+		var id int
+		var username string
+		if err = rows.Scan(&id, &username); err != nil {
+			rows.Close()
+			return err
+		}
+		skeletons[id] = validator.UsernameSkeleton(username)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for id, skeleton := range skeletons {
+		if _, err = db.Exec("UPDATE users SET username_skeleton = ? WHERE id = ?", skeleton, id); err != nil {
+			return fmt.Errorf("backfill users.username_skeleton: %w", err)
+		}
+	}
 
-	_, err = db.Exec(string(insertTestdataSQL))
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS users_idx_username_skeleton ON users(username_skeleton)")
+	return err
+}
+
+// migratePasswordFlags отмечает, у кого из старых пользователей есть пароль.
+// Аккаунтам, созданным через OAuth, раньше записывался хэш пустого пароля,
+// отличить их можно только сравнением, поэтому оно делается один раз здесь,
+// а не при каждом входе.
+func migratePasswordFlags(db *sql.DB) error {
+	rows, err := db.Query("SELECT id, password FROM users WHERE has_password IS NULL")
 	if err != nil {
 		return err
 	}
+	flags := map[int]bool{}
+	for rows.Next() {
+		var id int
+		var hashedPassword []byte
+		if err = rows.Scan(&id, &hashedPassword); err != nil {
+			rows.Close()
+			return err
+		}
+		flags[id] = bcrypt.CompareHashAndPassword(hashedPassword, []byte("")) != nil
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
 
+	for id, hasPassword := range flags {
+		if _, err = db.Exec("UPDATE users SET has_password = ? WHERE id = ?", hasPassword, id); err != nil {
+			return fmt.Errorf("backfill users.has_password: %w", err)
+		}
+	}
 	return nil
 }
+
+// migrateReports пересоздаёт таблицу жалоб без внешних ключей: жалоба на
+// профиль не относится к посту, история жалоб должна остаться после удаления
+// поста, а у жалоб автомодерации нет автора. ALTER TABLE в SQLite не умеет
+// удалять ограничения.
+func migrateReports(db *sql.DB) error {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM pragma_foreign_key_list('reports'))`
+	if err := db.QueryRow(stmt).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		`CREATE TABLE reports_new(
+			id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+			post_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			reason TEXT NOT NULL,
+			created TEXT NOT NULL,
+			target_type TEXT NOT NULL DEFAULT 'post',
+			target_id INTEGER NOT NULL DEFAULT 0,
+			target_user_id INTEGER NOT NULL DEFAULT 0,
+			category TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'open',
+			updated TEXT NOT NULL DEFAULT ''
+		)`,
+		`INSERT INTO reports_new (id, post_id, user_id, reason, created, target_type, target_id, target_user_id,
+			category, status, updated)
+		SELECT id, post_id, user_id, reason, created, target_type, target_id, target_user_id, category, status, updated
+		FROM reports`,
+		`DROP TABLE reports`,
+		`ALTER TABLE reports_new RENAME TO reports`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(s); err != nil {
+			return fmt.Errorf("migrate reports: %w", err)
+		}
+	}
+	return tx.Commit()
+}
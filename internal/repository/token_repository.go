@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type TokenSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewTokenSqlite3(db *sql.DB) *TokenSqlite3 {
+	return &TokenSqlite3{
+		DB: db,
+	}
+}
+
+func (r *TokenSqlite3) InsertToken(userID int, purpose, hash, data string, ttl time.Duration) error {
+	// Заодно чистим просроченные токены
+	_, err := r.DB.Exec("DELETE FROM tokens WHERE expiry <= datetime('now')")
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO tokens (hash, user_id, purpose, data, expiry, created)
+	VALUES (?, ?, ?, ?, datetime('now', ?), datetime('now'))`
+	_, err = r.DB.Exec(stmt, hash, userID, purpose, data, fmt.Sprintf("+%d seconds", int(ttl.Seconds())))
+	return err
+}
+
+func (r *TokenSqlite3) GetTokenUserID(purpose, hash string) (int, error) {
+	var userID int
+	stmt := `SELECT user_id FROM tokens
+	WHERE hash = ? AND purpose = ? AND expiry > datetime('now')`
+	err := r.DB.QueryRow(stmt, hash, purpose).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, entities.ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
+// ConsumeToken удаляет токен и возвращает его владельца и данные, поэтому
+// токен срабатывает только один раз.
+func (r *TokenSqlite3) ConsumeToken(purpose, hash string) (int, string, error) {
+	var userID int
+	var data string
+	stmt := `DELETE FROM tokens
+	WHERE hash = ? AND purpose = ? AND expiry > datetime('now')
+	RETURNING user_id, data`
+	err := r.DB.QueryRow(stmt, hash, purpose).Scan(&userID, &data)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", entities.ErrNoRecord
+		} else {
+			return 0, "", err
+		}
+	}
+	return userID, data, nil
+}
+
+func (r *TokenSqlite3) DeleteUserTokens(userID int, purpose string) error {
+	_, err := r.DB.Exec("DELETE FROM tokens WHERE user_id = ? AND purpose = ?", userID, purpose)
+	return err
+}
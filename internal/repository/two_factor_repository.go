@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"forum/internal/entities"
+)
+
+type TwoFactorSqlite3 struct {
+	DB *sql.DB
+}
+
+func NewTwoFactorSqlite3(db *sql.DB) *TwoFactorSqlite3 {
+	return &TwoFactorSqlite3{
+		DB: db,
+	}
+}
+
+func (r *TwoFactorSqlite3) GetTwoFactor(userID int) (*entities.TwoFactor, error) {
+	stmt := `SELECT user_id, secret, enabled, last_counter, created,
+	(SELECT COUNT(*) FROM recovery_codes WHERE user_id = t.user_id AND used IS NULL)
+	FROM user_totp AS t WHERE user_id = ?`
+
+	tf := &entities.TwoFactor{}
+	var created string
+
+	err := r.DB.QueryRow(stmt, userID).Scan(&tf.UserID, &tf.Secret, &tf.Enabled, &tf.LastCounter, &created, &tf.RecoveryCodesLeft)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	createdTime, err := time.Parse("2006-01-02 15:04:05", created)
+	if err != nil {
+		return nil, err
+	}
+	tf.Created = createdTime.Format(time.RFC3339)
+
+	return tf, nil
+}
+
+// SetPendingSecret сохраняет секрет, который ещё не подтверждён кодом.
+// Включённую двухфакторную аутентификацию он не перезаписывает.
+func (r *TwoFactorSqlite3) SetPendingSecret(userID int, secret string) error {
+	stmt := `INSERT INTO user_totp (user_id, secret, enabled, last_counter, created)
+	VALUES (?, ?, false, 0, datetime('now'))
+	ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, created = excluded.created
+	WHERE enabled = false`
+	_, err := r.DB.Exec(stmt, userID, secret)
+	return err
+}
+
+func (r *TwoFactorSqlite3) EnableTwoFactor(userID int, counter int64, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE user_totp SET enabled = true, last_counter = ?
+	WHERE user_id = ? AND enabled = false`
+	result, err := tx.Exec(stmt, counter, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TwoFactorSqlite3) DeleteTwoFactor(userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM user_totp WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseCounter запоминает принятый шаг TOTP. Возвращает false, если код этого
+// или более позднего шага уже использовался.
+func (r *TwoFactorSqlite3) UseCounter(userID int, counter int64) (bool, error) {
+	stmt := `UPDATE user_totp SET last_counter = ?
+	WHERE user_id = ? AND enabled = true AND last_counter < ?`
+	result, err := r.DB.Exec(stmt, counter, userID, counter)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (r *TwoFactorSqlite3) ReplaceRecoveryCodes(userID int, recoveryCodeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TwoFactorSqlite3) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	stmt := `UPDATE recovery_codes SET used = datetime('now')
+	WHERE user_id = ? AND code_hash = ? AND used IS NULL`
+	result, err := r.DB.Exec(stmt, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, recoveryCodeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, hash := range recoveryCodeHashes {
+		if _, err := stmt.Exec(userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
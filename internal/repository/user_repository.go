@@ -3,10 +3,12 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"forum/internal/entities"
+	"forum/pkg/validator"
 
 	"github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
@@ -35,10 +37,22 @@ func (r *UserSqlite3) Insert(username, email, password, role string) (int, error
 		return 0, err
 	}
 
-	stmt := `INSERT INTO users (username, email, password, role, created)
-    VALUES(?, ?, ?, ?, datetime('now'))`
+	// Имя, которое на экране не отличить от чужого, занято
+	skeleton := validator.UsernameSkeleton(username)
+	var similar bool
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE username_skeleton = ? AND username != ?)"
+	if err = r.DB.QueryRow(stmt, skeleton, username).Scan(&similar); err != nil {
+		return 0, err
+	}
+	if similar {
+		return 0, entities.ErrSimilarUsername
+	}
+
+	// Пустой пароль у аккаунтов, созданных через OAuth: войти можно только через провайдера
+	stmt = `INSERT INTO users (username, email, password, role, created, username_skeleton, has_password)
+    VALUES(?, ?, ?, ?, datetime('now'), ?, ?)`
 
-	result, err := r.DB.Exec(stmt, username, email, string(hashedPassword), role)
+	result, err := r.DB.Exec(stmt, username, email, string(hashedPassword), role, skeleton, password != "")
 	if err != nil {
 		var sqliteError sqlite3.Error
 		if errors.As(err, &sqliteError) {
@@ -63,23 +77,6 @@ func (r *UserSqlite3) Insert(username, email, password, role string) (int, error
 	return int(userID), nil
 }
 
-func (r *UserSqlite3) OauthAuthenticate(email string) (*entities.User, error) {
-	u := &entities.User{}
-
-	stmt := "SELECT id, role FROM users WHERE email = ?"
-
-	err := r.DB.QueryRow(stmt, email).Scan(&u.ID, &u.Role)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, entities.ErrInvalidCredentials
-		} else {
-			return nil, err
-		}
-	}
-
-	return u, nil
-}
-
 func (r *UserSqlite3) Authenticate(email, password string) (*entities.User, error) {
 	u := &entities.User{}
 
@@ -107,14 +104,14 @@ func (r *UserSqlite3) Authenticate(email, password string) (*entities.User, erro
 }
 
 func (r *UserSqlite3) Get(id int) (*entities.User, error) {
-	stmt := `SELECT id, username, email, role, created, role FROM users WHERE id = ?`
+	stmt := `SELECT id, username, email, role, created, role, email_verified FROM users WHERE id = ?`
 
 	row := r.DB.QueryRow(stmt, id)
 
 	u := &entities.User{}
 	var created string
 
-	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &created, &u.Role)
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &created, &u.Role, &u.EmailVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, entities.ErrNoRecord
@@ -162,14 +159,30 @@ func (r *UserSqlite3) UpdatePassword(id int, currentPassword, newPassword string
 	return err
 }
 
-func (r *UserSqlite3) InsertModerationRequest(userId int, reason string) error {
+func (r *UserSqlite3) InsertModerationRequest(userId int, reason string, categoryIDs []int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	stmt := `INSERT INTO moderation_requests (user_id, created, reason)
 	VALUES (?, datetime('now'), ?)`
-	_, err := r.DB.Exec(stmt, userId, reason)
+	_, err = tx.Exec(stmt, userId, reason)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	if _, err := tx.Exec("DELETE FROM moderation_request_categories WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+	for _, id := range categoryIDs {
+		stmt := "INSERT OR IGNORE INTO moderation_request_categories (user_id, category_id) VALUES (?, ?)"
+		if _, err := tx.Exec(stmt, userId, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (r *UserSqlite3) ExistsModerationRequest(userId int) (bool, error) {
@@ -215,13 +228,86 @@ func (r *UserSqlite3) ListModeratorApplicants() ([]*entities.ModeratorApplicant,
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	for _, applicant := range Applicants {
+		applicant.Categories, err = userCategories(r.DB, "moderation_request_categories", applicant.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return Applicants, nil
 }
 
-func (r *UserSqlite3) GetModerators() ([]*entities.User, error) {
-	stmt := `SELECT id, username, email, role FROM users
-	WHERE role = ?`
-	rows, err := r.DB.Query(stmt, entities.RoleModerator)
+func (r *UserSqlite3) DeleteModerator(userId int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users
+	SET role = ?
+	WHERE id = ?`
+	_, err = tx.Exec(stmt, entities.RoleUser, userId)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM moderator_categories WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ApproveModeratorRequest делает пользователя модератором тех категорий,
+// которые он выбрал в заявке
+func (r *UserSqlite3) ApproveModeratorRequest(userId int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users
+	SET role = ?
+	WHERE id = ?`
+	_, err = tx.Exec(stmt, entities.RoleModerator, userId)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM moderator_categories WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+	stmt = `INSERT INTO moderator_categories (user_id, category_id)
+	SELECT user_id, category_id FROM moderation_request_categories WHERE user_id = ?`
+	if _, err := tx.Exec(stmt, userId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *UserSqlite3) DeleteModerationRequest(userId int) error {
+	stmt := `DELETE FROM moderation_requests
+	WHERE user_id = ?`
+	_, err := r.DB.Exec(stmt, userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Exec("DELETE FROM moderation_request_categories WHERE user_id = ?", userId)
+	return err
+}
+
+func (r *UserSqlite3) GetByEmail(email string) (*entities.User, error) {
+	u := &entities.User{}
+
+	stmt := `SELECT id, username, email, role, email_verified, failed_logins, locked_until
+	FROM users WHERE email = ?`
+
+	var lockedUntil sql.NullString
+	err := r.DB.QueryRow(stmt, email).Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.EmailVerified, &u.FailedLogins, &lockedUntil)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, entities.ErrNoRecord
@@ -229,13 +315,132 @@ func (r *UserSqlite3) GetModerators() ([]*entities.User, error) {
 			return nil, err
 		}
 	}
+
+	u.LockedUntil, err = formatNullTime(lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// SetPassword меняет пароль без проверки текущего, например при сбросе по почте
+func (r *UserSqlite3) SetPassword(id int, newPassword string) error {
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE users SET password = ?, has_password = true WHERE id = ?"
+
+	_, err = r.DB.Exec(stmt, string(newHashedPassword), id)
+	return err
+}
+
+func (r *UserSqlite3) CheckPassword(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := "SELECT password FROM users WHERE id = ?"
+
+	err := r.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entities.ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrHashTooShort) {
+			return entities.ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasPassword сообщает, задан ли у пользователя пароль. У аккаунтов,
+// созданных через OAuth, пароля нет, пока его не зададут сбросом.
+func (r *UserSqlite3) HasPassword(id int) (bool, error) {
+	var hasPassword bool
+	err := r.DB.QueryRow("SELECT has_password FROM users WHERE id = ?", id).Scan(&hasPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, entities.ErrNoRecord
+		}
+		return false, err
+	}
+	return hasPassword, nil
+}
+
+func (r *UserSqlite3) SetEmailVerified(id int) error {
+	_, err := r.DB.Exec("UPDATE users SET email_verified = true WHERE id = ?", id)
+	return err
+}
+
+// UpdateEmail меняет адрес на уже подтверждённый новый
+func (r *UserSqlite3) UpdateEmail(id int, email string) error {
+	stmt := "UPDATE users SET email = ?, email_verified = true WHERE id = ?"
+	_, err := r.DB.Exec(stmt, email, id)
+	if err != nil {
+		var sqliteError sqlite3.Error
+		if errors.As(err, &sqliteError) {
+			if sqliteError.Code == sqlite3.ErrConstraint && strings.Contains(sqliteError.Error(), "users.email") {
+				return entities.ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// RegisterFailedLogin увеличивает счётчик неудачных входов подряд и возвращает его
+func (r *UserSqlite3) RegisterFailedLogin(id int) (int, error) {
+	var failed int
+	stmt := `UPDATE users SET failed_logins = failed_logins + 1 WHERE id = ?
+	RETURNING failed_logins`
+	err := r.DB.QueryRow(stmt, id).Scan(&failed)
+	return failed, err
+}
+
+func (r *UserSqlite3) LockUser(id int, until time.Time) error {
+	stmt := "UPDATE users SET locked_until = ? WHERE id = ?"
+	_, err := r.DB.Exec(stmt, until.UTC().Format("2006-01-02 15:04:05"), id)
+	return err
+}
+
+func (r *UserSqlite3) ResetFailedLogins(id int) error {
+	stmt := "UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = ?"
+	_, err := r.DB.Exec(stmt, id)
+	return err
+}
+
+// GetLockedUsers возвращает заблокированных сейчас пользователей и тех, у кого
+// есть неудачные попытки входа подряд
+func (r *UserSqlite3) GetLockedUsers() ([]*entities.User, error) {
+	stmt := `SELECT id, username, email, role, failed_logins, locked_until FROM users
+	WHERE failed_logins > 0 OR locked_until > datetime('now')
+	ORDER BY locked_until DESC, failed_logins DESC`
+	rows, err := r.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	var users []*entities.User
 	for rows.Next() {
 		user := &entities.User{}
+		var lockedUntil sql.NullString
+
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.FailedLogins, &lockedUntil); err != nil {
+			return nil, err
+		}
 
-		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role); err != nil {
+		user.LockedUntil, err = formatNullTime(lockedUntil)
+		if err != nil {
 			return nil, err
 		}
 
@@ -248,29 +453,193 @@ func (r *UserSqlite3) GetModerators() ([]*entities.User, error) {
 	return users, nil
 }
 
-func (r *UserSqlite3) DeleteModerator(userId int) error {
-	stmt := `UPDATE users
-	SET role = ?
-	WHERE id = ?`
-	_, err := r.DB.Exec(stmt, entities.RoleUser, userId)
-	return err
+func formatNullTime(value sql.NullString) (string, error) {
+	if !value.Valid {
+		return "", nil
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", value.String)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(time.RFC3339), nil
 }
 
-func (r *UserSqlite3) ApproveModeratorRequest(userId int) error {
-	stmt := `UPDATE users
-	SET role = ?
-	WHERE id = ?`
-	_, err := r.DB.Exec(stmt, entities.RoleModerator, userId)
-	return err
+// Пользователи со счётчиками. Последняя активность - самое позднее из
+// успешного входа, поста и комментария.
+const userOverviewSelect = `SELECT u.id, u.username, u.email, u.role, u.created, u.email_verified,
+	(SELECT COUNT(*) FROM posts p WHERE p.user_id = u.id) AS posts,
+	(SELECT COUNT(*) FROM comments c WHERE c.user_id = u.id) AS comments,
+	(SELECT COUNT(*) FROM reports rp WHERE rp.user_id = u.id) AS reports_filed,
+	(SELECT COUNT(*) FROM reports rp WHERE rp.target_user_id = u.id) AS reports_received,
+	NULLIF(MAX(
+		COALESCE((SELECT MAX(created) FROM login_attempts la WHERE la.user_id = u.id AND la.success = true), ''),
+		COALESCE((SELECT MAX(created) FROM posts p WHERE p.user_id = u.id), ''),
+		COALESCE((SELECT MAX(created) FROM comments c WHERE c.user_id = u.id), '')
+	), '') AS last_active,
+	EXISTS(SELECT true FROM user_bans b WHERE b.user_id = u.id AND ` + activeBanCondition + `) AS banned
+	FROM users u`
+
+// SearchUsers возвращает страницу пользователей по фильтру, новые первыми.
+// Возвращает на одну запись больше pageSize, если есть следующая страница.
+func (r *UserSqlite3) SearchUsers(filter *entities.UserFilter, page, pageSize int) ([]*entities.UserOverview, error) {
+	var conditions []string
+	var args []any
+
+	if filter.Query != "" {
+		pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+		conditions = append(conditions, `(username LIKE ? ESCAPE '\' OR email LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if filter.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, filter.Role)
+	}
+	if filter.JoinedFrom != "" {
+		conditions = append(conditions, "created >= date(?)")
+		args = append(args, filter.JoinedFrom)
+	}
+	if filter.JoinedTo != "" {
+		conditions = append(conditions, "created < date(?, '+1 day')")
+		args = append(args, filter.JoinedTo)
+	}
+
+	activeSince := fmt.Sprintf("datetime('now', '-%d days')", entities.ActivityWindowDays)
+	switch filter.Activity {
+	case entities.ActivityActive:
+		conditions = append(conditions, "last_active >= "+activeSince)
+	case entities.ActivityInactive:
+		conditions = append(conditions, "(last_active IS NULL OR last_active < "+activeSince+")")
+	}
+
+	stmt := "SELECT * FROM (" + userOverviewSelect + ")"
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	stmt += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, pageSize+1, (page-1)*pageSize)
+
+	rows, err := r.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*entities.UserOverview
+	for rows.Next() {
+		u, err := scanUserOverview(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
 }
 
-func (r *UserSqlite3) DeleteModerationRequest(userId int) error {
-	stmt := `DELETE FROM moderation_requests
-	WHERE user_id = ?`
-	_, err := r.DB.Exec(stmt, userId)
-	return err
+func (r *UserSqlite3) GetOverview(id int) (*entities.UserOverview, error) {
+	u, err := scanUserOverview(r.DB.QueryRow(userOverviewSelect+" WHERE u.id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, entities.ErrNoRecord
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func scanUserOverview(row rowScanner) (*entities.UserOverview, error) {
+	u := &entities.UserOverview{}
+	var created string
+	var lastActive sql.NullString
+
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &created, &u.EmailVerified,
+		&u.Posts, &u.Comments, &u.ReportsFiled, &u.ReportsReceived, &lastActive, &u.Banned)
+	if err != nil {
+		return nil, err
+	}
+
+	createdTime, err := time.Parse("2006-01-02 15:04:05", created)
+	if err != nil {
+		return nil, err
+	}
+	u.Created = createdTime.Format(time.RFC3339)
+
+	u.LastActive, err = formatNullTime(lastActive)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
 }
 
+// DeleteUser удаляет пользователя вместе с его постами, комментариями,
+// реакциями и жалобами. Зависимые строки удаляются явно: внешние ключи в
+// SQLite включаются на каждое соединение отдельно.
+func (r *UserSqlite3) DeleteUser(id int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userPosts := "SELECT id FROM posts WHERE user_id = ?"
+	userComments := "SELECT id FROM comments WHERE user_id = ? OR post_id IN (" + userPosts + ")"
+
+	stmts := []struct {
+		query string
+		args  int // сколько раз передать id
+	}{
+		{"DELETE FROM notifications WHERE user_id = ? OR trigger_user_id = ? OR post_id IN (" + userPosts + ")", 3},
+		{"DELETE FROM comment_reactions WHERE user_id = ? OR comment_id IN (" + userComments + ")", 3},
+		{"DELETE FROM revisions WHERE target_type = 'comment' AND target_id IN (" + userComments + ")", 2},
+		{"DELETE FROM revisions WHERE target_type = 'post' AND target_id IN (" + userPosts + ")", 1},
+		{"DELETE FROM comments WHERE id IN (" + userComments + ")", 2},
+		{"DELETE FROM post_reactions WHERE user_id = ? OR post_id IN (" + userPosts + ")", 2},
+		{"DELETE FROM report_events WHERE report_id IN (SELECT id FROM reports WHERE user_id = ? OR target_user_id = ?)", 2},
+		{"DELETE FROM reports WHERE user_id = ? OR target_user_id = ?", 2},
+		{"DELETE FROM post_categories WHERE post_id IN (" + userPosts + ")", 1},
+		{"DELETE FROM post_images WHERE post_id IN (" + userPosts + ")", 1},
+		{"DELETE FROM posts WHERE user_id = ?", 1},
+		{"DELETE FROM drafts WHERE user_id = ?", 1},
+		{"DELETE FROM moderation_requests WHERE user_id = ?", 1},
+		{"DELETE FROM moderation_request_categories WHERE user_id = ?", 1},
+		{"DELETE FROM moderator_categories WHERE user_id = ?", 1},
+		{"DELETE FROM sessions WHERE user_id = ?", 1},
+		{"DELETE FROM user_totp WHERE user_id = ?", 1},
+		{"DELETE FROM recovery_codes WHERE user_id = ?", 1},
+		{"DELETE FROM tokens WHERE user_id = ?", 1},
+		{"DELETE FROM login_attempts WHERE user_id = ?", 1},
+		{"DELETE FROM user_identities WHERE user_id = ?", 1},
+		{"DELETE FROM api_tokens WHERE user_id = ?", 1},
+		{"DELETE FROM user_bans WHERE user_id = ?", 1},
+		{"DELETE FROM queue_claims WHERE user_id = ? OR (target_type = 'user' AND target_id = ?)", 2},
+	}
+	for _, s := range stmts {
+		args := make([]any, s.args)
+		for i := range args {
+			args[i] = id
+		}
+		if _, err := tx.Exec(s.query, args...); err != nil {
+			return err
+		}
+	}
 
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return entities.ErrNoRecord
+	}
 
+	return tx.Commit()
+}
 
+// likeEscaper экранирует спецсимволы LIKE в пользовательском вводе
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
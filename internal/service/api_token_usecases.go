@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+// apiTokenPrefix помогает узнать токен в логах и при сканировании утечек
+const apiTokenPrefix = "fpat_"
+
+type APITokenUseCase struct {
+	apiTokenRepo repository.APITokenRepository
+	userRepo     repository.UserRepository
+	banRepo      repository.BanRepository
+}
+
+type apiTokenForm struct {
+	Name          string
+	Scopes        []string
+	ExpiresInDays string // "" - бессрочный
+	validator.Validator
+}
+
+func NewAPITokenUseCase(repo *repository.Repository) *APITokenUseCase {
+	return &APITokenUseCase{
+		apiTokenRepo: repo.APITokenRepository,
+		userRepo:     repo.UserRepository,
+		banRepo:      repo.BanRepository,
+	}
+}
+
+func (a *APITokenUseCase) NewAPITokenForm() apiTokenForm {
+	return apiTokenForm{
+		Scopes:        []string{entities.ScopeRead},
+		ExpiresInDays: "30",
+	}
+}
+
+// CreateAPIToken возвращает токен в открытом виде. Показать его можно только один раз.
+func (a *APITokenUseCase) CreateAPIToken(userID int, form *apiTokenForm) (string, error) {
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scopes", "Select at least one scope")
+	for _, scope := range form.Scopes {
+		form.CheckField(validator.PermittedValue(scope, entities.APITokenScopes...), "scopes", "Unknown scope")
+	}
+	form.CheckField(validator.PermittedValue(form.ExpiresInDays, "", "7", "30", "90", "365"), "expiresInDays", "Choose one of the listed periods")
+	if !form.Valid() {
+		return "", entities.ErrInvalidData
+	}
+
+	var expiry time.Time
+	if form.ExpiresInDays != "" {
+		days, _ := strconv.Atoi(form.ExpiresInDays)
+		expiry = time.Now().AddDate(0, 0, days)
+	}
+
+	plaintext, _, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+	plaintext = apiTokenPrefix + plaintext
+
+	_, err = a.apiTokenRepo.InsertAPIToken(userID, form.Name, hashToken(plaintext), plaintext[:len(apiTokenPrefix)+4], form.Scopes, expiry)
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+// AuthenticateAPIToken возвращает владельца токена и сам токен со списком прав
+func (a *APITokenUseCase) AuthenticateAPIToken(plaintext string) (*entities.User, *entities.APIToken, error) {
+	token, err := a.apiTokenRepo.GetActiveAPIToken(hashToken(plaintext))
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return nil, nil, entities.ErrInvalidAPIToken
+		}
+		return nil, nil, err
+	}
+
+	user, err := a.userRepo.Get(token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkBan(a.banRepo, user.ID); err != nil {
+		return nil, nil, err
+	}
+
+	err = a.apiTokenRepo.TouchAPIToken(token.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, token, nil
+}
+
+func (a *APITokenUseCase) ListAPITokens(userID int) ([]*entities.APIToken, error) {
+	return a.apiTokenRepo.ListAPITokens(userID)
+}
+
+func (a *APITokenUseCase) RevokeAPIToken(userID, tokenID int) error {
+	return a.apiTokenRepo.DeleteAPIToken(userID, tokenID)
+}
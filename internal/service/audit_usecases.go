@@ -0,0 +1,115 @@
+package service
+
+import (
+	"encoding/json"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+// Выгрузка журнала ограничена, чтобы один запрос не читал всю таблицу
+const auditExportLimit = 10000
+
+type AuditUseCase struct {
+	auditRepo repository.AuditRepository
+}
+
+type auditFilterForm struct {
+	entities.AuditFilter
+	validator.Validator
+}
+
+type AuditDTO struct {
+	Actions       []*entities.ModerationAction
+	HasNextPage   bool
+	CurrentPage   int
+	PaginationURL string
+}
+
+func NewAuditUseCase(repo *repository.Repository) *AuditUseCase {
+	return &AuditUseCase{
+		auditRepo: repo.AuditRepository,
+	}
+}
+
+func (uc *AuditUseCase) NewAuditFilterForm() auditFilterForm {
+	return auditFilterForm{}
+}
+
+func (uc *AuditUseCase) ListActions(form *auditFilterForm, page, pageSize int, paginationURL string) (*AuditDTO, error) {
+	if !form.validate() {
+		return nil, entities.ErrInvalidData
+	}
+
+	actions, err := uc.auditRepo.ListModerationActions(&form.AuditFilter, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(actions) > pageSize
+	if hasNextPage {
+		actions = actions[:pageSize]
+	}
+
+	return &AuditDTO{
+		Actions:       actions,
+		HasNextPage:   hasNextPage,
+		CurrentPage:   page,
+		PaginationURL: paginationURL,
+	}, nil
+}
+
+// ExportActions возвращает для выгрузки все записи по фильтру, но не больше auditExportLimit
+func (uc *AuditUseCase) ExportActions(form *auditFilterForm) ([]*entities.ModerationAction, error) {
+	if !form.validate() {
+		return nil, entities.ErrInvalidData
+	}
+	return uc.auditRepo.ListModerationActions(&form.AuditFilter, auditExportLimit, 0)
+}
+
+func (form *auditFilterForm) validate() bool {
+	form.CheckField(validator.MaxChars(form.Actor, 100), "actor", "This field cannot be more than 100 characters long")
+	form.CheckField(form.Action == "" || validator.PermittedValue(form.Action, entities.ModerationActions...), "action", "Unknown action")
+	form.CheckField(form.TargetType == "" || validator.PermittedValue(form.TargetType, entities.TargetTypes...), "target_type", "Unknown target type")
+	form.CheckField(form.TargetID >= 0, "target_id", "Enter a positive number")
+	form.CheckField(validDate(form.From), "from", "Enter a date like 2024-01-31")
+	form.CheckField(validDate(form.To), "to", "Enter a date like 2024-01-31")
+	return form.Valid()
+}
+
+// recordAction пишет действие сотрудника в журнал модерации. before и after
+// сохраняются как JSON-снимки, nil - без снимка.
+func recordAction(auditRepo repository.AuditRepository, action *entities.ModerationAction, before, after any) error {
+	var err error
+	if action.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if action.After, err = snapshot(after); err != nil {
+		return err
+	}
+	return auditRepo.InsertModerationAction(action)
+}
+
+func snapshot(v any) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// userAction - запись журнала о действии над самим пользователем
+func userAction(actorID int, action string, user *entities.User) *entities.ModerationAction {
+	return &entities.ModerationAction{
+		ActorID:      actorID,
+		Action:       action,
+		TargetType:   entities.TargetUser,
+		TargetID:     user.ID,
+		TargetUserID: user.ID,
+		TargetName:   user.Username,
+	}
+}
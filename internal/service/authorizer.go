@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"sync"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+)
+
+// AuthorizerUseCase - единственное место, где решается, что может роль.
+// Им пользуются и middleware обработчиков, и сервисы. Права ролей кешируются
+// в памяти, кеш сбрасывается при любом изменении ролей.
+type AuthorizerUseCase struct {
+	roleRepo      repository.RoleRepository
+	moderatorRepo repository.ModeratorRepository
+	categoryRepo  repository.CategoryRepository
+
+	mu    sync.RWMutex
+	cache map[string]map[string]bool
+}
+
+func NewAuthorizerUseCase(repo *repository.Repository) *AuthorizerUseCase {
+	return &AuthorizerUseCase{
+		roleRepo:      repo.RoleRepository,
+		moderatorRepo: repo.ModeratorRepository,
+		categoryRepo:  repo.CategoryRepository,
+		cache:         make(map[string]map[string]bool),
+	}
+}
+
+// Can сообщает, есть ли у роли право. Администратор может всё.
+// У неизвестной роли (например, удалённой) прав нет.
+func (a *AuthorizerUseCase) Can(role, permission string) (bool, error) {
+	if role == entities.RoleAdmin {
+		return true, nil
+	}
+
+	permissions, err := a.RolePermissions(role)
+	if err != nil {
+		return false, err
+	}
+	return permissions[permission], nil
+}
+
+// RolePermissions возвращает набор прав роли
+func (a *AuthorizerUseCase) RolePermissions(role string) (map[string]bool, error) {
+	if role == entities.RoleAdmin {
+		all := make(map[string]bool, len(entities.Permissions))
+		for _, p := range entities.Permissions {
+			all[p.Name] = true
+		}
+		return all, nil
+	}
+
+	a.mu.RLock()
+	permissions, ok := a.cache[role]
+	a.mu.RUnlock()
+	if ok {
+		return permissions, nil
+	}
+
+	list, err := a.roleRepo.GetRolePermissions(role)
+	if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+		return nil, err
+	}
+
+	permissions = make(map[string]bool, len(list))
+	for _, p := range list {
+		permissions[p] = true
+	}
+
+	a.mu.Lock()
+	a.cache[role] = permissions
+	a.mu.Unlock()
+
+	return permissions, nil
+}
+
+// IsStaff сообщает, что у роли есть хотя бы одно право, то есть это не обычный участник
+func (a *AuthorizerUseCase) IsStaff(role string) (bool, error) {
+	permissions, err := a.RolePermissions(role)
+	if err != nil {
+		return false, err
+	}
+	return len(permissions) > 0, nil
+}
+
+func (a *AuthorizerUseCase) invalidate() {
+	a.mu.Lock()
+	a.cache = make(map[string]map[string]bool)
+	a.mu.Unlock()
+}
+
+// CanOnPost проверяет право на конкретный пост с учётом категорий,
+// которые модерирует пользователь
+func (a *AuthorizerUseCase) CanOnPost(userID int, role string, postID int, permission string) (bool, error) {
+	allowed, err := a.Can(role, permission)
+	if err != nil || !allowed {
+		return false, err
+	}
+	return a.inScope(userID, role, postID)
+}
+
+// PostPermissions возвращает права пользователя на странице поста: права,
+// привязанные к посту, убираются, если пост не из его категорий
+func (a *AuthorizerUseCase) PostPermissions(userID int, role string, postID int) (map[string]bool, error) {
+	permissions, err := a.RolePermissions(role)
+	if err != nil {
+		return nil, err
+	}
+
+	inScope, err := a.inScope(userID, role, postID)
+	if err != nil || inScope {
+		return permissions, err
+	}
+
+	// Кешированный набор прав менять нельзя, поэтому копируем
+	scoped := make(map[string]bool, len(permissions))
+	for p, ok := range permissions {
+		scoped[p] = ok
+	}
+	for _, p := range entities.PostScopedPermissions {
+		delete(scoped, p)
+	}
+	return scoped, nil
+}
+
+// ModeratedCategoryIDs возвращает категории, которыми ограничен пользователь.
+// nil - ограничений нет.
+func (a *AuthorizerUseCase) ModeratedCategoryIDs(userID int, role string) ([]int, error) {
+	if role == entities.RoleAdmin {
+		return nil, nil
+	}
+	return a.moderatorRepo.GetModeratorCategoryIDs(userID)
+}
+
+func (a *AuthorizerUseCase) inScope(userID int, role string, postID int) (bool, error) {
+	scope, err := a.ModeratedCategoryIDs(userID, role)
+	if err != nil {
+		return false, err
+	}
+	if len(scope) == 0 {
+		return true, nil
+	}
+
+	categories, err := a.categoryRepo.GetCategoriesForPost(postID)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range categories {
+		for _, id := range scope {
+			if c.ID == id {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// canActOn разрешает действие над постом его владельцу или пользователю с
+// нужным правом в категориях поста. token - токен, с которым пришёл запрос,
+// nil для браузера: по токену чужое можно трогать только с правом moderate.
+func (a *AuthorizerUseCase) canActOn(user *entities.User, token *entities.APIToken, ownerID, postID int, permission string) (bool, error) {
+	if user.ID == ownerID {
+		return true, nil
+	}
+	if token != nil && !token.HasScope(entities.ScopeModerate) {
+		return false, nil
+	}
+	return a.CanOnPost(user.ID, user.Role, postID, permission)
+}
@@ -0,0 +1,374 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+// automodCategory - причина жалоб, которые подаёт автомодерация
+const automodCategory = "Automod"
+
+var linkRX = regexp.MustCompile(`(?i)https?://`)
+
+type AutomodUseCase struct {
+	automodRepo repository.AutomodRepository
+	reportRepo  repository.ReportRepository
+	postRepo    repository.PostRepository
+	commentRepo repository.CommentRepository
+}
+
+type automodRuleForm struct {
+	ID        int
+	Name      string
+	Target    string
+	Condition string
+	Value     string
+	Action    string
+	Enabled   bool
+	validator.Validator
+}
+
+type AutomodHitsDTO struct {
+	Hits          []*entities.AutomodHit
+	RuleID        int
+	HasNextPage   bool
+	CurrentPage   int
+	PaginationURL string
+}
+
+// automodText - текст поста или комментария, который проверяют правила
+type automodText struct {
+	TargetType string
+	TargetID   int
+	UserID     int
+	PostID     int
+	Text       string
+}
+
+// automodResult - общее решение сработавших правил
+type automodResult struct {
+	Review  bool     // queue или hide: текст ждёт модератора
+	Hide    bool     // hide
+	Approve bool     // approve и ни одного правила, требующего проверки
+	Flags   []string // названия правил, которые отмечают текст для модераторов
+}
+
+func NewAutomodUseCase(repo *repository.Repository) *AutomodUseCase {
+	return &AutomodUseCase{
+		automodRepo: repo.AutomodRepository,
+		reportRepo:  repo.ReportRepository,
+		postRepo:    repo.PostRepository,
+		commentRepo: repo.CommentRepository,
+	}
+}
+
+func (uc *AutomodUseCase) NewAutomodRuleForm() automodRuleForm {
+	return automodRuleForm{Enabled: true}
+}
+
+func (uc *AutomodUseCase) GetRules() ([]*entities.AutomodRule, error) {
+	return uc.automodRepo.GetRules()
+}
+
+// GetRuleForm возвращает форму с сохранённым правилом для редактирования
+func (uc *AutomodUseCase) GetRuleForm(id int) (*automodRuleForm, error) {
+	rule, err := uc.automodRepo.GetRule(id)
+	if err != nil {
+		return nil, err
+	}
+	return &automodRuleForm{
+		ID:        rule.ID,
+		Name:      rule.Name,
+		Target:    rule.Target,
+		Condition: rule.Condition,
+		Value:     rule.Value,
+		Action:    rule.Action,
+		Enabled:   rule.Enabled,
+	}, nil
+}
+
+// SaveRule создаёт правило или, если в форме есть ID, изменяет его
+func (uc *AutomodUseCase) SaveRule(form *automodRuleForm) error {
+	form.Name = strings.TrimSpace(form.Name)
+	form.Value = strings.TrimSpace(form.Value)
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(form.Target == "" || validator.PermittedValue(form.Target, entities.TargetPost, entities.TargetComment),
+		"target", "Unknown target")
+	form.CheckField(validator.PermittedValue(form.Action, entities.AutomodActions...), "action", "Unknown action")
+	if form.Action == entities.AutomodActionQueue || form.Action == entities.AutomodActionApprove {
+		form.CheckField(form.Target == entities.TargetPost, "action", "Only posts can be queued or approved")
+	}
+	form.CheckField(validator.MaxChars(form.Value, 500), "value", "This field cannot be more than 500 characters long")
+
+	switch form.Condition {
+	case entities.AutomodKeyword:
+		form.CheckField(len(splitKeywords(form.Value)) > 0, "value", "Enter at least one word")
+	case entities.AutomodRegex:
+		_, err := regexp.Compile(form.Value)
+		form.CheckField(validator.NotBlank(form.Value) && err == nil, "value", "Enter a valid regular expression")
+	case entities.AutomodLinks, entities.AutomodAccountAge, entities.AutomodApprovedPosts, entities.AutomodTrustedAge,
+		entities.AutomodTrustedPosts, entities.AutomodReportsPerHour:
+		n, err := strconv.Atoi(form.Value)
+		form.CheckField(err == nil && n > 0, "value", "Enter a positive number")
+	default:
+		form.AddFieldError("condition", "Unknown condition")
+	}
+
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	rule := &entities.AutomodRule{
+		ID:        form.ID,
+		Name:      form.Name,
+		Target:    form.Target,
+		Condition: form.Condition,
+		Value:     form.Value,
+		Action:    form.Action,
+		Enabled:   form.Enabled,
+	}
+	if rule.ID == 0 {
+		_, err := uc.automodRepo.InsertRule(rule)
+		return err
+	}
+	return uc.automodRepo.UpdateRule(rule)
+}
+
+func (uc *AutomodUseCase) DeleteRule(id int) error {
+	return uc.automodRepo.DeleteRule(id)
+}
+
+// GetHitsDTO возвращает журнал срабатываний; ruleID 0 - все правила
+func (uc *AutomodUseCase) GetHitsDTO(ruleID, page, pageSize int, paginationURL string) (*AutomodHitsDTO, error) {
+	hits, err := uc.automodRepo.GetHits(ruleID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(hits) > pageSize
+	if hasNextPage {
+		hits = hits[:pageSize]
+	}
+
+	return &AutomodHitsDTO{
+		Hits:          hits,
+		RuleID:        ruleID,
+		HasNextPage:   hasNextPage,
+		CurrentPage:   page,
+		PaginationURL: paginationURL,
+	}, nil
+}
+
+// checkPost проверяет созданный или изменённый пост. Пост, который ждёт
+// модератора, может быть одобрен правилом; опубликованный пост правило
+// queue или hide снимает с публикации.
+func (uc *AutomodUseCase) checkPost(post *entities.Post) error {
+	result, err := uc.check(&automodText{
+		TargetType: entities.TargetPost,
+		TargetID:   post.ID,
+		UserID:     post.UserID,
+		PostID:     post.ID,
+		Text:       post.Title + "\n" + post.Content,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Review && post.IsApproved:
+		if err = uc.postRepo.UnapprovePost(post.ID); err != nil {
+			return err
+		}
+		post.IsApproved = false
+	case result.Approve && !post.IsApproved && !post.IsRejected():
+		if err = uc.postRepo.ApprovePost(post.ID); err != nil {
+			return err
+		}
+		post.IsApproved = true
+	}
+
+	// Пост на одобрении модератор и так проверит, жалоба нужна только на опубликованный
+	if post.IsApproved && len(result.Flags) > 0 {
+		return uc.flag(entities.TargetPost, post.ID, post.UserID, post.ID, result.Flags)
+	}
+	return nil
+}
+
+// checkComment проверяет комментарий. Скрытый комментарий попадает в очередь
+// жалобой автомодерации: если модератор её отклонит, комментарий снова виден.
+func (uc *AutomodUseCase) checkComment(comment *entities.Comment) error {
+	result, err := uc.check(&automodText{
+		TargetType: entities.TargetComment,
+		TargetID:   comment.ID,
+		UserID:     comment.UserID,
+		PostID:     comment.PostID,
+		Text:       comment.Content,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.Hide && !comment.Hidden {
+		if err = uc.commentRepo.SetCommentHidden(comment.ID, true); err != nil {
+			return err
+		}
+		comment.Hidden = true
+	}
+
+	if len(result.Flags) > 0 {
+		return uc.flag(entities.TargetComment, comment.ID, comment.UserID, comment.PostID, result.Flags)
+	}
+	return nil
+}
+
+// check проверяет текст всеми включёнными правилами и записывает срабатывания
+func (uc *AutomodUseCase) check(text *automodText) (*automodResult, error) {
+	rules, err := uc.automodRepo.GetRules()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &automodResult{}
+	approve := false
+	var stats *entities.AuthorStats
+
+	for _, rule := range rules {
+		if !rule.Applies(text.TargetType) {
+			continue
+		}
+
+		// Сведения об авторе нужны не всем правилам, читаем их один раз
+		if stats == nil && needsStats(rule.Condition) {
+			if stats, err = uc.automodRepo.GetAuthorStats(text.UserID); err != nil {
+				return nil, err
+			}
+		}
+
+		matched, ok := matchRule(rule, text.Text, stats)
+		if !ok {
+			continue
+		}
+
+		err = uc.automodRepo.InsertHit(&entities.AutomodHit{
+			RuleID:     rule.ID,
+			RuleName:   rule.Name,
+			TargetType: text.TargetType,
+			TargetID:   text.TargetID,
+			UserID:     text.UserID,
+			Action:     rule.Action,
+			Matched:    matched,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		switch rule.Action {
+		case entities.AutomodActionQueue:
+			result.Review = true
+		case entities.AutomodActionHide:
+			result.Review = true
+			result.Hide = true
+			result.Flags = append(result.Flags, rule.Name)
+		case entities.AutomodActionApprove:
+			approve = true
+		case entities.AutomodActionFlag:
+			result.Flags = append(result.Flags, rule.Name)
+		}
+	}
+
+	// Правило, требующее проверки, сильнее одобрения
+	result.Approve = approve && !result.Review
+	return result, nil
+}
+
+// flag подаёт от имени автомодерации жалобу на текст, если такой ещё нет
+func (uc *AutomodUseCase) flag(targetType string, targetID, targetUserID, postID int, rules []string) error {
+	exists, err := uc.reportRepo.HasActiveReport(entities.AutomodReporterID, targetType, targetID)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = uc.reportRepo.CreateReport(&entities.Report{
+		UserID:       entities.AutomodReporterID,
+		TargetType:   targetType,
+		TargetID:     targetID,
+		TargetUserID: targetUserID,
+		PostID:       postID,
+		Category:     automodCategory,
+		Reason:       "Matched rules: " + strings.Join(rules, ", "),
+	})
+	return err
+}
+
+func needsStats(condition string) bool {
+	return condition != entities.AutomodKeyword && condition != entities.AutomodRegex &&
+		condition != entities.AutomodLinks
+}
+
+// matchRule проверяет условие правила и возвращает, что совпало
+func matchRule(rule *entities.AutomodRule, text string, stats *entities.AuthorStats) (string, bool) {
+	n, _ := strconv.Atoi(rule.Value)
+
+	switch rule.Condition {
+	case entities.AutomodKeyword:
+		lower := strings.ToLower(text)
+		for _, word := range splitKeywords(rule.Value) {
+			if strings.Contains(lower, word) {
+				return word, true
+			}
+		}
+	case entities.AutomodRegex:
+		// Выражение проверено при сохранении правила
+		rx, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return "", false
+		}
+		if loc := rx.FindStringIndex(text); loc != nil {
+			return excerpt(text[loc[0]:loc[1]], 100), true
+		}
+	case entities.AutomodLinks:
+		if links := len(linkRX.FindAllStringIndex(text, -1)); links >= n {
+			return fmt.Sprintf("%d links", links), true
+		}
+	case entities.AutomodAccountAge:
+		if stats.AccountAgeHours < n {
+			return fmt.Sprintf("account is %d hours old", stats.AccountAgeHours), true
+		}
+	case entities.AutomodApprovedPosts:
+		if stats.ApprovedPosts < n {
+			return fmt.Sprintf("%d approved posts", stats.ApprovedPosts), true
+		}
+	case entities.AutomodTrustedAge:
+		if stats.AccountAgeHours >= n {
+			return fmt.Sprintf("account is %d hours old", stats.AccountAgeHours), true
+		}
+	case entities.AutomodTrustedPosts:
+		if stats.ApprovedPosts >= n {
+			return fmt.Sprintf("%d approved posts", stats.ApprovedPosts), true
+		}
+	case entities.AutomodReportsPerHour:
+		if stats.ReportsLastHour >= n {
+			return fmt.Sprintf("%d reports in the last hour", stats.ReportsLastHour), true
+		}
+	}
+	return "", false
+}
+
+// splitKeywords - слова правила keyword в нижнем регистре
+func splitKeywords(value string) []string {
+	words := []string{}
+	for _, w := range strings.Split(value, ",") {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
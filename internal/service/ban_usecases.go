@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+const (
+	banHistoryLimit = 200
+	maxBanDays      = 3650
+)
+
+type BanUseCase struct {
+	banRepo    repository.BanRepository
+	userRepo   repository.UserRepository
+	auditRepo  repository.AuditRepository
+	authorizer *AuthorizerUseCase
+}
+
+type banForm struct {
+	UserID    int
+	Reason    string
+	Days      int
+	Permanent bool
+	Shadow    bool
+	validator.Validator
+}
+
+func NewBanUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase) *BanUseCase {
+	return &BanUseCase{
+		banRepo:    repo.BanRepository,
+		userRepo:   repo.UserRepository,
+		auditRepo:  repo.AuditRepository,
+		authorizer: authorizer,
+	}
+}
+
+func (uc *BanUseCase) NewBanForm() banForm {
+	return banForm{Days: 7}
+}
+
+// BanUser блокирует пользователя на form.Days дней или бессрочно. При теневом
+// бане пользователь может входить, но его посты, комментарии и реакции видит
+// только он сам. Администраторов блокировать нельзя, других сотрудников -
+// только администратору.
+func (uc *BanUseCase) BanUser(actorID int, form *banForm) error {
+	form.Reason = validator.Normalize(form.Reason)
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+	form.CheckField(validator.SafeText(form.Reason), "reason", "This field contains unsupported characters")
+	if !form.Permanent {
+		form.CheckField(form.Days >= 1 && form.Days <= maxBanDays, "days", "Suspension must last from 1 to 3650 days")
+	}
+
+	actor, err := uc.userRepo.Get(actorID)
+	if err != nil {
+		return err
+	}
+
+	target, err := uc.userRepo.Get(form.UserID)
+	if err != nil {
+		if !errors.Is(err, entities.ErrNoRecord) {
+			return err
+		}
+		form.AddFieldError("user_id", "User not found")
+		return entities.ErrInvalidData
+	}
+
+	form.CheckField(target.ID != actor.ID, "user_id", "You cannot suspend yourself")
+	form.CheckField(target.Role != entities.RoleAdmin, "user_id", "Administrators cannot be suspended")
+
+	staff, err := uc.authorizer.IsStaff(target.Role)
+	if err != nil {
+		return err
+	}
+	form.CheckField(!staff || actor.Role == entities.RoleAdmin, "user_id", "Only administrators can suspend staff members")
+
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	var expires time.Time
+	if !form.Permanent {
+		expires = time.Now().AddDate(0, 0, form.Days)
+	}
+
+	_, err = uc.banRepo.InsertBan(target.ID, actor.ID, form.Reason, expires, form.Shadow)
+	if err != nil {
+		return err
+	}
+
+	action := entities.ActionBan
+	if form.Shadow {
+		action = entities.ActionShadowBan
+	}
+	entry := userAction(actor.ID, action, target)
+	entry.Reason = form.Reason
+	return recordAction(uc.auditRepo, entry, nil, map[string]any{
+		"permanent": form.Permanent,
+		"days":      form.Days,
+		"shadow":    form.Shadow,
+	})
+}
+
+func (uc *BanUseCase) LiftBan(actorID, userID int) error {
+	target, err := uc.userRepo.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	err = uc.banRepo.LiftBan(userID, actorID)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(uc.auditRepo, userAction(actorID, entities.ActionLiftBan, target), nil, nil)
+}
+
+func (uc *BanUseCase) ListBans() ([]*entities.Ban, error) {
+	return uc.banRepo.ListBans(banHistoryLimit)
+}
+
+// CheckBan возвращает *entities.BanError, если пользователь сейчас заблокирован
+func (uc *BanUseCase) CheckBan(userID int) error {
+	return checkBan(uc.banRepo, userID)
+}
+
+func checkBan(banRepo repository.BanRepository, userID int) error {
+	ban, err := banRepo.GetActiveBan(userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+	return &entities.BanError{Ban: ban}
+}
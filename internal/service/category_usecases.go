@@ -8,6 +8,7 @@ import (
 
 type CategoryUseCase struct {
 	categoryRepo repository.CategoryRepository
+	auditRepo    repository.AuditRepository
 }
 type CategoryForm struct {
 	Name string
@@ -18,13 +19,17 @@ func (uc *CategoryUseCase) NewCategoryCreateForm() CategoryForm {
 	return CategoryForm{}
 }
 
-func NewCategoryUseCase(categoryRepo repository.CategoryRepository) *CategoryUseCase {
-	return &CategoryUseCase{categoryRepo: categoryRepo}
+func NewCategoryUseCase(repo *repository.Repository) *CategoryUseCase {
+	return &CategoryUseCase{
+		categoryRepo: repo.CategoryRepository,
+		auditRepo:    repo.AuditRepository,
+	}
 }
 
-func (u *CategoryUseCase) Insert(form *CategoryForm) (int, error) {
+func (u *CategoryUseCase) Insert(actorID int, form *CategoryForm) (int, error) {
+	form.Name = validator.Normalize(form.Name)
 	form.CheckField(validator.NotBlank(form.Name), "category", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Name, validator.TextRX), "category", "This field must contain only english or russian letters")
+	form.CheckField(validator.SafeText(form.Name) && validator.SingleLine(form.Name), "category", "This field contains unsupported characters")
 	exists, err := u.categoryRepo.ExistName(form.Name)
 	if err != nil {
 		return 0, err
@@ -37,7 +42,13 @@ func (u *CategoryUseCase) Insert(form *CategoryForm) (int, error) {
 		return 0, entities.ErrInvalidData
 	}
 
-	return u.categoryRepo.Insert(form.Name)
+	id, err := u.categoryRepo.Insert(form.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, recordAction(u.auditRepo, categoryAction(actorID, entities.ActionCategoryCreate, id, form.Name),
+		nil, map[string]any{"name": form.Name})
 }
 
 func (u *CategoryUseCase) Get(categoryId int) (*entities.Category, error) {
@@ -48,17 +59,30 @@ func (u *CategoryUseCase) GetAll() ([]*entities.Category, error) {
 	return u.categoryRepo.GetAll()
 }
 
-func (u *CategoryUseCase) Delete(categoryId int) error {
-	exists, err := u.categoryRepo.Exists(categoryId)
+func (u *CategoryUseCase) Delete(actorID, categoryId int) error {
+	category, err := u.categoryRepo.Get(categoryId)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return entities.ErrNoRecord
-	}
 	if categoryId == 1 {
 		return entities.ErrInvalidData
 	}
 
-	return u.categoryRepo.Delete(categoryId)
+	err = u.categoryRepo.Delete(categoryId)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(u.auditRepo, categoryAction(actorID, entities.ActionCategoryDelete, category.ID, category.Name),
+		map[string]any{"name": category.Name}, nil)
+}
+
+func categoryAction(actorID int, action string, categoryID int, name string) *entities.ModerationAction {
+	return &entities.ModerationAction{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: entities.TargetCategory,
+		TargetID:   categoryID,
+		TargetName: name,
+	}
 }
@@ -0,0 +1,323 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+// Публикацию можно запланировать не больше чем на год вперёд
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+type DraftUseCase struct {
+	draftRepo        repository.DraftRepository
+	categoryRepo     repository.CategoryRepository
+	postReactionRepo repository.PostReactionRepository
+	banRepo          repository.BanRepository
+	// Черновик публикуется так же, как новый пост из формы
+	posts     *PostUseCase
+	publishMu sync.Mutex
+}
+
+type draftForm struct {
+	ID int // 0 - новый черновик
+	postCreateForm
+	// PublishAt - время из <input type="datetime-local"> в часовом поясе автора,
+	// TimezoneOffset - его смещение в минутах, как у Date.getTimezoneOffset()
+	PublishAt      string
+	TimezoneOffset int
+}
+
+func NewDraftUseCase(repo *repository.Repository, posts *PostUseCase) *DraftUseCase {
+	return &DraftUseCase{
+		draftRepo:        repo.DraftRepository,
+		categoryRepo:     repo.CategoryRepository,
+		postReactionRepo: repo.PostReactionRepository,
+		banRepo:          repo.BanRepository,
+		posts:            posts,
+	}
+}
+
+func (uc *DraftUseCase) NewDraftForm() draftForm {
+	return draftForm{}
+}
+
+// GetDraft возвращает черновик автору; чужие черновики для него не существуют
+func (uc *DraftUseCase) GetDraft(userID, draftID int) (*entities.Draft, error) {
+	draft, err := uc.draftRepo.GetDraft(draftID)
+	if err != nil {
+		return nil, err
+	}
+	if draft.UserID != userID {
+		return nil, entities.ErrNoRecord
+	}
+	return draft, nil
+}
+
+func (uc *DraftUseCase) GetUserDrafts(userID int) ([]*entities.Draft, error) {
+	return uc.draftRepo.GetUserDrafts(userID)
+}
+
+// SaveDraft сохраняет черновик из формы. Черновик может быть неполным, но
+// если задано время публикации, он проверяется как готовый пост.
+func (uc *DraftUseCase) SaveDraft(userID int, form *draftForm) (int, error) {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return 0, err
+	}
+
+	form.checkDraft()
+
+	allCategories, err := uc.categoryRepo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	publishAt := ""
+	if strings.TrimSpace(form.PublishAt) != "" {
+		form.validateCategories(allCategories)
+		publishAt = form.publishTime()
+	} else {
+		form.Categories = knownCategories(form.Categories, allCategories)
+	}
+
+	if !form.Valid() {
+		return 0, entities.ErrInvalidCredentials
+	}
+
+	draft := &entities.Draft{
+		ID:          form.ID,
+		UserID:      userID,
+		Title:       form.Title,
+		Content:     form.Content,
+		CategoryIDs: form.Categories,
+		PublishAt:   publishAt,
+	}
+	if err = uc.saveDraft(draft); err != nil {
+		return 0, err
+	}
+	return draft.ID, nil
+}
+
+// AutosaveDraft сохраняет текст, пока автор пишет. Запланированное время
+// публикации при этом не меняется.
+func (uc *DraftUseCase) AutosaveDraft(userID int, form *draftForm) (int, error) {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return 0, err
+	}
+
+	form.checkDraft()
+	if !form.Valid() {
+		return 0, entities.ErrInvalidCredentials
+	}
+
+	allCategories, err := uc.categoryRepo.GetAll()
+	if err != nil {
+		return 0, err
+	}
+
+	draft := &entities.Draft{
+		ID:          form.ID,
+		UserID:      userID,
+		Title:       form.Title,
+		Content:     form.Content,
+		CategoryIDs: knownCategories(form.Categories, allCategories),
+	}
+	if form.ID != 0 {
+		saved, err := uc.GetDraft(userID, form.ID)
+		if err != nil {
+			return 0, err
+		}
+		draft.PublishAt = saved.PublishAt
+	}
+
+	if err = uc.saveDraft(draft); err != nil {
+		return 0, err
+	}
+	return draft.ID, nil
+}
+
+func (uc *DraftUseCase) saveDraft(draft *entities.Draft) error {
+	if draft.ID == 0 {
+		id, err := uc.draftRepo.InsertDraft(draft)
+		if err != nil {
+			return err
+		}
+		draft.ID = id
+		return nil
+	}
+
+	if _, err := uc.GetDraft(draft.UserID, draft.ID); err != nil {
+		return err
+	}
+	return uc.draftRepo.UpdateDraft(draft)
+}
+
+// PublishDraft публикует черновик сразу. Ошибки проверки поста остаются в form.
+func (uc *DraftUseCase) PublishDraft(userID, draftID int, form *draftForm) (int, error) {
+	return uc.publish(userID, draftID, form, false)
+}
+
+func (uc *DraftUseCase) DeleteDraft(userID, draftID int) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
+
+	if _, err := uc.GetDraft(userID, draftID); err != nil {
+		return err
+	}
+	return uc.draftRepo.DeleteDraft(draftID)
+}
+
+// PublishDueDrafts публикует черновики, время которых наступило. Черновик,
+// который не прошёл проверку, снимается с расписания с причиной: автор
+// увидит её в списке черновиков.
+func (uc *DraftUseCase) PublishDueDrafts() (int, error) {
+	drafts, err := uc.draftRepo.GetDueDrafts()
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, draft := range drafts {
+		form := uc.NewDraftForm()
+		postID, err := uc.publish(draft.UserID, draft.ID, &form, true)
+		if err != nil {
+			var banErr *entities.BanError
+			switch {
+			case errors.Is(err, entities.ErrNoRecord):
+				// автор успел удалить, перенести или опубликовать черновик сам
+				continue
+			case errors.Is(err, entities.ErrInvalidCredentials):
+				err = uc.draftRepo.FailDraft(draft.ID, "The post is incomplete: "+form.errorSummary())
+			case errors.As(err, &banErr):
+				err = uc.draftRepo.FailDraft(draft.ID, "Your account is suspended")
+			}
+			if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+				slog.Error("publish scheduled draft", "draft", draft.ID, "error", err)
+			}
+			continue
+		}
+
+		published++
+		err = uc.postReactionRepo.AddModerationNotification(draft.UserID, postID, draft.UserID, entities.NotificationPostPublished, "")
+		if err != nil {
+			slog.Error("notify about scheduled post", "post", postID, "error", err)
+		}
+	}
+	return published, nil
+}
+
+// publish создаёт пост из черновика и удаляет черновик. Пост, как и
+// созданный из формы, ждёт одобрения модератора или автомодерации.
+func (uc *DraftUseCase) publish(userID, draftID int, form *draftForm, scheduled bool) (int, error) {
+	// Автор может нажать "Опубликовать" в ту же секунду, когда черновик
+	// публикует планировщик: второй раз пост создаваться не должен
+	uc.publishMu.Lock()
+	defer uc.publishMu.Unlock()
+
+	draft, err := uc.GetDraft(userID, draftID)
+	if err != nil {
+		return 0, err
+	}
+
+	if scheduled {
+		due, err := draft.Due(time.Now())
+		if err != nil {
+			return 0, err
+		}
+		if !due {
+			return 0, entities.ErrNoRecord
+		}
+	}
+
+	if err = checkBan(uc.banRepo, draft.UserID); err != nil {
+		return 0, err
+	}
+
+	form.ID = draft.ID
+	form.Title, form.Content, form.Categories = draft.Title, draft.Content, draft.CategoryIDs
+	postID, _, err := uc.posts.CreatePostWithCategories(&form.postCreateForm, nil, draft.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = uc.draftRepo.DeleteDraft(draft.ID); err != nil {
+		return 0, err
+	}
+	return postID, nil
+}
+
+// checkDraft проверяет то, что есть в черновике: пустые поля допустимы
+func (form *draftForm) checkDraft() {
+	form.Title, form.Content = validator.Normalize(form.Title), validator.Normalize(form.Content)
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.SafeText(form.Title) && validator.SingleLine(form.Title), "title", "This field contains unsupported characters")
+	form.CheckField(validator.MaxChars(form.Content, maxPostChars), "content", "This field cannot be more than 20000 characters long")
+	form.CheckField(validator.SafeText(form.Content), "content", "This field contains unsupported characters")
+}
+
+// publishTime проверяет пост и время публикации и возвращает его в UTC
+func (form *draftForm) publishTime() string {
+	form.checkPost()
+
+	// getTimezoneOffset в браузере - от -840 (UTC+14) до 720 (UTC-12)
+	if form.TimezoneOffset < -840 || form.TimezoneOffset > 720 {
+		form.AddFieldError("publishAt", "Unknown time zone")
+		return ""
+	}
+
+	var local time.Time
+	var err error
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05"} {
+		if local, err = time.ParseInLocation(layout, strings.TrimSpace(form.PublishAt), time.UTC); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		form.AddFieldError("publishAt", "This field must be a valid date and time")
+		return ""
+	}
+
+	at := local.Add(time.Duration(form.TimezoneOffset) * time.Minute)
+	now := time.Now()
+	switch {
+	case !at.After(now):
+		form.AddFieldError("publishAt", "Choose a time in the future")
+	case at.After(now.Add(maxScheduleAhead)):
+		form.AddFieldError("publishAt", "Posts can be scheduled up to a year ahead")
+	}
+	return at.Format(time.RFC3339)
+}
+
+// errorSummary собирает ошибки проверки в одну строку для автора
+func (form *draftForm) errorSummary() string {
+	fields := []string{}
+	for _, key := range []string{"title", "content", "categories"} {
+		if message, ok := form.FieldErrors[key]; ok {
+			fields = append(fields, key+" - "+strings.ToLower(message))
+		}
+	}
+	return strings.Join(fields, "; ")
+}
+
+// knownCategories убирает из списка удалённые категории
+func knownCategories(ids []int, allCategories []*entities.Category) []int {
+	known := make(map[int]bool, len(allCategories))
+	for _, c := range allCategories {
+		known[c.ID] = true
+	}
+
+	result := []int{}
+	for _, id := range ids {
+		if known[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
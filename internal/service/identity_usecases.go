@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+// legacyProviders - провайдеры, через которые создавались аккаунты до
+// появления user_identities
+var legacyProviders = map[string]bool{"google": true, "github": true}
+
+type IdentityUseCase struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	banRepo      repository.BanRepository
+}
+
+type usernameForm struct {
+	Username string
+	validator.Validator
+}
+
+func NewIdentityUseCase(repo *repository.Repository) *IdentityUseCase {
+	return &IdentityUseCase{
+		userRepo:     repo.UserRepository,
+		identityRepo: repo.IdentityRepository,
+		banRepo:      repo.BanRepository,
+	}
+}
+
+func (i *IdentityUseCase) NewUsernameForm() usernameForm {
+	return usernameForm{}
+}
+
+// Authenticate ищет пользователя по привязанному внешнему аккаунту.
+// ErrNoRecord означает, что пользователя нужно зарегистрировать.
+func (i *IdentityUseCase) Authenticate(ext *entities.ExternalIdentity) (*entities.User, error) {
+	user, err := i.identityUser(ext)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBan(i.banRepo, user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (i *IdentityUseCase) identityUser(ext *entities.ExternalIdentity) (*entities.User, error) {
+	if err := checkIdentity(ext); err != nil {
+		return nil, err
+	}
+
+	userID, err := i.identityRepo.GetIdentityUserID(ext.Provider, ext.Subject)
+	if err == nil {
+		return i.userRepo.Get(userID)
+	}
+	if !errors.Is(err, entities.ErrNoRecord) {
+		return nil, err
+	}
+
+	user, err := i.userRepo.GetByEmail(ext.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	// Аккаунты, созданные через OAuth до появления привязок, не имеют ни пароля,
+	// ни записи в user_identities. Их привязываем по подтверждённому провайдером
+	// адресу, иначе владельцы потеряли бы доступ. Так можно только провайдерам,
+	// которые тогда были: у подключённых позже email_verified может оказаться
+	// под контролем самого пользователя. Аккаунт с паролем по email не
+	// привязывается никогда: его владелец должен сделать это сам.
+	if !legacyProviders[ext.Provider] || !ext.EmailVerified {
+		return nil, entities.ErrIdentityEmailTaken
+	}
+	hasPassword, err := i.userRepo.HasPassword(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if hasPassword {
+		return nil, entities.ErrIdentityEmailTaken
+	}
+
+	err = i.identityRepo.InsertIdentity(user.ID, ext.Provider, ext.Subject, ext.Email)
+	if err != nil {
+		return nil, err
+	}
+	return i.userRepo.Get(user.ID)
+}
+
+// SignUp создаёт пользователя для нового внешнего аккаунта с выбранным именем
+func (i *IdentityUseCase) SignUp(ext *entities.ExternalIdentity, form *usernameForm) (*entities.User, error) {
+	if err := checkIdentity(ext); err != nil {
+		return nil, err
+	}
+
+	form.Username = validator.Normalize(form.Username)
+	form.CheckField(validator.NotBlank(form.Username), "username", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Username, validator.UsernameRX), "username", "This field must be a valid username")
+	form.CheckField(validator.SingleScript(form.Username), "username", "Letters from different alphabets cannot be mixed")
+	form.CheckField(validator.MaxChars(form.Username, 100), "username", "This field cannot be more than 100 characters long")
+	if !form.Valid() {
+		return nil, entities.ErrInvalidData
+	}
+
+	userID, err := i.userRepo.Insert(form.Username, ext.Email, "", entities.RoleUser)
+	if err != nil {
+		if errors.Is(err, entities.ErrDuplicateUsername) {
+			form.AddFieldError("username", "Username is already in use")
+			return nil, entities.ErrInvalidData
+		}
+		if errors.Is(err, entities.ErrSimilarUsername) {
+			form.AddFieldError("username", "Username looks too much like an existing one")
+			return nil, entities.ErrInvalidData
+		}
+		if errors.Is(err, entities.ErrDuplicateEmail) {
+			return nil, entities.ErrIdentityEmailTaken
+		}
+		return nil, err
+	}
+
+	err = i.identityRepo.InsertIdentity(userID, ext.Provider, ext.Subject, ext.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	// Адрес, подтверждённый провайдером, повторно не проверяем
+	if ext.EmailVerified {
+		err = i.userRepo.SetEmailVerified(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return i.userRepo.Get(userID)
+}
+
+func (i *IdentityUseCase) Link(userID int, ext *entities.ExternalIdentity) error {
+	if err := checkIdentity(ext); err != nil {
+		return err
+	}
+
+	linkedUserID, err := i.identityRepo.GetIdentityUserID(ext.Provider, ext.Subject)
+	if err == nil {
+		if linkedUserID != userID {
+			return entities.ErrIdentityInUse
+		}
+		return nil
+	}
+	if !errors.Is(err, entities.ErrNoRecord) {
+		return err
+	}
+
+	// UNIQUE(user_id, provider) не даст привязать второй аккаунт того же провайдера
+	return i.identityRepo.InsertIdentity(userID, ext.Provider, ext.Subject, ext.Email)
+}
+
+// Unlink отвязывает провайдера, если у пользователя останется другой способ входа
+func (i *IdentityUseCase) Unlink(userID int, provider string) error {
+	identities, err := i.identityRepo.ListIdentities(userID)
+	if err != nil {
+		return err
+	}
+
+	hasPassword, err := i.userRepo.HasPassword(userID)
+	if err != nil {
+		return err
+	}
+	if !hasPassword && len(identities) <= 1 {
+		return entities.ErrLastLoginMethod
+	}
+
+	return i.identityRepo.DeleteIdentity(userID, provider)
+}
+
+func (i *IdentityUseCase) ListIdentities(userID int) ([]*entities.UserIdentity, error) {
+	return i.identityRepo.ListIdentities(userID)
+}
+
+// checkIdentity не пускает дальше внешний аккаунт без subject: иначе все такие
+// входы одного провайдера попали бы к пользователю, который привязался первым
+func checkIdentity(ext *entities.ExternalIdentity) error {
+	if ext.Provider == "" || ext.Subject == "" {
+		return entities.ErrIdentityNoSubject
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"forum/internal/entities"
+)
+
+const (
+	loginActivityLimit  = 100
+	loginActivityWindow = 24 * time.Hour
+)
+
+// LoginPolicy задаёт ограничения на подбор пароля
+type LoginPolicy struct {
+	MaxAttempts   int           // неудачных попыток подряд до блокировки аккаунта
+	LockoutBase   time.Duration // первая блокировка, каждая следующая вдвое длиннее
+	LockoutMax    time.Duration
+	IPMaxAttempts int // неудачных попыток с одного IP за IPWindow до первой паузы
+	IPWindow      time.Duration
+}
+
+type LoginActivityDTO struct {
+	LockedUsers []*entities.User
+	FailingIPs  []*entities.LoginIPStat
+	Attempts    []*entities.LoginAttempt
+}
+
+// Login проверяет пароль с учётом ограничений по IP и по аккаунту и пишет
+// каждую попытку в журнал.
+func (u *UserUseCase) Login(email, password, ip string) (*entities.User, error) {
+	throttled, err := u.ipThrottled(ip)
+	if err != nil {
+		return nil, err
+	}
+	if throttled {
+		return nil, entities.ErrTooManyAttempts
+	}
+
+	user, err := u.userRepo.GetByEmail(email)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return nil, u.failLogin(nil, email, ip)
+		}
+		return nil, err
+	}
+
+	if err = u.checkLocked(user, ip); err != nil {
+		return nil, err
+	}
+
+	authUser, err := u.userRepo.Authenticate(email, password)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidCredentials) {
+			return nil, u.failLogin(user, email, ip)
+		}
+		return nil, err
+	}
+
+	// С 2FA вход ещё не закончен: счётчик неудач сбросит верный код, иначе
+	// повторный ввод пароля давал бы бесконечно подбирать код
+	twoFactor, err := u.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !twoFactor {
+		if err = u.succeedLogin(user, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	// Причину блокировки показываем только тому, кто знает пароль
+	if err := checkBan(u.banRepo, user.ID); err != nil {
+		return nil, err
+	}
+
+	return authUser, nil
+}
+
+// LoginTwoFactor проверяет код второго шага входа. Неверный код - такая же
+// неудачная попытка, как неверный пароль, и ведёт к блокировке аккаунта.
+func (u *UserUseCase) LoginTwoFactor(userID int, ip string, form *TwoFactorForm) (*entities.User, error) {
+	authUser, err := u.userRepo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	// В GetByEmail есть счётчик неудач и срок блокировки
+	user, err := u.userRepo.GetByEmail(authUser.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = u.checkLocked(user, ip); err != nil {
+		return nil, err
+	}
+
+	err = u.twoFactor.Verify(userID, form)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidData) || errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			if failErr := u.failLogin(user, user.Email, ip); !errors.Is(failErr, entities.ErrInvalidCredentials) {
+				return nil, failErr
+			}
+		}
+		return nil, err
+	}
+
+	if err = u.succeedLogin(user, ip); err != nil {
+		return nil, err
+	}
+
+	if err := checkBan(u.banRepo, user.ID); err != nil {
+		return nil, err
+	}
+	return authUser, nil
+}
+
+// checkLocked возвращает *entities.LockoutError, если аккаунт сейчас заблокирован
+func (u *UserUseCase) checkLocked(user *entities.User, ip string) error {
+	if user.LockedUntil == "" {
+		return nil
+	}
+	until, err := time.Parse(time.RFC3339, user.LockedUntil)
+	if err != nil {
+		return err
+	}
+	if !time.Now().Before(until) {
+		return nil
+	}
+
+	err = u.loginAttemptRepo.InsertLoginAttempt(user.ID, user.Email, ip, false)
+	if err != nil {
+		return err
+	}
+	return &entities.LockoutError{User: user, Until: until}
+}
+
+func (u *UserUseCase) succeedLogin(user *entities.User, ip string) error {
+	err := u.loginAttemptRepo.InsertLoginAttempt(user.ID, user.Email, ip, true)
+	if err != nil {
+		return err
+	}
+
+	if user.FailedLogins > 0 || user.LockedUntil != "" {
+		return u.userRepo.ResetFailedLogins(user.ID)
+	}
+	return nil
+}
+
+func (u *UserUseCase) failLogin(user *entities.User, email, ip string) error {
+	userID := 0
+	if user != nil {
+		userID = user.ID
+	}
+
+	err := u.loginAttemptRepo.InsertLoginAttempt(userID, email, ip, false)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return entities.ErrInvalidCredentials
+	}
+
+	failed, err := u.userRepo.RegisterFailedLogin(user.ID)
+	if err != nil {
+		return err
+	}
+	if failed < u.loginPolicy.MaxAttempts {
+		return entities.ErrInvalidCredentials
+	}
+
+	until := time.Now().Add(u.lockoutDuration(failed))
+	err = u.userRepo.LockUser(user.ID, until)
+	if err != nil {
+		return err
+	}
+
+	return &entities.LockoutError{User: user, Until: until, Notify: true}
+}
+
+// ipThrottled сообщает, что с адреса пока нельзя входить. После IPMaxAttempts
+// неудач адрес ждёт LockoutBase с последней неудачи, и каждая следующая
+// неудача удваивает ожидание, как у блокировки аккаунта. Неудачи считаются
+// за IPWindow и ещё LockoutMax, чтобы они не забылись, пока адрес ждёт.
+func (u *UserUseCase) ipThrottled(ip string) (bool, error) {
+	failures, last, err := u.loginAttemptRepo.FailedByIP(ip, u.loginPolicy.IPWindow+u.loginPolicy.LockoutMax)
+	if err != nil {
+		return false, err
+	}
+	if failures < u.loginPolicy.IPMaxAttempts {
+		return false, nil
+	}
+
+	wait := u.backoff(u.loginPolicy.IPMaxAttempts, failures)
+	return time.Now().Before(last.Add(wait)), nil
+}
+
+// lockoutDuration удваивает блокировку за каждую неудачную попытку сверх порога
+func (u *UserUseCase) lockoutDuration(failed int) time.Duration {
+	return u.backoff(u.loginPolicy.MaxAttempts, failed)
+}
+
+// backoff - LockoutBase, удвоенная за каждую неудачу сверх threshold, но не больше LockoutMax
+func (u *UserUseCase) backoff(threshold, failed int) time.Duration {
+	d := u.loginPolicy.LockoutBase
+	for i := threshold; i < failed && d < u.loginPolicy.LockoutMax; i++ {
+		d *= 2
+	}
+	return min(d, u.loginPolicy.LockoutMax)
+}
+
+func (u *UserUseCase) UnlockUser(userID int) error {
+	exists, err := u.userRepo.Exists(userID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return entities.ErrNoRecord
+	}
+
+	return u.userRepo.ResetFailedLogins(userID)
+}
+
+func (u *UserUseCase) GetLoginActivity() (*LoginActivityDTO, error) {
+	lockedUsers, err := u.userRepo.GetLockedUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	failingIPs, err := u.loginAttemptRepo.ListFailingIPs(loginActivityWindow, loginActivityLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := u.loginAttemptRepo.ListRecentLoginAttempts(loginActivityLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginActivityDTO{
+		LockedUsers: lockedUsers,
+		FailingIPs:  failingIPs,
+		Attempts:    attempts,
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"log/slog"
 	"mime/multipart"
@@ -13,6 +14,7 @@ import (
 
 	"forum/internal/entities"
 	"forum/internal/repository"
+	"forum/pkg/markdown"
 	"forum/pkg/validator"
 
 	"github.com/gofrs/uuid"
@@ -20,6 +22,16 @@ import (
 
 const uploadDir = "uploads"
 
+// Длина markdown-текста поста и комментария в символах
+const (
+	maxPostChars    = 20000
+	maxCommentChars = 5000
+	// длина начала текста в списках постов
+	excerptChars = 200
+	// длина описания правки
+	maxSummaryChars = 200
+)
+
 // Use Case структура
 type PostUseCase struct {
 	categoryRepo        repository.CategoryRepository
@@ -28,7 +40,12 @@ type PostUseCase struct {
 	postRepo            repository.PostRepository
 	postReactionRepo    repository.PostReactionRepository
 	userRepo            repository.UserRepository
-	reportRepo          repository.ReportRepository
+	banRepo             repository.BanRepository
+	auditRepo           repository.AuditRepository
+	queueRepo           repository.QueueRepository
+	revisionRepo        repository.RevisionRepository
+	authorizer          *AuthorizerUseCase
+	automod             *AutomodUseCase
 }
 
 type PostDTO struct {
@@ -55,15 +72,22 @@ type postCreateForm struct {
 	Title      string
 	Content    string
 	Categories []int
+	Summary    string // описание правки для истории версий
 	validator.Validator
 }
 
 type CommentForm struct {
 	Content string
+	Summary string // описание правки для истории версий
+	validator.Validator
+}
+
+type rejectPostForm struct {
+	Reason string // автор увидит причину в уведомлении и на странице поста
 	validator.Validator
 }
 
-func NewPostUseCase(repo *repository.Repository) *PostUseCase {
+func NewPostUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase, automod *AutomodUseCase) *PostUseCase {
 	return &PostUseCase{
 		categoryRepo:        repo.CategoryRepository,
 		commentRepo:         repo.CommentRepository,
@@ -71,7 +95,12 @@ func NewPostUseCase(repo *repository.Repository) *PostUseCase {
 		postRepo:            repo.PostRepository,
 		postReactionRepo:    repo.PostReactionRepository,
 		userRepo:            repo.UserRepository,
-		reportRepo:          repo.ReportRepository,
+		banRepo:             repo.BanRepository,
+		auditRepo:           repo.AuditRepository,
+		queueRepo:           repo.QueueRepository,
+		revisionRepo:        repo.RevisionRepository,
+		authorizer:          authorizer,
+		automod:             automod,
 	}
 }
 
@@ -83,6 +112,10 @@ func (uc *PostUseCase) NewCommentForm() CommentForm {
 	return CommentForm{}
 }
 
+func (uc *PostUseCase) NewRejectPostForm() rejectPostForm {
+	return rejectPostForm{}
+}
+
 func (uc *PostUseCase) GetPostDTO(postID int, userID int) (*PostDTO, error) {
 	exists, err := uc.postRepo.Exists(postID)
 	if err != nil {
@@ -97,12 +130,20 @@ func (uc *PostUseCase) GetPostDTO(postID int, userID int) (*PostDTO, error) {
 		return nil, err
 	}
 
+	hidden, err := uc.hiddenFrom(post.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if hidden {
+		return nil, entities.ErrNoRecord
+	}
+
 	categories, err := uc.categoryRepo.GetCategoriesForPost(postID)
 	if err != nil {
 		return nil, err
 	}
 
-	likes, dislikes, err := uc.postReactionRepo.GetReactionsCount(postID)
+	likes, dislikes, err := uc.postReactionRepo.GetReactionsCount(postID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +161,7 @@ func (uc *PostUseCase) GetPostDTO(postID int, userID int) (*PostDTO, error) {
 		}
 	}
 
-	comments, err := uc.commentRepo.GetComments(postID)
+	comments, err := uc.commentRepo.GetComments(postID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -140,19 +181,23 @@ func (uc *PostUseCase) GetPostDTO(postID int, userID int) (*PostDTO, error) {
 			}
 		}
 
-		like, err := uc.commentReactionRepo.GetLikesCount(comment.ID)
+		like, err := uc.commentReactionRepo.GetLikesCount(comment.ID, userID)
 		if err != nil {
 			return nil, err
 		}
 		comment.Like = like
 
-		dislike, err := uc.commentReactionRepo.GetDislikesCount(comment.ID)
+		dislike, err := uc.commentReactionRepo.GetDislikesCount(comment.ID, userID)
 		if err != nil {
 			return nil, err
 		}
 		comment.Dislike = dislike
 	}
 
+	if err = renderLegacy(post, comments); err != nil {
+		return nil, err
+	}
+
 	return &PostDTO{
 		Post:         post,
 		Categories:   categories,
@@ -178,12 +223,20 @@ func (uc *PostUseCase) GetCommentedPostDTO(postID int, userID int) (*PostDTO, er
 		return nil, err
 	}
 
+	hidden, err := uc.hiddenFrom(post.UserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if hidden {
+		return nil, entities.ErrNoRecord
+	}
+
 	categories, err := uc.categoryRepo.GetCategoriesForPost(postID)
 	if err != nil {
 		return nil, err
 	}
 
-	likes, dislikes, err := uc.postReactionRepo.GetReactionsCount(postID)
+	likes, dislikes, err := uc.postReactionRepo.GetReactionsCount(postID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -221,19 +274,23 @@ func (uc *PostUseCase) GetCommentedPostDTO(postID int, userID int) (*PostDTO, er
 			}
 		}
 
-		like, err := uc.commentReactionRepo.GetLikesCount(comment.ID)
+		like, err := uc.commentReactionRepo.GetLikesCount(comment.ID, userID)
 		if err != nil {
 			return nil, err
 		}
 		comment.Like = like
 
-		dislike, err := uc.commentReactionRepo.GetDislikesCount(comment.ID)
+		dislike, err := uc.commentReactionRepo.GetDislikesCount(comment.ID, userID)
 		if err != nil {
 			return nil, err
 		}
 		comment.Dislike = dislike
 	}
 
+	if err = renderLegacy(post, comments); err != nil {
+		return nil, err
+	}
+
 	return &PostDTO{
 		Post:         post,
 		Categories:   categories,
@@ -245,8 +302,9 @@ func (uc *PostUseCase) GetCommentedPostDTO(postID int, userID int) (*PostDTO, er
 	}, nil
 }
 
-// Получение постов пользователя с пагинацией
-func (uc *PostUseCase) GetUserPostsDTO(userID, page, pageSize int, paginationURL string) (*PostsDTO, error) {
+// Получение постов пользователя с пагинацией. Посты автора под теневым баном
+// видит только он сам.
+func (uc *PostUseCase) GetUserPostsDTO(viewerID, userID, page, pageSize int, paginationURL string) (*PostsDTO, error) {
 	exists, err := uc.userRepo.Exists(userID)
 	if err != nil {
 		return nil, err
@@ -255,7 +313,7 @@ func (uc *PostUseCase) GetUserPostsDTO(userID, page, pageSize int, paginationURL
 		return nil, entities.ErrNoRecord
 	}
 
-	posts, err := uc.postRepo.GetUserPaginatedPosts(userID, page, pageSize)
+	posts, err := uc.postRepo.GetUserPaginatedPosts(viewerID, userID, page, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -278,6 +336,10 @@ func (uc *PostUseCase) GetUserPostsDTO(userID, page, pageSize int, paginationURL
 		posts = posts[:pageSize]
 	}
 
+	if err = setExcerpts(posts); err != nil {
+		return nil, err
+	}
+
 	return &PostsDTO{
 		User:          user,
 		Posts:         posts,
@@ -332,6 +394,10 @@ func (uc *PostUseCase) GetUserLikedPostsDTO(userID, page, pageSize int, paginati
 		posts = posts[:pageSize]
 	}
 
+	if err = setExcerpts(posts); err != nil {
+		return nil, err
+	}
+
 	return &PostsDTO{
 		User:          user,
 		Posts:         posts,
@@ -368,6 +434,10 @@ func (uc *PostUseCase) GetUserCommentedPostsDTO(userID, page, pageSize int, pagi
 		posts = posts[:pageSize]
 	}
 
+	if err = setExcerpts(posts); err != nil {
+		return nil, err
+	}
+
 	return &PostsDTO{
 		User:          user,
 		Posts:         posts,
@@ -378,9 +448,9 @@ func (uc *PostUseCase) GetUserCommentedPostsDTO(userID, page, pageSize int, pagi
 }
 
 // Получение всех постов с пагинацией
-func (uc *PostUseCase) GetAllPaginatedPostsDTO(page, pageSize int, paginationURL string) (*PostsDTO, error) {
+func (uc *PostUseCase) GetAllPaginatedPostsDTO(viewerID, page, pageSize int, paginationURL string) (*PostsDTO, error) {
 	// Получаем посты для нужной страницы.
-	posts, err := uc.postRepo.GetAllPaginatedPosts(page, pageSize)
+	posts, err := uc.postRepo.GetAllPaginatedPosts(viewerID, page, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -397,31 +467,7 @@ func (uc *PostUseCase) GetAllPaginatedPostsDTO(page, pageSize int, paginationURL
 		return nil, err
 	}
 
-	return &PostsDTO{
-		Posts:         posts,
-		HasNextPage:   hasNextPage,
-		CurrentPage:   page,
-		PaginationURL: paginationURL,
-		Categories:    categories,
-	}, nil
-}
-
-func (uc *PostUseCase) GetAllPaginatedUnapprovedPostsDTO(page, pageSize int, paginationURL string) (*PostsDTO, error) {
-	// Получаем посты для нужной страницы.
-	posts, err := uc.postRepo.GetAllPaginatedUnapprovedPosts(page, pageSize)
-	if err != nil {
-		return nil, err
-	}
-
-	// Проверяем, есть ли следующая страница
-	hasNextPage := len(posts) > pageSize
-	// Если постов больше, чем pageSize, обрезаем список до pageSize
-	if hasNextPage {
-		posts = posts[:pageSize]
-	}
-
-	categories, err := uc.categoryRepo.GetAll()
-	if err != nil {
+	if err = setExcerpts(posts); err != nil {
 		return nil, err
 	}
 
@@ -434,7 +480,7 @@ func (uc *PostUseCase) GetAllPaginatedUnapprovedPostsDTO(page, pageSize int, pag
 	}, nil
 }
 
-func (uc *PostUseCase) GetFilteredPaginatedPostsDTO(form *postCreateForm, page, pageSize int, paginationURL string) (*PostsDTO, error) {
+func (uc *PostUseCase) GetFilteredPaginatedPostsDTO(viewerID int, form *postCreateForm, page, pageSize int, paginationURL string) (*PostsDTO, error) {
 	allCategories, err := uc.categoryRepo.GetAll()
 	if err != nil {
 		return nil, err
@@ -451,7 +497,7 @@ func (uc *PostUseCase) GetFilteredPaginatedPostsDTO(form *postCreateForm, page,
 	}
 
 	if !form.Valid() {
-		posts, err := uc.postRepo.GetAllPaginatedPosts(page, pageSize)
+		posts, err := uc.postRepo.GetAllPaginatedPosts(viewerID, page, pageSize)
 		if err != nil {
 			return nil, err
 		}
@@ -463,6 +509,10 @@ func (uc *PostUseCase) GetFilteredPaginatedPostsDTO(form *postCreateForm, page,
 			posts = posts[:pageSize]
 		}
 
+		if err = setExcerpts(posts); err != nil {
+			return nil, err
+		}
+
 		PostsDTO.Posts = posts
 		PostsDTO.HasNextPage = hasNextPage
 
@@ -470,7 +520,7 @@ func (uc *PostUseCase) GetFilteredPaginatedPostsDTO(form *postCreateForm, page,
 	}
 
 	// Получаем посты с пагинацией
-	posts, err := uc.postRepo.GetPaginatedPostsByCategory(form.Categories, page, pageSize)
+	posts, err := uc.postRepo.GetPaginatedPostsByCategory(viewerID, form.Categories, page, pageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -497,6 +547,10 @@ func (uc *PostUseCase) GetFilteredPaginatedPostsDTO(form *postCreateForm, page,
 		PostsDTO.Header = fmt.Sprintf("Posts filtered by: %s", strings.Join(filteredCategoryNames, ", "))
 	}
 
+	if err = setExcerpts(posts); err != nil {
+		return nil, err
+	}
+
 	PostsDTO.Posts = posts
 	PostsDTO.HasNextPage = hasNextPage
 
@@ -505,13 +559,12 @@ func (uc *PostUseCase) GetFilteredPaginatedPostsDTO(form *postCreateForm, page,
 
 // Создание поста с категориями
 func (uc *PostUseCase) CreatePostWithCategories(form *postCreateForm, files []*multipart.FileHeader, userID int) (int, []*entities.Category, error) {
-	// валидировать все данные
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return 0, nil, err
+	}
 
-	form.CheckField(validator.Matches(form.Title, validator.TextRX), "title", "This field must contain only english or russian letters")
-	form.CheckField(validator.Matches(form.Content, validator.TextRX), "content", "This field must contain only english or russian letters")
+	// валидировать все данные
+	form.checkPost()
 
 	allCategories, err := uc.categoryRepo.GetAll()
 	if err != nil {
@@ -553,7 +606,12 @@ func (uc *PostUseCase) CreatePostWithCategories(form *postCreateForm, files []*m
 		}
 	}
 
-	postID, err := uc.postRepo.InsertPostWithCategories(form.Title, form.Content, userID, form.Categories, filePaths)
+	contentHTML, err := markdown.Render(form.Content)
+	if err != nil {
+		return 0, allCategories, err
+	}
+
+	postID, err := uc.postRepo.InsertPostWithCategories(form.Title, form.Content, string(contentHTML), userID, form.Categories, filePaths)
 	if err != nil {
 		for _, filePath := range filePaths {
 			err := os.Remove(filePath)
@@ -563,17 +621,22 @@ func (uc *PostUseCase) CreatePostWithCategories(form *postCreateForm, files []*m
 		}
 		return 0, allCategories, err
 	}
+
+	post := &entities.Post{ID: postID, UserID: userID, Title: form.Title, Content: form.Content}
+	if err = uc.automod.checkPost(post); err != nil {
+		return 0, allCategories, err
+	}
 	return postID, allCategories, nil
 }
 
-func (uc *PostUseCase) UpdatePostWithImage(form *postCreateForm, postID int, files []*multipart.FileHeader, userID int) error {
-	// валидировать все данные
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+func (uc *PostUseCase) UpdatePostWithImage(form *postCreateForm, postID int, files []*multipart.FileHeader, userID int, token *entities.APIToken) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
 
-	form.CheckField(validator.Matches(form.Title, validator.TextRX), "title", "This field must contain only english or russian letters")
-	form.CheckField(validator.Matches(form.Content, validator.TextRX), "content", "This field must contain only english or russian letters")
+	// валидировать все данные
+	form.checkPost()
+	form.checkSummary()
 	allCategories, err := uc.categoryRepo.GetAll()
 	if err != nil {
 		fmt.Println(1)
@@ -602,12 +665,23 @@ func (uc *PostUseCase) UpdatePostWithImage(form *postCreateForm, postID int, fil
 		return entities.ErrInvalidCredentials
 	}
 
-	exists, err := uc.userRepo.Exists(userID)
+	user, err := uc.userRepo.Get(userID)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return entities.ErrNoRecord
+
+	post, err := uc.postRepo.GetPost(postID)
+	if err != nil {
+		return err
+	}
+	ownerID := post.UserID
+
+	allowed, err := uc.authorizer.canActOn(user, token, ownerID, postID, entities.PermPostEditAny)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entities.ErrForbidden
 	}
 
 	filePaths := []string{}
@@ -618,7 +692,18 @@ func (uc *PostUseCase) UpdatePostWithImage(form *postCreateForm, postID int, fil
 		}
 	}
 
-	err = uc.postRepo.UpdatePostWithImage(form.Title, form.Content, postID, filePaths, form.Categories)
+	// Текст до правки станет первой версией, если версий ещё нет
+	base, err := currentPostRevision(uc.categoryRepo, post)
+	if err != nil {
+		return err
+	}
+
+	contentHTML, err := markdown.Render(form.Content)
+	if err != nil {
+		return err
+	}
+
+	err = uc.postRepo.UpdatePostWithImage(form.Title, form.Content, string(contentHTML), postID, filePaths, form.Categories)
 	if err != nil {
 
 		for _, filePath := range filePaths {
@@ -631,37 +716,139 @@ func (uc *PostUseCase) UpdatePostWithImage(form *postCreateForm, postID int, fil
 
 		return err
 	}
-	return nil
+
+	rev := &entities.Revision{
+		TargetType:  entities.TargetPost,
+		TargetID:    postID,
+		EditorID:    user.ID,
+		Title:       form.Title,
+		Content:     form.Content,
+		CategoryIDs: form.Categories,
+		Summary:     form.Summary,
+	}
+	// Правка только картинок новой версии не даёт
+	if !sameRevision(base, rev) {
+		if _, err = saveRevision(uc.revisionRepo, base, rev); err != nil {
+			return err
+		}
+	}
+
+	// Исправленный автором отклонённый пост снова уходит на модерацию
+	if post.IsRejected() && ownerID == user.ID {
+		if err = uc.postRepo.ResubmitPost(postID); err != nil {
+			return err
+		}
+		post.Rejected, post.RejectionReason = "", ""
+	}
+
+	post.Title, post.Content = form.Title, form.Content
+	return uc.automod.checkPost(post)
 }
 
-func (uc *PostUseCase) UpdateComment(form *CommentForm, commentID, userID int) error {
-	// валидировать все данные
+func (uc *PostUseCase) UpdateComment(form *CommentForm, commentID, userID int, token *entities.APIToken) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
 
+	// валидировать все данные
+	form.Content = validator.Normalize(form.Content)
 	form.CheckField(validator.NotBlank(form.Content), "comment", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Content, validator.TextRX), "comment", "This field must contain only english or russian letters")
+	form.CheckField(validator.MaxChars(form.Content, maxCommentChars), "comment", "This field cannot be more than 5000 characters long")
+	form.CheckField(validator.SafeText(form.Content), "comment", "This field contains unsupported characters")
+	form.Summary = validator.Normalize(strings.TrimSpace(form.Summary))
+	form.CheckField(validator.MaxChars(form.Summary, maxSummaryChars), "summary", "This field cannot be more than 200 characters long")
+	form.CheckField(validator.SafeText(form.Summary) && validator.SingleLine(form.Summary), "summary", "This field contains unsupported characters")
 
 	if !form.Valid() {
 		return entities.ErrInvalidCredentials
 	}
 
-	exists, err := uc.userRepo.Exists(userID)
+	user, err := uc.userRepo.Get(userID)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return entities.ErrNoRecord
+
+	comment, err := uc.commentRepo.GetComment(commentID)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := uc.authorizer.canActOn(user, token, comment.UserID, comment.PostID, entities.PermCommentEditAny)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entities.ErrForbidden
+	}
+
+	contentHTML, err := markdown.Render(form.Content)
+	if err != nil {
+		return err
 	}
 
-	err = uc.commentRepo.UpdateComment(commentID, form.Content)
+	err = uc.commentRepo.UpdateComment(commentID, form.Content, string(contentHTML))
 	if err != nil {
 		return err
 	}
 
+	if comment.Content != form.Content {
+		_, err = saveRevision(uc.revisionRepo, commentRevision(comment), &entities.Revision{
+			TargetType: entities.TargetComment,
+			TargetID:   commentID,
+			EditorID:   user.ID,
+			Content:    form.Content,
+			Summary:    form.Summary,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	err = uc.postReactionRepo.UpdateNotificationTime(commentID)
 	if err != nil {
 		return err
 	}
 
+	comment.Content = form.Content
+	return uc.automod.checkComment(comment)
+}
+
+// PreviewMarkdown показывает, как будет выглядеть текст поста или комментария
+func (uc *PostUseCase) PreviewMarkdown(content string) (template.HTML, error) {
+	content = validator.Normalize(content)
+	if !validator.MaxChars(content, maxPostChars) || !validator.SafeText(content) {
+		return "", entities.ErrInvalidData
+	}
+	return markdown.Render(content)
+}
+
+// setExcerpts готовит для списков постов начало текста без разметки
+func setExcerpts(posts []*entities.Post) error {
+	var err error
+	for _, post := range posts {
+		if post.Excerpt, err = markdown.Excerpt(post.Content, excerptChars); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderLegacy собирает HTML постов и комментариев, сохранённых до markdown:
+// у них пустой content_html. Новые тексты переводятся при сохранении.
+func renderLegacy(post *entities.Post, comments []*entities.Comment) error {
+	var err error
+	if post.ContentHTML == "" && post.Content != "" {
+		if post.ContentHTML, err = markdown.Render(post.Content); err != nil {
+			return err
+		}
+	}
+	for _, comment := range comments {
+		if comment.ContentHTML == "" && comment.Content != "" {
+			if comment.ContentHTML, err = markdown.Render(comment.Content); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -710,7 +897,16 @@ func uploadImages(files []*multipart.FileHeader) ([]string, error) {
 	return pathFiles, nil
 }
 
-func (uc *PostUseCase) ApprovePost(postID int) error {
+func (uc *PostUseCase) ApprovePost(postID, userID int) error {
+	return uc.approvePost(postID, userID, "")
+}
+
+// approvePost одобряет пост; reason попадает в журнал модерации
+func (uc *PostUseCase) approvePost(postID, userID int, reason string) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
+
 	exists, err := uc.postRepo.Exists(postID)
 	if err != nil {
 		return err
@@ -719,11 +915,116 @@ func (uc *PostUseCase) ApprovePost(postID int) error {
 		return entities.ErrNoRecord
 	}
 
-	return uc.postRepo.ApprovePost(postID)
+	user, err := uc.userRepo.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := uc.authorizer.CanOnPost(user.ID, user.Role, postID, entities.PermPostApprove)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entities.ErrForbidden
+	}
+
+	post, err := uc.postRepo.GetPost(postID)
+	if err != nil {
+		return err
+	}
+
+	if err = checkClaim(uc.queueRepo, user.ID, entities.TargetPost, postID); err != nil {
+		return err
+	}
+
+	err = uc.postRepo.ApprovePost(postID)
+	if err != nil {
+		return err
+	}
+
+	if post.UserID != user.ID {
+		err = uc.postReactionRepo.AddModerationNotification(post.UserID, postID, user.ID, entities.NotificationPostApproved, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	entry := postAction(user.ID, entities.ActionPostApprove, post)
+	entry.Reason = reason
+	return recordAction(uc.auditRepo, entry, map[string]any{"approved": post.IsApproved}, map[string]any{"approved": true})
+}
+
+// RejectPost отклоняет пост на модерации. Пост остаётся у автора: он видит
+// причину и может исправить пост, после чего тот снова попадёт в очередь.
+func (uc *PostUseCase) RejectPost(postID, userID int, form *rejectPostForm) error {
+	form.Reason = validator.Normalize(form.Reason)
+	form.CheckField(validator.NotBlank(form.Reason), "reason", "Tell the author why the post is rejected")
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+	form.CheckField(validator.SafeText(form.Reason), "reason", "This field contains unsupported characters")
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+	return uc.rejectPost(postID, userID, strings.TrimSpace(form.Reason))
+}
+
+func (uc *PostUseCase) rejectPost(postID, userID int, reason string) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
+
+	user, err := uc.userRepo.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	post, err := uc.postRepo.GetPost(postID)
+	if err != nil {
+		return err
+	}
+
+	allowed, err := uc.authorizer.CanOnPost(user.ID, user.Role, postID, entities.PermPostApprove)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entities.ErrForbidden
+	}
+
+	// Опубликованный пост не отклоняют, его удаляют
+	if post.IsApproved {
+		return entities.ErrInvalidData
+	}
+
+	if err = checkClaim(uc.queueRepo, user.ID, entities.TargetPost, postID); err != nil {
+		return err
+	}
+
+	if err = uc.postRepo.RejectPost(postID, reason); err != nil {
+		return err
+	}
+
+	err = uc.postReactionRepo.AddModerationNotification(post.UserID, postID, user.ID, entities.NotificationPostRejected, reason)
+	if err != nil {
+		return err
+	}
+
+	entry := postAction(user.ID, entities.ActionPostReject, post)
+	entry.Reason = reason
+	return recordAction(uc.auditRepo, entry, map[string]any{"rejected": post.IsRejected()}, map[string]any{"rejected": true})
 }
 
 // Удаление поста
-func (uc *PostUseCase) DeletePost(postID, userID int) error {
+func (uc *PostUseCase) DeletePost(postID, userID int, token *entities.APIToken) error {
+	return uc.deletePost(postID, userID, token, "")
+}
+
+// deletePost удаляет пост; reason попадает в журнал модерации. Очередь и
+// жалобы передают token = nil: их страницы по токену без moderate не откроются.
+func (uc *PostUseCase) deletePost(postID, userID int, token *entities.APIToken, reason string) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
+
 	exists, err := uc.postRepo.Exists(postID)
 	if err != nil {
 		return err
@@ -742,30 +1043,66 @@ func (uc *PostUseCase) DeletePost(postID, userID int) error {
 		return err
 	}
 
-	if (post.UserID == userID) || (user.Role == entities.RoleModerator) || (user.Role == entities.RoleAdmin) {
-		filePaths, err := uc.postRepo.GetImagesByPost(postID)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-			} else {
-				return err
-			}
+	allowed, err := uc.authorizer.canActOn(user, token, post.UserID, postID, entities.PermPostDeleteAny)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entities.ErrForbidden
+	}
+
+	if post.UserID != user.ID {
+		if err = checkClaim(uc.queueRepo, user.ID, entities.TargetPost, postID); err != nil {
+			return err
 		}
+	}
 
-		if len(filePaths) != 0 {
-			for _, filePath := range filePaths {
-				err := os.Remove(filePath.UrlImage)
-				if err != nil {
-					return err
-				}
+	filePaths, err := uc.postRepo.GetImagesByPost(postID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+		} else {
+			return err
+		}
+	}
+
+	if len(filePaths) != 0 {
+		for _, filePath := range filePaths {
+			err := os.Remove(filePath.UrlImage)
+			if err != nil {
+				return err
 			}
 		}
+	}
 
-		return uc.postRepo.DeletePost(postID)
+	before, err := uc.postSnapshot(post)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	err = uc.postRepo.DeletePost(postID)
+	if err != nil {
+		return err
+	}
+
+	// Автор удаляет свой пост сам, в журнал модерации это не попадает
+	if post.UserID == user.ID {
+		return nil
+	}
+	entry := postAction(user.ID, entities.ActionPostDelete, post)
+	entry.Reason = reason
+	return recordAction(uc.auditRepo, entry, before, nil)
+}
+
+func (uc *PostUseCase) DeleteComment(commentID, userID int, token *entities.APIToken) error {
+	return uc.deleteComment(commentID, userID, token, "")
 }
 
-func (uc *PostUseCase) DeleteComment(commentID, userID int) error {
+// deleteComment удаляет комментарий; reason попадает в журнал модерации
+func (uc *PostUseCase) deleteComment(commentID, userID int, token *entities.APIToken, reason string) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
+
 	comment, err := uc.commentRepo.GetComment(commentID)
 	if err != nil {
 		return err
@@ -780,10 +1117,63 @@ func (uc *PostUseCase) DeleteComment(commentID, userID int) error {
 		return err
 	}
 
-	if (comment.UserID == userID) || (user.Role == entities.RoleAdmin) {
-		return uc.commentRepo.DeleteComment(commentID)
+	allowed, err := uc.authorizer.canActOn(user, token, comment.UserID, comment.PostID, entities.PermCommentDeleteAny)
+	if err != nil {
+		return err
 	}
-	return nil
+	if !allowed {
+		return entities.ErrForbidden
+	}
+
+	if comment.UserID != user.ID {
+		if err = checkClaim(uc.queueRepo, user.ID, entities.TargetComment, commentID); err != nil {
+			return err
+		}
+	}
+
+	err = uc.commentRepo.DeleteComment(commentID)
+	if err != nil {
+		return err
+	}
+
+	if comment.UserID == user.ID {
+		return nil
+	}
+
+	author, err := uc.userRepo.Get(comment.UserID)
+	if err != nil {
+		return err
+	}
+	return recordAction(uc.auditRepo, &entities.ModerationAction{
+		ActorID:      user.ID,
+		Action:       entities.ActionCommentDelete,
+		TargetType:   entities.TargetComment,
+		TargetID:     comment.ID,
+		TargetUserID: comment.UserID,
+		TargetName:   author.Username,
+		Reason:       reason,
+	}, map[string]any{
+		"post_id": comment.PostID,
+		"content": comment.Content,
+	}, nil)
+}
+
+// checkPost проверяет заголовок и текст поста
+func (form *postCreateForm) checkPost() {
+	form.Title, form.Content = validator.Normalize(form.Title), validator.Normalize(form.Content)
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+
+	form.CheckField(validator.SafeText(form.Title) && validator.SingleLine(form.Title), "title", "This field contains unsupported characters")
+	form.CheckField(validator.MaxChars(form.Content, maxPostChars), "content", "This field cannot be more than 20000 characters long")
+	form.CheckField(validator.SafeText(form.Content), "content", "This field contains unsupported characters")
+}
+
+func (form *postCreateForm) checkSummary() {
+	form.Summary = validator.Normalize(strings.TrimSpace(form.Summary))
+	form.CheckField(validator.MaxChars(form.Summary, maxSummaryChars), "summary", "This field cannot be more than 200 characters long")
+	form.CheckField(validator.SafeText(form.Summary) && validator.SingleLine(form.Summary), "summary", "This field contains unsupported characters")
 }
 
 func (form *postCreateForm) validateCategories(allCategories []*entities.Category) {
@@ -812,21 +1202,41 @@ func (form *postCreateForm) validateCategories(allCategories []*entities.Categor
 	}
 }
 
-func (uc *PostUseCase) DeleteReport(userId, postId int) error {
-	exists, err := uc.postRepo.Exists(postId)
+// postSnapshot - пост для журнала модерации
+func (uc *PostUseCase) postSnapshot(post *entities.Post) (map[string]any, error) {
+	categories, err := uc.categoryRepo.GetCategoriesForPost(post.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !exists {
-		return entities.ErrNoRecord
+	categoryNames := make([]string, len(categories))
+	for i, c := range categories {
+		categoryNames[i] = c.Name
 	}
-	exists, err = uc.userRepo.Exists(userId)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return entities.ErrNoRecord
+
+	return map[string]any{
+		"title":      post.Title,
+		"content":    post.Content,
+		"approved":   post.IsApproved,
+		"created":    post.Created,
+		"categories": categoryNames,
+	}, nil
+}
+
+func postAction(actorID int, action string, post *entities.Post) *entities.ModerationAction {
+	return &entities.ModerationAction{
+		ActorID:      actorID,
+		Action:       action,
+		TargetType:   entities.TargetPost,
+		TargetID:     post.ID,
+		TargetUserID: post.UserID,
+		TargetName:   post.Title,
 	}
+}
 
-	return uc.reportRepo.DeleteReport(userId, postId)
+// hiddenFrom сообщает, что записи автора под теневым баном и viewerID их не видит
+func (uc *PostUseCase) hiddenFrom(authorID, viewerID int) (bool, error) {
+	if authorID == viewerID {
+		return false, nil
+	}
+	return uc.banRepo.IsShadowBanned(authorID)
 }
@@ -0,0 +1,393 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+type QueueUseCase struct {
+	queueRepo    repository.QueueRepository
+	postRepo     repository.PostRepository
+	userRepo     repository.UserRepository
+	categoryRepo repository.CategoryRepository
+	auditRepo    repository.AuditRepository
+	authorizer   *AuthorizerUseCase
+	// Решения по элементам очереди принимаются там же, где и по одному
+	posts   *PostUseCase
+	reports *ReportUseCase
+}
+
+type queueFilterForm struct {
+	entities.QueueFilter
+	validator.Validator
+}
+
+type queueActionForm struct {
+	Items      []string // ключи элементов, например post:12
+	Action     string
+	Reason     string
+	AssigneeID int
+	validator.Validator
+}
+
+type QueueDTO struct {
+	Items         []*entities.QueueItem
+	Categories    []*entities.Category
+	Staff         []*entities.User // кому можно назначить; пусто без права queue.assign
+	HasNextPage   bool
+	CurrentPage   int
+	PaginationURL string
+}
+
+// QueueResult - итог массового действия
+type QueueResult struct {
+	Done    int
+	Skipped int // элементы, занятые другими, уже обработанные или недоступные
+}
+
+func NewQueueUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase, posts *PostUseCase, reports *ReportUseCase) *QueueUseCase {
+	return &QueueUseCase{
+		queueRepo:    repo.QueueRepository,
+		postRepo:     repo.PostRepository,
+		userRepo:     repo.UserRepository,
+		categoryRepo: repo.CategoryRepository,
+		auditRepo:    repo.AuditRepository,
+		authorizer:   authorizer,
+		posts:        posts,
+		reports:      reports,
+	}
+}
+
+func (uc *QueueUseCase) NewQueueFilterForm() queueFilterForm {
+	return queueFilterForm{}
+}
+
+func (uc *QueueUseCase) NewQueueActionForm() queueActionForm {
+	return queueActionForm{}
+}
+
+// GetQueueDTO возвращает элементы очереди, которые userID может разбирать
+func (uc *QueueUseCase) GetQueueDTO(userID int, form *queueFilterForm, page, pageSize int, paginationURL string) (*QueueDTO, error) {
+	form.CheckField(form.Kind == "" || validator.PermittedValue(form.Kind, entities.QueueApproval, entities.QueueReport),
+		"kind", "Unknown item type")
+	form.CheckField(form.Assignee == "" || validator.PermittedValue(form.Assignee,
+		entities.QueueAssigneeMe, entities.QueueAssigneeUnclaimed, entities.QueueAssigneeClaimed),
+		"assignee", "Unknown value")
+	form.CheckField(form.CategoryID >= 0, "category", "Choose a category from the list")
+	form.CheckField(form.MinAgeHours >= 0, "age", "Enter a positive number")
+	form.CheckField(form.MinReports >= 0, "reports", "Enter a positive number")
+	if !form.Valid() {
+		return nil, entities.ErrInvalidData
+	}
+
+	user, err := uc.userRepo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds, err := uc.queueKinds(user.Role)
+	if err != nil {
+		return nil, err
+	}
+	categoryIDs, err := uc.authorizer.ModeratedCategoryIDs(user.ID, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := uc.queueRepo.GetQueue(user.ID, &form.QueueFilter, kinds, categoryIDs, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(items) > pageSize
+	if hasNextPage {
+		items = items[:pageSize]
+	}
+
+	categories, err := uc.categoryRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	dto := &QueueDTO{
+		Items:         items,
+		Categories:    categories,
+		HasNextPage:   hasNextPage,
+		CurrentPage:   page,
+		PaginationURL: paginationURL,
+	}
+
+	canAssign, err := uc.authorizer.Can(user.Role, entities.PermQueueAssign)
+	if err != nil {
+		return nil, err
+	}
+	if canAssign {
+		if dto.Staff, err = uc.queueRepo.GetQueueStaff(); err != nil {
+			return nil, err
+		}
+	}
+	return dto, nil
+}
+
+// ApplyAction выполняет действие над выбранными элементами очереди. Элементы,
+// которые взял другой модератор или которые уже разобрали, пропускаются.
+func (uc *QueueUseCase) ApplyAction(actorID int, form *queueActionForm) (*QueueResult, error) {
+	form.CheckField(validator.PermittedValue(form.Action, entities.QueueActions...), "action", "Unknown action")
+	form.CheckField(len(form.Items) > 0, "items", "Select at least one item")
+	form.CheckField(len(form.Items) <= 100, "items", "Select no more than 100 items")
+	form.Reason = validator.Normalize(form.Reason)
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+	form.CheckField(validator.SafeText(form.Reason), "reason", "This field contains unsupported characters")
+	// Причину отклонения увидит автор поста
+	form.CheckField(form.Action != entities.QueueActionReject || validator.NotBlank(form.Reason), "reason", "Give a reason for the rejection")
+
+	actor, err := uc.userRepo.Get(actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	canAssign, err := uc.authorizer.Can(actor.Role, entities.PermQueueAssign)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignee *entities.User
+	if form.Action == entities.QueueActionAssign {
+		if !canAssign {
+			return nil, entities.ErrForbidden
+		}
+		staff, err := uc.queueRepo.GetQueueStaff()
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range staff {
+			if u.ID == form.AssigneeID {
+				assignee = u
+			}
+		}
+		form.CheckField(assignee != nil, "assignee", "Choose a staff member from the list")
+	}
+	if !form.Valid() {
+		return nil, entities.ErrInvalidData
+	}
+
+	kinds, err := uc.queueKinds(actor.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueueResult{}
+	for _, key := range form.Items {
+		targetType, targetID, ok := parseQueueKey(key)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+
+		switch form.Action {
+		case entities.QueueActionClaim:
+			err = uc.claim(actor, targetType, targetID, kinds)
+		case entities.QueueActionRelease:
+			err = uc.release(actor.ID, canAssign, targetType, targetID)
+		case entities.QueueActionAssign:
+			err = uc.assign(actor.ID, assignee, targetType, targetID, kinds)
+		default:
+			err = uc.decide(actor.ID, form.Action, targetType, targetID, strings.TrimSpace(form.Reason))
+		}
+
+		switch {
+		case err == nil:
+			result.Done++
+		case errors.Is(err, entities.ErrClaimed), errors.Is(err, entities.ErrForbidden),
+			errors.Is(err, entities.ErrNoRecord), errors.Is(err, entities.ErrReportClosed),
+			errors.Is(err, entities.ErrInvalidData):
+			result.Skipped++
+		default:
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (uc *QueueUseCase) claim(actor *entities.User, targetType string, targetID int, kinds []string) error {
+	if err := uc.checkQueued(actor, targetType, targetID, kinds); err != nil {
+		return err
+	}
+
+	claim, err := uc.queueRepo.GetClaim(targetType, targetID)
+	if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+		return err
+	}
+	if claim != nil {
+		if claim.UserID != actor.ID {
+			return entities.ErrClaimed
+		}
+		// Назначенный элемент остаётся назначенным
+		if claim.AssignedBy != 0 {
+			return nil
+		}
+	}
+	return uc.queueRepo.SaveClaim(targetType, targetID, actor.ID, 0, entities.ClaimTimeout)
+}
+
+// Снять отметку может тот, кто работает над элементом, или тот, кто назначает
+func (uc *QueueUseCase) release(actorID int, canAssign bool, targetType string, targetID int) error {
+	claim, err := uc.queueRepo.GetClaim(targetType, targetID)
+	if err != nil {
+		return err
+	}
+	if claim.UserID != actorID && !canAssign {
+		return entities.ErrClaimed
+	}
+	return uc.queueRepo.DeleteClaim(targetType, targetID)
+}
+
+func (uc *QueueUseCase) assign(actorID int, assignee *entities.User, targetType string, targetID int, kinds []string) error {
+	// Категории проверяются у того, кому назначают: разбирать элемент ему
+	if err := uc.checkQueued(assignee, targetType, targetID, kinds); err != nil {
+		return err
+	}
+
+	// Назначенный элемент не освобождается по таймауту
+	if err := uc.queueRepo.SaveClaim(targetType, targetID, assignee.ID, actorID, 0); err != nil {
+		return err
+	}
+
+	return recordAction(uc.auditRepo, &entities.ModerationAction{
+		ActorID:      actorID,
+		Action:       entities.ActionQueueAssign,
+		TargetType:   targetType,
+		TargetID:     targetID,
+		TargetUserID: assignee.ID,
+		TargetName:   assignee.Username,
+	}, nil, map[string]any{"assignee": assignee.Username})
+}
+
+// decide одобряет, отклоняет или удаляет элемент. Пост на одобрении
+// одобряется, отклоняется с причиной для автора или удаляется; жалобы
+// принимаются или отклоняются.
+func (uc *QueueUseCase) decide(actorID int, action, targetType string, targetID int, reason string) error {
+	if targetType == entities.TargetPost {
+		post, err := uc.postRepo.GetPost(targetID)
+		if err != nil {
+			return err
+		}
+		if !post.IsApproved {
+			switch action {
+			case entities.QueueActionApprove:
+				return uc.posts.approvePost(targetID, actorID, reason)
+			case entities.QueueActionReject:
+				return uc.posts.rejectPost(targetID, actorID, reason)
+			}
+			return uc.posts.deletePost(targetID, actorID, nil, reason)
+		}
+	}
+
+	form := uc.reports.NewReportStatusForm()
+	form.Note = reason
+	switch action {
+	case entities.QueueActionApprove:
+		form.Status = entities.ReportResolved
+	case entities.QueueActionReject:
+		form.Status = entities.ReportDismissed
+	default:
+		// Профиль из очереди не удаляется: для этого есть консоль пользователей
+		if targetType == entities.TargetUser {
+			return entities.ErrForbidden
+		}
+		form.Status = entities.ReportResolved
+		form.Remove = true
+	}
+	return uc.reports.SetReportStatus(actorID, targetType, targetID, &form)
+}
+
+// checkQueued проверяет, что объект сейчас в очереди, доступной модератору.
+// Пост на одобрении должен быть из категорий user, как и в списке очереди:
+// иначе модератор с категориями занимал бы чужие посты.
+func (uc *QueueUseCase) checkQueued(user *entities.User, targetType string, targetID int, kinds []string) error {
+	if targetType == entities.TargetPost && validator.PermittedValue(entities.QueueApproval, kinds...) {
+		post, err := uc.postRepo.GetPost(targetID)
+		if err != nil {
+			return err
+		}
+		if !post.IsApproved && !post.IsRejected() {
+			allowed, err := uc.authorizer.CanOnPost(user.ID, user.Role, post.ID, entities.PermPostApprove)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				return entities.ErrForbidden
+			}
+			return nil
+		}
+	}
+
+	if validator.PermittedValue(entities.QueueReport, kinds...) {
+		target, err := uc.reports.GetReportTarget(targetType, targetID)
+		if err != nil {
+			return err
+		}
+		if target.Active {
+			return nil
+		}
+	}
+	return entities.ErrNoRecord
+}
+
+// queueKinds - виды элементов очереди, доступные роли
+func (uc *QueueUseCase) queueKinds(role string) ([]string, error) {
+	kinds := []string{}
+
+	canApprove, err := uc.authorizer.Can(role, entities.PermPostApprove)
+	if err != nil {
+		return nil, err
+	}
+	if canApprove {
+		kinds = append(kinds, entities.QueueApproval)
+	}
+
+	canResolve, err := uc.authorizer.Can(role, entities.PermReportResolve)
+	if err != nil {
+		return nil, err
+	}
+	if canResolve {
+		kinds = append(kinds, entities.QueueReport)
+	}
+	return kinds, nil
+}
+
+func parseQueueKey(key string) (string, int, bool) {
+	targetType, id, ok := strings.Cut(key, ":")
+	if !ok {
+		return "", 0, false
+	}
+	targetID, err := strconv.Atoi(id)
+	if err != nil || targetID < 1 {
+		return "", 0, false
+	}
+	return targetType, targetID, true
+}
+
+// checkClaim не даёт действовать над объектом, который взял в работу другой
+// модератор. Свою отметку действие продлевает: модератор ещё работает.
+func checkClaim(queueRepo repository.QueueRepository, actorID int, targetType string, targetID int) error {
+	claim, err := queueRepo.GetClaim(targetType, targetID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+	if claim.UserID != actorID {
+		return entities.ErrClaimed
+	}
+	if claim.AssignedBy == 0 {
+		return queueRepo.SaveClaim(targetType, targetID, actorID, 0, entities.ClaimTimeout)
+	}
+	return nil
+}
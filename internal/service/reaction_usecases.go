@@ -3,6 +3,7 @@ package service
 import (
 	"forum/internal/entities"
 	"forum/internal/repository"
+	"forum/pkg/markdown"
 	"forum/pkg/validator"
 )
 
@@ -13,10 +14,12 @@ type ReactionUseCase struct {
 	postRepo            repository.PostRepository
 	postReactionRepo    repository.PostReactionRepository
 	userRepo            repository.UserRepository
-	reportRepo          repository.ReportRepository
+	banRepo             repository.BanRepository
+	authorizer          *AuthorizerUseCase
+	automod             *AutomodUseCase
 }
 
-type reactionForm struct {
+type ReactionForm struct {
 	Comment       string
 	PostIsLike    string
 	CommentIsLike string
@@ -24,15 +27,7 @@ type reactionForm struct {
 	validator.Validator
 }
 
-type ReportsDTO struct {
-	Reports       []*entities.Report
-	HasNextPage   bool
-	CurrentPage   int
-	PaginationURL string
-	Header        string
-}
-
-func NewReactionUseCase(repo *repository.Repository) *ReactionUseCase {
+func NewReactionUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase, automod *AutomodUseCase) *ReactionUseCase {
 	return &ReactionUseCase{
 		categoryRepo:        repo.CategoryRepository,
 		commentRepo:         repo.CommentRepository,
@@ -40,15 +35,21 @@ func NewReactionUseCase(repo *repository.Repository) *ReactionUseCase {
 		postRepo:            repo.PostRepository,
 		postReactionRepo:    repo.PostReactionRepository,
 		userRepo:            repo.UserRepository,
-		reportRepo:          repo.ReportRepository,
+		banRepo:             repo.BanRepository,
+		authorizer:          authorizer,
+		automod:             automod,
 	}
 }
 
-func (ruc *ReactionUseCase) NewReactionForm() reactionForm {
-	return reactionForm{}
+func (ruc *ReactionUseCase) NewReactionForm() ReactionForm {
+	return ReactionForm{}
 }
 
-func (ruc *ReactionUseCase) UpdatePostReaction(userID, postID int, form *reactionForm) error {
+func (ruc *ReactionUseCase) UpdatePostReaction(userID, postID int, form *ReactionForm) error {
+	if err := checkBan(ruc.banRepo, userID); err != nil {
+		return err
+	}
+
 	exists, err := ruc.userRepo.Exists(userID)
 	if err != nil {
 		return err
@@ -70,17 +71,46 @@ func (ruc *ReactionUseCase) UpdatePostReaction(userID, postID int, form *reactio
 		return err
 	}
 
+	// Посты под теневым баном видит только автор
+	if ownerID != userID {
+		hidden, err := ruc.banRepo.IsShadowBanned(ownerID)
+		if err != nil {
+			return err
+		}
+		if hidden {
+			return entities.ErrNoRecord
+		}
+	}
+
+	// Действия пользователя под теневым баном никого не уведомляют
+	shadowBanned, err := ruc.banRepo.IsShadowBanned(userID)
+	if err != nil {
+		return err
+	}
+	notify := ownerID != userID && !shadowBanned
+
 	if form.Comment != "" {
+		form.Comment = validator.Normalize(form.Comment)
 		form.CheckField(validator.NotBlank(form.Comment), "comment", "This field cannot be blank")
-		form.CheckField(validator.Matches(form.Comment, validator.TextRX), "comment", "This field must contain only english or russian letters")
+		form.CheckField(validator.MaxChars(form.Comment, maxCommentChars), "comment", "This field cannot be more than 5000 characters long")
+		form.CheckField(validator.SafeText(form.Comment), "comment", "This field contains unsupported characters")
 		if !form.Valid() {
 			return entities.ErrInvalidData
 		}
-		commentId, err := ruc.commentRepo.InsertComment(postID, userID, form.Comment)
+		contentHTML, err := markdown.Render(form.Comment)
+		if err != nil {
+			return err
+		}
+		commentId, err := ruc.commentRepo.InsertComment(postID, userID, form.Comment, string(contentHTML))
 		if err != nil {
 			return err
 		}
-		if ownerID != userID {
+		comment := &entities.Comment{ID: commentId, PostID: postID, UserID: userID, Content: form.Comment}
+		if err = ruc.automod.checkComment(comment); err != nil {
+			return err
+		}
+		// О скрытом комментарии автор поста узнает после проверки модератором
+		if notify && !comment.Hidden {
 			err = ruc.postReactionRepo.AddNotification(ownerID, postID, userID, "comment", &commentId)
 			if err != nil {
 				return err
@@ -116,7 +146,7 @@ func (ruc *ReactionUseCase) UpdatePostReaction(userID, postID int, form *reactio
 		} else {
 
 			if userReaction == nil {
-				if ownerID != userID {
+				if notify {
 					err = ruc.postReactionRepo.AddNotification(ownerID, postID, userID, action, nil)
 					if err != nil {
 						return err
@@ -176,59 +206,3 @@ func (ruc *ReactionUseCase) UpdatePostReaction(userID, postID int, form *reactio
 
 	return nil
 }
-
-func (ruc *ReactionUseCase) CreateReport(userID, postID int, reason string) error {
-	exists, err := ruc.userRepo.Exists(userID)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return entities.ErrNoRecord
-	}
-
-	exists, err = ruc.postRepo.Exists(postID)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return entities.ErrNoRecord
-	}
-
-	err = ruc.reportRepo.CreateReport(userID, postID, reason)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (ruc *ReactionUseCase) GetPostReport(postID int) (*entities.Report, error) {
-	report, err := ruc.reportRepo.GetPostReport(postID)
-	if err != nil {
-		return nil, err
-	}
-
-	return report, nil
-}
-
-func (ruc *ReactionUseCase) GetAllPaginatedPostReportsDTO(page, pageSize int, paginationURL string) (*ReportsDTO, error) {
-	// Получаем репорты для нужной страницы.
-	reports, err := ruc.reportRepo.GetAllPaginatedPostReports(page, pageSize)
-	if err != nil {
-		return nil, err
-	}
-
-	// Проверяем, есть ли следующая страница
-	hasNextPage := len(reports) > pageSize
-	// Если постов больше, чем pageSize, обрезаем список до pageSize
-	if hasNextPage {
-		reports = reports[:pageSize]
-	}
-
-	return &ReportsDTO{
-		Reports:       reports,
-		HasNextPage:   hasNextPage,
-		CurrentPage:   page,
-		PaginationURL: paginationURL,
-	}, nil
-}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+type ReportUseCase struct {
+	reportRepo  repository.ReportRepository
+	postRepo    repository.PostRepository
+	commentRepo repository.CommentRepository
+	userRepo    repository.UserRepository
+	settingRepo repository.SettingRepository
+	auditRepo   repository.AuditRepository
+	queueRepo   repository.QueueRepository
+	banRepo     repository.BanRepository
+	// Удаление объекта по жалобе идёт через посты: там права и журнал
+	posts *PostUseCase
+}
+
+type reportForm struct {
+	TargetType string
+	TargetID   int
+	Category   string
+	Reason     string
+	validator.Validator
+}
+
+type reportStatusForm struct {
+	Status string
+	Note   string
+	Remove bool // удалить пост или комментарий вместе с решением жалобы
+	validator.Validator
+}
+
+type ReportsDTO struct {
+	Targets       []*entities.ReportTarget
+	Status        string
+	HasNextPage   bool
+	CurrentPage   int
+	PaginationURL string
+}
+
+type ReportTargetDTO struct {
+	TargetType string
+	TargetID   int
+	TargetName string // пусто, если объект удалён
+	PostID     int
+	Active     bool // есть незакрытые жалобы
+	Reports    []*entities.Report
+	Events     []*entities.ReportEvent
+}
+
+func NewReportUseCase(repo *repository.Repository, posts *PostUseCase) *ReportUseCase {
+	return &ReportUseCase{
+		reportRepo:  repo.ReportRepository,
+		postRepo:    repo.PostRepository,
+		commentRepo: repo.CommentRepository,
+		userRepo:    repo.UserRepository,
+		settingRepo: repo.SettingRepository,
+		auditRepo:   repo.AuditRepository,
+		queueRepo:   repo.QueueRepository,
+		banRepo:     repo.BanRepository,
+		posts:       posts,
+	}
+}
+
+func (uc *ReportUseCase) NewReportForm() reportForm {
+	return reportForm{}
+}
+
+func (uc *ReportUseCase) NewReportStatusForm() reportStatusForm {
+	return reportStatusForm{}
+}
+
+func (uc *ReportUseCase) ReportReasons() ([]string, error) {
+	return getReportReasons(uc.settingRepo)
+}
+
+// CreateReport сохраняет жалобу userID на пост, комментарий или профиль
+func (uc *ReportUseCase) CreateReport(userID int, form *reportForm) error {
+	if err := checkBan(uc.banRepo, userID); err != nil {
+		return err
+	}
+
+	reasons, err := uc.ReportReasons()
+	if err != nil {
+		return err
+	}
+
+	form.CheckField(validator.PermittedValue(form.Category, reasons...), "category", "Choose a reason from the list")
+	form.Reason = validator.Normalize(form.Reason)
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+	form.CheckField(validator.SafeText(form.Reason), "reason", "This field contains unsupported characters")
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	report := &entities.Report{
+		UserID:     userID,
+		TargetType: form.TargetType,
+		TargetID:   form.TargetID,
+		Category:   form.Category,
+		Reason:     strings.TrimSpace(form.Reason),
+	}
+
+	switch form.TargetType {
+	case entities.TargetPost:
+		post, err := uc.postRepo.GetPost(form.TargetID)
+		if err != nil {
+			return err
+		}
+		// Пост на модерации видят только автор и модераторы
+		if !post.IsApproved {
+			return entities.ErrNoRecord
+		}
+		report.TargetUserID = post.UserID
+		report.PostID = post.ID
+	case entities.TargetComment:
+		comment, err := uc.commentRepo.GetComment(form.TargetID)
+		if err != nil {
+			return err
+		}
+		// Скрытый комментарий и комментарии к посту на модерации видят только
+		// автор и модераторы, как и сам такой пост
+		if comment.Hidden {
+			return entities.ErrNoRecord
+		}
+		post, err := uc.postRepo.GetPost(comment.PostID)
+		if err != nil {
+			return err
+		}
+		if !post.IsApproved {
+			return entities.ErrNoRecord
+		}
+		report.TargetUserID = comment.UserID
+		report.PostID = comment.PostID
+	case entities.TargetUser:
+		user, err := uc.userRepo.Get(form.TargetID)
+		if err != nil {
+			return err
+		}
+		report.TargetUserID = user.ID
+	default:
+		return entities.ErrNoRecord
+	}
+
+	// Жаловаться на себя нельзя
+	if report.TargetUserID == userID {
+		return entities.ErrForbidden
+	}
+
+	exists, err := uc.reportRepo.HasActiveReport(userID, report.TargetType, report.TargetID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return entities.ErrDuplicateReport
+	}
+
+	_, err = uc.reportRepo.CreateReport(report)
+	return err
+}
+
+// GetReportsDTO возвращает объекты с жалобами в статусе status, по pageSize на страницу
+func (uc *ReportUseCase) GetReportsDTO(status string, page, pageSize int, paginationURL string) (*ReportsDTO, error) {
+	if !entities.IsReportStatus(status) {
+		return nil, entities.ErrInvalidData
+	}
+
+	targets, err := uc.reportRepo.GetReportTargets(status, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(targets) > pageSize
+	if hasNextPage {
+		targets = targets[:pageSize]
+	}
+
+	return &ReportsDTO{
+		Targets:       targets,
+		Status:        status,
+		HasNextPage:   hasNextPage,
+		CurrentPage:   page,
+		PaginationURL: paginationURL,
+	}, nil
+}
+
+// GetReportTarget возвращает все жалобы на объект и историю их статусов
+func (uc *ReportUseCase) GetReportTarget(targetType string, targetID int) (*ReportTargetDTO, error) {
+	reports, err := uc.reportRepo.GetTargetReports(targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, entities.ErrNoRecord
+	}
+
+	events, err := uc.reportRepo.GetTargetEvents(targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := uc.targetName(targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	dto := &ReportTargetDTO{
+		TargetType: targetType,
+		TargetID:   targetID,
+		TargetName: name,
+		PostID:     reports[0].PostID,
+		Reports:    reports,
+		Events:     events,
+	}
+	for _, r := range reports {
+		if r.Status == entities.ReportOpen || r.Status == entities.ReportInReview {
+			dto.Active = true
+		}
+	}
+	return dto, nil
+}
+
+// SetReportStatus берёт в работу, решает или отклоняет все незакрытые жалобы на объект
+func (uc *ReportUseCase) SetReportStatus(actorID int, targetType string, targetID int, form *reportStatusForm) error {
+	form.CheckField(validator.PermittedValue(form.Status, entities.ReportInReview, entities.ReportResolved, entities.ReportDismissed),
+		"status", "Unknown status")
+	form.CheckField(validator.MaxChars(form.Note, 500), "note", "This field cannot be more than 500 characters long")
+	if form.Remove {
+		form.CheckField(form.Status == entities.ReportResolved, "remove", "Content can only be removed when the report is resolved")
+		form.CheckField(targetType != entities.TargetUser, "remove", "Use the users console to ban or delete an account")
+	}
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	// Название берём до удаления объекта
+	name, err := uc.targetName(targetType, targetID)
+	if err != nil {
+		return err
+	}
+	reports, err := uc.reportRepo.GetTargetReports(targetType, targetID)
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		return entities.ErrNoRecord
+	}
+
+	if err = checkClaim(uc.queueRepo, actorID, targetType, targetID); err != nil {
+		return err
+	}
+
+	changed, err := uc.reportRepo.SetTargetStatus(targetType, targetID, form.Status, actorID, strings.TrimSpace(form.Note))
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		for _, r := range reports {
+			if r.Status == entities.ReportOpen || r.Status == entities.ReportInReview {
+				// Жалобы уже в этом статусе
+				return nil
+			}
+		}
+		return entities.ErrReportClosed
+	}
+
+	if form.Remove {
+		switch targetType {
+		case entities.TargetPost:
+			err = uc.posts.deletePost(targetID, actorID, nil, form.Note)
+		case entities.TargetComment:
+			err = uc.posts.deleteComment(targetID, actorID, nil, form.Note)
+		}
+		// Объект могли удалить раньше, жалоба всё равно решена
+		if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+			return err
+		}
+	}
+
+	// Комментарий, скрытый автомодерацией, после отклонения жалоб снова виден
+	if targetType == entities.TargetComment && form.Status == entities.ReportDismissed {
+		if err = uc.commentRepo.SetCommentHidden(targetID, false); err != nil {
+			return err
+		}
+	}
+
+	action := entities.ActionReportReview
+	switch form.Status {
+	case entities.ReportResolved:
+		action = entities.ActionReportAccept
+	case entities.ReportDismissed:
+		action = entities.ActionReportReject
+	}
+
+	return recordAction(uc.auditRepo, &entities.ModerationAction{
+		ActorID:      actorID,
+		Action:       action,
+		TargetType:   targetType,
+		TargetID:     targetID,
+		TargetUserID: reports[0].TargetUserID,
+		TargetName:   name,
+		Reason:       strings.TrimSpace(form.Note),
+	}, nil, map[string]any{
+		"status":  form.Status,
+		"reports": changed,
+		"removed": form.Remove,
+	})
+}
+
+// targetName - заголовок поста, начало комментария или имя пользователя.
+// Для удалённого объекта возвращается пустая строка.
+func (uc *ReportUseCase) targetName(targetType string, targetID int) (string, error) {
+	var name string
+	var err error
+
+	switch targetType {
+	case entities.TargetPost:
+		var post *entities.Post
+		if post, err = uc.postRepo.GetPost(targetID); err == nil {
+			name = post.Title
+		}
+	case entities.TargetComment:
+		var comment *entities.Comment
+		if comment, err = uc.commentRepo.GetComment(targetID); err == nil {
+			name = excerpt(comment.Content, 80)
+		}
+	case entities.TargetUser:
+		var user *entities.User
+		if user, err = uc.userRepo.Get(targetID); err == nil {
+			name = user.Username
+		}
+	default:
+		return "", entities.ErrNoRecord
+	}
+
+	if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+		return "", err
+	}
+	return name, nil
+}
+
+// Если причины жалоб ещё ни разу не сохраняли, действуют причины по умолчанию
+func getReportReasons(settingRepo repository.SettingRepository) ([]string, error) {
+	value, err := settingRepo.GetSetting(entities.SettingReportReasons)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return entities.DefaultReportReasons, nil
+		}
+		return nil, err
+	}
+	return splitLines(value), nil
+}
+
+// splitLines возвращает непустые строки без пробелов по краям
+func splitLines(value string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// excerpt обрезает текст до max символов
+func excerpt(text string, max int) string {
+	if cut, ok := validator.Truncate(text, max); ok {
+		return cut + "…"
+	}
+	return text
+}
@@ -0,0 +1,495 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/diff"
+	"forum/pkg/markdown"
+	"forum/pkg/validator"
+)
+
+type RevisionUseCase struct {
+	revisionRepo repository.RevisionRepository
+	postRepo     repository.PostRepository
+	commentRepo  repository.CommentRepository
+	categoryRepo repository.CategoryRepository
+	userRepo     repository.UserRepository
+	banRepo      repository.BanRepository
+	auditRepo    repository.AuditRepository
+	queueRepo    repository.QueueRepository
+	authorizer   *AuthorizerUseCase
+}
+
+type rollbackForm struct {
+	Revision int
+	Reason   string
+	validator.Validator
+}
+
+// HistoryDTO - версии поста или комментария и сравнение двух из них
+type HistoryDTO struct {
+	TargetType string
+	TargetID   int
+	PostID     int
+	PostTitle  string
+	Revisions  []*entities.Revision // от последней к первой
+	From       *entities.Revision
+	To         *entities.Revision
+	Title      []diff.Line
+	Categories []diff.Line
+	Content    []diff.Line
+	// Content в две колонки, если выбран такой вид
+	ContentRows []diff.Row
+	Split       bool
+	Changed     bool
+	CanRollback bool
+}
+
+func NewRevisionUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase) *RevisionUseCase {
+	return &RevisionUseCase{
+		revisionRepo: repo.RevisionRepository,
+		postRepo:     repo.PostRepository,
+		commentRepo:  repo.CommentRepository,
+		categoryRepo: repo.CategoryRepository,
+		userRepo:     repo.UserRepository,
+		banRepo:      repo.BanRepository,
+		auditRepo:    repo.AuditRepository,
+		queueRepo:    repo.QueueRepository,
+		authorizer:   authorizer,
+	}
+}
+
+func (uc *RevisionUseCase) NewRollbackForm() rollbackForm {
+	return rollbackForm{}
+}
+
+// GetHistoryDTO сравнивает версии from и to; 0 - последняя версия и версия
+// перед ней. Историю видят те же, кто видит сам пост или комментарий.
+func (uc *RevisionUseCase) GetHistoryDTO(targetType string, targetID, viewerID, from, to int, split bool) (*HistoryDTO, error) {
+	dto := &HistoryDTO{TargetType: targetType, TargetID: targetID, Split: split}
+
+	var viewer *entities.User
+	if viewerID > 0 {
+		var err error
+		if viewer, err = uc.userRepo.Get(viewerID); err != nil {
+			return nil, err
+		}
+	}
+
+	var current *entities.Revision
+	switch targetType {
+	case entities.TargetPost:
+		post, err := uc.postRepo.GetPost(targetID)
+		if err != nil {
+			return nil, err
+		}
+		if err = uc.checkPostVisible(post, viewer); err != nil {
+			return nil, err
+		}
+		if current, err = currentPostRevision(uc.categoryRepo, post); err != nil {
+			return nil, err
+		}
+		dto.PostID, dto.PostTitle = post.ID, post.Title
+	case entities.TargetComment:
+		comment, err := uc.commentRepo.GetComment(targetID)
+		if err != nil {
+			return nil, err
+		}
+		post, err := uc.postRepo.GetPost(comment.PostID)
+		if err != nil {
+			return nil, err
+		}
+		if err = uc.checkPostVisible(post, viewer); err != nil {
+			return nil, err
+		}
+		if err = uc.checkCommentVisible(comment, viewer); err != nil {
+			return nil, err
+		}
+		current = commentRevision(comment)
+		dto.PostID, dto.PostTitle = post.ID, post.Title
+	default:
+		return nil, entities.ErrNoRecord
+	}
+
+	revisions, err := uc.revisionRepo.GetRevisions(targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	// Нетронутый текст - единственная, ещё не сохранённая версия
+	if len(revisions) == 0 {
+		current.Number = 1
+		if current.EditorName, err = uc.username(current.EditorID); err != nil {
+			return nil, err
+		}
+		revisions = []*entities.Revision{current}
+	}
+
+	latest := len(revisions)
+	if to == 0 {
+		to = latest
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+	if from < 1 || from > latest || to < 1 || to > latest {
+		return nil, entities.ErrNoRecord
+	}
+	dto.From, dto.To = revisions[from-1], revisions[to-1]
+
+	if err = uc.compare(dto); err != nil {
+		return nil, err
+	}
+
+	dto.Revisions = slices.Clone(revisions)
+	slices.Reverse(dto.Revisions)
+
+	if viewer != nil {
+		dto.CanRollback, err = uc.authorizer.CanOnPost(viewer.ID, viewer.Role, dto.PostID, entities.PermRevisionRollback)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dto, nil
+}
+
+// Rollback возвращает пост или комментарий к версии form.Revision. Откат
+// сохраняется как новая версия и записывается в журнал модерации.
+func (uc *RevisionUseCase) Rollback(actorID int, targetType string, targetID int, form *rollbackForm) error {
+	form.Reason = validator.Normalize(strings.TrimSpace(form.Reason))
+	form.CheckField(validator.MaxChars(form.Reason, 200), "reason", "This field cannot be more than 200 characters long")
+	form.CheckField(validator.SafeText(form.Reason) && validator.SingleLine(form.Reason), "reason", "This field contains unsupported characters")
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	actor, err := uc.userRepo.Get(actorID)
+	if err != nil {
+		return err
+	}
+
+	rev, err := uc.revisionRepo.GetRevision(targetType, targetID, form.Revision)
+	if err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("Rolled back to revision %d", rev.Number)
+	if form.Reason != "" {
+		summary += ": " + form.Reason
+	}
+
+	switch targetType {
+	case entities.TargetPost:
+		return uc.rollbackPost(actor, rev, summary, form.Reason)
+	case entities.TargetComment:
+		return uc.rollbackComment(actor, rev, summary, form.Reason)
+	}
+	return entities.ErrNoRecord
+}
+
+func (uc *RevisionUseCase) rollbackPost(actor *entities.User, rev *entities.Revision, summary, reason string) error {
+	post, err := uc.postRepo.GetPost(rev.TargetID)
+	if err != nil {
+		return err
+	}
+	if err = uc.checkRollback(actor, post.ID, entities.TargetPost, post.ID); err != nil {
+		return err
+	}
+
+	current, err := currentPostRevision(uc.categoryRepo, post)
+	if err != nil {
+		return err
+	}
+
+	// Удалённые с тех пор категории пропускаем; если не осталось ни одной,
+	// категории поста не меняются
+	all, err := uc.categoryRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	categoryIDs := []int{}
+	for _, id := range rev.CategoryIDs {
+		if slices.ContainsFunc(all, func(c *entities.Category) bool { return c.ID == id }) {
+			categoryIDs = append(categoryIDs, id)
+		}
+	}
+	if len(categoryIDs) == 0 {
+		categoryIDs = current.CategoryIDs
+	}
+
+	contentHTML, err := markdown.Render(rev.Content)
+	if err != nil {
+		return err
+	}
+	if err = uc.postRepo.UpdatePostWithImage(rev.Title, rev.Content, string(contentHTML), post.ID, nil, categoryIDs); err != nil {
+		return err
+	}
+
+	number, err := saveRevision(uc.revisionRepo, current, &entities.Revision{
+		TargetType:  entities.TargetPost,
+		TargetID:    post.ID,
+		EditorID:    actor.ID,
+		Title:       rev.Title,
+		Content:     rev.Content,
+		CategoryIDs: categoryIDs,
+		Summary:     summary,
+	})
+	if err != nil {
+		return err
+	}
+
+	entry := postAction(actor.ID, entities.ActionPostRollback, post)
+	entry.Reason = reason
+	return recordAction(uc.auditRepo, entry, map[string]any{
+		"revision": number - 1,
+		"title":    post.Title,
+		"content":  post.Content,
+	}, map[string]any{
+		"revision": number,
+		"restored": rev.Number,
+		"title":    rev.Title,
+		"content":  rev.Content,
+	})
+}
+
+func (uc *RevisionUseCase) rollbackComment(actor *entities.User, rev *entities.Revision, summary, reason string) error {
+	comment, err := uc.commentRepo.GetComment(rev.TargetID)
+	if err != nil {
+		return err
+	}
+	if err = uc.checkRollback(actor, comment.PostID, entities.TargetComment, comment.ID); err != nil {
+		return err
+	}
+
+	contentHTML, err := markdown.Render(rev.Content)
+	if err != nil {
+		return err
+	}
+	if err = uc.commentRepo.UpdateComment(comment.ID, rev.Content, string(contentHTML)); err != nil {
+		return err
+	}
+
+	number, err := saveRevision(uc.revisionRepo, commentRevision(comment), &entities.Revision{
+		TargetType: entities.TargetComment,
+		TargetID:   comment.ID,
+		EditorID:   actor.ID,
+		Content:    rev.Content,
+		Summary:    summary,
+	})
+	if err != nil {
+		return err
+	}
+
+	author, err := uc.username(comment.UserID)
+	if err != nil {
+		return err
+	}
+	return recordAction(uc.auditRepo, &entities.ModerationAction{
+		ActorID:      actor.ID,
+		Action:       entities.ActionCommentRollback,
+		TargetType:   entities.TargetComment,
+		TargetID:     comment.ID,
+		TargetUserID: comment.UserID,
+		TargetName:   author,
+		Reason:       reason,
+	}, map[string]any{
+		"post_id":  comment.PostID,
+		"revision": number - 1,
+		"content":  comment.Content,
+	}, map[string]any{
+		"post_id":  comment.PostID,
+		"revision": number,
+		"restored": rev.Number,
+		"content":  rev.Content,
+	})
+}
+
+// checkRollback проверяет право на откат в категориях поста и то, что объект
+// не взял в работу другой модератор
+func (uc *RevisionUseCase) checkRollback(actor *entities.User, postID int, targetType string, targetID int) error {
+	allowed, err := uc.authorizer.CanOnPost(actor.ID, actor.Role, postID, entities.PermRevisionRollback)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entities.ErrForbidden
+	}
+	return checkClaim(uc.queueRepo, actor.ID, targetType, targetID)
+}
+
+// checkPostVisible повторяет правила страницы поста: пост под теневым баном
+// не существует для других, пост на модерации видят автор и модераторы
+func (uc *RevisionUseCase) checkPostVisible(post *entities.Post, viewer *entities.User) error {
+	viewerID, role := 0, ""
+	if viewer != nil {
+		viewerID, role = viewer.ID, viewer.Role
+	}
+
+	if post.UserID != viewerID {
+		hidden, err := uc.banRepo.IsShadowBanned(post.UserID)
+		if err != nil {
+			return err
+		}
+		if hidden {
+			return entities.ErrNoRecord
+		}
+	}
+
+	if post.IsApproved || post.UserID == viewerID {
+		return nil
+	}
+	canApprove, err := uc.authorizer.CanOnPost(viewerID, role, post.ID, entities.PermPostApprove)
+	if err != nil {
+		return err
+	}
+	if !canApprove {
+		return entities.ErrForbidden
+	}
+	return nil
+}
+
+// checkCommentVisible: скрытый автомодерацией комментарий видят автор и те,
+// кто может его откатить
+func (uc *RevisionUseCase) checkCommentVisible(comment *entities.Comment, viewer *entities.User) error {
+	if viewer != nil && viewer.ID == comment.UserID {
+		return nil
+	}
+	if !comment.Hidden {
+		hidden, err := uc.banRepo.IsShadowBanned(comment.UserID)
+		if err != nil || !hidden {
+			return err
+		}
+		return entities.ErrNoRecord
+	}
+	if viewer == nil {
+		return entities.ErrNoRecord
+	}
+	allowed, err := uc.authorizer.CanOnPost(viewer.ID, viewer.Role, comment.PostID, entities.PermRevisionRollback)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return entities.ErrNoRecord
+	}
+	return nil
+}
+
+// compare заполняет сравнение версий dto.From и dto.To
+func (uc *RevisionUseCase) compare(dto *HistoryDTO) error {
+	dto.Content = diff.Lines(dto.From.Content, dto.To.Content)
+	if dto.Split {
+		dto.ContentRows = diff.Split(dto.Content)
+	}
+	dto.Changed = diff.Changed(dto.Content)
+
+	if dto.TargetType != entities.TargetPost {
+		return nil
+	}
+
+	dto.Title = diff.Lines(dto.From.Title, dto.To.Title)
+
+	all, err := uc.categoryRepo.GetAll()
+	if err != nil {
+		return err
+	}
+	names := map[int]string{}
+	for _, c := range all {
+		names[c.ID] = c.Name
+	}
+	categoryNames := func(ids []int) string {
+		lines := make([]string, len(ids))
+		for i, id := range ids {
+			name, ok := names[id]
+			if !ok {
+				name = "Deleted category #" + strconv.Itoa(id)
+			}
+			lines[i] = name
+		}
+		slices.Sort(lines)
+		return strings.Join(lines, "\n")
+	}
+	dto.Categories = diff.Lines(categoryNames(dto.From.CategoryIDs), categoryNames(dto.To.CategoryIDs))
+
+	dto.Changed = dto.Changed || diff.Changed(dto.Title) || diff.Changed(dto.Categories)
+	return nil
+}
+
+func (uc *RevisionUseCase) username(userID int) (string, error) {
+	user, err := uc.userRepo.Get(userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return "Deleted User", nil
+		}
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// currentPostRevision - сохранённый пост как версия: исходная версия
+// принадлежит автору и датируется созданием поста
+func currentPostRevision(categoryRepo repository.CategoryRepository, post *entities.Post) (*entities.Revision, error) {
+	categories, err := categoryRepo.GetCategoriesForPost(post.ID)
+	if err != nil {
+		return nil, err
+	}
+	categoryIDs := make([]int, len(categories))
+	for i, c := range categories {
+		categoryIDs[i] = c.ID
+	}
+
+	return &entities.Revision{
+		TargetType:  entities.TargetPost,
+		TargetID:    post.ID,
+		EditorID:    post.UserID,
+		Title:       post.Title,
+		Content:     post.Content,
+		CategoryIDs: categoryIDs,
+		Created:     post.Created,
+	}, nil
+}
+
+func commentRevision(comment *entities.Comment) *entities.Revision {
+	return &entities.Revision{
+		TargetType: entities.TargetComment,
+		TargetID:   comment.ID,
+		EditorID:   comment.UserID,
+		Content:    comment.Content,
+		Created:    comment.Created,
+	}
+}
+
+// saveRevision сохраняет новую версию rev и возвращает её номер. Версии
+// появляются с первой правкой: если их ещё нет, сначала сохраняется base -
+// текст до правки.
+func saveRevision(revisionRepo repository.RevisionRepository, base, rev *entities.Revision) (int, error) {
+	revisions, err := revisionRepo.GetRevisions(rev.TargetType, rev.TargetID)
+	if err != nil {
+		return 0, err
+	}
+
+	number := len(revisions) + 1
+	if len(revisions) == 0 {
+		if _, err = revisionRepo.InsertRevision(base); err != nil {
+			return 0, err
+		}
+		number++
+	}
+
+	if _, err = revisionRepo.InsertRevision(rev); err != nil {
+		return 0, err
+	}
+	return number, nil
+}
+
+// sameRevision сообщает, что правка ничего не изменила в тексте и категориях
+func sameRevision(a, b *entities.Revision) bool {
+	ac, bc := slices.Clone(a.CategoryIDs), slices.Clone(b.CategoryIDs)
+	slices.Sort(ac)
+	slices.Sort(bc)
+	return a.Title == b.Title && a.Content == b.Content && slices.Equal(ac, bc)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+type RoleUseCase struct {
+	roleRepo   repository.RoleRepository
+	userRepo   repository.UserRepository
+	auditRepo  repository.AuditRepository
+	authorizer *AuthorizerUseCase
+}
+
+type roleForm struct {
+	Name        string
+	Description string
+	Permissions []string
+	validator.Validator
+}
+
+func NewRoleUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase) *RoleUseCase {
+	return &RoleUseCase{
+		roleRepo:   repo.RoleRepository,
+		userRepo:   repo.UserRepository,
+		auditRepo:  repo.AuditRepository,
+		authorizer: authorizer,
+	}
+}
+
+func (uc *RoleUseCase) NewRoleForm() roleForm {
+	return roleForm{Permissions: []string{}}
+}
+
+func (uc *RoleUseCase) ListRoles() ([]*entities.Role, error) {
+	return uc.roleRepo.ListRoles()
+}
+
+func (uc *RoleUseCase) GetRole(name string) (*entities.Role, error) {
+	return uc.roleRepo.GetRole(name)
+}
+
+func (uc *RoleUseCase) CreateRole(form *roleForm) error {
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Name, validator.RoleNameRX), "name", "Use 2-32 lowercase latin letters, digits, '-' or '_', starting with a letter")
+	form.validatePermissions()
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	err := uc.roleRepo.InsertRole(form.Name, form.Description, form.Permissions)
+	if err != nil {
+		if errors.Is(err, entities.ErrDuplicateRole) {
+			form.AddFieldError("name", "Role with this name already exists")
+			return entities.ErrInvalidData
+		}
+		return err
+	}
+
+	uc.authorizer.invalidate()
+	return nil
+}
+
+// UpdateRole меняет описание и права роли. Права администратора не настраиваются.
+func (uc *RoleUseCase) UpdateRole(form *roleForm) error {
+	if form.Name == entities.RoleAdmin {
+		return entities.ErrBuiltinRole
+	}
+
+	form.validatePermissions()
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	err := uc.roleRepo.UpdateRole(form.Name, form.Description, form.Permissions)
+	if err != nil {
+		return err
+	}
+
+	uc.authorizer.invalidate()
+	return nil
+}
+
+func (uc *RoleUseCase) DeleteRole(name string) error {
+	role, err := uc.roleRepo.GetRole(name)
+	if err != nil {
+		return err
+	}
+	if role.Builtin {
+		return entities.ErrBuiltinRole
+	}
+	if role.Users > 0 {
+		return entities.ErrRoleInUse
+	}
+
+	err = uc.roleRepo.DeleteRole(name)
+	if err != nil {
+		return err
+	}
+
+	uc.authorizer.invalidate()
+	return nil
+}
+
+// AssignRole назначает пользователю роль. Свою роль менять нельзя, а выдавать
+// и отбирать роль администратора может только администратор.
+func (uc *RoleUseCase) AssignRole(actorID, userID int, role string) error {
+	if actorID == userID {
+		return entities.ErrForbidden
+	}
+
+	if _, err := uc.roleRepo.GetRole(role); err != nil {
+		return err
+	}
+
+	actor, err := uc.userRepo.Get(actorID)
+	if err != nil {
+		return err
+	}
+	user, err := uc.userRepo.Get(userID)
+	if err != nil {
+		return err
+	}
+
+	if (role == entities.RoleAdmin || user.Role == entities.RoleAdmin) && actor.Role != entities.RoleAdmin {
+		return entities.ErrForbidden
+	}
+
+	if user.Role == role {
+		return nil
+	}
+
+	err = uc.roleRepo.SetUserRole(userID, role)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(uc.auditRepo, userAction(actor.ID, entities.ActionRoleChange, user),
+		map[string]any{"role": user.Role}, map[string]any{"role": role})
+}
+
+func (form *roleForm) validatePermissions() {
+	form.CheckField(validator.MaxChars(form.Description, 200), "description", "This field cannot be more than 200 characters long")
+	for _, p := range form.Permissions {
+		form.CheckField(entities.IsPermission(p), "permissions", "Unknown permission")
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"html/template"
 	"mime/multipart"
 
 	"forum/internal/entities"
@@ -10,19 +11,36 @@ import (
 type User interface {
 	NewUserAuthForm() userAuthForm
 	NewAccountPasswordUpdateForm() accountPasswordUpdateForm
+	NewForgotPasswordForm() forgotPasswordForm
+	NewPasswordResetForm() passwordResetForm
+	NewEmailUpdateForm() emailUpdateForm
 	Insert(username, email, password, role string) (int, error)
 	Authenticate(email, password string) (*entities.User, error)
-	OauthAuthenticate(email string) (*entities.User, error)
+	Login(email, password, ip string) (*entities.User, error)
+	LoginTwoFactor(userID int, ip string, form *TwoFactorForm) (*entities.User, error)
+	UnlockUser(userID int) error
+	GetLoginActivity() (*LoginActivityDTO, error)
 	UserExists(id int) (bool, error)
 	GetUserByID(id int) (*entities.User, error)
 	UpdatePassword(userID int, form *accountPasswordUpdateForm) error
 	CreateModerationRequest(userId int, form *ModerationRequestForm) error
 	NewModerationForm() ModerationRequestForm
-	GetModerators() ([]*entities.User, error)
-	DeleteModerator(userId int) error
+	GetModerators() ([]*entities.Moderator, error)
+	SetModeratorCategories(actorID, userID int, categoryIDs []int) error
+	DeleteModerator(actorID, userId int) error
 	GetModerationApplicants() ([]*entities.ModeratorApplicant, error)
 	DeleteModerationRequest(userId int) error
-	ApproveModerationRequest(userId int) error
+	ApproveModerationRequest(actorID, userId int) error
+	RejectModerationRequest(actorID, userId int) error
+	RequestPasswordReset(form *forgotPasswordForm) (*entities.User, string, error)
+	CheckPasswordResetToken(token string) error
+	ResetPassword(form *passwordResetForm) (int, error)
+	CreateEmailVerification(userID int) (*entities.User, string, error)
+	VerifyEmail(token string) (int, error)
+	MarkEmailVerified(userID int) error
+	EmailVerificationRequired(userID int) (bool, error)
+	RequestEmailChange(userID int, form *emailUpdateForm) (*entities.User, string, error)
+	ConfirmEmailChange(token string) (int, error)
 }
 
 type Post interface {
@@ -30,50 +48,204 @@ type Post interface {
 	NewCommentForm() CommentForm
 	GetPostDTO(postID int, userID int) (*PostDTO, error)
 	GetCommentedPostDTO(postID int, userID int) (*PostDTO, error)
-	GetAllPaginatedPostsDTO(page, pageSize int, paginationURL string) (*PostsDTO, error)
-	GetAllPaginatedUnapprovedPostsDTO(page, pageSize int, paginationURL string) (*PostsDTO, error)
-	GetUserPostsDTO(userID, page, pageSize int, paginationURL string) (*PostsDTO, error)
+	GetAllPaginatedPostsDTO(viewerID, page, pageSize int, paginationURL string) (*PostsDTO, error)
+	GetUserPostsDTO(viewerID, userID, page, pageSize int, paginationURL string) (*PostsDTO, error)
 	GetUserCommentedPostsDTO(userID, page, pageSize int, paginationURL string) (*PostsDTO, error)
 	GetUserLikedPostsDTO(userID, page, pageSize int, paginationURL string) (*PostsDTO, error)
-	GetFilteredPaginatedPostsDTO(form *postCreateForm, page, pageSize int, paginationURL string) (*PostsDTO, error)
+	GetFilteredPaginatedPostsDTO(viewerID int, form *postCreateForm, page, pageSize int, paginationURL string) (*PostsDTO, error)
 	CreatePostWithCategories(form *postCreateForm, files []*multipart.FileHeader, userID int) (int, []*entities.Category, error)
-	UpdatePostWithImage(form *postCreateForm, postID int, files []*multipart.FileHeader, userID int) error
-	DeleteComment(commentID, userID int) error
-	UpdateComment(form *CommentForm, commentID, userID int) error
+	UpdatePostWithImage(form *postCreateForm, postID int, files []*multipart.FileHeader, userID int, token *entities.APIToken) error
+	DeleteComment(commentID, userID int, token *entities.APIToken) error
+	UpdateComment(form *CommentForm, commentID, userID int, token *entities.APIToken) error
 	GetUserNotifications(userID int) ([]*entities.Notification, error)
-	ApprovePost(postID int) error
-	DeletePost(postID, userID int) error
-	DeleteReport(userId, postId int) error
+	ApprovePost(postID, userID int) error
+	NewRejectPostForm() rejectPostForm
+	RejectPost(postID, userID int, form *rejectPostForm) error
+	DeletePost(postID, userID int, token *entities.APIToken) error
+	PreviewMarkdown(content string) (template.HTML, error)
 }
 
 type Reaction interface {
-	NewReactionForm() reactionForm
-	UpdatePostReaction(userID, postID int, form *reactionForm) error
-	CreateReport(userID, postID int, reason string) error
-	GetPostReport(postID int) (*entities.Report, error)
-	GetAllPaginatedPostReportsDTO(page, pageSize int, paginationURL string) (*ReportsDTO, error)
+	NewReactionForm() ReactionForm
+	UpdatePostReaction(userID, postID int, form *ReactionForm) error
+}
+
+type Report interface {
+	NewReportForm() reportForm
+	NewReportStatusForm() reportStatusForm
+	ReportReasons() ([]string, error)
+	CreateReport(userID int, form *reportForm) error
+	GetReportsDTO(status string, page, pageSize int, paginationURL string) (*ReportsDTO, error)
+	GetReportTarget(targetType string, targetID int) (*ReportTargetDTO, error)
+	SetReportStatus(actorID int, targetType string, targetID int, form *reportStatusForm) error
+}
+
+type Queue interface {
+	NewQueueFilterForm() queueFilterForm
+	NewQueueActionForm() queueActionForm
+	GetQueueDTO(userID int, form *queueFilterForm, page, pageSize int, paginationURL string) (*QueueDTO, error)
+	ApplyAction(actorID int, form *queueActionForm) (*QueueResult, error)
 }
 
 type Category interface {
-	Insert(form *CategoryForm) (int, error)
+	Insert(actorID int, form *CategoryForm) (int, error)
 	Get(categoryId int) (*entities.Category, error)
 	GetAll() ([]*entities.Category, error)
-	Delete(categoryId int) error
+	Delete(actorID, categoryId int) error
 	NewCategoryCreateForm() CategoryForm
 }
 
+type TwoFactor interface {
+	NewTwoFactorForm() TwoFactorForm
+	GetTwoFactor(userID int) (*entities.TwoFactor, error)
+	IsEnabled(userID int) (bool, error)
+	BeginEnrollment(userID int) (*entities.TwoFactor, error)
+	ProvisioningURI(userID int) (string, error)
+	ConfirmEnrollment(userID int, form *TwoFactorForm) ([]string, error)
+	Verify(userID int, form *TwoFactorForm) error
+	Disable(userID int, role string, form *TwoFactorForm) error
+	RegenerateRecoveryCodes(userID int, form *TwoFactorForm) ([]string, error)
+	IsRequired(role string) (bool, error)
+	SetupRequired(userID int, role string) (bool, error)
+}
+
+type Identity interface {
+	NewUsernameForm() usernameForm
+	Authenticate(ext *entities.ExternalIdentity) (*entities.User, error)
+	SignUp(ext *entities.ExternalIdentity, form *usernameForm) (*entities.User, error)
+	Link(userID int, ext *entities.ExternalIdentity) error
+	Unlink(userID int, provider string) error
+	ListIdentities(userID int) ([]*entities.UserIdentity, error)
+}
+
+type APIToken interface {
+	NewAPITokenForm() apiTokenForm
+	CreateAPIToken(userID int, form *apiTokenForm) (string, error)
+	AuthenticateAPIToken(plaintext string) (*entities.User, *entities.APIToken, error)
+	ListAPITokens(userID int) ([]*entities.APIToken, error)
+	RevokeAPIToken(userID, tokenID int) error
+}
+
+type Authorizer interface {
+	Can(role, permission string) (bool, error)
+	RolePermissions(role string) (map[string]bool, error)
+	IsStaff(role string) (bool, error)
+	CanOnPost(userID int, role string, postID int, permission string) (bool, error)
+	PostPermissions(userID int, role string, postID int) (map[string]bool, error)
+}
+
+type Role interface {
+	NewRoleForm() roleForm
+	ListRoles() ([]*entities.Role, error)
+	GetRole(name string) (*entities.Role, error)
+	CreateRole(form *roleForm) error
+	UpdateRole(form *roleForm) error
+	DeleteRole(name string) error
+	AssignRole(actorID, userID int, role string) error
+}
+
+type Ban interface {
+	NewBanForm() banForm
+	BanUser(actorID int, form *banForm) error
+	LiftBan(actorID, userID int) error
+	ListBans() ([]*entities.Ban, error)
+	CheckBan(userID int) error
+}
+
+type UserAdmin interface {
+	NewUserFilterForm() userFilterForm
+	SearchUsers(form *userFilterForm, page, pageSize int, paginationURL string) (*UsersDTO, error)
+	GetUserDetail(userID int) (*UserDetailDTO, error)
+	ResetPassword(actorID, userID int) (*entities.User, string, error)
+	ForceLogout(actorID, userID int) error
+	DeleteUser(actorID, userID int) error
+}
+
+type Audit interface {
+	NewAuditFilterForm() auditFilterForm
+	ListActions(form *auditFilterForm, page, pageSize int, paginationURL string) (*AuditDTO, error)
+	ExportActions(form *auditFilterForm) ([]*entities.ModerationAction, error)
+}
+
+type Automod interface {
+	NewAutomodRuleForm() automodRuleForm
+	GetRules() ([]*entities.AutomodRule, error)
+	GetRuleForm(id int) (*automodRuleForm, error)
+	SaveRule(form *automodRuleForm) error
+	DeleteRule(id int) error
+	GetHitsDTO(ruleID, page, pageSize int, paginationURL string) (*AutomodHitsDTO, error)
+}
+
+type Setting interface {
+	GetSettingsForm() (*SettingsForm, error)
+	UpdateSettings(form *SettingsForm) error
+}
+
+type Revision interface {
+	NewRollbackForm() rollbackForm
+	GetHistoryDTO(targetType string, targetID, viewerID, from, to int, split bool) (*HistoryDTO, error)
+	Rollback(actorID int, targetType string, targetID int, form *rollbackForm) error
+}
+
+type Draft interface {
+	NewDraftForm() draftForm
+	GetDraft(userID, draftID int) (*entities.Draft, error)
+	GetUserDrafts(userID int) ([]*entities.Draft, error)
+	SaveDraft(userID int, form *draftForm) (int, error)
+	AutosaveDraft(userID int, form *draftForm) (int, error)
+	PublishDraft(userID, draftID int, form *draftForm) (int, error)
+	DeleteDraft(userID, draftID int) error
+	PublishDueDrafts() (int, error)
+}
+
 type Service struct {
 	User
 	Post
 	Reaction
+	Report
+	Queue
 	Category
+	TwoFactor
+	Setting
+	Identity
+	APIToken
+	Authorizer
+	Role
+	Ban
+	UserAdmin
+	Audit
+	Automod
+	Revision
+	Draft
 }
 
-func NewService(repos *repository.Repository) *Service {
+func NewService(repos *repository.Repository, loginPolicy LoginPolicy) *Service {
+	// Один авторизатор на всё приложение: у него общий кеш прав ролей
+	authorizer := NewAuthorizerUseCase(repos)
+
+	automod := NewAutomodUseCase(repos)
+	post := NewPostUseCase(repos, authorizer, automod)
+	report := NewReportUseCase(repos, post)
+	twoFactor := NewTwoFactorUseCase(repos, authorizer)
+
 	return &Service{
-		User:     NewUserUseCase(repos.UserRepository),
-		Post:     NewPostUseCase(repos),
-		Reaction: NewReactionUseCase(repos),
-		Category: NewCategoryUseCase(repos.CategoryRepository),
+		User:       NewUserUseCase(repos, loginPolicy, twoFactor),
+		Post:       post,
+		Reaction:   NewReactionUseCase(repos, authorizer, automod),
+		Report:     report,
+		Queue:      NewQueueUseCase(repos, authorizer, post, report),
+		Category:   NewCategoryUseCase(repos),
+		TwoFactor:  twoFactor,
+		Setting:    NewSettingUseCase(repos.SettingRepository),
+		Identity:   NewIdentityUseCase(repos),
+		APIToken:   NewAPITokenUseCase(repos),
+		Authorizer: authorizer,
+		Role:       NewRoleUseCase(repos, authorizer),
+		Ban:        NewBanUseCase(repos, authorizer),
+		UserAdmin:  NewUserAdminUseCase(repos, authorizer),
+		Audit:      NewAuditUseCase(repos),
+		Automod:    automod,
+		Revision:   NewRevisionUseCase(repos, authorizer),
+		Draft:      NewDraftUseCase(repos, post),
 	}
 }
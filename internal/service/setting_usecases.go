@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+type SettingUseCase struct {
+	settingRepo repository.SettingRepository
+}
+
+type SettingsForm struct {
+	RequireStaffTwoFactor    bool
+	RequireEmailVerification bool
+	ReportReasons            string // по одной причине в строке
+	validator.Validator
+}
+
+func NewSettingUseCase(settingRepo repository.SettingRepository) *SettingUseCase {
+	return &SettingUseCase{settingRepo: settingRepo}
+}
+
+func (uc *SettingUseCase) GetSettingsForm() (*SettingsForm, error) {
+	form := &SettingsForm{}
+
+	var err error
+	form.RequireStaffTwoFactor, err = getBoolSetting(uc.settingRepo, entities.SettingRequireStaffTwoFactor, false)
+	if err != nil {
+		return nil, err
+	}
+
+	form.RequireEmailVerification, err = getBoolSetting(uc.settingRepo, entities.SettingRequireEmailVerification, true)
+	if err != nil {
+		return nil, err
+	}
+
+	reasons, err := getReportReasons(uc.settingRepo)
+	if err != nil {
+		return nil, err
+	}
+	form.ReportReasons = strings.Join(reasons, "\n")
+
+	return form, nil
+}
+
+func (uc *SettingUseCase) UpdateSettings(form *SettingsForm) error {
+	reasons := splitLines(form.ReportReasons)
+	form.CheckField(len(reasons) > 0, "reportReasons", "Enter at least one report reason")
+	form.CheckField(len(reasons) <= 20, "reportReasons", "Enter no more than 20 report reasons")
+	for _, reason := range reasons {
+		form.CheckField(validator.MaxChars(reason, 50), "reportReasons", "Each report reason must be no more than 50 characters long")
+	}
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	err := uc.settingRepo.SetSetting(entities.SettingRequireStaffTwoFactor, strconv.FormatBool(form.RequireStaffTwoFactor))
+	if err != nil {
+		return err
+	}
+
+	err = uc.settingRepo.SetSetting(entities.SettingRequireEmailVerification, strconv.FormatBool(form.RequireEmailVerification))
+	if err != nil {
+		return err
+	}
+
+	return uc.settingRepo.SetSetting(entities.SettingReportReasons, strings.Join(reasons, "\n"))
+}
+
+// Если настройку ещё ни разу не сохраняли, возвращается значение по умолчанию
+func getBoolSetting(settingRepo repository.SettingRepository, key string, defaultVal bool) (bool, error) {
+	value, err := settingRepo.GetSetting(key)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return defaultVal, nil
+		}
+		return false, err
+	}
+	return strconv.ParseBool(value)
+}
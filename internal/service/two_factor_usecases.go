@@ -0,0 +1,272 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/totp"
+	"forum/pkg/validator"
+)
+
+const (
+	totpIssuer         = "Forum"
+	totpSkew           = 1 // допускаем расхождение часов на один шаг в каждую сторону
+	recoveryCodesCount = 10
+)
+
+type TwoFactorUseCase struct {
+	twoFactorRepo repository.TwoFactorRepository
+	userRepo      repository.UserRepository
+	settingRepo   repository.SettingRepository
+	authorizer    *AuthorizerUseCase
+}
+
+type TwoFactorForm struct {
+	Code string
+	validator.Validator
+}
+
+func NewTwoFactorUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase) *TwoFactorUseCase {
+	return &TwoFactorUseCase{
+		twoFactorRepo: repo.TwoFactorRepository,
+		userRepo:      repo.UserRepository,
+		settingRepo:   repo.SettingRepository,
+		authorizer:    authorizer,
+	}
+}
+
+func (uc *TwoFactorUseCase) NewTwoFactorForm() TwoFactorForm {
+	return TwoFactorForm{}
+}
+
+// GetTwoFactor возвращает состояние 2FA пользователя; если он её никогда не
+// настраивал, возвращается выключенная запись.
+func (uc *TwoFactorUseCase) GetTwoFactor(userID int) (*entities.TwoFactor, error) {
+	tf, err := uc.twoFactorRepo.GetTwoFactor(userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return &entities.TwoFactor{UserID: userID}, nil
+		}
+		return nil, err
+	}
+	return tf, nil
+}
+
+func (uc *TwoFactorUseCase) IsEnabled(userID int) (bool, error) {
+	tf, err := uc.GetTwoFactor(userID)
+	if err != nil {
+		return false, err
+	}
+	return tf.Enabled, nil
+}
+
+// BeginEnrollment создаёт новый секрет, который начнёт действовать после
+// подтверждения кодом из приложения.
+func (uc *TwoFactorUseCase) BeginEnrollment(userID int) (*entities.TwoFactor, error) {
+	tf, err := uc.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled || tf.Secret != "" {
+		return tf, nil
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = uc.twoFactorRepo.SetPendingSecret(userID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.GetTwoFactor(userID)
+}
+
+func (uc *TwoFactorUseCase) ProvisioningURI(userID int) (string, error) {
+	tf, err := uc.GetTwoFactor(userID)
+	if err != nil {
+		return "", err
+	}
+	if tf.Enabled || tf.Secret == "" {
+		return "", entities.ErrNoRecord
+	}
+
+	user, err := uc.userRepo.Get(userID)
+	if err != nil {
+		return "", err
+	}
+
+	return totp.ProvisioningURI(tf.Secret, totpIssuer, user.Email), nil
+}
+
+// ConfirmEnrollment включает 2FA, если код совпал с ожидающим секретом, и
+// возвращает свежие коды восстановления.
+func (uc *TwoFactorUseCase) ConfirmEnrollment(userID int, form *TwoFactorForm) ([]string, error) {
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+	if !form.Valid() {
+		return nil, entities.ErrInvalidData
+	}
+
+	tf, err := uc.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled || tf.Secret == "" {
+		return nil, entities.ErrNoRecord
+	}
+
+	counter, ok := totp.Validate(tf.Secret, form.Code, time.Now(), totpSkew)
+	if !ok {
+		form.AddFieldError("code", "The code is incorrect")
+		return nil, entities.ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.twoFactorRepo.EnableTwoFactor(userID, counter, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Verify принимает код из приложения или один из кодов восстановления.
+// Каждый код срабатывает только один раз.
+func (uc *TwoFactorUseCase) Verify(userID int, form *TwoFactorForm) error {
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+	if !form.Valid() {
+		return entities.ErrInvalidData
+	}
+
+	tf, err := uc.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled {
+		return entities.ErrNoRecord
+	}
+
+	if counter, ok := totp.Validate(tf.Secret, form.Code, time.Now(), totpSkew); ok {
+		fresh, err := uc.twoFactorRepo.UseCounter(userID, counter)
+		if err != nil {
+			return err
+		}
+		if fresh {
+			return nil
+		}
+	} else {
+		used, err := uc.twoFactorRepo.UseRecoveryCode(userID, hashRecoveryCode(form.Code))
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+
+	form.AddFieldError("code", "The code is incorrect or has already been used")
+	return entities.ErrInvalidTwoFactorCode
+}
+
+func (uc *TwoFactorUseCase) Disable(userID int, role string, form *TwoFactorForm) error {
+	required, err := uc.IsRequired(role)
+	if err != nil {
+		return err
+	}
+	if required {
+		form.AddNonFieldError("Two-factor authentication is required for your role and cannot be turned off")
+		return entities.ErrTwoFactorRequired
+	}
+
+	err = uc.Verify(userID, form)
+	if err != nil {
+		return err
+	}
+
+	return uc.twoFactorRepo.DeleteTwoFactor(userID)
+}
+
+func (uc *TwoFactorUseCase) RegenerateRecoveryCodes(userID int, form *TwoFactorForm) ([]string, error) {
+	err := uc.Verify(userID, form)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.twoFactorRepo.ReplaceRecoveryCodes(userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// IsRequired сообщает, обязана ли роль использовать 2FA по настройкам сайта.
+// Требование касается всех ролей, у которых есть хоть какие-то права.
+func (uc *TwoFactorUseCase) IsRequired(role string) (bool, error) {
+	staff, err := uc.authorizer.IsStaff(role)
+	if err != nil || !staff {
+		return false, err
+	}
+	return getBoolSetting(uc.settingRepo, entities.SettingRequireStaffTwoFactor, false)
+}
+
+// SetupRequired сообщает, что пользователь должен включить 2FA, прежде чем
+// пользоваться правами своей роли.
+func (uc *TwoFactorUseCase) SetupRequired(userID int, role string) (bool, error) {
+	required, err := uc.IsRequired(role)
+	if err != nil || !required {
+		return false, err
+	}
+
+	enabled, err := uc.IsEnabled(userID)
+	if err != nil {
+		return false, err
+	}
+	return !enabled, nil
+}
+
+func generateRecoveryCodes() (codes []string, hashes []string, err error) {
+	// 32 символа без похожих друг на друга l, o, 0 и 1
+	const alphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for j := range b {
+			b[j] = alphabet[b[j]&31]
+		}
+		code := string(b[:5]) + "-" + string(b[5:])
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// Коды восстановления достаточно случайны, поэтому для них хватает sha256
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"forum/internal/entities"
+	"forum/internal/repository"
+	"forum/pkg/validator"
+)
+
+const (
+	adminPasswordResetTokenTTL = 24 * time.Hour
+	userActionsLimit           = 50
+)
+
+type UserAdminUseCase struct {
+	userRepo     repository.UserRepository
+	tokenRepo    repository.TokenRepository
+	roleRepo     repository.RoleRepository
+	auditRepo    repository.AuditRepository
+	apiTokenRepo repository.APITokenRepository
+	authorizer   *AuthorizerUseCase
+}
+
+type userFilterForm struct {
+	entities.UserFilter
+	validator.Validator
+}
+
+type UsersDTO struct {
+	Users         []*entities.UserOverview
+	HasNextPage   bool
+	CurrentPage   int
+	PaginationURL string
+}
+
+type UserDetailDTO struct {
+	User    *entities.UserOverview
+	Actions []*entities.ModerationAction
+	Roles   []*entities.Role
+}
+
+func NewUserAdminUseCase(repo *repository.Repository, authorizer *AuthorizerUseCase) *UserAdminUseCase {
+	return &UserAdminUseCase{
+		userRepo:     repo.UserRepository,
+		tokenRepo:    repo.TokenRepository,
+		roleRepo:     repo.RoleRepository,
+		auditRepo:    repo.AuditRepository,
+		apiTokenRepo: repo.APITokenRepository,
+		authorizer:   authorizer,
+	}
+}
+
+func (uc *UserAdminUseCase) NewUserFilterForm() userFilterForm {
+	return userFilterForm{}
+}
+
+func (uc *UserAdminUseCase) SearchUsers(form *userFilterForm, page, pageSize int, paginationURL string) (*UsersDTO, error) {
+	form.CheckField(validator.MaxChars(form.Query, 100), "query", "This field cannot be more than 100 characters long")
+	form.CheckField(validDate(form.JoinedFrom), "joined_from", "Enter a date like 2024-01-31")
+	form.CheckField(validDate(form.JoinedTo), "joined_to", "Enter a date like 2024-01-31")
+	form.CheckField(validator.PermittedValue(form.Activity, "", entities.ActivityActive, entities.ActivityInactive), "activity", "Unknown activity filter")
+	if form.Role != "" {
+		_, err := uc.roleRepo.GetRole(form.Role)
+		if err != nil && !errors.Is(err, entities.ErrNoRecord) {
+			return nil, err
+		}
+		form.CheckField(err == nil, "role", "Unknown role")
+	}
+	if !form.Valid() {
+		return nil, entities.ErrInvalidData
+	}
+
+	users, err := uc.userRepo.SearchUsers(&form.UserFilter, page, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hasNextPage := len(users) > pageSize
+	if hasNextPage {
+		users = users[:pageSize]
+	}
+
+	return &UsersDTO{
+		Users:         users,
+		HasNextPage:   hasNextPage,
+		CurrentPage:   page,
+		PaginationURL: paginationURL,
+	}, nil
+}
+
+func (uc *UserAdminUseCase) GetUserDetail(userID int) (*UserDetailDTO, error) {
+	user, err := uc.userRepo.GetOverview(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := uc.auditRepo.ListModerationActions(&entities.AuditFilter{TargetUserID: userID}, userActionsLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := uc.roleRepo.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserDetailDTO{
+		User:    user,
+		Actions: actions,
+		Roles:   roles,
+	}, nil
+}
+
+// ResetPassword заменяет пароль пользователя случайным и выдаёт токен для
+// письма со ссылкой на выбор нового пароля. Сессии пользователя завершает
+// вызывающий.
+func (uc *UserAdminUseCase) ResetPassword(actorID, userID int) (*entities.User, string, error) {
+	target, err := uc.checkTarget(actorID, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	password, _, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	err = uc.userRepo.SetPassword(target.ID, password)
+	if err != nil {
+		return nil, "", err
+	}
+
+	revoked, err := uc.apiTokenRepo.DeleteUserAPITokens(target.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = uc.tokenRepo.DeleteUserTokens(target.ID, entities.TokenPurposePasswordReset)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, hash, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = uc.tokenRepo.InsertToken(target.ID, entities.TokenPurposePasswordReset, hash, "", adminPasswordResetTokenTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = recordAction(uc.auditRepo, userAction(actorID, entities.ActionPasswordReset, target), nil, revokedTokens(revoked))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return target, token, nil
+}
+
+// ForceLogout проверяет, что сотрудник может завершить сессии пользователя,
+// отзывает его токены доступа и пишет это в журнал. Сами сессии завершает
+// вызывающий.
+func (uc *UserAdminUseCase) ForceLogout(actorID, userID int) error {
+	target, err := uc.checkTarget(actorID, userID)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := uc.apiTokenRepo.DeleteUserAPITokens(target.ID)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(uc.auditRepo, userAction(actorID, entities.ActionForceLogout, target), nil, revokedTokens(revoked))
+}
+
+// revokedTokens - запись для журнала о том, сколько токенов доступа отозвано
+func revokedTokens(n int) map[string]any {
+	return map[string]any{"api_tokens_revoked": n}
+}
+
+// DeleteUser удаляет аккаунт вместе со всеми записями пользователя
+func (uc *UserAdminUseCase) DeleteUser(actorID, userID int) error {
+	target, err := uc.checkTarget(actorID, userID)
+	if err != nil {
+		return err
+	}
+
+	overview, err := uc.userRepo.GetOverview(target.ID)
+	if err != nil {
+		return err
+	}
+
+	err = uc.userRepo.DeleteUser(target.ID)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(uc.auditRepo, userAction(actorID, entities.ActionDelete, target), map[string]any{
+		"email":    overview.Email,
+		"role":     overview.Role,
+		"created":  overview.Created,
+		"posts":    overview.Posts,
+		"comments": overview.Comments,
+	}, nil)
+}
+
+// checkTarget возвращает пользователя, над которым сотрудник может действовать.
+// Над собой действовать нельзя, над сотрудниками - только администратору.
+func (uc *UserAdminUseCase) checkTarget(actorID, userID int) (*entities.User, error) {
+	if actorID == userID {
+		return nil, entities.ErrForbidden
+	}
+
+	actor, err := uc.userRepo.Get(actorID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := uc.userRepo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	staff, err := uc.authorizer.IsStaff(target.Role)
+	if err != nil {
+		return nil, err
+	}
+	if staff && actor.Role != entities.RoleAdmin {
+		return nil, entities.ErrForbidden
+	}
+
+	return target, nil
+}
+
+func validDate(value string) bool {
+	if value == "" {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
@@ -1,13 +1,32 @@
 package service
 
 import (
+	"errors"
+	"time"
+
 	"forum/internal/entities"
 	"forum/internal/repository"
 	"forum/pkg/validator"
 )
 
+const (
+	passwordResetTokenTTL     = time.Hour
+	emailVerificationTokenTTL = 24 * time.Hour
+)
+
 type UserUseCase struct {
-	userRepo repository.UserRepository
+	userRepo         repository.UserRepository
+	tokenRepo        repository.TokenRepository
+	settingRepo      repository.SettingRepository
+	loginAttemptRepo repository.LoginAttemptRepository
+	categoryRepo     repository.CategoryRepository
+	moderatorRepo    repository.ModeratorRepository
+	banRepo          repository.BanRepository
+	auditRepo        repository.AuditRepository
+	apiTokenRepo     repository.APITokenRepository
+	loginPolicy      LoginPolicy
+	// Неверные коды второго шага входа считаются неудачными входами
+	twoFactor *TwoFactorUseCase
 }
 
 type userAuthForm struct {
@@ -18,7 +37,8 @@ type userAuthForm struct {
 }
 
 type ModerationRequestForm struct {
-	Reason string
+	Reason     string
+	Categories []int
 	validator.Validator
 }
 
@@ -26,12 +46,41 @@ type accountPasswordUpdateForm struct {
 	CurrentPassword         string
 	NewPassword             string
 	NewPasswordConfirmation string
+	SignOutOtherSessions    bool
+	validator.Validator
+}
+
+type forgotPasswordForm struct {
+	Email string
+	validator.Validator
+}
+
+type passwordResetForm struct {
+	Token                   string
+	NewPassword             string
+	NewPasswordConfirmation string
 	validator.Validator
 }
 
-func NewUserUseCase(userRepo repository.UserRepository) *UserUseCase {
+type emailUpdateForm struct {
+	NewEmail        string
+	CurrentPassword string
+	validator.Validator
+}
+
+func NewUserUseCase(repo *repository.Repository, loginPolicy LoginPolicy, twoFactor *TwoFactorUseCase) *UserUseCase {
 	return &UserUseCase{
-		userRepo: userRepo,
+		userRepo:         repo.UserRepository,
+		tokenRepo:        repo.TokenRepository,
+		settingRepo:      repo.SettingRepository,
+		loginAttemptRepo: repo.LoginAttemptRepository,
+		categoryRepo:     repo.CategoryRepository,
+		moderatorRepo:    repo.ModeratorRepository,
+		banRepo:          repo.BanRepository,
+		auditRepo:        repo.AuditRepository,
+		apiTokenRepo:     repo.APITokenRepository,
+		loginPolicy:      loginPolicy,
+		twoFactor:        twoFactor,
 	}
 }
 
@@ -40,13 +89,25 @@ func (uc *UserUseCase) NewUserAuthForm() userAuthForm {
 }
 
 func (uc *UserUseCase) NewModerationForm() ModerationRequestForm {
-	return ModerationRequestForm{}
+	return ModerationRequestForm{Categories: []int{}}
 }
 
 func (uc *UserUseCase) NewAccountPasswordUpdateForm() accountPasswordUpdateForm {
 	return accountPasswordUpdateForm{}
 }
 
+func (uc *UserUseCase) NewForgotPasswordForm() forgotPasswordForm {
+	return forgotPasswordForm{}
+}
+
+func (uc *UserUseCase) NewPasswordResetForm() passwordResetForm {
+	return passwordResetForm{}
+}
+
+func (uc *UserUseCase) NewEmailUpdateForm() emailUpdateForm {
+	return emailUpdateForm{}
+}
+
 func (u *UserUseCase) Insert(username, email, password, role string) (int, error) {
 	return u.userRepo.Insert(username, email, password, role)
 }
@@ -55,10 +116,6 @@ func (u *UserUseCase) Authenticate(email, password string) (*entities.User, erro
 	return u.userRepo.Authenticate(email, password)
 }
 
-func (u *UserUseCase) OauthAuthenticate(email string) (*entities.User, error) {
-	return u.userRepo.OauthAuthenticate(email)
-}
-
 func (u *UserUseCase) UserExists(id int) (bool, error) {
 	return u.userRepo.Exists(id)
 }
@@ -89,13 +146,21 @@ func (u *UserUseCase) CreateModerationRequest(userId int, form *ModerationReques
 		return entities.ErrFormAlreadySubmitted
 	}
 
+	form.Reason = validator.Normalize(form.Reason)
 	form.CheckField(validator.NotBlank(form.Reason), "reason", "This field cannot be blank")
-	form.CheckField(validator.Matches(form.Reason, validator.TextRX), "reason", "This field must contain only english or russian letters")
+	form.CheckField(validator.SafeText(form.Reason), "reason", "This field contains unsupported characters")
+	form.CheckField(len(form.Categories) > 0, "categories", "Choose one or more categories")
+
+	valid, err := u.validCategories(form.Categories)
+	if err != nil {
+		return err
+	}
+	form.CheckField(valid, "categories", "One or more categories are invalid")
 	if !form.Valid() {
 		return entities.ErrInvalidData
 	}
 
-	err = u.userRepo.InsertModerationRequest(userId, form.Reason)
+	err = u.userRepo.InsertModerationRequest(userId, form.Reason, form.Categories)
 	if err != nil {
 		return err
 	}
@@ -103,12 +168,74 @@ func (u *UserUseCase) CreateModerationRequest(userId int, form *ModerationReques
 	return nil
 }
 
-func (u *UserUseCase) GetModerators() ([]*entities.User, error) {
-	return u.userRepo.GetModerators()
+func (u *UserUseCase) GetModerators() ([]*entities.Moderator, error) {
+	return u.moderatorRepo.ListModerators()
+}
+
+// SetModeratorCategories ограничивает модератора категориями.
+// Пустой список снимает ограничение.
+func (u *UserUseCase) SetModeratorCategories(actorID, userId int, categoryIDs []int) error {
+	user, err := u.userRepo.Get(userId)
+	if err != nil {
+		return err
+	}
+	if user.Role != entities.RoleModerator {
+		return entities.ErrNoRecord
+	}
+
+	valid, err := u.validCategories(categoryIDs)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return entities.ErrInvalidData
+	}
+
+	before, err := u.moderatorRepo.GetModeratorCategoryIDs(userId)
+	if err != nil {
+		return err
+	}
+
+	err = u.moderatorRepo.SetModeratorCategories(userId, categoryIDs)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(u.auditRepo, userAction(actorID, entities.ActionModeratorCategories, user),
+		map[string]any{"categories": before}, map[string]any{"categories": categoryIDs})
+}
+
+func (u *UserUseCase) validCategories(categoryIDs []int) (bool, error) {
+	for _, id := range categoryIDs {
+		exists, err := u.categoryRepo.Exists(id)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
-func (u *UserUseCase) DeleteModerator(userId int) error {
-	return u.userRepo.DeleteModerator(userId)
+func (u *UserUseCase) DeleteModerator(actorID, userId int) error {
+	user, err := u.userRepo.Get(userId)
+	if err != nil {
+		return err
+	}
+
+	categoryIDs, err := u.moderatorRepo.GetModeratorCategoryIDs(userId)
+	if err != nil {
+		return err
+	}
+
+	err = u.userRepo.DeleteModerator(userId)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(u.auditRepo, userAction(actorID, entities.ActionModeratorRemove, user),
+		map[string]any{"role": user.Role, "categories": categoryIDs}, map[string]any{"role": entities.RoleUser})
 }
 
 func (u *UserUseCase) GetModerationApplicants() ([]*entities.ModeratorApplicant, error) {
@@ -119,8 +246,260 @@ func (u *UserUseCase) DeleteModerationRequest(userId int) error {
 	return u.userRepo.DeleteModerationRequest(userId)
 }
 
-func (u *UserUseCase) ApproveModerationRequest(userId int) error {
-	return u.userRepo.ApproveModeratorRequest(userId)
+func (u *UserUseCase) ApproveModerationRequest(actorID, userId int) error {
+	user, err := u.userRepo.Get(userId)
+	if err != nil {
+		return err
+	}
+
+	err = u.userRepo.ApproveModeratorRequest(userId)
+	if err != nil {
+		return err
+	}
+
+	categoryIDs, err := u.moderatorRepo.GetModeratorCategoryIDs(userId)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(u.auditRepo, userAction(actorID, entities.ActionModeratorPromote, user),
+		map[string]any{"role": user.Role}, map[string]any{"role": entities.RoleModerator, "categories": categoryIDs})
+}
+
+// RejectModerationRequest отклоняет заявку в модераторы
+func (u *UserUseCase) RejectModerationRequest(actorID, userId int) error {
+	user, err := u.userRepo.Get(userId)
+	if err != nil {
+		return err
+	}
+
+	err = u.userRepo.DeleteModerationRequest(userId)
+	if err != nil {
+		return err
+	}
+
+	return recordAction(u.auditRepo, userAction(actorID, entities.ActionModeratorReject, user), nil, nil)
+}
+
+// RequestPasswordReset выдаёт токен сброса пароля для владельца адреса.
+// Прежние неиспользованные токены при этом перестают действовать.
+func (u *UserUseCase) RequestPasswordReset(form *forgotPasswordForm) (*entities.User, string, error) {
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.MaxChars(form.Email, 100), "email", "This field cannot be more than 100 characters long")
+	if !form.Valid() {
+		return nil, "", entities.ErrInvalidData
+	}
+
+	user, err := u.userRepo.GetByEmail(form.Email)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = u.tokenRepo.DeleteUserTokens(user.ID, entities.TokenPurposePasswordReset)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, hash, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = u.tokenRepo.InsertToken(user.ID, entities.TokenPurposePasswordReset, hash, "", passwordResetTokenTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, token, nil
+}
+
+func (u *UserUseCase) CheckPasswordResetToken(token string) error {
+	_, err := u.tokenRepo.GetTokenUserID(entities.TokenPurposePasswordReset, hashToken(token))
+	if errors.Is(err, entities.ErrNoRecord) {
+		return entities.ErrExpiredToken
+	}
+	return err
+}
+
+// ResetPassword задаёт новый пароль по токену из письма и возвращает ID пользователя
+func (u *UserUseCase) ResetPassword(form *passwordResetForm) (int, error) {
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "This field cannot be blank")
+	form.CheckField(validator.NotHaveAnySpaces(form.NewPassword), "newPassword", "This field cannot have any spaces")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "This field must be at least 8 characters long")
+	form.CheckField(validator.MaxChars(form.NewPassword, 100), "newPassword", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.Matches(form.NewPassword, validator.PasswordRX), "newPassword", "This field must contain only letters, digits and these symbols !_.@#$%^&*")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "newPasswordConfirmation", "Passwords do not match")
+	if !form.Valid() {
+		return 0, entities.ErrInvalidData
+	}
+
+	userID, _, err := u.tokenRepo.ConsumeToken(entities.TokenPurposePasswordReset, hashToken(form.Token))
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return 0, entities.ErrExpiredToken
+		}
+		return 0, err
+	}
+
+	err = u.userRepo.SetPassword(userID, form.NewPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	err = u.tokenRepo.DeleteUserTokens(userID, entities.TokenPurposePasswordReset)
+	if err != nil {
+		return 0, err
+	}
+
+	// Письмо о блокировке ведёт сюда же: после сброса войти можно сразу.
+	// Токены доступа, выданные до сброса, мог получить тот, от кого меняют пароль.
+	err = u.userRepo.ResetFailedLogins(userID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = u.apiTokenRepo.DeleteUserAPITokens(userID); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// CreateEmailVerification выдаёт токен для подтверждения текущего адреса пользователя
+func (u *UserUseCase) CreateEmailVerification(userID int) (*entities.User, string, error) {
+	user, err := u.userRepo.Get(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if user.EmailVerified {
+		return nil, "", entities.ErrEmailAlreadyVerified
+	}
+
+	err = u.tokenRepo.DeleteUserTokens(userID, entities.TokenPurposeEmailVerification)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, hash, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = u.tokenRepo.InsertToken(userID, entities.TokenPurposeEmailVerification, hash, "", emailVerificationTokenTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, token, nil
+}
+
+func (u *UserUseCase) VerifyEmail(token string) (int, error) {
+	userID, _, err := u.tokenRepo.ConsumeToken(entities.TokenPurposeEmailVerification, hashToken(token))
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return 0, entities.ErrExpiredToken
+		}
+		return 0, err
+	}
+
+	return userID, u.userRepo.SetEmailVerified(userID)
+}
+
+// MarkEmailVerified используется, когда адрес уже подтвердил внешний провайдер
+func (u *UserUseCase) MarkEmailVerified(userID int) error {
+	return u.userRepo.SetEmailVerified(userID)
+}
+
+// EmailVerificationRequired сообщает, что пользователю нельзя писать и
+// реагировать, пока он не подтвердит адрес.
+func (u *UserUseCase) EmailVerificationRequired(userID int) (bool, error) {
+	required, err := getBoolSetting(u.settingRepo, entities.SettingRequireEmailVerification, true)
+	if err != nil || !required {
+		return false, err
+	}
+
+	user, err := u.userRepo.Get(userID)
+	if err != nil {
+		return false, err
+	}
+	return !user.EmailVerified, nil
+}
+
+// RequestEmailChange выдаёт токен, который нужно открыть с нового адреса.
+// Адрес пользователя меняется только после подтверждения.
+func (u *UserUseCase) RequestEmailChange(userID int, form *emailUpdateForm) (*entities.User, string, error) {
+	form.CheckField(validator.NotBlank(form.NewEmail), "newEmail", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.NewEmail, validator.EmailRX), "newEmail", "This field must be a valid email address")
+	form.CheckField(validator.MaxChars(form.NewEmail, 100), "newEmail", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "currentPassword", "This field cannot be blank")
+	if !form.Valid() {
+		return nil, "", entities.ErrInvalidData
+	}
+
+	user, err := u.userRepo.Get(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = u.userRepo.CheckPassword(userID, form.CurrentPassword)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidCredentials) {
+			form.AddFieldError("currentPassword", "Current password is incorrect")
+			return nil, "", entities.ErrInvalidData
+		}
+		return nil, "", err
+	}
+
+	if form.NewEmail == user.Email {
+		form.AddFieldError("newEmail", "This is already your email address")
+		return nil, "", entities.ErrInvalidData
+	}
+
+	_, err = u.userRepo.GetByEmail(form.NewEmail)
+	if err == nil {
+		form.AddFieldError("newEmail", "Email address is already in use")
+		return nil, "", entities.ErrInvalidData
+	} else if !errors.Is(err, entities.ErrNoRecord) {
+		return nil, "", err
+	}
+
+	err = u.tokenRepo.DeleteUserTokens(userID, entities.TokenPurposeEmailChange)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, hash, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = u.tokenRepo.InsertToken(userID, entities.TokenPurposeEmailChange, hash, form.NewEmail, emailVerificationTokenTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, token, nil
 }
 
+func (u *UserUseCase) ConfirmEmailChange(token string) (int, error) {
+	userID, email, err := u.tokenRepo.ConsumeToken(entities.TokenPurposeEmailChange, hashToken(token))
+	if err != nil {
+		if errors.Is(err, entities.ErrNoRecord) {
+			return 0, entities.ErrExpiredToken
+		}
+		return 0, err
+	}
 
+	err = u.userRepo.UpdateEmail(userID, email)
+	if err != nil {
+		return 0, err
+	}
+
+	// Ссылки, отправленные на старый адрес, больше не нужны
+	err = u.tokenRepo.DeleteUserTokens(userID, entities.TokenPurposeEmailVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
@@ -1,9 +1,13 @@
 package session
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"sync"
 	"time"
 
@@ -22,7 +26,30 @@ type Provider interface {
 	SessionRead(sid string) (Session, error)
 	SessionDestroy(sid string) error
 	SessionGC(maxLifeTime int64)
-	SessionBindUser(userID int, sessionID string) error
+	// SessionBindUser records that sessionID belongs to userID. A userID below 1
+	// detaches the session from its user (logout). Other sessions of the same
+	// user are left untouched.
+	SessionBindUser(userID int, sessionID string, client Client) error
+	SessionsByUser(userID int) ([]Info, error)
+	// SessionDestroyUser destroys every session of userID except exceptSid.
+	SessionDestroyUser(userID int, exceptSid string) error
+}
+
+// Client describes the device a session was opened from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// Info describes one logged in session of a user
+type Info struct {
+	ID         string // opaque handle, safe to show in html
+	SID        string // real session id, only filled inside providers
+	UserID     int
+	Created    time.Time
+	LastAccess time.Time
+	Client
+	Current bool // session of the request that asked for the list
 }
 
 type Session interface {
@@ -75,7 +102,10 @@ func (manager *Manager) SessionStart(w http.ResponseWriter, r *http.Request) (Se
 		return session, nil
 	} else {
 		sid, _ := url.QueryUnescape(cookie.Value)
-		session, _ := manager.provider.SessionRead(sid)
+		session, err := manager.provider.SessionRead(sid)
+		if err != nil {
+			return nil, err
+		}
 		return session, nil
 	}
 }
@@ -118,7 +148,7 @@ func (manager *Manager) RenewToken(w http.ResponseWriter, r *http.Request, userI
 	}
 
 	// Привязываем новую сессию к текущему пользователю
-	err = manager.provider.SessionBindUser(userID, newSid)
+	err = manager.provider.SessionBindUser(userID, newSid, clientFromRequest(r))
 	if err != nil {
 		return fmt.Errorf("session bind user: %w", err)
 	}
@@ -165,9 +195,89 @@ func (manager *Manager) SessionDestroy(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// UserSessions lists all sessions of userID, marking the one used by r as current.
+func (manager *Manager) UserSessions(r *http.Request, userID int) ([]Info, error) {
+	sessions, err := manager.provider.SessionsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	currentSid := manager.requestSid(r)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SID == currentSid
+		sessions[i].ID = sessionHandle(sessions[i].SID)
+		sessions[i].SID = ""
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastAccess.After(sessions[j].LastAccess)
+	})
+	return sessions, nil
+}
+
+// DestroyUserSession destroys the session of userID identified by the handle
+// from Info.ID. It returns false if the user has no such session.
+func (manager *Manager) DestroyUserSession(userID int, id string) (bool, error) {
+	sessions, err := manager.provider.SessionsByUser(userID)
+	if err != nil {
+		return false, err
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	for _, s := range sessions {
+		if sessionHandle(s.SID) == id {
+			return true, manager.provider.SessionDestroy(s.SID)
+		}
+	}
+	return false, nil
+}
+
+// DestroyOtherUserSessions signs userID out everywhere except the session used by r.
+func (manager *Manager) DestroyOtherUserSessions(r *http.Request, userID int) error {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	return manager.provider.SessionDestroyUser(userID, manager.requestSid(r))
+}
+
+// DestroyAllUserSessions signs userID out on every device.
+func (manager *Manager) DestroyAllUserSessions(userID int) error {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	return manager.provider.SessionDestroyUser(userID, "")
+}
+
+func (manager *Manager) requestSid(r *http.Request) string {
+	cookie, err := r.Cookie(manager.cookieName)
+	if err != nil {
+		return ""
+	}
+	sid, _ := url.QueryUnescape(cookie.Value)
+	return sid
+}
+
+// sessionHandle скрывает настоящий sid: его нельзя выводить в html
+func sessionHandle(sid string) string {
+	sum := sha256.Sum256([]byte(sid))
+	return hex.EncodeToString(sum[:12])
+}
+
+// ClientIP возвращает адрес клиента без порта. Им пользуются сессии, журнал
+// входов и ограничение частоты запросов, чтобы один клиент везде был одним адресом.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func clientFromRequest(r *http.Request) Client {
+	return Client{IP: ClientIP(r), UserAgent: r.UserAgent()}
+}
+
 func (manager *Manager) GC() {
 	manager.lock.Lock()
 	defer manager.lock.Unlock()
 	manager.provider.SessionGC(manager.maxlifetime)
-	time.AfterFunc(time.Duration(manager.maxlifetime), func() { manager.GC() })
+	time.AfterFunc(time.Duration(manager.maxlifetime)*time.Second, func() { manager.GC() })
 }
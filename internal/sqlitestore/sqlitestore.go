@@ -0,0 +1,256 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"forum/internal/session"
+)
+
+// touchInterval ограничивает частоту записи timeAccessed в базу при чтении значений
+const touchInterval = time.Minute
+
+// Register делает провайдер "sqlite" доступным для session.NewManager. В
+// отличие от memory ему нужна открытая база, поэтому он регистрируется из
+// main, а не в init().
+//
+// Значения сессии сохраняются через encoding/gob: встроенные типы (int,
+// string, bool...) работают сразу, остальные нужно передать в gob.Register.
+func Register(db *sql.DB) {
+	session.Register("sqlite", &Provider{DB: db})
+}
+
+type SessionStore struct {
+	sid          string                      // идентификатор сессии
+	lock         sync.Mutex                  // защищает value и timeAccessed
+	timeAccessed time.Time                   // время последнего доступа
+	value        map[interface{}]interface{} // значения сессии
+	pder         *Provider
+}
+
+type Provider struct {
+	DB *sql.DB
+}
+
+func (st *SessionStore) Set(key, value interface{}) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	st.value[key] = value
+	if err := st.pder.save(st); err != nil {
+		delete(st.value, key)
+		return fmt.Errorf("failed to set the session value: %v", err)
+	}
+	return nil
+}
+
+// Get и GetAll не возвращают ошибку по интерфейсу session.Session, поэтому
+// ошибка обновления времени доступа только пишется в журнал
+func (st *SessionStore) Get(key interface{}) interface{} {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	if err := st.pder.touch(st); err != nil {
+		slog.Error("touch session on get", "error", err)
+	}
+
+	if v, ok := st.value[key]; ok {
+		return v
+	}
+	return nil
+}
+
+func (st *SessionStore) GetAll() map[interface{}]interface{} {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	if err := st.pder.touch(st); err != nil {
+		slog.Error("touch session on get all", "error", err)
+	}
+
+	values := make(map[interface{}]interface{}, len(st.value))
+	for k, v := range st.value {
+		values[k] = v
+	}
+	return values
+}
+
+func (st *SessionStore) Delete(key interface{}) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	if _, ok := st.value[key]; !ok {
+		return st.pder.touch(st)
+	}
+
+	old := st.value[key]
+	delete(st.value, key)
+	if err := st.pder.save(st); err != nil {
+		st.value[key] = old
+		return fmt.Errorf("failed to delete the session value: %v", err)
+	}
+	return nil
+}
+
+func (st *SessionStore) SessionID() string {
+	return st.sid
+}
+
+func (pder *Provider) SessionInit(sid string) (session.Session, error) {
+	st := &SessionStore{
+		sid:          sid,
+		timeAccessed: time.Now(),
+		value:        make(map[interface{}]interface{}),
+		pder:         pder,
+	}
+
+	data, err := encode(st.value)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO sessions (sid, data, created, time_accessed) VALUES (?, ?, ?, ?)
+	ON CONFLICT(sid) DO UPDATE SET data = excluded.data, created = excluded.created,
+	time_accessed = excluded.time_accessed, user_id = NULL`
+	_, err = pder.DB.Exec(stmt, sid, data, st.timeAccessed.Unix(), st.timeAccessed.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+func (pder *Provider) SessionRead(sid string) (session.Session, error) {
+	stmt := `SELECT data, time_accessed FROM sessions WHERE sid = ?`
+
+	var data []byte
+	var accessed int64
+	err := pder.DB.QueryRow(stmt, sid).Scan(&data, &accessed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return pder.SessionInit(sid)
+		}
+		return nil, err
+	}
+
+	value, err := decode(data)
+	if err != nil {
+		// Сессию, которую невозможно прочитать (например, после смены типов), начинаем заново
+		return pder.SessionInit(sid)
+	}
+
+	return &SessionStore{
+		sid:          sid,
+		timeAccessed: time.Unix(accessed, 0),
+		value:        value,
+		pder:         pder,
+	}, nil
+}
+
+func (pder *Provider) SessionDestroy(sid string) error {
+	stmt := `DELETE FROM sessions WHERE sid = ?`
+	_, err := pder.DB.Exec(stmt, sid)
+	return err
+}
+
+func (pder *Provider) SessionGC(maxlifetime int64) {
+	stmt := `DELETE FROM sessions WHERE time_accessed < ?`
+	_, err := pder.DB.Exec(stmt, time.Now().Unix()-maxlifetime)
+	if err != nil {
+		slog.Error("session gc", "error", err)
+	}
+}
+
+func (pder *Provider) SessionBindUser(userID int, sid string, client session.Client) error {
+	if userID < 1 {
+		_, err := pder.DB.Exec(`UPDATE sessions SET user_id = NULL WHERE sid = ?`, sid)
+		return err
+	}
+
+	// Связываем сессию с пользователем, остальные его сессии не трогаем
+	stmt := `UPDATE sessions SET user_id = ?, ip = ?, user_agent = ? WHERE sid = ?`
+	_, err := pder.DB.Exec(stmt, userID, client.IP, client.UserAgent, sid)
+	return err
+}
+
+func (pder *Provider) SessionsByUser(userID int) ([]session.Info, error) {
+	stmt := `SELECT sid, created, time_accessed, ip, user_agent FROM sessions
+	WHERE user_id = ?
+	ORDER BY time_accessed DESC`
+
+	rows, err := pder.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []session.Info{}
+	for rows.Next() {
+		info := session.Info{UserID: userID}
+		var created, accessed int64
+		if err := rows.Scan(&info.SID, &created, &accessed, &info.IP, &info.UserAgent); err != nil {
+			return nil, err
+		}
+		info.Created = time.Unix(created, 0)
+		info.LastAccess = time.Unix(accessed, 0)
+		sessions = append(sessions, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (pder *Provider) SessionDestroyUser(userID int, exceptSid string) error {
+	stmt := `DELETE FROM sessions WHERE user_id = ? AND sid != ?`
+	_, err := pder.DB.Exec(stmt, userID, exceptSid)
+	return err
+}
+
+func (pder *Provider) save(st *SessionStore) error {
+	data, err := encode(st.value)
+	if err != nil {
+		return err
+	}
+
+	st.timeAccessed = time.Now()
+	stmt := `UPDATE sessions SET data = ?, time_accessed = ? WHERE sid = ?`
+	_, err = pder.DB.Exec(stmt, data, st.timeAccessed.Unix(), st.sid)
+	return err
+}
+
+// touch обновляет время последнего доступа, но не чаще одного раза в touchInterval
+func (pder *Provider) touch(st *SessionStore) error {
+	now := time.Now()
+	if now.Sub(st.timeAccessed) < touchInterval {
+		return nil
+	}
+
+	st.timeAccessed = now
+	stmt := `UPDATE sessions SET time_accessed = ? WHERE sid = ?`
+	_, err := pder.DB.Exec(stmt, now.Unix(), st.sid)
+	return err
+}
+
+func encode(value map[interface{}]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, fmt.Errorf("session: encode values: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(data []byte) (map[interface{}]interface{}, error) {
+	value := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, fmt.Errorf("session: decode values: %w", err)
+	}
+	return value, nil
+}
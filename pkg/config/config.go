@@ -8,34 +8,66 @@ import (
 )
 
 type Config struct {
-	Host                    string
-	Port                    string
-	Dsn                     string
-	GoogleClientID          string
-	GoogleClientSecret      string
-	GoogleClientCallbackURL string
-	GithubClientID          string
-	GithubClientSecret      string
-	GithubClientCallbackURL string
-	MaxSendFileSize         int64
-	DialerTimeout           time.Duration
+	Host               string
+	Port               string
+	Dsn                string
+	MaxSendFileSize    int64
+	DialerTimeout      time.Duration
+	SessionProvider    string // "memory" или "sqlite"
+	SessionLifetime    int64  // в секундах
+	CSRFRotatePerForm  bool   // выдавать новый CSRF-токен после каждой отправленной формы
+	BaseURL            string // адрес сайта для ссылок в письмах
+	MailTransport      string // "smtp" или "outbox" (письма складываются в файлы, только для разработки)
+	MailFrom           string
+	MailOutboxDir      string
+	SMTPHost           string
+	SMTPPort           string
+	SMTPUsername       string
+	SMTPPassword       string
+	LoginMaxAttempts   int           // неудачных входов подряд до блокировки аккаунта
+	LoginLockoutBase   time.Duration // первая блокировка, дальше удваивается
+	LoginLockoutMax    time.Duration
+	LoginIPMaxAttempts int // неудачных входов с одного IP за LoginIPWindow, дальше пауза с удвоением
+	LoginIPWindow      time.Duration
+	OAuthProviders     []OAuthProvider
+	SchedulerInterval  time.Duration // как часто публиковать запланированные посты
 }
 
 // New returns a new Config struct
 func New() *Config {
+	baseURL := getEnv("BASE_URL", "https://localhost:4000")
+
 	return &Config{
-		Host:                    getEnv("HOST", "localhost"),
-		Port:                    getEnv("PORT", "4000"),
-		Dsn:                     getEnv("DSN", "./forum.db"),
-		GoogleClientID:          getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret:      getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleClientCallbackURL: getEnv("GOOGLE_CLIENT_CALLBACK_URL", "https://localhost:4000/auth/google/callback"),
-		GithubClientID:          getEnv("GITHUB_CLIENT_ID", ""),
-		GithubClientSecret:      getEnv("GITHUB_CLIENT_SECRET", ""),
-		GithubClientCallbackURL: getEnv("GITHUB_CLIENT_CALLBACK_URL", "https://localhost:4000/auth/github/callback"),
+		Host: getEnv("HOST", "localhost"),
+		Port: getEnv("PORT", "4000"),
+		Dsn:  getEnv("DSN", "./forum.db"),
 
 		MaxSendFileSize: int64(getEnvAsInt("MAX_SEND_FILE_SIZE", 26214400)),
 		DialerTimeout:   time.Duration(getEnvAsInt("DIALER_TIMEOUT", 60)),
+
+		SessionProvider: getEnv("SESSION_PROVIDER", "sqlite"),
+		SessionLifetime: int64(getEnvAsInt("SESSION_LIFETIME", 3600)),
+
+		CSRFRotatePerForm: getEnvAsBool("CSRF_ROTATE_PER_FORM", false),
+
+		BaseURL:       baseURL,
+		MailTransport: getEnv("MAIL_TRANSPORT", "smtp"),
+		MailFrom:      getEnv("MAIL_FROM", "Forum <no-reply@localhost>"),
+		MailOutboxDir: getEnv("MAIL_OUTBOX_DIR", "./outbox"),
+		SMTPHost:      getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:      getEnv("SMTP_PORT", "587"),
+		SMTPUsername:  getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:  getEnv("SMTP_PASSWORD", ""),
+
+		LoginMaxAttempts:   getEnvAsInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginLockoutBase:   time.Duration(getEnvAsInt("LOGIN_LOCKOUT_BASE", 60)) * time.Second,
+		LoginLockoutMax:    time.Duration(getEnvAsInt("LOGIN_LOCKOUT_MAX", 3600)) * time.Second,
+		LoginIPMaxAttempts: getEnvAsInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginIPWindow:      time.Duration(getEnvAsInt("LOGIN_IP_WINDOW", 900)) * time.Second,
+
+		OAuthProviders: loadOAuthProviders(baseURL),
+
+		SchedulerInterval: time.Duration(getEnvAsInt("SCHEDULER_INTERVAL", 30)) * time.Second,
 	}
 }
 
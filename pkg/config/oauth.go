@@ -0,0 +1,122 @@
+package config
+
+import (
+	"log"
+	"strings"
+)
+
+// OAuthProvider описывает внешний сервис входа. Если задан Issuer, провайдер
+// считается OIDC: адреса и ключи берутся из discovery, данные пользователя -
+// из ID-токена. Иначе используются AuthURL/TokenURL и запрос к UserInfoURL.
+type OAuthProvider struct {
+	Name         string // используется в адресах /auth/{name}/...
+	DisplayName  string
+	IconURL      string
+	ClientID     string
+	ClientSecret string
+	CallbackURL  string
+	Scopes       []string
+
+	Issuer string
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	EmailsURL   string // список адресов в формате GitHub /user/emails, если основной не отдаётся
+
+	// Поля ответа провайдера, из которых берутся данные пользователя
+	SubjectField       string
+	EmailField         string
+	EmailVerifiedField string
+	NameField          string
+	UsernameField      string
+}
+
+// Встроенные настройки для известных провайдеров, их можно переопределить переменными окружения
+var oauthPresets = map[string]OAuthProvider{
+	"google": {
+		DisplayName: "Google",
+		IconURL:     "/static/images/google-logo.png",
+		Issuer:      "https://accounts.google.com",
+	},
+	"github": {
+		DisplayName:   "GitHub",
+		IconURL:       "/static/images/github-logo.png",
+		Scopes:        []string{"read:user", "user:email"},
+		AuthURL:       "https://github.com/login/oauth/authorize",
+		TokenURL:      "https://github.com/login/oauth/access_token",
+		UserInfoURL:   "https://api.github.com/user",
+		EmailsURL:     "https://api.github.com/user/emails",
+		SubjectField:  "id",
+		UsernameField: "login",
+	},
+	"gitlab": {
+		DisplayName: "GitLab",
+		Issuer:      "https://gitlab.com",
+	},
+}
+
+// loadOAuthProviders читает список OAUTH_PROVIDERS и для каждого имени
+// переменные OAUTH_<NAME>_*. Провайдеры без CLIENT_ID пропускаются, без
+// SUBJECT_FIELD - тоже: по нему учётная запись провайдера связывается с пользователем.
+func loadOAuthProviders(baseURL string) []OAuthProvider {
+	var providers []OAuthProvider
+
+	for _, name := range getEnvAsSlice("OAUTH_PROVIDERS", []string{"google", "github"}, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		p := oauthPresets[name]
+		p.Name = name
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		// Старые переменные GOOGLE_CLIENT_ID и GITHUB_CLIENT_ID продолжают работать
+		legacy := strings.ToUpper(name) + "_CLIENT_"
+		p.ClientID = getEnv(prefix+"CLIENT_ID", getEnv(legacy+"ID", ""))
+		p.ClientSecret = getEnv(prefix+"CLIENT_SECRET", getEnv(legacy+"SECRET", ""))
+		p.CallbackURL = getEnv(prefix+"CALLBACK_URL", getEnv(legacy+"CALLBACK_URL", baseURL+"/auth/"+name+"/callback"))
+		if p.ClientID == "" {
+			continue
+		}
+
+		p.DisplayName = getEnv(prefix+"DISPLAY_NAME", p.DisplayName)
+		if p.DisplayName == "" {
+			p.DisplayName = name
+		}
+		p.IconURL = getEnv(prefix+"ICON_URL", p.IconURL)
+		p.Issuer = getEnv(prefix+"ISSUER", p.Issuer)
+		p.AuthURL = getEnv(prefix+"AUTH_URL", p.AuthURL)
+		p.TokenURL = getEnv(prefix+"TOKEN_URL", p.TokenURL)
+		p.UserInfoURL = getEnv(prefix+"USERINFO_URL", p.UserInfoURL)
+		p.EmailsURL = getEnv(prefix+"EMAILS_URL", p.EmailsURL)
+
+		defaultScopes := p.Scopes
+		if p.Issuer != "" && defaultScopes == nil {
+			defaultScopes = []string{"openid", "email", "profile"}
+		}
+		p.Scopes = getEnvAsSlice(prefix+"SCOPES", defaultScopes, " ")
+
+		p.SubjectField = strings.TrimSpace(getEnv(prefix+"SUBJECT_FIELD", defaultString(p.SubjectField, "sub")))
+		if p.SubjectField == "" {
+			log.Printf("OAuth provider %q is disabled: %sSUBJECT_FIELD is empty", name, prefix)
+			continue
+		}
+		p.EmailField = getEnv(prefix+"EMAIL_FIELD", defaultString(p.EmailField, "email"))
+		p.EmailVerifiedField = getEnv(prefix+"EMAIL_VERIFIED_FIELD", defaultString(p.EmailVerifiedField, "email_verified"))
+		p.NameField = getEnv(prefix+"NAME_FIELD", defaultString(p.NameField, "name"))
+		p.UsernameField = getEnv(prefix+"USERNAME_FIELD", defaultString(p.UsernameField, "preferred_username"))
+
+		providers = append(providers, p)
+	}
+
+	return providers
+}
+
+func defaultString(value, defaultVal string) string {
+	if value == "" {
+		return defaultVal
+	}
+	return value
+}
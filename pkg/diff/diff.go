@@ -0,0 +1,157 @@
+// Package diff сравнивает две версии текста построчно
+package diff
+
+import "strings"
+
+// Виды строк сравнения
+const (
+	Equal  = "equal"
+	Delete = "delete" // строка есть только в старой версии
+	Insert = "insert" // строка есть только в новой версии
+)
+
+// maxCells ограничивает таблицу LCS: очень длинные несовпадающие тексты
+// показываются как замена целиком
+const maxCells = 4_000_000
+
+// Line - строка сравнения. OldNo и NewNo - номера строк в старой и новой
+// версии, 0, если строки в этой версии нет.
+type Line struct {
+	Kind  string
+	Text  string
+	OldNo int
+	NewNo int
+}
+
+// Row - строка сравнения в две колонки. Пустая сторона - nil.
+type Row struct {
+	Old *Line
+	New *Line
+}
+
+// Lines сравнивает тексты по строкам
+func Lines(a, b string) []Line {
+	oldLines, newLines := split(a), split(b)
+
+	// Общие начало и конец не участвуют в LCS
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(oldLines)+len(newLines))
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Kind: Equal, Text: oldLines[i], OldNo: i + 1, NewNo: i + 1})
+	}
+
+	lines = append(lines, middle(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix], prefix, prefix)...)
+
+	for i := suffix; i > 0; i-- {
+		o, n := len(oldLines)-i, len(newLines)-i
+		lines = append(lines, Line{Kind: Equal, Text: oldLines[o], OldNo: o + 1, NewNo: n + 1})
+	}
+	return lines
+}
+
+// Split раскладывает сравнение на две колонки: удалённые строки встают
+// напротив добавленных на их место
+func Split(lines []Line) []Row {
+	rows := []Row{}
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			rows = append(rows, Row{Old: &lines[i], New: &lines[i]})
+			i++
+			continue
+		}
+
+		var deleted, inserted []*Line
+		for ; i < len(lines) && lines[i].Kind != Equal; i++ {
+			if lines[i].Kind == Delete {
+				deleted = append(deleted, &lines[i])
+			} else {
+				inserted = append(inserted, &lines[i])
+			}
+		}
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			row := Row{}
+			if j < len(deleted) {
+				row.Old = deleted[j]
+			}
+			if j < len(inserted) {
+				row.New = inserted[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// Changed сообщает, есть ли в сравнении отличия
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Kind != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// middle сравнивает части текстов между общим началом и концом через
+// наибольшую общую подпоследовательность строк
+func middle(a, b []string, oldOffset, newOffset int) []Line {
+	lines := []Line{}
+	if len(a)*len(b) > maxCells {
+		for i, s := range a {
+			lines = append(lines, Line{Kind: Delete, Text: s, OldNo: oldOffset + i + 1})
+		}
+		for j, s := range b {
+			lines = append(lines, Line{Kind: Insert, Text: s, NewNo: newOffset + j + 1})
+		}
+		return lines
+	}
+
+	// lcs[i][j] - длина общей подпоследовательности a[i:] и b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, Line{Kind: Equal, Text: a[i], OldNo: oldOffset + i + 1, NewNo: newOffset + j + 1})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, Line{Kind: Delete, Text: a[i], OldNo: oldOffset + i + 1})
+			i++
+		default:
+			lines = append(lines, Line{Kind: Insert, Text: b[j], NewNo: newOffset + j + 1})
+			j++
+		}
+	}
+	return lines
+}
+
+func split(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		want    []Line
+		changed bool
+	}{
+		{
+			name: "equal",
+			a:    "one\ntwo",
+			b:    "one\ntwo\n",
+			want: []Line{
+				{Kind: Equal, Text: "one", OldNo: 1, NewNo: 1},
+				{Kind: Equal, Text: "two", OldNo: 2, NewNo: 2},
+			},
+		},
+		{
+			name: "insert in the middle",
+			a:    "one\nthree",
+			b:    "one\ntwo\nthree",
+			want: []Line{
+				{Kind: Equal, Text: "one", OldNo: 1, NewNo: 1},
+				{Kind: Insert, Text: "two", NewNo: 2},
+				{Kind: Equal, Text: "three", OldNo: 2, NewNo: 3},
+			},
+			changed: true,
+		},
+		{
+			name: "delete at the end",
+			a:    "one\ntwo\nthree",
+			b:    "one\ntwo",
+			want: []Line{
+				{Kind: Equal, Text: "one", OldNo: 1, NewNo: 1},
+				{Kind: Equal, Text: "two", OldNo: 2, NewNo: 2},
+				{Kind: Delete, Text: "three", OldNo: 3},
+			},
+			changed: true,
+		},
+		{
+			name: "replace",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Line{
+				{Kind: Equal, Text: "one", OldNo: 1, NewNo: 1},
+				{Kind: Delete, Text: "two", OldNo: 2},
+				{Kind: Insert, Text: "2", NewNo: 2},
+				{Kind: Equal, Text: "three", OldNo: 3, NewNo: 3},
+			},
+			changed: true,
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "one",
+			want: []Line{
+				{Kind: Insert, Text: "one", NewNo: 1},
+			},
+			changed: true,
+		},
+		{
+			name: "crlf is the same line",
+			a:    "one\r\ntwo",
+			b:    "one\ntwo",
+			want: []Line{
+				{Kind: Equal, Text: "one", OldNo: 1, NewNo: 1},
+				{Kind: Equal, Text: "two", OldNo: 2, NewNo: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines(%q, %q) =\n%+v\nwant\n%+v", tt.a, tt.b, got, tt.want)
+			}
+			if Changed(got) != tt.changed {
+				t.Errorf("Changed = %v, want %v", Changed(got), tt.changed)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	// row - номера строк в колонках, 0 - пустая сторона
+	type row struct{ old, new int }
+
+	tests := []struct {
+		name string
+		a, b string
+		want []row
+	}{
+		{"insert", "one\nthree", "one\ntwo\nthree", []row{{1, 1}, {0, 2}, {2, 3}}},
+		{"delete", "one\ntwo\nthree", "one\nthree", []row{{1, 1}, {2, 0}, {3, 2}}},
+		{"replace pairs lines", "one\ntwo\nthree", "one\n2\nthree", []row{{1, 1}, {2, 2}, {3, 3}}},
+		{"longer replacement", "a\nb\nz", "a\nc\nd\ne\nz", []row{{1, 1}, {2, 2}, {0, 3}, {0, 4}, {3, 5}}},
+		{"shorter replacement", "a\nb\nc\nz", "a\nd\nz", []row{{1, 1}, {2, 2}, {3, 0}, {4, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []row
+			for _, r := range Split(Lines(tt.a, tt.b)) {
+				var cur row
+				if r.Old != nil {
+					cur.old = r.Old.OldNo
+				}
+				if r.New != nil {
+					cur.new = r.New.NewNo
+				}
+				got = append(got, cur)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Package markdown превращает текст постов и комментариев в безопасный HTML
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/rivo/uniseg"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+)
+
+//go:generate go run forum/cmd/highlightcss -o ../../ui/static/css/highlight.css
+
+// Темы подсветки кода: светлая по умолчанию и тёмная для prefers-color-scheme: dark
+const (
+	LightStyle = "github"
+	DarkStyle  = "github-dark"
+)
+
+// Подсветка пишет классы вместо style="...": строгая Content-Security-Policy
+// запрещает встроенные стили, цвета берутся из ui/static/css/highlight.css
+var formatOptions = []chromahtml.Option{chromahtml.WithClasses(true)}
+
+// CommonMark с таблицами, зачёркиванием, автоссылками и списками задач GFM.
+// Сырой HTML из текста goldmark не пропускает. Блок кода с неизвестным
+// языком выводится без подсветки.
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle(LightStyle),
+			highlighting.WithFormatOptions(formatOptions...),
+		),
+	),
+)
+
+// policy - разрешённые теги и атрибуты. Всё, чего нет в списке, вырезается.
+var policy = newPolicy()
+
+// excerptPolicy оставляет от HTML только текст
+var excerptPolicy = bluemonday.StrictPolicy()
+
+var spaceRX = regexp.MustCompile(`\s+`)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Язык блока кода без подсветки: ```lang -> <code class="language-lang">
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	// Классы подсветки: <pre class="chroma">, <span class="kd">
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z]+( [a-z]+)*$`)).OnElements("pre", "span")
+	// Флажки списков задач
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render переводит markdown в HTML и пропускает результат через фильтр
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// Excerpt возвращает начало текста без разметки, не длиннее max символов.
+// Результат - обычный текст: шаблон экранирует его сам, и обрезка не
+// оставляет незакрытых тегов.
+func Excerpt(source string, max int) (string, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+
+	text := html.UnescapeString(excerptPolicy.Sanitize(buf.String()))
+	text = strings.TrimSpace(spaceRX.ReplaceAllString(text, " "))
+	if uniseg.GraphemeClusterCount(text) <= max {
+		return text, nil
+	}
+
+	// Режем по границе видимых символов, чтобы не разорвать эмодзи
+	rest, state := text, -1
+	for i := 0; i < max; i++ {
+		_, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+	}
+	cut := text[:len(text)-len(rest)]
+	// Не режем слово посередине, если пробел недалеко
+	if i := strings.LastIndex(cut, " "); i > len(cut)*3/4 {
+		return cut[:i] + "…", nil
+	}
+	return cut + "…", nil
+}
+
+// HighlightCSS пишет стили подсветки для обеих тем
+func HighlightCSS(w io.Writer) error {
+	formatter := chromahtml.New(formatOptions...)
+
+	fmt.Fprintln(w, "/* Сгенерировано go generate ./pkg/markdown, не править вручную */")
+	if err := formatter.WriteCSS(w, styles.Get(LightStyle)); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\n@media (prefers-color-scheme: dark) {")
+	if err := formatter.WriteCSS(w, styles.Get(DarkStyle)); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
@@ -0,0 +1,83 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Обработчик событий внутри тега: <img onerror=...>, <a onclick="...">
+var eventAttrRX = regexp.MustCompile(`(?i)<[^>]*\son[a-z]+\s*=`)
+
+// Ссылка или картинка с опасной схемой. В обычном тексте "javascript:" не опасен.
+var schemeAttrRX = regexp.MustCompile(`(?i)(href|src)\s*=\s*["']?\s*(javascript|vbscript|data):`)
+
+func TestRenderStripsXSS(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"script tag", "<script>alert(1)</script>"},
+		{"script in paragraph", "hello <script>alert(1)</script> world"},
+		{"img onerror", `<img src=x onerror=alert(1)>`},
+		{"svg onload", `<svg onload=alert(1)>`},
+		{"iframe", `<iframe src="//evil.example"></iframe>`},
+		{"style tag", `<style>body{display:none}</style>`},
+		{"raw javascript link", `<a href="javascript:alert(1)">x</a>`},
+		{"markdown javascript link", `[x](javascript:alert(1))`},
+		{"mixed case scheme", `[x](JaVaScRiPt:alert(1))`},
+		{"entity encoded scheme", `[x](&#106;avascript:alert(1))`},
+		{"javascript image", `![x](javascript:alert(1))`},
+		{"data url", `[x](data:text/html;base64,PHNjcmlwdD4=)`},
+		{"attribute injection in title", `[x](https://example.com "t" onclick=alert(1))`},
+		{"reference link", "[x][1]\n\n[1]: javascript:alert(1)"},
+		{"autolink", `<javascript:alert(1)>`},
+		{"html in code block", "```html\n<script>alert(1)</script>\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			out := strings.ToLower(string(got))
+			for _, bad := range []string{"<script", "<iframe", "<svg", "<style"} {
+				if strings.Contains(out, bad) {
+					t.Errorf("output contains %q: %s", bad, got)
+				}
+			}
+			if eventAttrRX.MatchString(out) {
+				t.Errorf("output contains an event handler attribute: %s", got)
+			}
+			if schemeAttrRX.MatchString(out) {
+				t.Errorf("output contains a link with an unsafe scheme: %s", got)
+			}
+		})
+	}
+}
+
+func TestRenderKeepsSafeMarkup(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"emphasis", "**bold** and *italic*", "<p><strong>bold</strong> and <em>italic</em></p>\n"},
+		{"external link", "[ok](https://example.com)", `<p><a href="https://example.com" rel="nofollow noopener" target="_blank">ok</a></p>` + "\n"},
+		{"inline code is escaped", "`<script>`", "<p><code>&lt;script&gt;</code></p>\n"},
+		{"strikethrough", "~~old~~", "<p><del>old</del></p>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.source)
+			if err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package validator
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize приводит текст к NFC: "é" из буквы и комбинируемого знака и
+// готовая "é" после нормализации совпадают
+func Normalize(value string) string {
+	return norm.NFC.String(value)
+}
+
+// Graphemes - число видимых символов: эмодзи из нескольких кодовых точек и
+// буква с диакритикой считаются за один
+func Graphemes(value string) int {
+	return uniseg.GraphemeClusterCount(value)
+}
+
+// Truncate обрезает текст до max видимых символов, не разрывая их
+func Truncate(value string, max int) (string, bool) {
+	state := -1
+	rest := value
+	for i := 0; i < max && rest != ""; i++ {
+		_, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+	}
+	if rest == "" {
+		return value, false
+	}
+	return value[:len(value)-len(rest)], true
+}
+
+// SafeText проверяет, что текст - корректный UTF-8 без управляющих символов,
+// кроме переводов строк и табуляции, и без подмены направления письма:
+// символы LRO и RLO запрещены, встраивания и изоляты должны закрываться в
+// той же строке
+func SafeText(value string) bool {
+	if !utf8.ValidString(value) {
+		return false
+	}
+
+	embeddings, isolates := 0, 0
+	for _, r := range value {
+		switch {
+		case r == '\n' || r == '\r':
+			if embeddings != 0 || isolates != 0 {
+				return false
+			}
+		case r == '\t':
+		case unicode.IsControl(r):
+			return false
+		case r == '\u202D' || r == '\u202E': // LRO, RLO
+			return false
+		case r == '\u202A' || r == '\u202B': // LRE, RLE
+			embeddings++
+		case r == '\u202C': // PDF
+			if embeddings--; embeddings < 0 {
+				return false
+			}
+		case r >= '\u2066' && r <= '\u2068': // LRI, RLI, FSI
+			isolates++
+		case r == '\u2069': // PDI
+			if isolates--; isolates < 0 {
+				return false
+			}
+		}
+	}
+	return embeddings == 0 && isolates == 0
+}
+
+// SingleLine проверяет, что в тексте нет переводов строк
+func SingleLine(value string) bool {
+	return !strings.ContainsAny(value, "\n\r\u2028\u2029")
+}
+
+// Письменности, которые принято смешивать в одном слове
+var scriptSets = [][]string{
+	{"Han", "Hiragana", "Katakana"},
+	{"Han", "Hangul"},
+	{"Han", "Bopomofo"},
+}
+
+// SingleScript проверяет, что буквы имени взяты из одной письменности:
+// "pаypal" с кириллической "а" не пройдёт. Цифры, знаки и диакритика
+// (Common и Inherited) допустимы с любой письменностью.
+func SingleScript(value string) bool {
+	scripts := map[string]bool{}
+	for _, r := range value {
+		if name := scriptOf(r); name != "" {
+			scripts[name] = true
+		}
+	}
+	if len(scripts) <= 1 {
+		return true
+	}
+
+	for _, set := range scriptSets {
+		covered := 0
+		for _, name := range set {
+			if scripts[name] {
+				covered++
+			}
+		}
+		if covered == len(scripts) {
+			return true
+		}
+	}
+	return false
+}
+
+func scriptOf(r rune) string {
+	if unicode.Is(unicode.Common, r) || unicode.Is(unicode.Inherited, r) {
+		return ""
+	}
+	// Чаще всего встречаются эти письменности, проверяем их первыми
+	for _, name := range []string{"Latin", "Cyrillic", "Greek"} {
+		if unicode.Is(unicode.Scripts[name], r) {
+			return name
+		}
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+// confusables - буквы, которые на экране не отличить от латинских.
+// Небольшая выборка из Unicode TR39 для тех письменностей, что есть на форуме.
+var confusables = map[rune]string{
+	// Латиница
+	'1': "l", '|': "l", 'ı': "i", 'ɑ': "a", '0': "O",
+	// Кириллица
+	'а': "a", 'е': "e", 'о': "o", 'р': "p", 'с': "c", 'у': "y", 'х': "x",
+	'ѕ': "s", 'і': "i", 'ј': "j", 'һ': "h", 'ԁ': "d", 'ԛ': "q", 'ԝ': "w", 'ӏ': "l", 'ү': "y",
+	'А': "A", 'В': "B", 'Е': "E", 'К': "K", 'М': "M", 'Н': "H", 'О': "O", 'Р': "P",
+	'С': "C", 'Т': "T", 'У': "Y", 'Х': "X", 'Ѕ': "S", 'І': "I", 'Ј': "J", 'Ү': "Y", 'Ӏ': "l",
+	// Греческий
+	'α': "a", 'ο': "o", 'ρ': "p", 'ν': "v", 'ι': "i", 'υ': "u", 'χ': "x", 'κ': "k",
+	'Α': "A", 'Β': "B", 'Ε': "E", 'Ζ': "Z", 'Η': "H", 'Ι': "I", 'Κ': "K", 'Μ': "M",
+	'Ν': "N", 'Ο': "O", 'Ρ': "P", 'Τ': "T", 'Υ': "Y", 'Χ': "X",
+}
+
+// UsernameSkeleton возвращает "скелет" имени по Unicode TR39: имена с
+// одинаковым скелетом выглядят одинаково ("admin", "Admin", "аdmin" с
+// кириллической "а"), второе такое имя зарегистрировать нельзя
+func UsernameSkeleton(value string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(value) {
+		if s, ok := confusables[r]; ok {
+			b.WriteString(s)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return norm.NFD.String(skeletonReplacer.Replace(strings.ToLower(b.String())))
+}
+
+// Заглавная "I" в нижнем регистре становится "i", а на экране она
+// неотличима от "l"; так же выглядят "rn" и "m"
+var skeletonReplacer = strings.NewReplacer("l", "i", "rn", "m")
@@ -0,0 +1,84 @@
+package validator
+
+import "testing"
+
+func TestUsernameSkeleton(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"admin", "admin", true},
+		{"adm1n", "admin", true},
+		{"Admin", "admin", true},
+		{"ADMIN", "admin", true},
+		{"аdmin", "admin", true}, // кириллическая "а"
+		{"αdmin", "admin", true}, // греческая "α"
+		{"admln", "admin", true},
+		{"adrnin", "admin", true}, // "rn" выглядит как "m"
+		{"modern", "modem", true},
+		{"ｄｅｖ", "dev", true},         // полноширинные буквы
+		{"café", "cafe\u0301", true}, // буква с комбинируемым знаком
+		{"admin", "admins", false},
+		{"bob", "rob", false},
+	}
+
+	for _, tt := range tests {
+		got := UsernameSkeleton(tt.a) == UsernameSkeleton(tt.b)
+		if got != tt.same {
+			t.Errorf("UsernameSkeleton(%q) == UsernameSkeleton(%q) is %v, want %v (%q, %q)",
+				tt.a, tt.b, got, tt.same, UsernameSkeleton(tt.a), UsernameSkeleton(tt.b))
+		}
+	}
+}
+
+func TestSingleScript(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"admin", true},
+		{"админ", true},
+		{"user_42", true},
+		{"café", true},
+		{"δοκιμή", true},
+		{"東京たワー", true},   // хань с каной
+		{"한국語", true},     // хангыль с ханью
+		{"pаypal", false}, // кириллическая "а" среди латиницы
+		{"admin_админ", false},
+		{"αdmin", false},
+		{"한국たワー", false},
+	}
+
+	for _, tt := range tests {
+		if got := SingleScript(tt.value); got != tt.want {
+			t.Errorf("SingleScript(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSafeText(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  bool
+	}{
+		{"plain", "hello, мир", true},
+		{"newlines and tabs", "a\n\tb\r\n", true},
+		{"emoji", "👍🏽 ok", true},
+		{"closed isolate", "a ⁧עברית⁩ b", true},
+		{"control character", "a\x00b", false},
+		{"invalid utf-8", "a\xffb", false},
+		{"right-to-left override", "abc‮gpj.exe", false},
+		{"unclosed isolate", "a ⁧b", false},
+		{"isolate across lines", "a ⁧b\n⁩", false},
+		{"unbalanced pop", "a‬b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SafeText(tt.value); got != tt.want {
+				t.Errorf("SafeText(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
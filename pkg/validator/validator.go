@@ -9,7 +9,6 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
-	"unicode/utf8"
 )
 
 const (
@@ -17,10 +16,11 @@ const (
 )
 
 var (
-	UsernameRX = regexp.MustCompile(`^[^._ ](?:[\w-]|\.[\w-])+[^._ ]$`)
+	// Буквы и цифры любых языков, "_", "-" и одиночные точки внутри имени
+	UsernameRX = regexp.MustCompile(`^[\p{L}\p{N}-](?:[\p{L}\p{M}\p{N}_-]|\.[\p{L}\p{M}\p{N}_-])+[\p{L}\p{M}\p{N}-]$`)
 	EmailRX    = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 	PasswordRX = regexp.MustCompile("[0-9a-zA-Z!_.@#$%^&*]{8,}")
-	TextRX     = regexp.MustCompile(`^[а-яА-ЯёЁa-zA-Z0-9.,:;!?'"()\-–—\[\]{}<>/|@#$%^&*+=_~\s]+$`)
+	RoleNameRX = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
 )
 
 type Validator struct {
@@ -62,8 +62,16 @@ func NotHaveAnySpaces(value string) bool {
 	return strings.Join(strings.Fields(value), "") == value
 }
 
+// maxCharBytes - сколько байт в среднем может занимать видимый символ.
+// Эмодзи из нескольких кодовых точек укладываются с запасом, а буква с
+// тысячей комбинируемых знаков - нет: иначе лимит в символах не ограничивал
+// бы размер текста.
+const maxCharBytes = 32
+
+// MaxChars и MinChars считают видимые символы, а не байты или кодовые точки.
+// MaxChars вдобавок ограничивает размер в байтах, см. maxCharBytes.
 func MaxChars(value string, n int) bool {
-	return utf8.RuneCountInString(value) <= n
+	return len(value) <= n*maxCharBytes && Graphemes(value) <= n
 }
 
 func PermittedValue[T comparable](value T, permittedValues ...T) bool {
@@ -76,7 +84,7 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 }
 
 func MinChars(value string, n int) bool {
-	return utf8.RuneCountInString(value) >= n
+	return Graphemes(value) >= n
 }
 
 func Matches(value string, rx *regexp.Regexp) bool {
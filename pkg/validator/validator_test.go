@@ -0,0 +1,33 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxChars(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		n     int
+		want  bool
+	}{
+		{"ascii within limit", "hello", 5, true},
+		{"ascii over limit", "hello!", 5, false},
+		{"cyrillic counts letters, not bytes", "привет", 6, true},
+		{"combining mark is part of the letter", "cafe\u0301", 4, true},
+		{"emoji family is one character", "👨‍👩‍👧‍👦", 1, true},
+		{"flag is one character", "🇺🇦🇰🇿", 2, true},
+		{"zalgo letter is one character but too many bytes", "a" + strings.Repeat("\u0301", 1000), 5, false},
+		{"endless emoji zwj chain", strings.Repeat("👨\u200d", 1000) + "👨", 5, false},
+		{"a few marks per letter are fine", strings.Repeat("e\u0323\u0301", 100), 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxChars(tt.value, tt.n); got != tt.want {
+				t.Errorf("MaxChars(%q, %d) = %v, want %v", tt.value, tt.n, got, tt.want)
+			}
+		})
+	}
+}
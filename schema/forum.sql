@@ -12,9 +12,10 @@ CREATE TABLE IF NOT EXISTS notifications (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     user_id INTEGER,          -- Владелец поста, который получает уведомление
     post_id INTEGER,          -- ID поста
-    action_type TEXT,         -- 'like', 'dislike', или 'comment'
+    action_type TEXT,         -- 'like', 'dislike', 'comment', 'approved' или 'rejected'
     comment_id INTEGER,
     trigger_user_id INTEGER,  -- ID пользователя, который вызвал уведомление
+    note TEXT NOT NULL DEFAULT '', -- причина отклонения поста
     created TEXT NOT NULL,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
@@ -49,7 +50,10 @@ CREATE TABLE IF NOT EXISTS comments(
   post_id INTEGER NOT NULL,
   user_id INTEGER NOT NULL,
   content TEXT NOT NULL,
+  content_html TEXT NOT NULL DEFAULT '', -- content, переведённый из markdown в безопасный HTML
   created TEXT NOT NULL,
+  hidden BOOLEAN NOT NULL DEFAULT false, -- скрыт автомодерацией до проверки
+  edited TEXT,                           -- время последней правки; пусто, если не правили
   CONSTRAINT users_comments
     FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE No action
       ON UPDATE No action,
@@ -90,9 +94,13 @@ CREATE TABLE IF NOT EXISTS posts(
   id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
   title TEXT NOT NULL,
   content TEXT NOT NULL,
+  content_html TEXT NOT NULL DEFAULT '', -- content, переведённый из markdown в безопасный HTML
   user_id INTEGER NOT NULL,
   created TEXT NOT NULL,
   is_approved BOOLEAN DEFAULT FALSE, -- для модерации
+  rejected TEXT,                     -- когда модератор отклонил пост; пусто, пока пост ждёт решения
+  rejection_reason TEXT NOT NULL DEFAULT '',
+  edited TEXT,                       -- время последней правки; пусто, если не правили
   CONSTRAINT users_posts
     FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE No action
       ON UPDATE No action
@@ -104,7 +112,12 @@ CREATE TABLE IF NOT EXISTS users(
   email TEXT NOT NULL,
   password TEXT NOT NULL,
   role TEXT NOT NULL,
-  created TEXT NOT NULL
+  created TEXT NOT NULL,
+  email_verified BOOLEAN NOT NULL DEFAULT false,
+  failed_logins INTEGER NOT NULL DEFAULT 0, -- неудачные попытки подряд
+  locked_until TEXT,
+  username_skeleton TEXT NOT NULL DEFAULT '', -- имя без различий в регистре и похожих буквах
+  has_password BOOLEAN -- false у аккаунтов, созданных через OAuth; NULL - ещё не проверен, см. migratePasswordFlags
 );
 
 CREATE TABLE IF NOT EXISTS moderation_requests (
@@ -122,16 +135,317 @@ CREATE UNIQUE INDEX IF NOT EXISTS users_uc_username ON users(username);
 
 CREATE TABLE IF NOT EXISTS reports(
   id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
-  post_id INTEGER NOT NULL,
+  post_id INTEGER NOT NULL, -- пост, в котором находится объект жалобы; 0 для профиля
+  user_id INTEGER NOT NULL, -- 0, если жалобу подала автомодерация
+  reason TEXT NOT NULL, -- пояснение, может быть пустым
+  created TEXT NOT NULL,
+  target_type TEXT NOT NULL DEFAULT 'post', -- post, comment, user
+  target_id INTEGER NOT NULL DEFAULT 0,
+  target_user_id INTEGER NOT NULL DEFAULT 0, -- автор объекта или сам пользователь
+  category TEXT NOT NULL DEFAULT '', -- причина из списка в настройках
+  status TEXT NOT NULL DEFAULT 'open', -- open, in_review, resolved, dismissed
+  updated TEXT NOT NULL DEFAULT ''
+);
+
+-- История статусов жалоб: записи не удаляются вместе со сменой статуса
+CREATE TABLE IF NOT EXISTS report_events(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  report_id INTEGER NOT NULL,
+  actor_id INTEGER NOT NULL,
+  status TEXT NOT NULL,
+  note TEXT NOT NULL DEFAULT '',
+  created TEXT NOT NULL,
+  CONSTRAINT reports_report_events
+    FOREIGN KEY (report_id) REFERENCES reports (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS report_events_idx_report_id ON report_events(report_id);
+
+
+CREATE TABLE IF NOT EXISTS sessions(
+  sid TEXT PRIMARY KEY NOT NULL,
+  user_id INTEGER,
+  data BLOB NOT NULL,
+  created INTEGER NOT NULL DEFAULT 0, -- unix time
+  time_accessed INTEGER NOT NULL, -- unix time, используется для SessionGC
+  ip TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  CONSTRAINT users_sessions
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_idx_time_accessed ON sessions(time_accessed);
+CREATE INDEX IF NOT EXISTS sessions_idx_user_id ON sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS user_totp(
+  user_id INTEGER PRIMARY KEY NOT NULL,
+  secret TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT false,
+  last_counter INTEGER NOT NULL DEFAULT 0,
+  created TEXT NOT NULL,
+  CONSTRAINT users_user_totp
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  user_id INTEGER NOT NULL,
+  code_hash TEXT NOT NULL, -- sha256, сами коды показываются пользователю один раз
+  used TEXT,
+  CONSTRAINT users_recovery_codes
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS recovery_codes_idx_user_id ON recovery_codes(user_id);
+
+CREATE TABLE IF NOT EXISTS settings(
+  key TEXT PRIMARY KEY NOT NULL,
+  value TEXT NOT NULL
+);
+
+-- Одноразовые токены из писем (сброс пароля и т.п.)
+CREATE TABLE IF NOT EXISTS tokens(
+  hash TEXT PRIMARY KEY NOT NULL, -- sha256 от токена, сам токен есть только в письме
+  user_id INTEGER NOT NULL,
+  purpose TEXT NOT NULL,
+  data TEXT NOT NULL DEFAULT '', -- например, новый адрес при смене email
+  expiry TEXT NOT NULL,
+  created TEXT NOT NULL,
+  CONSTRAINT users_tokens
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS tokens_idx_user_id ON tokens(user_id);
+
+-- Журнал попыток входа: по нему видно подбор паролей с одного IP
+CREATE TABLE IF NOT EXISTS login_attempts(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  user_id INTEGER, -- NULL, если аккаунта с таким email нет
+  email TEXT NOT NULL,
+  ip TEXT NOT NULL,
+  success BOOLEAN NOT NULL,
+  created TEXT NOT NULL,
+  CONSTRAINT users_login_attempts
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_idx_ip_created ON login_attempts(ip, created);
+CREATE INDEX IF NOT EXISTS login_attempts_idx_created ON login_attempts(created);
+
+-- Внешние аккаунты (OAuth/OIDC), привязанные к пользователю.
+-- Вход ищется по провайдеру и его идентификатору, а не по email.
+CREATE TABLE IF NOT EXISTS user_identities(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  user_id INTEGER NOT NULL,
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL DEFAULT '', -- адрес у провайдера на момент привязки, только для показа
+  created TEXT NOT NULL,
+  UNIQUE(provider, subject),
+  UNIQUE(user_id, provider),
+  CONSTRAINT users_user_identities
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Персональные токены доступа для скриптов. Хранится только sha256 от токена.
+CREATE TABLE IF NOT EXISTS api_tokens(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  user_id INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE,
+  prefix TEXT NOT NULL,
+  scopes TEXT NOT NULL, -- через пробел: read write moderate
+  expiry TEXT, -- NULL - бессрочный
+  last_used TEXT,
+  created TEXT NOT NULL,
+  CONSTRAINT users_api_tokens
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_idx_user_id ON api_tokens(user_id);
+
+-- Роли и их права. users.role ссылается на roles.name.
+-- Встроенные роли создаются при запуске (repository.seedRoles).
+CREATE TABLE IF NOT EXISTS roles(
+  name TEXT PRIMARY KEY NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  builtin BOOLEAN NOT NULL DEFAULT false,
+  created TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions(
+  role TEXT NOT NULL,
+  permission TEXT NOT NULL,
+  PRIMARY KEY (role, permission),
+  CONSTRAINT roles_role_permissions
+    FOREIGN KEY (role) REFERENCES roles (name) ON DELETE CASCADE
+);
+
+-- Права, добавленные встроенным ролям уже существующих баз (repository.seedRoles).
+-- Каждое выдаётся один раз: убранное администратором право не вернётся.
+CREATE TABLE IF NOT EXISTS role_permission_migrations(
+  role TEXT NOT NULL,
+  permission TEXT NOT NULL,
+  PRIMARY KEY (role, permission)
+);
+
+-- Категории, которые модерирует пользователь. Нет строк - модерирует все категории.
+-- Без каскада по категории: удаление категории не должно превращать
+-- модератора одной категории в модератора всего форума.
+CREATE TABLE IF NOT EXISTS moderator_categories(
+  user_id INTEGER NOT NULL,
+  category_id INTEGER NOT NULL,
+  PRIMARY KEY (user_id, category_id),
+  CONSTRAINT users_moderator_categories
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Категории, выбранные в заявке в модераторы
+CREATE TABLE IF NOT EXISTS moderation_request_categories(
+  user_id INTEGER NOT NULL,
+  category_id INTEGER NOT NULL,
+  PRIMARY KEY (user_id, category_id),
+  CONSTRAINT users_moderation_request_categories
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Блокировки пользователей. expires IS NULL - бессрочно.
+-- Записи не удаляются: снятая блокировка остаётся в истории с lifted.
+CREATE TABLE IF NOT EXISTS user_bans(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
   user_id INTEGER NOT NULL,
+  banned_by INTEGER NOT NULL,
   reason TEXT NOT NULL,
   created TEXT NOT NULL,
-  CONSTRAINT posts_comments
-  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
-    ON UPDATE No action
-  CONSTRAINT users_posts
-    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE No action
-      ON UPDATE No action
+  expires TEXT,
+  lifted TEXT,
+  lifted_by INTEGER,
+  shadow BOOLEAN NOT NULL DEFAULT false, -- теневой бан: вход не запрещён, записи видны только автору
+  CONSTRAINT users_user_bans
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_bans_idx_user_id ON user_bans(user_id);
+
+-- Журнал действий администрации: только добавление, записи не меняются и не
+-- удаляются. Без внешних ключей: записи остаются и после удаления объектов.
+CREATE TABLE IF NOT EXISTS moderation_actions(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  actor_id INTEGER NOT NULL,
+  action TEXT NOT NULL,
+  target_type TEXT NOT NULL DEFAULT 'user', -- user, post, comment, category
+  target_id INTEGER NOT NULL DEFAULT 0,
+  target_user_id INTEGER NOT NULL DEFAULT 0, -- пользователь или автор объекта, 0 для категорий
+  target_name TEXT NOT NULL DEFAULT '', -- имя или заголовок на момент действия
+  reason TEXT NOT NULL DEFAULT '',
+  before_state TEXT NOT NULL DEFAULT '', -- JSON-снимок до действия
+  after_state TEXT NOT NULL DEFAULT '', -- JSON-снимок после действия
+  created TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS moderation_actions_idx_target_user_id ON moderation_actions(target_user_id);
+
+CREATE TRIGGER IF NOT EXISTS moderation_actions_no_update BEFORE UPDATE ON moderation_actions
+BEGIN
+  SELECT RAISE(ABORT, 'moderation_actions is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS moderation_actions_no_delete BEFORE DELETE ON moderation_actions
+BEGIN
+  SELECT RAISE(ABORT, 'moderation_actions is append-only');
+END;
+
+-- Элементы очереди модерации, взятые в работу. expires пусто у назначенных
+-- элементов, они не освобождаются сами.
+CREATE TABLE IF NOT EXISTS queue_claims(
+  target_type TEXT NOT NULL, -- post, comment, user
+  target_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  assigned_by INTEGER NOT NULL DEFAULT 0,
+  claimed TEXT NOT NULL,
+  expires TEXT,
+  PRIMARY KEY (target_type, target_id)
+);
+
+-- Элемент уходит из очереди, когда пост одобрен или удалён. Взятые в работу
+-- жалобы освобождает ReportSqlite3.SetTargetStatus, отклонённые посты -
+-- PostSqlite3.RejectPost.
+CREATE TRIGGER IF NOT EXISTS queue_claims_post_approved AFTER UPDATE OF is_approved ON posts
+WHEN NEW.is_approved
+BEGIN
+  DELETE FROM queue_claims WHERE target_type = 'post' AND target_id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS queue_claims_post_deleted AFTER DELETE ON posts
+BEGIN
+  DELETE FROM queue_claims WHERE target_type = 'post' AND target_id = OLD.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS queue_claims_comment_deleted AFTER DELETE ON comments
+BEGIN
+  DELETE FROM queue_claims WHERE target_type = 'comment' AND target_id = OLD.id;
+END;
+
+-- Правила автомодерации, см. entities.AutomodRule
+CREATE TABLE IF NOT EXISTS automod_rules(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  name TEXT NOT NULL,
+  target TEXT NOT NULL DEFAULT '', -- post, comment или пусто для любого текста
+  condition TEXT NOT NULL,
+  value TEXT NOT NULL DEFAULT '',
+  action TEXT NOT NULL, -- queue, hide, approve, flag
+  enabled BOOLEAN NOT NULL DEFAULT true,
+  created TEXT NOT NULL
+);
+
+-- Срабатывания правил автомодерации. Без внешних ключей: записи остаются
+-- после удаления правил и объектов.
+CREATE TABLE IF NOT EXISTS automod_hits(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  rule_id INTEGER NOT NULL,
+  rule_name TEXT NOT NULL,
+  target_type TEXT NOT NULL, -- post, comment
+  target_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL, -- автор текста
+  action TEXT NOT NULL,
+  matched TEXT NOT NULL DEFAULT '',
+  created TEXT NOT NULL
 );
 
+CREATE INDEX IF NOT EXISTS automod_hits_idx_rule_id ON automod_hits(rule_id);
+
+-- Версии постов и комментариев, см. entities.Revision. Первой версией
+-- сохраняется исходный текст, когда его правят впервые.
+CREATE TABLE IF NOT EXISTS revisions(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  target_type TEXT NOT NULL, -- post, comment
+  target_id INTEGER NOT NULL,
+  number INTEGER NOT NULL,   -- номер версии, с 1
+  editor_id INTEGER NOT NULL,
+  title TEXT NOT NULL DEFAULT '',
+  content TEXT NOT NULL,
+  categories TEXT NOT NULL DEFAULT '', -- id категорий поста через запятую
+  summary TEXT NOT NULL DEFAULT '',
+  created TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS revisions_uc_target_number ON revisions(target_type, target_id, number);
+
+-- Черновики постов, см. entities.Draft. Черновик с publish_at публикует
+-- планировщик, когда это время наступит (UTC).
+CREATE TABLE IF NOT EXISTS drafts(
+  id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  user_id INTEGER NOT NULL,
+  title TEXT NOT NULL DEFAULT '',
+  content TEXT NOT NULL DEFAULT '',
+  categories TEXT NOT NULL DEFAULT '', -- id категорий через запятую
+  publish_at TEXT,                     -- когда опубликовать; пусто - обычный черновик
+  publish_error TEXT NOT NULL DEFAULT '', -- почему планировщик не смог опубликовать
+  created TEXT NOT NULL,
+  updated TEXT NOT NULL,
+  CONSTRAINT users_drafts
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE Cascade
+);
 
+CREATE INDEX IF NOT EXISTS drafts_idx_user_id ON drafts(user_id);
+CREATE INDEX IF NOT EXISTS drafts_idx_publish_at ON drafts(publish_at);
@@ -31,3 +31,123 @@ for (var i = 0; i < navLinks.length; i++) {
             }
         });
     });
+
+
+    // csrfFetch - обёртка над fetch, добавляющая CSRF-токен в заголовок X-CSRF-Token
+    function csrfFetch(url, options = {}) {
+        const meta = document.querySelector('meta[name="csrf-token"]');
+        const headers = new Headers(options.headers || {});
+        if (meta) {
+            headers.set('X-CSRF-Token', meta.getAttribute('content'));
+        }
+        return fetch(url, Object.assign({}, options, { headers: headers, credentials: 'same-origin' }));
+    }
+
+
+    // Предпросмотр markdown: кнопка отправляет текст из поля data-source
+    // на /post/preview и показывает готовый HTML под полем
+    document.querySelectorAll('.markdown-preview-button').forEach(function(button) {
+        button.addEventListener('click', function() {
+            const form = button.closest('form');
+            const source = form.querySelector('[name="' + button.dataset.source + '"]');
+            const preview = form.querySelector('.markdown-preview');
+
+            const body = new URLSearchParams();
+            body.set('content', source.value);
+            csrfFetch('/post/preview', { method: 'POST', body: body })
+                .then(function(response) {
+                    return response.text().then(function(text) {
+                        if (!response.ok) {
+                            preview.textContent = text;
+                        } else {
+                            preview.innerHTML = text;
+                        }
+                        preview.hidden = false;
+                    });
+                })
+                .catch(function() {
+                    preview.textContent = 'Preview is not available right now';
+                    preview.hidden = false;
+                });
+        });
+    });
+
+
+    // Поле datetime-local с data-time показывает запланированное время в
+    // часовом поясе пользователя. Перед отправкой формы в timezone_offset
+    // записывается смещение этого пояса на выбранную дату.
+    document.querySelectorAll('input[type="datetime-local"][data-time]').forEach(function(input) {
+        const dateTime = new Date(input.dataset.time);
+        if (!input.dataset.time || isNaN(dateTime.getTime())) {
+            return;
+        }
+        const pad = function(n) { return String(n).padStart(2, '0'); };
+        input.value = dateTime.getFullYear() + '-' + pad(dateTime.getMonth() + 1) + '-' + pad(dateTime.getDate()) +
+            'T' + pad(dateTime.getHours()) + ':' + pad(dateTime.getMinutes());
+    });
+
+    document.querySelectorAll('input[name="timezone_offset"]').forEach(function(offset) {
+        offset.form.addEventListener('submit', function() {
+            const publishAt = offset.form.querySelector('[name="publish_at"]');
+            let dateTime = new Date(publishAt ? publishAt.value : '');
+            if (isNaN(dateTime.getTime())) {
+                dateTime = new Date();
+            }
+            offset.value = dateTime.getTimezoneOffset();
+        });
+    });
+
+
+    // Автосохранение черновика: через несколько секунд после правки текст
+    // уходит на /draft/autosave, а id черновика запоминается в draft_id,
+    // чтобы следующие сохранения обновляли тот же черновик
+    document.querySelectorAll('form[data-autosave]').forEach(function(form) {
+        const status = form.querySelector('.autosave-status');
+        const draftID = form.querySelector('[name="draft_id"]');
+        let timer = null;
+        // сохранения идут по очереди, иначе первые два создали бы два черновика
+        let queue = Promise.resolve();
+
+        function save() {
+            const title = form.querySelector('[name="title"]').value;
+            const content = form.querySelector('[name="content"]').value;
+            if (!title.trim() && !content.trim()) {
+                return Promise.resolve();
+            }
+
+            const body = new URLSearchParams();
+            body.set('draft_id', draftID.value);
+            body.set('title', title);
+            body.set('content', content);
+            form.querySelectorAll('[name="categories"]:checked').forEach(function(category) {
+                body.append('categories', category.value);
+            });
+
+            return csrfFetch('/draft/autosave', { method: 'POST', body: body })
+                .then(function(response) {
+                    if (!response.ok) {
+                        return response.text().then(function(text) {
+                            status.textContent = text;
+                        });
+                    }
+                    return response.json().then(function(result) {
+                        draftID.value = result.id;
+                        const saved = new Date(result.saved);
+                        status.textContent = 'Draft saved at ' + saved.toLocaleTimeString(undefined, { hour: '2-digit', minute: '2-digit', hour12: false });
+                    });
+                })
+                .catch(function() {
+                    status.textContent = 'The draft is not saved, check your connection';
+                });
+        }
+
+        form.addEventListener('input', function() {
+            clearTimeout(timer);
+            timer = setTimeout(function() {
+                queue = queue.then(save);
+            }, 3000);
+        });
+        form.addEventListener('submit', function() {
+            clearTimeout(timer);
+        });
+    });